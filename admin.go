@@ -0,0 +1,155 @@
+package cors
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// policyView is the effective configuration served by PolicyHandler
+type policyView struct {
+	AllowedOrigins       []string            `json:"allowedOrigins"`
+	DeniedOrigins        []string            `json:"deniedOrigins,omitempty"`
+	AllowAllOrigins      bool                `json:"allowAllOrigins"`
+	AllowOriginFunc      bool                `json:"allowOriginFunc"`
+	OriginProvider       bool                `json:"originProvider"`
+	AllowNullOrigin      bool                `json:"allowNullOrigin"`
+	AllowLocalhost       bool                `json:"allowLocalhost"`
+	AllowedMethods       []string            `json:"allowedMethods"`
+	AllowedHeaders       []string            `json:"allowedHeaders"`
+	MethodHeaders        map[string][]string `json:"methodHeaders,omitempty"`
+	ExposedHeaders       []string            `json:"exposedHeaders"`
+	AllowCredentials     bool                `json:"allowCredentials"`
+	AllowCredentialsFunc bool                `json:"allowCredentialsFunc"`
+	MaxAge               int                 `json:"maxAge"`
+	MaxAgeFunc           bool                `json:"maxAgeFunc"`
+	AllowPrivateNetwork  bool                `json:"allowPrivateNetwork"`
+	OptionsPassthrough   bool                `json:"optionsPassthrough"`
+	SkipPaths            []string            `json:"skipPaths,omitempty"`
+	Skip                 bool                `json:"skip"`
+	ReportOnly           bool                `json:"reportOnly"`
+	PolicyResolver       bool                `json:"policyResolver"`
+	Frozen               bool                `json:"frozen"`
+	Fingerprint          string              `json:"fingerprint"`
+}
+
+// view returns the effective configuration of the handler
+func (c *Cors) view() policyView {
+	p := c.current()
+	health := c.policyHealth()
+	v := policyView{
+		AllowedOrigins:       nonNil(p.allowedOriginsList),
+		AllowAllOrigins:      p.allowedOriginsAll,
+		AllowOriginFunc:      p.allowOriginFunc != nil,
+		OriginProvider:       p.originProvider != nil,
+		AllowNullOrigin:      p.allowNullOrigin,
+		AllowLocalhost:       p.allowLocalhost,
+		AllowedMethods:       nonNil(p.allowedMethods),
+		AllowedHeaders:       nonNil(p.allowedHeaders),
+		MethodHeaders:        p.methodHeaders,
+		ExposedHeaders:       nonNil(p.exposedHeaders),
+		AllowCredentials:     p.allowCredentials,
+		AllowCredentialsFunc: p.allowCredentialsFunc != nil,
+		MaxAge:               p.maxAge,
+		MaxAgeFunc:           p.maxAgeFunc != nil,
+		AllowPrivateNetwork:  p.allowPrivateNetwork,
+		OptionsPassthrough:   p.optionPassthrough,
+		SkipPaths:            p.skipPaths,
+		Skip:                 p.skip != nil,
+		ReportOnly:           p.reportOnly,
+		PolicyResolver:       p.resolver != nil,
+		Frozen:               health.Frozen,
+		Fingerprint:          health.Fingerprint,
+	}
+	if p.allowedHeadersAll {
+		v.AllowedHeaders = []string{"*"}
+	}
+	if p.deniedOrigins != nil {
+		for _, o := range p.deniedOrigins.Patterns() {
+			v.DeniedOrigins = append(v.DeniedOrigins, o.String())
+		}
+	}
+	return v
+}
+
+// nonNil returns s, or an empty slice if nil so that it is encoded as []
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// PolicyHandler returns a handler serving the configuration in effect as JSON
+// (origins, methods, headers, credentials, max age...), to be mounted under an
+// internal admin route so that operators can see the policy a running instance
+// enforces. Functions and providers are only reported by their presence. It
+// discloses the policy and must not be exposed publicly.
+func (c *Cors) PolicyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		serveJSON(w, c.view(), "no-store")
+	})
+}
+
+// WellKnownPath is the conventional path under which to mount WellKnownHandler
+const WellKnownPath = "/.well-known/cors-policy"
+
+// wellKnownDocument is the public policy document served by WellKnownHandler
+type wellKnownDocument struct {
+	AllowedOrigins   []string `json:"allowedOrigins"`
+	AllowedMethods   []string `json:"allowedMethods"`
+	AllowedHeaders   []string `json:"allowedHeaders"`
+	ExposedHeaders   []string `json:"exposedHeaders"`
+	AllowCredentials bool     `json:"allowCredentials"`
+	MaxAge           int      `json:"maxAge"`
+	Dynamic          bool     `json:"dynamic"`
+}
+
+// WellKnownHandler returns a handler serving a public, machine-readable document
+// of the policy, to be mounted at WellKnownPath so that frontends can verify, e.g.
+// at deploy time, that their origin and the headers they send are allowed. Unlike
+// PolicyHandler it only discloses what browsers learn from preflights anyway:
+// allowed origins ("*" when all are), methods, headers, exposed headers,
+// credentials and max age. Dynamic is set when the policy is also decided per
+// request, by AllowOriginFunc, an OriginProvider, AllowCredentialsFunc or a
+// PolicyResolver, in which case the document is not exhaustive.
+func (c *Cors) WellKnownHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		v := c.view()
+		doc := wellKnownDocument{
+			AllowedOrigins:   v.AllowedOrigins,
+			AllowedMethods:   v.AllowedMethods,
+			AllowedHeaders:   v.AllowedHeaders,
+			ExposedHeaders:   v.ExposedHeaders,
+			AllowCredentials: v.AllowCredentials,
+			MaxAge:           v.MaxAge,
+			Dynamic:          v.AllowOriginFunc || v.OriginProvider || v.PolicyResolver || v.AllowCredentialsFunc,
+		}
+		if v.AllowAllOrigins {
+			doc.AllowedOrigins = []string{"*"}
+		}
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		serveJSON(w, doc, "public, max-age=300")
+	})
+}
+
+// serveJSON writes v as indented JSON with the given Cache-Control
+func serveJSON(w http.ResponseWriter, v interface{}, cacheControl string) {
+	body, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Write(append(body, '\n'))
+}
@@ -0,0 +1,111 @@
+package cors
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestPolicyHandler(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins:   []string{"https://app.com", "https://*.app.com"},
+		DeniedOrigins:    []string{"https://legacy.app.com"},
+		AllowedMethods:   []string{"GET", "PUT"},
+		AllowedHeaders:   []string{"X-Header-1"},
+		AllowCredentials: true,
+		MaxAge:           600,
+	})
+	serve := func(method string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "http://example.com/admin/cors", nil)
+		res := httptest.NewRecorder()
+		s.PolicyHandler().ServeHTTP(res, req)
+		return res
+	}
+
+	res := serve("GET")
+	assertResponse(t, res, http.StatusOK)
+	if ct := res.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(res.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON %q: %v", res.Body.String(), err)
+	}
+	want := map[string]interface{}{
+		"allowedOrigins":   []interface{}{"https://app.com", "https://*.app.com"},
+		"deniedOrigins":    []interface{}{"https://legacy.app.com"},
+		"allowedMethods":   []interface{}{"GET", "PUT"},
+		"allowedHeaders":   []interface{}{"Origin", "X-Header-1"},
+		"exposedHeaders":   []interface{}{},
+		"allowCredentials": true,
+		"maxAge":           float64(600),
+		"allowAllOrigins":  false,
+		"frozen":           false,
+	}
+	for key, value := range want {
+		if !reflect.DeepEqual(got[key], value) {
+			t.Errorf("%s = %#v, want %#v", key, got[key], value)
+		}
+	}
+	if got["fingerprint"] == "" {
+		t.Error("fingerprint missing")
+	}
+
+	s.UpdateOptions(Options{AllowedHeaders: []string{"*"}})
+	json.Unmarshal(serve("GET").Body.Bytes(), &got)
+	if got["allowAllOrigins"] != true || !reflect.DeepEqual(got["allowedHeaders"], []interface{}{"*"}) {
+		t.Errorf("policy not updated: %v", got)
+	}
+
+	res = serve("POST")
+	assertResponse(t, res, http.StatusMethodNotAllowed)
+}
+
+func TestWellKnownHandler(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins:   []string{"https://app.com"},
+		DeniedOrigins:    []string{"https://legacy.app.com"},
+		AllowedHeaders:   []string{"X-Header-1"},
+		ExposedHeaders:   []string{"X-Request-Id"},
+		AllowCredentials: true,
+	})
+	serve := func(method string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "http://example.com"+WellKnownPath, nil)
+		res := httptest.NewRecorder()
+		s.WellKnownHandler().ServeHTTP(res, req)
+		return res
+	}
+
+	res := serve("GET")
+	assertResponse(t, res, http.StatusOK)
+	assertHeaders(t, res.Header(), map[string]string{
+		"Access-Control-Allow-Origin": "*",
+		"Content-Type":                "application/json",
+	})
+	var got map[string]interface{}
+	if err := json.Unmarshal(res.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON %q: %v", res.Body.String(), err)
+	}
+	want := map[string]interface{}{
+		"allowedOrigins":   []interface{}{"https://app.com"},
+		"allowedMethods":   []interface{}{"GET", "POST", "HEAD"},
+		"allowedHeaders":   []interface{}{"Origin", "X-Header-1"},
+		"exposedHeaders":   []interface{}{"X-Request-Id"},
+		"allowCredentials": true,
+		"maxAge":           float64(0),
+		"dynamic":          false,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("document = %#v, want %#v", got, want)
+	}
+
+	s.UpdateOptions(Options{AllowOriginFunc: func(r *http.Request, origin string) bool { return true }})
+	json.Unmarshal(serve("GET").Body.Bytes(), &got)
+	if got["dynamic"] != true {
+		t.Errorf("dynamic = %v, want true", got["dynamic"])
+	}
+
+	assertResponse(t, serve("DELETE"), http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,133 @@
+// Package analyzer provides a go vet style analyzer reporting insecure or invalid
+// cors.Options literals: the "*" origin or header combined with credentials,
+// wildcard origins covering a whole public suffix and malformed origins, such as
+// origins with a path.
+//
+// It can be run in CI with the corsvet command:
+//
+//	go install github.com/go-chi/cors/analyzer/cmd/corsvet@latest
+//	go vet -vettool=$(which corsvet) ./...
+//
+// Only constant values are checked: options built at run time should be checked
+// with Options.Validate.
+package analyzer
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"strings"
+
+	"github.com/go-chi/cors"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// Analyzer reports insecure or invalid cors.Options literals
+var Analyzer = &analysis.Analyzer{
+	Name:     "cors",
+	Doc:      "report insecure or invalid cors.Options literals",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+const optionsPkg = "github.com/go-chi/cors"
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	inspect.Preorder([]ast.Node{(*ast.CompositeLit)(nil)}, func(n ast.Node) {
+		lit := n.(*ast.CompositeLit)
+		if !isOptions(pass.TypesInfo.TypeOf(lit)) {
+			return
+		}
+		checkOptions(pass, fields(lit))
+	})
+	return nil, nil
+}
+
+// isOptions reports whether t is cors.Options
+func isOptions(t types.Type) bool {
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Name() == "Options" && obj.Pkg() != nil && obj.Pkg().Path() == optionsPkg
+}
+
+// fields returns the values of the keyed fields of an Options literal
+func fields(lit *ast.CompositeLit) map[string]ast.Expr {
+	values := make(map[string]ast.Expr)
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		if key, ok := kv.Key.(*ast.Ident); ok {
+			values[key.Name] = kv.Value
+		}
+	}
+	return values
+}
+
+func checkOptions(pass *analysis.Pass, values map[string]ast.Expr) {
+	credentials := isTrue(pass, values["AllowCredentials"]) || isSet(pass, values["AllowCredentialsFunc"])
+	broad := isTrue(pass, values["AllowBroadWildcards"])
+
+	for _, elt := range stringElements(pass, values["AllowedOrigins"]) {
+		origin := elt.value
+		if origin == "*" {
+			if credentials {
+				pass.ReportRangef(elt.pos, `allowed origin "*" cannot be combined with credentials, list the trusted origins instead`)
+			}
+			continue
+		}
+		options := cors.Options{AllowedOrigins: []string{origin}, AllowBroadWildcards: broad}
+		if err := options.Validate(); err != nil {
+			pass.ReportRangef(elt.pos, "%s", strings.TrimPrefix(err.Error(), "cors: "))
+		}
+	}
+	if credentials {
+		for _, elt := range stringElements(pass, values["AllowedHeaders"]) {
+			if elt.value == "*" {
+				pass.ReportRangef(elt.pos, `allowed header "*" with credentials lets any header through, list the expected headers instead`)
+			}
+		}
+	}
+}
+
+// isTrue reports whether expr is the constant true
+func isTrue(pass *analysis.Pass, expr ast.Expr) bool {
+	if expr == nil {
+		return false
+	}
+	v := pass.TypesInfo.Types[expr].Value
+	return v != nil && v.Kind() == constant.Bool && constant.BoolVal(v)
+}
+
+// isSet reports whether expr is anything but nil
+func isSet(pass *analysis.Pass, expr ast.Expr) bool {
+	return expr != nil && !pass.TypesInfo.Types[expr].IsNil()
+}
+
+type stringElement struct {
+	value string
+	pos   ast.Node
+}
+
+// stringElements returns the constant strings of a []string literal
+func stringElements(pass *analysis.Pass, expr ast.Expr) []stringElement {
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return nil
+	}
+	var elements []stringElement
+	for _, elt := range lit.Elts {
+		v := pass.TypesInfo.Types[elt].Value
+		if v != nil && v.Kind() == constant.String {
+			elements = append(elements, stringElement{constant.StringVal(v), elt})
+		}
+	}
+	return elements
+}
@@ -0,0 +1,12 @@
+// Command corsvet reports insecure or invalid cors.Options literals, standalone
+// or through go vet -vettool=$(which corsvet).
+package main
+
+import (
+	"github.com/go-chi/cors/analyzer"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(analyzer.Analyzer)
+}
@@ -0,0 +1,15 @@
+module github.com/go-chi/cors/analyzer
+
+go 1.26.0
+
+require (
+	github.com/go-chi/cors v1.2.2-0.20261015144340-21220bdce7df
+	golang.org/x/tools v0.50.0
+)
+
+require (
+	golang.org/x/mod v0.41.0 // indirect
+	golang.org/x/sync v0.23.0 // indirect
+)
+
+replace github.com/go-chi/cors => ../
@@ -0,0 +1,48 @@
+package a
+
+import (
+	"net/http"
+
+	"github.com/go-chi/cors"
+)
+
+var credentials = true
+
+func policies() {
+	cors.New(cors.Options{
+		AllowedOrigins:   []string{"*"}, // want `allowed origin "\*" cannot be combined with credentials`
+		AllowCredentials: true,
+	})
+	cors.New(cors.Options{
+		AllowedOrigins: []string{"*"}, // want `allowed origin "\*" cannot be combined with credentials`
+		AllowCredentialsFunc: func(r *http.Request, origin string) bool {
+			return true
+		},
+	})
+	_ = &cors.Options{
+		AllowedOrigins:   []string{"https://example.com"},
+		AllowedHeaders:   []string{"*"}, // want `allowed header "\*" with credentials lets any header through`
+		AllowCredentials: true,
+	}
+	_ = cors.Options{
+		AllowedOrigins: []string{
+			"https://*.com",           // want `allowed origin "https://\*.com" matches a whole public suffix`
+			"https://example.com/app", // want `invalid allowed origin "https://example.com/app": origins cannot contain a path`
+			"https://*.github.io",     // want `allowed origin "https://\*.github.io" matches a whole public suffix`
+			"https://app.example.com",
+		},
+	}
+	_ = cors.Options{
+		AllowedOrigins:      []string{"https://*.github.io"},
+		AllowBroadWildcards: true,
+	}
+	_ = cors.Options{
+		AllowedOrigins:   []string{"*"},
+		AllowCredentials: false,
+	}
+	_ = cors.Options{
+		// only constant values are checked
+		AllowedOrigins:   []string{"*"},
+		AllowCredentials: credentials,
+	}
+}
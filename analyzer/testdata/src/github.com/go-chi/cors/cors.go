@@ -0,0 +1,15 @@
+package cors
+
+import "net/http"
+
+type Options struct {
+	AllowedOrigins       []string
+	AllowedHeaders       []string
+	AllowCredentials     bool
+	AllowCredentialsFunc func(r *http.Request, origin string) bool
+	AllowBroadWildcards  bool
+}
+
+type Cors struct{}
+
+func New(options Options) *Cors { return nil }
@@ -0,0 +1,48 @@
+package cors
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// auditRecord is the JSON line written to Options.AuditWriter for a denial
+type auditRecord struct {
+	Time      time.Time `json:"time"`
+	Origin    string    `json:"origin"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Preflight bool      `json:"preflight"`
+	Reason    string    `json:"reason"`
+	Error     string    `json:"error,omitempty"`
+	Policy    string    `json:"policy,omitempty"`
+}
+
+// audit records the denial d of the request r in the audit sinks, if any
+func (p *policy) audit(r *http.Request, d Decision) {
+	if p.auditFunc != nil {
+		p.auditFunc(d, r)
+	}
+	if p.auditWriter == nil {
+		return
+	}
+	record := auditRecord{
+		Time:      time.Now().UTC(),
+		Origin:    d.Origin,
+		Method:    d.Method,
+		Path:      r.URL.Path,
+		Preflight: d.Preflight,
+		Reason:    d.Reason,
+		Policy:    p.name,
+	}
+	if d.Err != nil {
+		record.Error = d.Err.Error()
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	p.c.auditMu.Lock()
+	defer p.c.auditMu.Unlock()
+	p.auditWriter.Write(append(line, '\n'))
+}
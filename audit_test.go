@@ -0,0 +1,58 @@
+package cors
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAudit(t *testing.T) {
+	var buf bytes.Buffer
+	var audited []Decision
+	s := New(Options{
+		Name:           "api",
+		AllowedOrigins: []string{"http://foo.com"},
+		AuditWriter:    &buf,
+		AuditFunc:      func(d Decision, r *http.Request) { audited = append(audited, d) },
+	})
+	for _, origin := range []string{"http://foo.com", "http://bar.com", ""} {
+		req, _ := http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		req.Header.Set("Access-Control-Request-Method", "DELETE")
+		s.Handler(testHandler).ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || len(audited) != 2 {
+		t.Fatalf("audit lines = %q, %d decisions audited, want 2", lines, len(audited))
+	}
+	var record auditRecord
+	if err := json.Unmarshal([]byte(lines[1]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(record.Time) > time.Minute {
+		t.Errorf("time = %v", record.Time)
+	}
+	record.Time = time.Time{}
+	want := auditRecord{
+		Origin:    "http://bar.com",
+		Method:    "DELETE",
+		Path:      "/foo",
+		Preflight: true,
+		Reason:    ReasonOrigin,
+		Error:     "origin 'http://bar.com' not allowed",
+		Policy:    "api",
+	}
+	if record != want {
+		t.Errorf("record = %+v, want %+v", record, want)
+	}
+	if audited[0].Reason != ReasonMethod || audited[1].Reason != ReasonOrigin {
+		t.Errorf("audited = %+v", audited)
+	}
+}
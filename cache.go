@@ -0,0 +1,111 @@
+package cors
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// CacheStats holds the counters of a cache
+type CacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// HitRate returns the fraction of lookups served from the cache
+func (s CacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// lruCache is a bounded, concurrency safe, least recently used cache with an
+// optional time to live for its entries.
+type lruCache struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	ll    *list.List
+	items map[string]*list.Element
+
+	// now is overridden in tests
+	now func() time.Time
+}
+
+type lruEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+// newLRUCache creates a cache holding at most size entries. A zero ttl means
+// entries never expire.
+func newLRUCache(size int, ttl time.Duration) *lruCache {
+	return &lruCache{
+		size:  size,
+		ttl:   ttl,
+		ll:    list.New(),
+		items: make(map[string]*list.Element, size),
+		now:   time.Now,
+	}
+}
+
+// get returns the value stored for key if present and not expired
+func (c *lruCache) get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*lruEntry)
+	if c.ttl > 0 && c.now().After(entry.expires) {
+		c.removeElement(e)
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return entry.value, true
+}
+
+// add stores value for key, evicting the least recently used entry when full
+func (c *lruCache) add(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = c.now().Add(c.ttl)
+	}
+	if e, ok := c.items[key]; ok {
+		c.ll.MoveToFront(e)
+		entry := e.Value.(*lruEntry)
+		entry.value = value
+		entry.expires = expires
+		return
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry{key, value, expires})
+	if c.ll.Len() > c.size {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+// remove deletes key from the cache
+func (c *lruCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.removeElement(e)
+	}
+}
+
+// len returns the number of entries in the cache, including expired ones
+func (c *lruCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *lruCache) removeElement(e *list.Element) {
+	c.ll.Remove(e)
+	delete(c.items, e.Value.(*lruEntry).key)
+}
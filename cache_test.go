@@ -0,0 +1,56 @@
+package cors
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUCacheEviction(t *testing.T) {
+	c := newLRUCache(2, 0)
+	c.add("a", 1)
+	c.add("b", 2)
+	c.get("a")
+	c.add("c", 3)
+	if _, ok := c.get("b"); ok {
+		t.Error("b should have been evicted as least recently used")
+	}
+	if v, ok := c.get("a"); !ok || v.(int) != 1 {
+		t.Errorf("get(a) = %v, %v, want 1, true", v, ok)
+	}
+	if v, ok := c.get("c"); !ok || v.(int) != 3 {
+		t.Errorf("get(c) = %v, %v, want 3, true", v, ok)
+	}
+	if c.len() != 2 {
+		t.Errorf("len() = %d, want 2", c.len())
+	}
+	c.remove("a")
+	if _, ok := c.get("a"); ok {
+		t.Error("a should have been removed")
+	}
+}
+
+func TestLRUCacheTTL(t *testing.T) {
+	now := time.Now()
+	c := newLRUCache(10, time.Minute)
+	c.now = func() time.Time { return now }
+	c.add("a", 1)
+	if _, ok := c.get("a"); !ok {
+		t.Error("a should not have expired yet")
+	}
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.get("a"); ok {
+		t.Error("a should have expired")
+	}
+	if c.len() != 0 {
+		t.Errorf("expired entry should have been removed, len() = %d", c.len())
+	}
+}
+
+func TestCacheStatsHitRate(t *testing.T) {
+	if r := (CacheStats{}).HitRate(); r != 0 {
+		t.Errorf("HitRate() = %v, want 0", r)
+	}
+	if r := (CacheStats{Hits: 3, Misses: 1}).HitRate(); r != 0.75 {
+		t.Errorf("HitRate() = %v, want 0.75", r)
+	}
+}
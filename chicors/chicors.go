@@ -0,0 +1,40 @@
+// Package chicors keeps the allowed methods of a CORS policy in sync with a chi
+// router: of the AllowedMethods, only those registered for the requested path
+// are allowed, for both preflights and actual requests, or all of them with the
+// AllowRoutedMethods option. It lives in its own module so that
+// github.com/go-chi/cors doesn't depend on chi.
+//
+//	r := chi.NewRouter()
+//	r.Use(chicors.Handler(cors.New(options), r))
+//	r.Get("/items/{id}", getItem)
+//	r.Put("/items/{id}", putItem)
+package chicors
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/cors"
+)
+
+// Handler returns a middleware applying the policy of c with the methods routes
+// registers for the requested path, see cors.RouteHandler. routes is usually
+// the router the middleware is mounted on.
+func Handler(c *cors.Cors, routes chi.Routes) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return c.RouteHandler(next, func(r *http.Request, method string) bool {
+			return routes.Match(chi.NewRouteContext(), method, routePath(r))
+		})
+	}
+}
+
+// routePath returns the path chi routes r on
+func routePath(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePath != "" {
+		return rctx.RoutePath
+	}
+	if r.URL.RawPath != "" {
+		return r.URL.RawPath
+	}
+	return r.URL.Path
+}
@@ -0,0 +1,59 @@
+package chicors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/cors"
+)
+
+func newRouter() http.Handler {
+	r := chi.NewRouter()
+	r.Use(Handler(cors.New(cors.Options{
+		AllowedOrigins:            []string{"http://foo.com"},
+		AllowRoutedMethods:        true,
+		PreflightMethodNotAllowed: true,
+	}), r))
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	r.Get("/items/{id}", ok)
+	r.Put("/items/{id}", ok)
+	r.Route("/admin", func(r chi.Router) {
+		r.Delete("/cache", ok)
+	})
+	return r
+}
+
+func TestHandler(t *testing.T) {
+	h := newRouter()
+	cases := []struct {
+		method, path, requested string
+		status                  int
+		allowOrigin, allowed    string
+	}{
+		{"OPTIONS", "/items/1", "PUT", http.StatusOK, "http://foo.com", "GET, PUT"},
+		{"OPTIONS", "/admin/cache", "DELETE", http.StatusOK, "http://foo.com", "DELETE"},
+		{"OPTIONS", "/items/1", "DELETE", http.StatusMethodNotAllowed, "", ""},
+		{"PUT", "/items/1", "", http.StatusOK, "http://foo.com", ""},
+		{"POST", "/items/1", "", http.StatusMethodNotAllowed, "", ""},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, "http://example.com"+tc.path, nil)
+		req.Header.Set("Origin", "http://foo.com")
+		if tc.requested != "" {
+			req.Header.Set("Access-Control-Request-Method", tc.requested)
+		}
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		if res.Code != tc.status {
+			t.Errorf("%s %s %s: status = %d, want %d", tc.method, tc.path, tc.requested, res.Code, tc.status)
+		}
+		if got := res.Header().Get("Access-Control-Allow-Origin"); got != tc.allowOrigin {
+			t.Errorf("%s %s %s: Access-Control-Allow-Origin = %q, want %q", tc.method, tc.path, tc.requested, got, tc.allowOrigin)
+		}
+		if got := res.Header().Get("Access-Control-Allow-Methods"); got != tc.allowed {
+			t.Errorf("%s %s %s: Access-Control-Allow-Methods = %q, want %q", tc.method, tc.path, tc.requested, got, tc.allowed)
+		}
+	}
+}
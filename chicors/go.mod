@@ -0,0 +1,10 @@
+module github.com/go-chi/cors/chicors
+
+go 1.25.0
+
+require (
+	github.com/go-chi/chi/v5 v5.3.2
+	github.com/go-chi/cors v1.2.2-0.20261015144340-21220bdce7df
+)
+
+replace github.com/go-chi/cors => ../
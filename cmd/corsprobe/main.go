@@ -0,0 +1,132 @@
+// Command corsprobe tells whether a browser would let a page from an origin read
+// the response of a live server, by performing the preflight and actual requests
+// a browser would and checking them the same way.
+//
+// Usage:
+//
+//	corsprobe -origin https://app.example.com [-method PUT] [-H 'Authorization: Bearer x'] [-credentials] [-json] URL
+//
+// It prints the requests, the CORS headers of the responses and the verdict, or
+// the whole result as JSON with -json. The exit status is 0 if the request is
+// allowed, 1 if a browser would block it and 2 if the server can't be reached.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/cors/corstest"
+)
+
+// headerFlags collects repeated -H flags
+type headerFlags http.Header
+
+func (h headerFlags) String() string {
+	return fmt.Sprint(http.Header(h))
+}
+
+func (h headerFlags) Set(value string) error {
+	i := strings.IndexByte(value, ':')
+	if i <= 0 {
+		return fmt.Errorf("header %q is not in the 'Name: value' form", value)
+	}
+	http.Header(h).Add(strings.TrimSpace(value[:i]), strings.TrimSpace(value[i+1:]))
+	return nil
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("corsprobe", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	origin := flags.String("origin", "", "origin of the page sending the request (required)")
+	method := flags.String("method", http.MethodGet, "method of the request")
+	credentials := flags.Bool("credentials", false, "send the request with credentials, as fetch(url, {credentials: 'include'})")
+	asJSON := flags.Bool("json", false, "print the result as JSON")
+	timeout := flags.Duration("timeout", 10*time.Second, "timeout of each request")
+	headers := headerFlags{}
+	flags.Var(headers, "H", "request header as 'Name: value', repeatable")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: corsprobe -origin ORIGIN [-method METHOD] [-H 'Name: value'...] [-credentials] [-json] URL")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *origin == "" || flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	p := corstest.Prober{Client: &http.Client{Timeout: *timeout}, Credentials: *credentials}
+	result, err := p.Probe(flags.Arg(0), *origin, *method, http.Header(headers))
+	if err != nil {
+		fmt.Fprintln(stderr, "corsprobe:", err)
+		return 2
+	}
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(result)
+	} else {
+		printResult(stdout, result)
+	}
+	if !result.Allowed {
+		return 1
+	}
+	return 0
+}
+
+func printResult(w io.Writer, result corstest.Result) {
+	if result.Preflight != nil {
+		fmt.Fprintln(w, "Preflight:")
+		printExchange(w, result.Preflight)
+	} else {
+		fmt.Fprintln(w, "Preflight: not needed, simple request")
+	}
+	if result.Actual != nil {
+		fmt.Fprintln(w, "Actual request:")
+		printExchange(w, result.Actual)
+	} else {
+		fmt.Fprintln(w, "Actual request: not sent by browsers as the preflight failed")
+	}
+	if result.Allowed {
+		fmt.Fprintln(w, "Verdict: ALLOWED, a browser would let the page read the response")
+	} else {
+		fmt.Fprintf(w, "Verdict: BLOCKED, %s\n", result.Reason)
+	}
+}
+
+// printExchange prints the request headers and the CORS related response headers
+func printExchange(w io.Writer, ex *corstest.Exchange) {
+	fmt.Fprintf(w, "  > %s\n", ex.Method)
+	printHeaders(w, "  > ", ex.Header, nil)
+	fmt.Fprintf(w, "  < %d %s\n", ex.Status, http.StatusText(ex.Status))
+	printHeaders(w, "  < ", ex.ResponseHeader, func(name string) bool {
+		return strings.HasPrefix(name, "Access-Control-") || name == "Vary"
+	})
+}
+
+func printHeaders(w io.Writer, prefix string, h http.Header, keep func(string) bool) {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		if keep == nil || keep(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range h[name] {
+			fmt.Fprintf(w, "%s%s: %s\n", prefix, name, value)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/cors"
+	"github.com/go-chi/cors/corstest"
+)
+
+func TestRun(t *testing.T) {
+	ts := httptest.NewServer(cors.New(cors.Options{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedMethods: []string{"GET", "PUT"},
+		AllowedHeaders: []string{"Authorization"},
+	}).Handler(http.NotFoundHandler()))
+	defer ts.Close()
+
+	var stdout, stderr bytes.Buffer
+	status := run([]string{"-origin", "https://app.example.com", "-method", "PUT", "-H", "Authorization: Bearer x", ts.URL}, &stdout, &stderr)
+	if status != 0 {
+		t.Fatalf("status = %d, output:\n%s%s", status, stdout.String(), stderr.String())
+	}
+	for _, want := range []string{
+		"  > Access-Control-Request-Method: PUT",
+		"  < Access-Control-Allow-Origin: https://app.example.com",
+		"Verdict: ALLOWED",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, stdout.String())
+		}
+	}
+
+	stdout.Reset()
+	status = run([]string{"-json", "-origin", "https://evil.example.com", ts.URL}, &stdout, &stderr)
+	if status != 1 {
+		t.Errorf("status = %d, want 1", status)
+	}
+	var result corstest.Result
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		t.Fatalf("invalid JSON %q: %v", stdout.String(), err)
+	}
+	if result.Allowed || result.Reason == "" || result.Actual == nil {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	for _, args := range [][]string{
+		{"http://localhost"},
+		{"-origin", "https://app.example.com"},
+		{"-origin", "https://app.example.com", "-H", "invalid", "http://localhost"},
+	} {
+		if status := run(args, &stdout, &stderr); status != 2 {
+			t.Errorf("%q: status = %d, want 2", args, status)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package cors
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+)
+
+// contextKey is the type of the request context keys of the package
+type contextKey int
+
+const (
+	// stateKey marks requests which went through a CORS handler, its value being
+	// the *requestState of the outermost one
+	stateKey contextKey = iota
+
+	// routeMethodsKey holds the methods routed for the path of a request, see
+	// RouteHandler
+	routeMethodsKey
+)
+
+// requestState is what a CORS handler records about a request in its context
+type requestState struct {
+	decision  Decision
+	evaluated bool
+
+	// Set once a CORS handler took the request, the state being created ahead of
+	// it by WithDecision otherwise
+	handled bool
+
+	// Request headers the origin decision depends on, see AllowOriginVaryFunc
+	vary []string
+
+	// Set when the request was not drawn by LogSampleRate
+	unlogged bool
+
+	// Policy of the handler when the request came in, before resolving
+	policy *policy
+}
+
+func (s *requestState) setDecision(d Decision) {
+	s.decision = d
+	s.evaluated = true
+}
+
+// markHandled returns ctx marked as handled along with the state recording the
+// decision, or a nil state if an outer CORS handler already marked it
+func markHandled(ctx context.Context) (context.Context, *requestState) {
+	if state, ok := ctx.Value(stateKey).(*requestState); ok {
+		if state.handled {
+			return ctx, nil
+		}
+		state.handled = true
+		return ctx, state
+	}
+	state := &requestState{handled: true}
+	return context.WithValue(ctx, stateKey, state), state
+}
+
+// WithDecision returns a copy of the context of a request in which the CORS
+// handler serving it records its decision, so that middlewares wrapping the
+// handler can call FromContext on it once the handler returned, preflights and
+// denied requests included:
+//
+//	ctx := cors.WithDecision(r.Context())
+//	h.ServeHTTP(w, r.WithContext(ctx))
+//	d, ok := cors.FromContext(ctx)
+//
+// ctx is returned as is if a CORS handler already went through it.
+func WithDecision(ctx context.Context) context.Context {
+	if ctx.Value(stateKey) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, stateKey, &requestState{})
+}
+
+// FromContext returns the decision taken by the CORS handler a request went
+// through, so that next handlers can tell whether it was cross-origin (Origin is
+// not empty), whether it was a preflight and which pattern allowed it, e.g. to
+// rate limit per partner. The boolean is false if no CORS handler evaluated it.
+func FromContext(ctx context.Context) (Decision, bool) {
+	state, ok := ctx.Value(stateKey).(*requestState)
+	if !ok || !state.evaluated {
+		return Decision{}, false
+	}
+	return state.decision, true
+}
+
+// originVaryFunc adapts an AllowOriginVaryFunc to AllowOriginFunc, the headers
+// it returns being recorded in the state of the request
+func originVaryFunc(f func(r *http.Request, origin string) (bool, []string)) func(r *http.Request, origin string) bool {
+	return func(r *http.Request, origin string) bool {
+		allowed, vary := f(r, origin)
+		if r != nil {
+			if state, ok := r.Context().Value(stateKey).(*requestState); ok {
+				state.vary = append(state.vary, vary...)
+			}
+		}
+		return allowed
+	}
+}
+
+// requestVary returns the request headers recorded by AllowOriginVaryFunc
+func requestVary(r *http.Request) []string {
+	if state, ok := r.Context().Value(stateKey).(*requestState); ok {
+		return state.vary
+	}
+	return nil
+}
+
+// requestPolicy returns the policy c applies to r, the one loaded when r went
+// through c, so that handlers behind it see the same options
+func (c *Cors) requestPolicy(r *http.Request) *policy {
+	if state, ok := r.Context().Value(stateKey).(*requestState); ok && state.policy != nil && state.policy.c == c {
+		return state.policy
+	}
+	return c.current()
+}
+
+// routeMethods returns the methods routed for the path of r, or nil if unknown
+func routeMethods(r *http.Request) []string {
+	if r == nil {
+		return nil
+	}
+	methods, _ := r.Context().Value(routeMethodsKey).([]string)
+	return methods
+}
+
+// warnNested logs, once per instance, that c is nested in another CORS handler
+func (c *Cors) warnNested() {
+	if !atomic.CompareAndSwapUint32(&c.nestedWarned, 0, 1) {
+		return
+	}
+	msg := "nested in another CORS handler, the outer one takes precedence and this one is skipped"
+	c.logf("Warning: %s", msg)
+	if p := c.current(); p.logger != nil {
+		p.logger.Warn("cors: " + msg)
+	}
+}
@@ -0,0 +1,139 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestNestedHandler(t *testing.T) {
+	l := &recordingLevelLogger{}
+	outer := New(Options{AllowedOrigins: []string{"http://foo.com"}})
+	inner := New(Options{
+		AllowedOrigins: []string{"http://foo.com", "http://bar.com"},
+		ExposedHeaders: []string{"X-Inner"},
+		Logger:         l,
+	})
+	handler := outer.Handler(inner.Handler(testHandler))
+	for _, origin := range []string{"http://foo.com", "http://bar.com"} {
+		req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+		req.Header.Add("Origin", origin)
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		want := map[string]string{"Vary": "Origin"}
+		if origin == "http://foo.com" {
+			want["Access-Control-Allow-Origin"] = origin
+		}
+		assertHeaders(t, res.Header(), want)
+	}
+	want := []string{"WARN cors: nested in another CORS handler, the outer one takes precedence and this one is skipped []"}
+	if !reflect.DeepEqual(l.entries, want) {
+		t.Errorf("logged %q, want %q", l.entries, want)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	var got Decision
+	var found bool
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, found = FromContext(r.Context())
+	})
+	s := New(Options{AllowedOrigins: []string{"https://*.partner.com"}, OptionsPassthrough: true})
+	handler := s.Handler(next)
+
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "https://acme.partner.com")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if !found || !got.Allowed || got.Preflight || got.MatchedOrigin != "https://*.partner.com" || got.Origin != "https://acme.partner.com" {
+		t.Errorf("FromContext() = %+v, %v after an allowed request", got, found)
+	}
+
+	req, _ = http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "https://evil.com")
+	req.Header.Add("Access-Control-Request-Method", "GET")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if !found || got.Allowed || !got.Preflight || got.Reason != ReasonOrigin {
+		t.Errorf("FromContext() = %+v, %v after a denied preflight", got, found)
+	}
+
+	req, _ = http.NewRequest("GET", "http://example.com/foo", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if !found || got.Origin != "" {
+		t.Errorf("FromContext() = %+v, %v after a same-origin request", got, found)
+	}
+
+	if _, ok := FromContext(req.Context()); ok {
+		t.Error("FromContext() found a decision in a context no handler went through")
+	}
+}
+
+func TestWithDecision(t *testing.T) {
+	s := New(Options{AllowedOrigins: []string{"https://foo.com"}})
+	handler := s.Handler(testHandler)
+
+	req, _ := http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "https://evil.com")
+	req.Header.Add("Access-Control-Request-Method", "GET")
+	ctx := WithDecision(req.Context())
+	if _, ok := FromContext(ctx); ok {
+		t.Error("FromContext() found a decision before the handler ran")
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+	if d, ok := FromContext(ctx); !ok || d.Allowed || !d.Preflight || d.Reason != ReasonOrigin {
+		t.Errorf("FromContext() = %+v, %v after a denied preflight", d, ok)
+	}
+
+	// Handlers nested in another one are still skipped
+	var inner Decision
+	outer := New(Options{AllowedOrigins: []string{"https://evil.com"}}).Handler(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			ctx := WithDecision(r.Context())
+			handler.ServeHTTP(w, r.WithContext(ctx))
+			inner, _ = FromContext(ctx)
+		}))
+	req, _ = http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "https://evil.com")
+	res := httptest.NewRecorder()
+	outer.ServeHTTP(res, req)
+	if !inner.Allowed || res.Header().Get("Access-Control-Allow-Origin") != "https://evil.com" {
+		t.Errorf("nested handler applied: decision %+v, headers %v", inner, res.Header())
+	}
+}
+
+func TestAllowOriginVaryFunc(t *testing.T) {
+	s := New(Options{
+		AllowOriginVaryFunc: func(r *http.Request, origin string) (bool, []string) {
+			return r.Header.Get("Sec-Fetch-Site") == "same-site", []string{"Sec-Fetch-Site"}
+		},
+		OriginFuncCacheSize: 10,
+		PreflightCacheSize:  10,
+	})
+	for _, site := range []string{"same-site", "cross-site", "same-site"} {
+		req, _ := http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+		req.Header.Add("Origin", "http://foo.com")
+		req.Header.Add("Access-Control-Request-Method", "GET")
+		req.Header.Add("Sec-Fetch-Site", site)
+		res := httptest.NewRecorder()
+		s.Handler(testHandler).ServeHTTP(res, req)
+		allowed, methods := "", ""
+		if site == "same-site" {
+			allowed, methods = "http://foo.com", "GET"
+		}
+		assertHeaders(t, res.Header(), map[string]string{
+			"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers, Sec-Fetch-Site",
+			"Access-Control-Allow-Origin":  allowed,
+			"Access-Control-Allow-Methods": methods,
+		})
+
+		req, _ = http.NewRequest("GET", "http://example.com/foo", nil)
+		req.Header.Add("Origin", "http://foo.com")
+		req.Header.Add("Sec-Fetch-Site", site)
+		res = httptest.NewRecorder()
+		s.Handler(testHandler).ServeHTTP(res, req)
+		assertHeaders(t, res.Header(), map[string]string{
+			"Vary":                        "Origin, Sec-Fetch-Site",
+			"Access-Control-Allow-Origin": allowed,
+		})
+	}
+}
@@ -19,56 +19,499 @@
 package cors
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"log"
+	"math/rand"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/cors/engine"
+)
+
+// AllowedHeadersResponseMode defines how preflight responses list allowed headers
+type AllowedHeadersResponseMode int
+
+const (
+	// AllowedHeadersEcho lists the requested headers, once checked against
+	// AllowedHeaders
+	AllowedHeadersEcho AllowedHeadersResponseMode = iota
+
+	// AllowedHeadersStatic lists all of AllowedHeaders whatever was requested, so
+	// that responses are the same for all clients and can be cached by CDNs. When
+	// all headers are allowed "*" is sent, unless credentials are allowed as
+	// browsers then don't honor it and requested headers are echoed.
+	AllowedHeadersStatic
+)
+
+// ResourcePolicy defines the Cross-Origin-Resource-Policy header set on actual
+// responses. Responses carrying Access-Control-Allow-Origin get "cross-origin",
+// so that CORP agrees with CORS and the origins CORS allows can embed them from
+// documents requiring CORP (Cross-Origin-Embedder-Policy: require-corp); the
+// others get the policy's own value.
+type ResourcePolicy int
+
+const (
+	// ResourcePolicyNone leaves Cross-Origin-Resource-Policy to the next handlers
+	ResourcePolicyNone ResourcePolicy = iota
+
+	// ResourcePolicySameOrigin sends "same-origin" unless the origin is allowed
+	ResourcePolicySameOrigin
+
+	// ResourcePolicySameSite sends "same-site" unless the origin is allowed
+	ResourcePolicySameSite
+
+	// ResourcePolicyCrossOrigin always sends "cross-origin"
+	ResourcePolicyCrossOrigin
 )
 
 // Options is a configuration container to setup the CORS middleware.
+// Options can be loaded from and written to JSON and YAML configuration files,
+// except for the functions and interfaces they hold, see Options.MarshalJSON.
 type Options struct {
 	// AllowedOrigins is a list of origins a cross-domain request can be executed from.
 	// If the special "*" value is present in the list, all origins will be allowed.
 	// An origin may contain a wildcard (*) to replace 0 or more characters
 	// (i.e.: http://*.domain.com). Usage of wildcards implies a small performance penalty.
-	// Only one wildcard can be used per origin.
+	// Only one wildcard can be used per origin. A trailing ":*" matches any port, or
+	// no port at all (i.e.: http://localhost:*), and can be combined with a wildcard
+	// in the rest of the origin. Application schemes are supported as well, such as
+	// chrome-extension://* or capacitor://localhost. Configured and request origins are compared in
+	// canonical form: lower-cased, without default port (:80 for http, :443 for
+	// https) and with internationalized host names in punycode.
 	// Default value is ["*"]
-	AllowedOrigins []string
+	AllowedOrigins []string `json:"allowedOrigins,omitempty" yaml:"allowedOrigins,omitempty"`
+
+	// AllowedOriginsRegex is a list of regular expressions an origin is matched
+	// against, in addition to AllowedOrigins. Expressions are anchored at both ends
+	// and matched against the canonical origin (i.e.: https://pr-\d+\.example\.com).
+	// New panics if one of the expressions does not compile.
+	AllowedOriginsRegex []string `json:"allowedOriginsRegex,omitempty" yaml:"allowedOriginsRegex,omitempty"`
+
+	// DeniedOrigins is a list of origins which are never allowed, using the same
+	// syntax as AllowedOrigins. It is checked before any other origin option, so
+	// that https://legacy.example.com can be blocked while https://*.example.com
+	// is allowed.
+	DeniedOrigins []string `json:"deniedOrigins,omitempty" yaml:"deniedOrigins,omitempty"`
+
+	// AllowLocalhost allows http and https origins on localhost, 127.0.0.1 and [::1]
+	// with any port, in addition to the other origin options. It is meant for
+	// local development.
+	AllowLocalhost bool `json:"allowLocalhost,omitempty" yaml:"allowLocalhost,omitempty"`
+
+	// AllowBroadWildcards lets Validate accept wildcard origins covering a whole
+	// public suffix, like https://*.com or https://*.github.io, which are otherwise
+	// rejected as likely mistakes.
+	AllowBroadWildcards bool `json:"allowBroadWildcards,omitempty" yaml:"allowBroadWildcards,omitempty"`
+
+	// AllowNullOrigin allows requests from the opaque "null" origin sent by sandboxed
+	// iframes, file:// documents or after cross-origin redirects. Such requests get
+	// "Access-Control-Allow-Origin: null" and never Access-Control-Allow-Credentials,
+	// as any document can claim this origin. This option is the only way to allow
+	// the null origin: it is not matched by "*", AllowedOrigins entries,
+	// AllowedOriginsRegex, AllowOriginFunc or OriginProvider.
+	AllowNullOrigin bool `json:"allowNullOrigin,omitempty" yaml:"allowNullOrigin,omitempty"`
+
+	// OriginMatchMode defines which pattern of AllowedOrigins and AllowedOriginsRegex
+	// is reported as the rule allowing an origin when several of them match.
+	// Default value is MatchMostSpecific.
+	OriginMatchMode OriginMatchMode `json:"originMatchMode,omitempty" yaml:"originMatchMode,omitempty"`
 
 	// AllowOriginFunc is a custom function to validate the origin. It takes the origin
 	// as argument and returns true if allowed or false otherwise. If this option is
 	// set, the content of AllowedOrigins is ignored.
-	AllowOriginFunc func(r *http.Request, origin string) bool
+	AllowOriginFunc func(r *http.Request, origin string) bool `json:"-" yaml:"-"`
+
+	// AllowOriginVaryFunc is AllowOriginFunc for decisions depending on other
+	// request headers, such as Authorization or Sec-Fetch-Site: it also returns
+	// the names of these headers, which are added to the Vary header of the
+	// response so that caches don't serve it to other requests. It takes
+	// precedence over AllowOriginFunc, and its results are never cached.
+	AllowOriginVaryFunc func(r *http.Request, origin string) (bool, []string) `json:"-" yaml:"-"`
+
+	// PolicyResolver, if set, supplies the options applied to each request, the
+	// other options being used when it returns nil. Requests it fails to resolve
+	// get no CORS headers and are reported with the ReasonPolicy reason.
+	PolicyResolver PolicyResolver `json:"-" yaml:"-"`
+
+	// OriginFuncCacheSize is the maximum number of AllowOriginFunc results
+	// remembered by origin, so that expensive functions run once per origin and
+	// OriginFuncCacheTTL. The function must then only depend on the origin.
+	// Entries can be dropped early with InvalidateOrigin. Default value is 0 which
+	// disables the cache.
+	OriginFuncCacheSize int `json:"originFuncCacheSize,omitempty" yaml:"originFuncCacheSize,omitempty"`
+
+	// OriginFuncCacheTTL is how long an AllowOriginFunc result is remembered. Default
+	// value is 0 which keeps entries until they are evicted or invalidated.
+	OriginFuncCacheTTL time.Duration `json:"originFuncCacheTTL,omitempty" yaml:"originFuncCacheTTL,omitempty"`
+
+	// OriginProvider supplies additional allowed origins from a dynamic source, such
+	// as a database. Origins use the same syntax as AllowedOrigins and are reloaded
+	// once OriginProviderTTL expires; a single request triggers the reload while
+	// the others keep using the previous list.
+	OriginProvider OriginProvider `json:"-" yaml:"-"`
+
+	// OriginProviderTTL is how long origins returned by OriginProvider are used before
+	// being reloaded. Default value is one minute.
+	OriginProviderTTL time.Duration `json:"originProviderTTL,omitempty" yaml:"originProviderTTL,omitempty"`
 
 	// AllowedMethods is a list of methods the client is allowed to use with
 	// cross-domain requests. Default value is simple methods (HEAD, GET and POST).
-	AllowedMethods []string
+	AllowedMethods []string `json:"allowedMethods,omitempty" yaml:"allowedMethods,omitempty"`
+
+	// StrictMethodCheck stops allowing OPTIONS implicitly: non-preflight OPTIONS
+	// requests and preflights requesting OPTIONS are only allowed when OPTIONS is
+	// listed in AllowedMethods, as the Fetch standard expects.
+	StrictMethodCheck bool `json:"strictMethodCheck,omitempty" yaml:"strictMethodCheck,omitempty"`
+
+	// ImplyHeadForGet allows HEAD whenever GET is in AllowedMethods, in preflight
+	// and actual request checks, since a HEAD is a GET without a body.
+	ImplyHeadForGet bool `json:"implyHeadForGet,omitempty" yaml:"implyHeadForGet,omitempty"`
+
+	// AllowRoutedMethods lets RouteHandler and MuxHandler allow the standard
+	// methods (GET, HEAD, POST, PUT, PATCH, DELETE) routed for the requested path
+	// even when they are not in AllowedMethods. By default routed methods are
+	// only allowed if they are in AllowedMethods too.
+	AllowRoutedMethods bool `json:"allowRoutedMethods,omitempty" yaml:"allowRoutedMethods,omitempty"`
 
 	// AllowedHeaders is list of non simple headers the client is allowed to use with
 	// cross-domain requests.
 	// If the special "*" value is present in the list, all headers will be allowed.
 	// Default value is [] but "Origin" is always appended to the list.
-	AllowedHeaders []string
+	// Forbidden header names (Host, Cookie, Sec-*, Proxy-*, ... as defined by the
+	// Fetch standard) and HTTP/2 pseudo-headers are never echoed in
+	// Access-Control-Allow-Headers, even when all headers are allowed.
+	// The CORS-safelisted Accept, Accept-Language and Content-Language headers are
+	// always allowed and need not be listed.
+	AllowedHeaders []string `json:"allowedHeaders,omitempty" yaml:"allowedHeaders,omitempty"`
+
+	// MethodHeaderPolicy lists, per method, headers allowed only in requests using
+	// that method, in addition to AllowedHeaders, e.g. Content-Type for POST and PUT
+	// but X-Admin-Token for DELETE only. Methods are case-insensitive. It has no
+	// effect when AllowedHeaders allows all headers.
+	MethodHeaderPolicy map[string][]string `json:"methodHeaderPolicy,omitempty" yaml:"methodHeaderPolicy,omitempty"`
+
+	// StrictContentType denies actual requests whose Content-Type is not one a
+	// browser sends without a preflight (form data or plain text) unless
+	// Content-Type is an allowed header, catching clients that skip the preflight.
+	StrictContentType bool `json:"strictContentType,omitempty" yaml:"strictContentType,omitempty"`
+
+	// AllowedHeadersResponseMode selects what Access-Control-Allow-Headers lists in
+	// preflight responses. Default value is AllowedHeadersEcho.
+	AllowedHeadersResponseMode AllowedHeadersResponseMode `json:"allowedHeadersResponseMode,omitempty" yaml:"allowedHeadersResponseMode,omitempty"`
+
+	// CacheableResponses makes successful preflight responses identical for all the
+	// requests from an origin, whatever method and headers they ask for, so that
+	// CDNs can cache them keyed by Origin: Access-Control-Allow-Methods lists all
+	// AllowedMethods and AllowedHeadersResponseMode is forced to
+	// AllowedHeadersStatic. Access-Control-Allow-Origin is only "*" when all
+	// origins are allowed without credentials, it has to echo the origin otherwise.
+	CacheableResponses bool `json:"cacheableResponses,omitempty" yaml:"cacheableResponses,omitempty"`
+
+	// ReportOnly evaluates the policy and reports would-be denials to the logs,
+	// telemetry and OnDecision, but responds as if every request were allowed. It is
+	// meant to trial a stricter policy before enforcing it.
+	ReportOnly bool `json:"reportOnly,omitempty" yaml:"reportOnly,omitempty"`
+
+	// DenyForbiddenHeaders denies preflight requests listing forbidden header names
+	// or pseudo-headers instead of filtering them out of the response.
+	DenyForbiddenHeaders bool `json:"denyForbiddenHeaders,omitempty" yaml:"denyForbiddenHeaders,omitempty"`
 
 	// ExposedHeaders indicates which headers are safe to expose to the API of a CORS
 	// API specification
-	ExposedHeaders []string
+	ExposedHeaders []string `json:"exposedHeaders,omitempty" yaml:"exposedHeaders,omitempty"`
+
+	// EmitTimingAllowOrigin sets Timing-Allow-Origin to the value of
+	// Access-Control-Allow-Origin on actual responses, so that allowed origins also
+	// get the Resource Timing details of the responses.
+	EmitTimingAllowOrigin bool `json:"emitTimingAllowOrigin,omitempty" yaml:"emitTimingAllowOrigin,omitempty"`
+
+	// ResourcePolicy sets Cross-Origin-Resource-Policy on actual responses,
+	// including same-origin and no-cors ones, consistently with the origins
+	// allowed. Default value is ResourcePolicyNone.
+	ResourcePolicy ResourcePolicy `json:"resourcePolicy,omitempty" yaml:"resourcePolicy,omitempty"`
 
 	// AllowCredentials indicates whether the request can include user credentials like
 	// cookies, HTTP authentication or client side SSL certificates.
-	AllowCredentials bool
+	// Credentials are only granted to secure origins: https ones, http origins on
+	// the local host (localhost, *.localhost, 127.0.0.1 and [::1]) and browser
+	// extension or webview schemes (chrome-extension://, capacitor://...), unless
+	// AllowInsecureCredentials is set.
+	AllowCredentials bool `json:"allowCredentials,omitempty" yaml:"allowCredentials,omitempty"`
+
+	// AllowCredentialsFunc decides per request whether an allowed origin is granted
+	// credentials, e.g. only first-party origins while partner origins get
+	// credential-less access. Credentials are granted when either AllowCredentials
+	// is set or the function returns true, and never to insecure origins unless
+	// AllowInsecureCredentials is set. Setting it disables the preflight cache.
+	AllowCredentialsFunc func(r *http.Request, origin string) bool `json:"-" yaml:"-"`
+
+	// AllowInsecureCredentials grants credentials to plain http:// origins too,
+	// exposing cookie authenticated APIs to pages loaded without TLS.
+	AllowInsecureCredentials bool `json:"allowInsecureCredentials,omitempty" yaml:"allowInsecureCredentials,omitempty"`
+
+	// AutoAllowAuthHeaders adds Authorization to the allowed headers when
+	// AllowCredentials is set, so that authenticated requests don't fail after a
+	// successful preflight. Cookies are covered by AllowCredentials alone and never
+	// need to be listed in AllowedHeaders.
+	AutoAllowAuthHeaders bool `json:"autoAllowAuthHeaders,omitempty" yaml:"autoAllowAuthHeaders,omitempty"`
 
 	// MaxAge indicates how long (in seconds) the results of a preflight request
-	// can be cached
-	MaxAge int
+	// can be cached. 0 omits the header, leaving browsers to their default of 5
+	// seconds, while a negative value sends "Access-Control-Max-Age: 0" to tell
+	// them not to cache preflights at all.
+	MaxAge int `json:"maxAge,omitempty" yaml:"maxAge,omitempty"`
+
+	// MaxAgeDuration is MaxAge as a time.Duration, truncated to whole seconds. It
+	// takes precedence over MaxAge when non-zero; a negative duration disables
+	// preflight caching like a negative MaxAge does.
+	MaxAgeDuration time.Duration `json:"maxAgeDuration,omitempty" yaml:"maxAgeDuration,omitempty"`
+
+	// MaxAgeFunc computes the preflight max age (in seconds) per request, e.g. a
+	// short one for preview origins and a long one for production origins. It
+	// overrides MaxAge and MaxAgeDuration, with the same meaning for 0 and
+	// negative results. Setting it disables the preflight cache.
+	MaxAgeFunc func(r *http.Request, origin string) int `json:"-" yaml:"-"`
+
+	// AllowPrivateNetwork indicates whether to accept cross-origin requests over a
+	// private network (see https://wicg.github.io/private-network-access/). Preflights
+	// carrying "Access-Control-Request-Private-Network: true" are then answered with
+	// "Access-Control-Allow-Private-Network: true".
+	AllowPrivateNetwork bool `json:"allowPrivateNetwork,omitempty" yaml:"allowPrivateNetwork,omitempty"`
 
 	// OptionsPassthrough instructs preflight to let other potential next handlers to
 	// process the OPTIONS method. Turn this on if your application handles OPTIONS.
-	OptionsPassthrough bool
+	OptionsPassthrough bool `json:"optionsPassthrough,omitempty" yaml:"optionsPassthrough,omitempty"`
+
+	// PassthroughFunc lets the next handlers process the preflight requests for
+	// which it returns true, like OptionsPassthrough does for all of them, so that
+	// endpoints genuinely serving OPTIONS (e.g. WebDAV) receive them while preflights
+	// for the rest of the API are answered by the middleware.
+	PassthroughFunc func(r *http.Request) bool `json:"-" yaml:"-"`
+
+	// SkipPaths lists the paths of endpoints exempted from CORS processing, like
+	// "/healthz" or "/metrics": their requests are passed to the next handler
+	// untouched, without CORS headers nor denial. A path ending with a slash
+	// exempts the whole subtree, e.g. "/webhooks/".
+	SkipPaths []string `json:"skipPaths,omitempty" yaml:"skipPaths,omitempty"`
+
+	// Skip exempts the requests for which it returns true from CORS processing,
+	// like SkipPaths does for paths.
+	Skip func(r *http.Request) bool `json:"-" yaml:"-"`
+
+	// DenyWithStatus answers denied cross-origin requests with this status (e.g.
+	// 403) instead of passing them to the next handler without CORS headers, for
+	// APIs only meant to be used by allowed browser origins. Denied preflights get
+	// it instead of 200 unless OptionsPassthrough is set. Same-origin requests, for
+	// which browsers send an Origin header too, and ReportOnly mode are never
+	// blocked. Default value is 0 which blocks nothing.
+	DenyWithStatus int `json:"denyWithStatus,omitempty" yaml:"denyWithStatus,omitempty"`
+
+	// EnforceFetchMetadata rejects the cross-site requests which CORS would not
+	// allow anyway, as told by the Sec-Fetch-Site and Sec-Fetch-Mode headers
+	// browsers send, including the ones which are not preflighted and carry no
+	// Origin header (images, scripts, form posts...). Cross-site requests are
+	// only let through when they are top-level GET navigations or come from an
+	// allowed origin. Rejected requests get the DenyWithStatus status, or 403,
+	// unless in ReportOnly mode. Requests from browsers not sending these headers
+	// are left to CORS.
+	EnforceFetchMetadata bool `json:"enforceFetchMetadata,omitempty" yaml:"enforceFetchMetadata,omitempty"`
+
+	// EnforceOriginForUnsafeMethods protects against CSRF by rejecting the
+	// requests with a state-changing method (anything but GET, HEAD, OPTIONS and
+	// TRACE) whose Origin, or the origin of their Referer when they have no Origin
+	// header, is neither the origin of the request nor an allowed origin.
+	// Requests with neither header are let through. Rejected requests get the
+	// DenyWithStatus status, or 403, unless in ReportOnly mode.
+	EnforceOriginForUnsafeMethods bool `json:"enforceOriginForUnsafeMethods,omitempty" yaml:"enforceOriginForUnsafeMethods,omitempty"`
+
+	// TrustForwardedProto takes the scheme of requests from the X-Forwarded-Proto
+	// header, or the proto parameter of the Forwarded header, rather than from
+	// the connection, when telling same-origin requests for DenyWithStatus and
+	// EnforceOriginForUnsafeMethods. Only set it behind a proxy terminating TLS
+	// which overwrites these headers.
+	TrustForwardedProto bool `json:"trustForwardedProto,omitempty" yaml:"trustForwardedProto,omitempty"`
+
+	// PreflightMethodNotAllowed answers preflights for a method which is not
+	// allowed with a 405 Method Not Allowed response whose Allow header lists the
+	// allowed methods, through ErrorHandler if any, rather than with a 200 response
+	// without CORS headers or the DenyWithStatus status. Behind RouteHandler or
+	// MuxHandler, the Allow header lists the methods routed for the path.
+	PreflightMethodNotAllowed bool `json:"preflightMethodNotAllowed,omitempty" yaml:"preflightMethodNotAllowed,omitempty"`
 
-	// Debugging flag adds additional output to debug server side CORS issues
-	Debug bool
+	// ErrorHandler writes the response of requests blocked by DenyWithStatus, given
+	// the status and the decision. Default writes the status text as plain text.
+	// It also writes the response of denied preflights answered by the middleware
+	// when DenyWithStatus is not set, with a 200 status.
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, status int, d Decision) `json:"-" yaml:"-"`
+
+	// Messages overrides the text of the denials given to ErrorHandler in
+	// Decision.Message. When set, the default error response holds the message
+	// instead of the status text.
+	Messages *Messages `json:"messages,omitempty" yaml:"messages,omitempty"`
+
+	// PreflightResponseBody is written in the response of the preflights answered
+	// by the middleware which are not denied, for gateways and probes expecting a
+	// body on OPTIONS, e.g. []byte(`{"ok":true}`). PreflightContentType is its
+	// Content-Type, "text/plain; charset=utf-8" by default. Denied preflights are
+	// rendered by ErrorHandler.
+	PreflightResponseBody []byte `json:"preflightResponseBody,omitempty" yaml:"preflightResponseBody,omitempty"`
+	PreflightContentType  string `json:"preflightContentType,omitempty" yaml:"preflightContentType,omitempty"`
+
+	// PreflightCacheSize is the maximum number of allowed preflight responses kept
+	// in memory, so that repeated preflights skip matching and normalization.
+	// The cache is not used when AllowOriginFunc or OriginProvider is set as their
+	// results may change between requests. Default value is 0 which disables the cache.
+	PreflightCacheSize int `json:"preflightCacheSize,omitempty" yaml:"preflightCacheSize,omitempty"`
+
+	// NegativeOriginCacheSize is the maximum number of denied origins remembered, so
+	// that repeated requests from the same disallowed origin (i.e.: scanners) skip
+	// wildcard and regular expression matching. Origins validated by AllowOriginFunc
+	// are never cached. Default value is 0 which disables the cache.
+	NegativeOriginCacheSize int `json:"negativeOriginCacheSize,omitempty" yaml:"negativeOriginCacheSize,omitempty"`
+
+	// NegativeOriginCacheTTL is how long a denied origin is remembered. Default value
+	// is 0 which keeps entries until they are evicted.
+	NegativeOriginCacheTTL time.Duration `json:"negativeOriginCacheTTL,omitempty" yaml:"negativeOriginCacheTTL,omitempty"`
+
+	// MaxAddedHeaderBytes caps the size of the Access-Control-* headers the middleware
+	// adds to a response, some load balancers rejecting responses with large header
+	// blocks. A request whose headers would exceed the budget is denied: no CORS
+	// header is added and the denial is reported with the ReasonHeaderBudget reason.
+	// Vary headers are not counted. Default value is 0 which disables the limit.
+	MaxAddedHeaderBytes int `json:"maxAddedHeaderBytes,omitempty" yaml:"maxAddedHeaderBytes,omitempty"`
+
+	// MaxPreflightHeaderBytes and MaxPreflightHeaderTokens bound the size and the
+	// number of comma separated names of the Access-Control-Request-Headers of a
+	// preflight. Larger requests are denied with the ReasonRequestHeadersLimit
+	// reason before the header is parsed. Default values are 0 which disable the
+	// limits.
+	MaxPreflightHeaderBytes  int `json:"maxPreflightHeaderBytes,omitempty" yaml:"maxPreflightHeaderBytes,omitempty"`
+	MaxPreflightHeaderTokens int `json:"maxPreflightHeaderTokens,omitempty" yaml:"maxPreflightHeaderTokens,omitempty"`
+
+	// StrictRequestHeaders requires the Access-Control-Request-Headers of preflights
+	// to be the single sorted, byte-lowercased and comma separated list of names the
+	// Fetch standard makes browsers send. Anything else is denied with the
+	// ReasonMalformedHeaders reason without being parsed, which also catches
+	// non-browser clients forging preflights.
+	StrictRequestHeaders bool `json:"strictRequestHeaders,omitempty" yaml:"strictRequestHeaders,omitempty"`
+
+	// Name identifies the policy in telemetry, which is useful when several Cors
+	// instances share the same Telemetry.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+
+	// Telemetry collects decision counters. It can be shared between several Cors
+	// instances, labelled by their Name.
+	Telemetry *Telemetry `json:"-" yaml:"-"`
+
+	// OnDecision is called with the outcome of every request carrying an Origin
+	// header, preflight or actual, and of the requests without one rejected by
+	// EnforceFetchMetadata or EnforceOriginForUnsafeMethods, after the policy was
+	// evaluated. It must not block. Decisions are sampled by SampleAllows,
+	// SampleDenials and SampleReasons, use Telemetry for exact counts.
+	OnDecision func(Decision) `json:"-" yaml:"-"`
+
+	// AuditWriter receives a JSON line for every denied preflight or actual
+	// request picked by SampleDenials and SampleReasons, holding its time, origin,
+	// method, path, reason and error, as an audit trail of blocked cross-origin
+	// activity kept apart from debug logs. Writes are serialized and their errors
+	// ignored.
+	AuditWriter io.Writer `json:"-" yaml:"-"`
+
+	// AuditFunc is called with every denied preflight or actual request and its
+	// decision, like AuditWriter is written to. It must not block.
+	AuditFunc func(d Decision, r *http.Request) `json:"-" yaml:"-"`
+
+	// ReportCollector receives a report for every denied request, next to the
+	// reports browsers deliver to it, see ReportCollector.
+	ReportCollector *ReportCollector `json:"-" yaml:"-"`
+
+	// ReportingEndpoint is the URL of the report collector advertised to browsers
+	// in the Reporting-Endpoints header of responses, under the ReportingGroup
+	// name, so that reports of policies naming it, like
+	// IsolationOptions.ReportTo, are delivered there.
+	ReportingEndpoint string `json:"reportingEndpoint,omitempty" yaml:"reportingEndpoint,omitempty"`
+
+	// ShadowPolicy is a candidate configuration evaluated alongside this one without
+	// affecting responses. OnShadowDivergence is called whenever both configurations
+	// disagree on a request, telling which changes a rollout would cause.
+	ShadowPolicy *Options `json:"shadowPolicy,omitempty" yaml:"shadowPolicy,omitempty"`
+
+	// OnShadowDivergence receives the decisions of the active and shadow policies
+	// when they differ in outcome or headers.
+	OnShadowDivergence func(active, shadow Decision) `json:"-" yaml:"-"`
+
+	// StrictHeaderPlacement removes headers set by next handlers which don't belong
+	// to the response: Access-Control-Expose-Headers on passed through preflight
+	// responses, and preflight only headers (Access-Control-Allow-Methods, -Headers,
+	// -Private-Network and Access-Control-Max-Age) on actual responses. The middleware
+	// itself never emits them there.
+	StrictHeaderPlacement bool `json:"strictHeaderPlacement,omitempty" yaml:"strictHeaderPlacement,omitempty"`
+
+	// DisableVaryMerge adds the Vary values of the middleware as separate header
+	// lines, byte for byte like earlier versions. By default they are merged with
+	// the Vary values set by the middleware before this one into a single
+	// deduplicated header. Next handlers replacing Vary must keep these values,
+	// unless OverrideUpstreamHeaders is set.
+	DisableVaryMerge bool `json:"disableVaryMerge,omitempty" yaml:"disableVaryMerge,omitempty"`
+
+	// OverrideUpstreamHeaders makes the middleware the only source of CORS headers,
+	// for reverse proxies in front of services setting their own: Access-Control-*
+	// headers set before the middleware or by the next handler are replaced by the
+	// ones of the middleware, and Vary values, including the ones set by the next
+	// handler, are merged and deduplicated even if DisableVaryMerge is set.
+	// Access-Control-Expose-Headers values of the next handler are kept on allowed
+	// responses so that ExposeHeaders keeps working.
+	OverrideUpstreamHeaders bool `json:"overrideUpstreamHeaders,omitempty" yaml:"overrideUpstreamHeaders,omitempty"`
+
+	// Debugging flag adds additional output to debug server side CORS issues, and
+	// explains denials in an X-Cors-Debug response header, e.g. "origin
+	// https://x.com denied: no pattern matched; candidates: https://app.com".
+	// Never enable it in production as it discloses the policy.
+	Debug bool `json:"debug,omitempty" yaml:"debug,omitempty"`
+
+	// Logger receives structured decision logs: allowed requests at debug level,
+	// denied ones at info level, with the origin, method and denial reason as
+	// attributes. A *slog.Logger can be used directly. Sampling options apply.
+	Logger LevelLogger `json:"-" yaml:"-"`
+
+	// SampleAllows is the fraction (between 0 and 1) of allowed decisions that are
+	// reported to OnDecision and logged. Zero value means all allowed decisions are
+	// reported, use a negative value to report none of them. Telemetry and
+	// ReportCollector see every decision.
+	SampleAllows float64 `json:"sampleAllows,omitempty" yaml:"sampleAllows,omitempty"`
+
+	// SampleDenials is the fraction (between 0 and 1) of denied decisions that are
+	// reported to OnDecision, AuditFunc and AuditWriter, and logged. Zero value
+	// means all denied decisions are reported, use a negative value to report none
+	// of them.
+	SampleDenials float64 `json:"sampleDenials,omitempty" yaml:"sampleDenials,omitempty"`
+
+	// SampleByOrigin makes sampling deterministic by hashing the request origin
+	// instead of drawing a random number, so that a given origin is either always
+	// or never sampled.
+	SampleByOrigin bool `json:"sampleByOrigin,omitempty" yaml:"sampleByOrigin,omitempty"`
+
+	// SampleReasons is the fraction (between 0 and 1) of denied decisions that
+	// are reported per denial reason (ReasonOrigin, ReasonMethod...), overriding
+	// SampleDenials for these reasons, e.g. to keep every header budget denial
+	// but 1% of the origin ones. Zero values mean all such decisions are reported,
+	// use a negative value to report none of them.
+	SampleReasons map[string]float64 `json:"sampleReasons,omitempty" yaml:"sampleReasons,omitempty"`
+
+	// LogSampleRate is the fraction (between 0 and 1) of requests whose lines are
+	// written to the debug logger, so that Debug can be enabled on high-traffic
+	// services. Requests are drawn once, all the lines of a drawn request being
+	// written, and by origin when SampleByOrigin is set; decision lines are
+	// further sampled by SampleAllows, SampleDenials and SampleReasons. Zero value
+	// means all requests are logged, use a negative value to log none of them.
+	LogSampleRate float64 `json:"logSampleRate,omitempty" yaml:"logSampleRate,omitempty"`
 }
 
 // Logger generic interface for logger
@@ -76,29 +519,82 @@ type Logger interface {
 	Printf(string, ...interface{})
 }
 
+// LevelLogger is a leveled, structured logger. Arguments following the message are
+// alternating attribute keys and values, as accepted by *slog.Logger.
+type LevelLogger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+}
+
 // Cors http handler
 type Cors struct {
 	// Debug logger
 	Log Logger
 
-	// Normalized list of plain allowed origins
-	allowedOrigins []string
+	// Current *policy, swapped by UpdateOptions
+	policy atomic.Value
+
+	// Serializes configuration changes
+	mu sync.Mutex
+	// Fingerprint of the policy recorded by Freeze, empty until then
+	frozenFingerprint string
+
+	// Set once a nested handler has been reported
+	nestedWarned uint32
+
+	// Serializes writes to Options.AuditWriter
+	auditMu sync.Mutex
+}
+
+// policy is the compiled form of Options
+type policy struct {
+	// Owning handler, used for logging
+	c *Cors
+
+	// Digest of the options compiled, see fingerprint
+	optionsDigest string
+
+	// Compiled allowed origin patterns
+	origins *engine.OriginMatcher
 
-	// List of allowed origins containing wildcards
-	allowedWOrigins []wildcard
+	// Compiled denied origin patterns, nil if none
+	deniedOrigins *engine.OriginMatcher
+	// Configured allowed origin patterns, reported in errors
+	allowedOriginsList []string
+
+	// Optional dynamic origins source
+	originProvider *dynamicOrigins
 
 	// Optional origin validator function
 	allowOriginFunc func(r *http.Request, origin string) bool
 
 	// Normalized list of allowed headers
 	allowedHeaders []string
+	// Normalized headers allowed per method, on top of allowedHeaders
+	methodHeaders map[string][]string
 
 	// Normalized list of allowed methods
 	allowedMethods []string
 
 	// Normalized list of exposed headers
-	exposedHeaders []string
-	maxAge         int
+	exposedHeaders    []string
+	timingAllowOrigin bool
+	resourcePolicy    ResourcePolicy
+	maxAge            int
+	maxAgeFunc        func(r *http.Request, origin string) int
+
+	// Header values computed once and shared by all responses, they must never be
+	// modified
+	allowMethodsValues map[string][]string
+	exposeHeadersValue []string
+	maxAgeValue        []string
+	// Set in static allowed headers mode
+	allowHeadersValue []string
+	// Set in static allowed headers mode for the methods with their own headers
+	methodAllowHeadersValues map[string][]string
+	// Set in cacheable responses mode
+	staticAllowMethods []string
 
 	// Set to true when allowed origins contains a "*"
 	allowedOriginsAll bool
@@ -106,80 +602,326 @@ type Cors struct {
 	// Set to true when allowed headers contains a "*"
 	allowedHeadersAll bool
 
-	allowCredentials  bool
-	optionPassthrough bool
+	allowCredentials     bool
+	allowCredentialsFunc func(r *http.Request, origin string) bool
+	allowNullOrigin      bool
+	allowPrivateNetwork  bool
+	optionPassthrough    bool
+	debug                bool
+	passthroughFunc      func(r *http.Request) bool
+	skipPaths            []string
+	skip                 func(r *http.Request) bool
+	denyStatus           int
+	fetchMetadata        bool
+	unsafeOrigin         bool
+	trustForwardedProto  bool
+	methodNotAllowed     bool
+	errorHandler         func(w http.ResponseWriter, r *http.Request, status int, d Decision)
+	messages             *Messages
+	preflightBody        []byte
+	preflightContentType string
+	strictPlacement      bool
+	mergeVary            bool
+	overrideUpstream     bool
+	denyForbidden        bool
+	reportOnly           bool
+	insecureCredentials  bool
+	allowLocalhost       bool
+	strictMethods        bool
+	allowRoutedMethods   bool
+	strictContentType    bool
+
+	// Cache of allowed preflight responses, nil when disabled
+	preflightCache *lruCache
+
+	// Cache of denied origins, nil when disabled
+	negativeCache      *lruCache
+	negativeCacheStats *CacheStats
+
+	// Optional per-request policy resolver, and policies compiled from its options
+	resolver PolicyResolver
+	resolved *resolvedPolicies
+
+	// Optional cache of AllowOriginFunc results
+	originFuncCache *lruCache
+
+	// Maximum size of added CORS headers, 0 when unlimited
+	maxAddedHeaderBytes int
+	maxReqHeaderBytes   int
+	maxReqHeaderTokens  int
+	strictReqHeaders    bool
+
+	// Optional decision callback
+	onDecision func(Decision)
+
+	collector          *ReportCollector
+	auditWriter        io.Writer
+	auditFunc          func(d Decision, r *http.Request)
+	reportingEndpoints string
+
+	// Candidate policy compared to this one, and divergence callback
+	shadow             *policy
+	onShadowDivergence func(active, shadow Decision)
+
+	// Optional structured logger
+	logger LevelLogger
+
+	// Shared decision counters, labelled with the policy name
+	name      string
+	telemetry *Telemetry
+
+	// Fraction of allowed and denied decisions to log
+	sampleAllows   float64
+	sampleDenials  float64
+	sampleByOrigin bool
+	sampleReasons  map[string]float64
+	logSampleRate  float64
 }
 
-// New creates a new Cors handler with the provided options.
+// New creates a new Cors handler with the provided options. New panics if the
+// options cannot be compiled, see NewWithError to validate them instead.
 func New(options Options) *Cors {
-	c := &Cors{
-		exposedHeaders:    convert(options.ExposedHeaders, http.CanonicalHeaderKey),
-		allowOriginFunc:   options.AllowOriginFunc,
-		allowCredentials:  options.AllowCredentials,
-		maxAge:            options.MaxAge,
-		optionPassthrough: options.OptionsPassthrough,
-	}
-	if options.Debug && c.Log == nil {
+	c := &Cors{}
+	if options.Debug {
 		c.Log = log.New(os.Stdout, "[cors] ", log.LstdFlags)
 	}
+	p, err := newPolicy(c, options)
+	if err != nil {
+		panic(err)
+	}
+	c.policy.Store(p)
+	return c
+}
+
+// UpdateOptions atomically replaces the configuration of a live handler, requests
+// in flight completing with the previous one. Caches are reset and the logger is
+// left untouched. The current configuration is kept if the options cannot be
+// compiled, and ErrFrozen is returned once Freeze was called.
+func (c *Cors) UpdateOptions(options Options) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.frozenFingerprint != "" {
+		return ErrFrozen
+	}
+	p, err := newPolicy(c, options)
+	if err != nil {
+		return err
+	}
+	c.policy.Store(p)
+	return nil
+}
+
+// current returns the policy in effect
+func (c *Cors) current() *policy {
+	return c.policy.Load().(*policy)
+}
+
+// newPolicy compiles options into a policy owned by c
+func newPolicy(c *Cors, options Options) (*policy, error) {
+	p := &policy{
+		c:                    c,
+		exposedHeaders:       sortedSet(convert(options.ExposedHeaders, http.CanonicalHeaderKey)),
+		timingAllowOrigin:    options.EmitTimingAllowOrigin,
+		resourcePolicy:       options.ResourcePolicy,
+		allowOriginFunc:      options.AllowOriginFunc,
+		allowCredentials:     options.AllowCredentials,
+		allowCredentialsFunc: options.AllowCredentialsFunc,
+		allowNullOrigin:      options.AllowNullOrigin,
+		allowPrivateNetwork:  options.AllowPrivateNetwork,
+		maxAge:               options.MaxAge,
+		maxAgeFunc:           options.MaxAgeFunc,
+		optionPassthrough:    options.OptionsPassthrough,
+		debug:                options.Debug,
+		passthroughFunc:      options.PassthroughFunc,
+		skipPaths:            sortedSet(options.SkipPaths),
+		skip:                 options.Skip,
+		denyStatus:           options.DenyWithStatus,
+		fetchMetadata:        options.EnforceFetchMetadata,
+		unsafeOrigin:         options.EnforceOriginForUnsafeMethods,
+		trustForwardedProto:  options.TrustForwardedProto,
+		methodNotAllowed:     options.PreflightMethodNotAllowed,
+		errorHandler:         options.ErrorHandler,
+		messages:             options.Messages,
+		preflightBody:        options.PreflightResponseBody,
+		preflightContentType: options.PreflightContentType,
+		strictPlacement:      options.StrictHeaderPlacement,
+		mergeVary:            !options.DisableVaryMerge || options.OverrideUpstreamHeaders,
+		overrideUpstream:     options.OverrideUpstreamHeaders,
+		denyForbidden:        options.DenyForbiddenHeaders,
+		reportOnly:           options.ReportOnly,
+		insecureCredentials:  options.AllowInsecureCredentials,
+		allowLocalhost:       options.AllowLocalhost,
+		optionsDigest:        optionsDigest(options),
+		resolver:             options.PolicyResolver,
+		resolved:             &resolvedPolicies{},
+		strictMethods:        options.StrictMethodCheck,
+		allowRoutedMethods:   options.AllowRoutedMethods,
+		strictContentType:    options.StrictContentType,
+		sampleAllows:         sampleRate(options.SampleAllows),
+		sampleDenials:        sampleRate(options.SampleDenials),
+		sampleByOrigin:       options.SampleByOrigin,
+		logSampleRate:        sampleRate(options.LogSampleRate),
+		maxAddedHeaderBytes:  options.MaxAddedHeaderBytes,
+		maxReqHeaderBytes:    options.MaxPreflightHeaderBytes,
+		maxReqHeaderTokens:   options.MaxPreflightHeaderTokens,
+		strictReqHeaders:     options.StrictRequestHeaders,
+		name:                 options.Name,
+		telemetry:            options.Telemetry,
+		logger:               options.Logger,
+		onDecision:           options.OnDecision,
+		collector:            options.ReportCollector,
+		auditWriter:          options.AuditWriter,
+		auditFunc:            options.AuditFunc,
+		onShadowDivergence:   options.OnShadowDivergence,
+	}
+	for reason, rate := range options.SampleReasons {
+		if p.sampleReasons == nil {
+			p.sampleReasons = make(map[string]float64, len(options.SampleReasons))
+		}
+		p.sampleReasons[reason] = sampleRate(rate)
+	}
+	if options.ReportingEndpoint != "" {
+		p.reportingEndpoints = ReportingGroup + "=" + strconv.Quote(options.ReportingEndpoint)
+	}
+	if options.OriginProvider != nil {
+		p.originProvider = newDynamicOrigins(options.OriginProvider, options.OriginProviderTTL, options.OriginMatchMode)
+		p.originProvider.onError = func(err error) {
+			c.logf("Origin provider reload failed: %v", err)
+			if p.logger != nil {
+				p.logger.Warn("cors: origin provider reload failed", "error", err.Error())
+			}
+		}
+	}
+	if options.AllowOriginVaryFunc != nil {
+		p.allowOriginFunc = originVaryFunc(options.AllowOriginVaryFunc)
+	}
+	if options.PreflightCacheSize > 0 && p.allowOriginFunc == nil && options.OriginProvider == nil &&
+		options.AllowCredentialsFunc == nil && options.MaxAgeFunc == nil {
+		p.preflightCache = newLRUCache(options.PreflightCacheSize, 0)
+	}
+	if options.NegativeOriginCacheSize > 0 {
+		p.negativeCache = newLRUCache(options.NegativeOriginCacheSize, options.NegativeOriginCacheTTL)
+		p.negativeCacheStats = &CacheStats{}
+	}
+	if options.OriginFuncCacheSize > 0 && options.AllowOriginFunc != nil && options.AllowOriginVaryFunc == nil {
+		p.originFuncCache = newLRUCache(options.OriginFuncCacheSize, options.OriginFuncCacheTTL)
+	}
 
 	// Normalize options
 	// Note: for origins and methods matching, the spec requires a case-sensitive matching.
 	// As it may error prone, we chose to ignore the spec here.
 
 	// Allowed Origins
-	if len(options.AllowedOrigins) == 0 {
-		if options.AllowOriginFunc == nil {
+	if len(options.AllowedOrigins) == 0 && len(options.AllowedOriginsRegex) == 0 {
+		if p.allowOriginFunc == nil && options.OriginProvider == nil && !options.AllowLocalhost {
 			// Default is all origins
-			c.allowedOriginsAll = true
+			p.allowedOriginsAll = true
 		}
-	} else {
-		c.allowedOrigins = []string{}
-		c.allowedWOrigins = []wildcard{}
-		for _, origin := range options.AllowedOrigins {
-			// Normalize
-			origin = strings.ToLower(origin)
-			if origin == "*" {
-				// If "*" is present in the list, turn the whole list into a match all
-				c.allowedOriginsAll = true
-				c.allowedOrigins = nil
-				c.allowedWOrigins = nil
-				break
-			} else if i := strings.IndexByte(origin, '*'); i >= 0 {
-				// Split the origin in two: start and end string without the *
-				w := wildcard{origin[0:i], origin[i+1:]}
-				c.allowedWOrigins = append(c.allowedWOrigins, w)
-			} else {
-				c.allowedOrigins = append(c.allowedOrigins, origin)
-			}
+	}
+	for _, origin := range options.AllowedOrigins {
+		if origin == "*" {
+			// If "*" is present in the list, turn the whole list into a match all
+			p.allowedOriginsAll = true
+			break
+		}
+	}
+	p.allowedOriginsList = append(append([]string(nil), options.AllowedOrigins...), options.AllowedOriginsRegex...)
+	if len(options.DeniedOrigins) > 0 {
+		p.deniedOrigins, _ = engine.NewOriginMatcher(options.DeniedOrigins, nil, engine.MatchMostSpecific)
+	}
+	if !p.allowedOriginsAll {
+		origins, err := engine.NewOriginMatcher(options.AllowedOrigins, options.AllowedOriginsRegex, engine.MatchMode(options.OriginMatchMode))
+		if err != nil {
+			return nil, err
 		}
+		p.origins = origins
 	}
 
 	// Allowed Headers
 	if len(options.AllowedHeaders) == 0 {
 		// Use sensible defaults
-		c.allowedHeaders = []string{"Origin", "Accept", "Content-Type"}
+		p.allowedHeaders = []string{"Origin", "Accept", "Content-Type"}
 	} else {
 		// Origin is always appended as some browsers will always request for this header at preflight
-		c.allowedHeaders = convert(append(options.AllowedHeaders, "Origin"), http.CanonicalHeaderKey)
+		p.allowedHeaders = convert(append(options.AllowedHeaders, "Origin"), http.CanonicalHeaderKey)
 		for _, h := range options.AllowedHeaders {
 			if h == "*" {
-				c.allowedHeadersAll = true
-				c.allowedHeaders = nil
+				p.allowedHeadersAll = true
+				p.allowedHeaders = nil
 				break
 			}
 		}
 	}
+	if (options.AllowCredentials || options.AllowCredentialsFunc != nil) && options.AutoAllowAuthHeaders &&
+		!p.allowedHeadersAll && !containsString(p.allowedHeaders, "Authorization") {
+		p.allowedHeaders = append(p.allowedHeaders, "Authorization")
+	}
+	p.allowedHeaders = sortedSet(p.allowedHeaders)
+	if !p.allowedHeadersAll && len(options.MethodHeaderPolicy) > 0 {
+		p.methodHeaders = make(map[string][]string, len(options.MethodHeaderPolicy))
+		for method, headers := range options.MethodHeaderPolicy {
+			method = strings.ToUpper(method)
+			p.methodHeaders[method] = sortedSet(append(p.methodHeaders[method], convert(headers, http.CanonicalHeaderKey)...))
+		}
+	}
 
 	// Allowed Methods
 	if len(options.AllowedMethods) == 0 {
 		// Default is spec's "simple" methods
-		c.allowedMethods = []string{http.MethodGet, http.MethodPost, http.MethodHead}
+		p.allowedMethods = []string{http.MethodGet, http.MethodPost, http.MethodHead}
 	} else {
-		c.allowedMethods = convert(options.AllowedMethods, strings.ToUpper)
+		p.allowedMethods = sortedSet(convert(options.AllowedMethods, strings.ToUpper))
+		if options.ImplyHeadForGet && containsString(p.allowedMethods, http.MethodGet) {
+			p.allowedMethods = sortedSet(append(p.allowedMethods, http.MethodHead))
+		}
 	}
 
-	return c
+	p.allowMethodsValues = make(map[string][]string, len(p.allowedMethods))
+	for _, m := range p.allowedMethods {
+		p.allowMethodsValues[m] = []string{m}
+	}
+	if len(p.exposedHeaders) > 0 {
+		p.exposeHeadersValue = []string{strings.Join(p.exposedHeaders, ", ")}
+	}
+	if options.MaxAgeDuration != 0 {
+		p.maxAge = int(options.MaxAgeDuration / time.Second)
+		if options.MaxAgeDuration < 0 {
+			p.maxAge = -1
+		}
+	}
+	p.maxAgeValue = maxAgeValue(p.maxAge)
+	if options.CacheableResponses {
+		p.staticAllowMethods = []string{strings.Join(p.allowedMethods, ", ")}
+	}
+	if options.AllowedHeadersResponseMode == AllowedHeadersStatic || options.CacheableResponses {
+		if !p.allowedHeadersAll {
+			p.allowHeadersValue = []string{strings.Join(p.allowedHeaders, ", ")}
+			for method := range p.methodHeaders {
+				if p.methodAllowHeadersValues == nil {
+					p.methodAllowHeadersValues = make(map[string][]string, len(p.methodHeaders))
+				}
+				p.methodAllowHeadersValues[method] = []string{strings.Join(p.headersFor(method), ", ")}
+			}
+		} else if !p.allowCredentials && p.allowCredentialsFunc == nil {
+			p.allowHeadersValue = []string{"*"}
+		}
+	}
+
+	if options.ShadowPolicy != nil {
+		shadow, err := newPolicy(c, *options.ShadowPolicy)
+		if err != nil {
+			return nil, fmt.Errorf("cors: shadow policy: %v", err)
+		}
+		p.shadow = shadow
+	}
+
+	for _, warning := range options.Warnings() {
+		c.logf("Warning: %s", warning)
+		if p.logger != nil {
+			p.logger.Warn("cors: " + warning)
+		}
+	}
+	return p, nil
 }
 
 // Handler creates a new Cors handler with passed options.
@@ -206,125 +948,577 @@ func AllowAll() *Cors {
 	})
 }
 
+// AllowLocalhost creates a new Cors handler for local development, allowing pages
+// served from localhost, 127.0.0.1 or [::1] on any port to use all standard
+// methods with any header and credentials.
+func AllowLocalhost() *Cors {
+	return New(Options{
+		AllowLocalhost: true,
+		AllowedMethods: []string{
+			http.MethodHead,
+			http.MethodGet,
+			http.MethodPost,
+			http.MethodPut,
+			http.MethodPatch,
+			http.MethodDelete,
+		},
+		AllowedHeaders:   []string{"*"},
+		AllowCredentials: true,
+	})
+}
+
+// Strict creates a new Cors handler with hardened defaults, the opposite of AllowAll:
+// only the given origins are allowed, they must all be https, with the spec's simple
+// methods checked strictly (see StrictMethodCheck), the default headers, no
+// credentials and a short preflight cache duration.
+// Strict panics if an origin is not a valid https origin.
+func Strict(allowed ...string) *Cors {
+	for _, origin := range allowed {
+		if !strings.HasPrefix(strings.ToLower(origin), "https://") {
+			panic(fmt.Sprintf("cors: strict origin %q must use https", origin))
+		}
+	}
+	options := Options{
+		AllowedOrigins: allowed,
+		AllowedMethods: []string{
+			http.MethodHead,
+			http.MethodGet,
+			http.MethodPost,
+		},
+		StrictMethodCheck: true,
+		AllowCredentials:  false,
+		MaxAge:            300,
+	}
+	if len(allowed) == 0 {
+		// Never fall back to the allow all default
+		options.AllowOriginFunc = func(r *http.Request, origin string) bool { return false }
+	}
+	if err := options.Validate(); err != nil {
+		panic(err)
+	}
+	return New(options)
+}
+
 // Handler apply the CORS specification on the request, and add relevant CORS headers
-// as necessary.
+// as necessary. Every response carries "Vary: Origin", including responses to
+// same-origin requests without an Origin header, so that shared caches never serve
+// a copy without CORS headers to a cross-origin client.
+//
+// A handler nested in another CORS handler, as easily happens with nested
+// routers, does nothing but log a warning: the outer one alone adds headers.
+// Requests exempted by SkipPaths or Skip are passed to next untouched.
 func (c *Cors) Handler(next http.Handler) http.Handler {
+	return c.handler(next, nil)
+}
+
+// handler applies the policy in front of next, restricting the allowed methods
+// to the routed ones if routed is not nil (see RouteHandler). The policy is
+// loaded once per request, so that UpdateOptions never mixes two policies in a
+// response.
+func (c *Cors) handler(next http.Handler, routed func(r *http.Request, method string) bool) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
-			c.logf("Handler: Preflight request")
-			c.handlePreflight(w, r)
+		ctx, state := markHandled(r.Context())
+		if state == nil {
+			c.warnNested()
+			next.ServeHTTP(w, r)
+			return
+		}
+		base := c.current()
+		state.policy = base
+		r = r.WithContext(ctx)
+		if base.skips(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		state.unlogged = !base.drawLog(r)
+		p, err := base.resolve(r)
+		if err != nil {
+			p = base
+			state.setDecision(p.resolveError(w, r, err))
+			p.report(r, state.decision)
+			if isPreflight(r) && !p.passthrough(r) {
+				p.writePreflight(w, r, state.decision)
+				return
+			}
+			if p.blocks(r, state.decision) {
+				p.writeDenial(w, r, state.decision)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		if routed != nil && headerValue(r.Header, "Origin") != "" && !(isPreflight(r) && p.passthrough(r)) {
+			// Methods are routed among the ones of the resolved policy
+			if methods := routedMethods(r, p, routed); len(methods) > 0 {
+				r = r.WithContext(context.WithValue(r.Context(), routeMethodsKey, methods))
+			}
+		}
+		if p.overrideUpstream {
+			removeAccessControlHeaders(w.Header())
+		}
+		if p.reportingEndpoints != "" {
+			w.Header().Set("Reporting-Endpoints", p.reportingEndpoints)
+		}
+		if isPreflight(r) {
+			p.logf(r, "Handler: Preflight request")
+			state.setDecision(p.handlePreflight(w, r))
 			// Preflight requests are standalone and should stop the chain as some other
 			// middleware may not handle OPTIONS requests correctly. One typical example
 			// is authentication middleware ; OPTIONS requests won't carry authentication
 			// headers (see #1)
-			if c.optionPassthrough {
-				next.ServeHTTP(w, r)
+			if p.passthrough(r) {
+				serveBeforeWrite(next, w, r, p.beforeWrite(w.Header(), actualOnlyHeaders, p.preflightVary()))
 			} else {
-				w.WriteHeader(http.StatusOK)
+				p.writePreflight(w, r, state.decision)
 			}
 		} else {
-			c.logf("Handler: Actual request")
-			c.handleActualRequest(w, r)
-			next.ServeHTTP(w, r)
+			p.logf(r, "Handler: Actual request")
+			state.setDecision(p.handleActualRequest(w, r))
+			setResourcePolicy(w.Header(), p.resourcePolicy)
+			if p.blocks(r, state.decision) {
+				p.writeDenial(w, r, state.decision)
+				return
+			}
+			serveBeforeWrite(next, w, r, p.beforeWrite(w.Header(), preflightOnlyHeaders, actualVary))
 		}
 	})
 }
 
-// handlePreflight handles pre-flight CORS requests
-func (c *Cors) handlePreflight(w http.ResponseWriter, r *http.Request) {
+// passthrough reports whether the preflight request r is to be passed to the next
+// handler
+func (p *policy) passthrough(r *http.Request) bool {
+	return p.optionPassthrough || (p.passthroughFunc != nil && p.passthroughFunc(r))
+}
+
+// skips reports whether r is exempted from CORS processing
+func (p *policy) skips(r *http.Request) bool {
+	for _, path := range p.skipPaths {
+		if r.URL.Path == path || strings.HasSuffix(path, "/") && strings.HasPrefix(r.URL.Path, path) {
+			return true
+		}
+	}
+	return p.skip != nil && p.skip(r)
+}
+
+// handlePreflight handles pre-flight CORS requests and returns their decision
+func (p *policy) handlePreflight(w http.ResponseWriter, r *http.Request) Decision {
 	headers := w.Header()
-	origin := r.Header.Get("Origin")
 
 	if r.Method != http.MethodOptions {
-		c.logf("Preflight aborted: %s!=OPTIONS", r.Method)
-		return
+		p.logf(r, "Preflight aborted: %s!=OPTIONS", r.Method)
+		return Decision{Origin: headerValue(r.Header, "Origin"), Method: r.Method}
 	}
 	// Always set Vary headers
 	// see https://github.com/rs/cors/issues/10,
 	//     https://github.com/rs/cors/commit/dbdca4d95feaa7511a46e6f1efb3b3aa505bc43f#commitcomment-12352001
-	headers.Add("Vary", "Origin")
-	headers.Add("Vary", "Access-Control-Request-Method")
-	headers.Add("Vary", "Access-Control-Request-Headers")
+	p.addVary(headers, p.preflightVary()...)
 
-	if origin == "" {
-		c.logf("Preflight aborted: empty origin")
-		return
+	d := p.checkPreflight(r)
+	p.addVary(headers, requestVary(r)...)
+	p.report(r, d)
+	p.compareShadow(r, d)
+	p.setDebugHeader(headers, d)
+	if !d.Allowed {
+		if !p.reportOnly || d.Origin == "" {
+			return d
+		}
+		d.header = p.reportOnlyHeaders(r, d)
 	}
-	if !c.isOriginAllowed(r, origin) {
-		c.logf("Preflight aborted: origin '%s' not allowed", origin)
-		return
+	for k, v := range d.header {
+		// Values are never modified once computed, it is safe to share them
+		headers[k] = v
 	}
+	p.logDecision(r, d, "Preflight response headers: %v", headers)
+	return d
+}
 
-	reqMethod := r.Header.Get("Access-Control-Request-Method")
-	if !c.isMethodAllowed(reqMethod) {
-		c.logf("Preflight aborted: method '%s' not allowed", reqMethod)
-		return
+// checkPreflight evaluates a preflight request
+func (p *policy) checkPreflight(r *http.Request) Decision {
+	origin := headerValue(r.Header, "Origin")
+	reqMethod := headerValue(r.Header, "Access-Control-Request-Method")
+	if origin == "" {
+		return Decision{Preflight: true, Method: strings.ToUpper(reqMethod)}
 	}
-	reqHeaders := parseHeaderList(r.Header.Get("Access-Control-Request-Headers"))
-	if !c.areHeadersAllowed(reqHeaders) {
-		c.logf("Preflight aborted: headers '%v' not allowed", reqHeaders)
-		return
+	if err := checkOriginSyntax(r, origin); err != nil {
+		d := Decision{Preflight: true, Origin: origin, Method: strings.ToUpper(reqMethod)}
+		return d.deny(ReasonMalformedOrigin, err)
 	}
-	if c.allowedOriginsAll {
-		headers.Set("Access-Control-Allow-Origin", "*")
+	reqHeaderValues := headerValues(r.Header, "Access-Control-Request-Headers")
+	if err := p.checkRequestHeadersLimits(reqHeaderValues); err != nil {
+		d := Decision{Preflight: true, Origin: origin, Method: strings.ToUpper(reqMethod)}
+		return d.deny(ReasonRequestHeadersLimit, err)
+	}
+	if p.strictReqHeaders && len(reqHeaderValues) > 0 {
+		if len(reqHeaderValues) > 1 || reqHeaderValues[0] == "" || !isFetchHeaderList(reqHeaderValues[0]) {
+			d := Decision{Preflight: true, Origin: origin, Method: strings.ToUpper(reqMethod)}
+			return d.deny(ReasonMalformedHeaders, &MalformedRequestHeadersError{Value: strings.Join(reqHeaderValues, ",")})
+		}
+	}
+	reqHeaders := strings.Join(reqHeaderValues, ",")
+	var d Decision
+	if routeMethods(r) != nil {
+		// Decisions depend on the route, which is not part of the cache key
+		if d = p.evaluatePreflight(r, origin, reqMethod, reqHeaders); !d.Allowed {
+			return d
+		}
 	} else {
-		headers.Set("Access-Control-Allow-Origin", origin)
+		key := preflightKey(origin, reqMethod, reqHeaders)
+		var cached bool
+		if d, cached = p.cachedPreflight(key); !cached {
+			if d = p.evaluatePreflight(r, origin, reqMethod, reqHeaders); !d.Allowed {
+				return d
+			}
+			p.cachePreflight(key, d)
+		}
+	}
+	if p.allowPrivateNetwork && headerValue(r.Header, "Access-Control-Request-Private-Network") == "true" {
+		// Cached headers are shared and must not be modified
+		d.header = d.header.Clone()
+		d.header.Set("Access-Control-Allow-Private-Network", "true")
+	}
+	return p.checkHeaderBudget(d)
+}
+
+// checkOriginSyntax checks the request has a single, well-formed Origin header, so
+// that garbage is never matched nor reflected
+func checkOriginSyntax(r *http.Request, origin string) error {
+	// Internationalized host names are accepted in their Unicode form
+	if !engine.IsSerializedOrigin(origin) || len(headerValues(r.Header, "Origin")) > 1 {
+		return &MalformedOriginError{Origin: origin}
+	}
+	return nil
+}
+
+// checkRequestHeadersLimits checks the raw Access-Control-Request-Headers values
+// against MaxPreflightHeaderBytes and MaxPreflightHeaderTokens
+func (p *policy) checkRequestHeadersLimits(values []string) error {
+	if p.maxReqHeaderBytes <= 0 && p.maxReqHeaderTokens <= 0 {
+		return nil
+	}
+	size, tokens := 0, 0
+	for _, v := range values {
+		size += len(v)
+		tokens += strings.Count(v, ",") + 1
+	}
+	if p.maxReqHeaderBytes > 0 && size > p.maxReqHeaderBytes {
+		return &RequestHeadersLimitError{Unit: "bytes", Size: size, Max: p.maxReqHeaderBytes}
+	}
+	if p.maxReqHeaderTokens > 0 && tokens > p.maxReqHeaderTokens {
+		return &RequestHeadersLimitError{Unit: "tokens", Size: tokens, Max: p.maxReqHeaderTokens}
+	}
+	return nil
+}
+
+// evaluatePreflight checks a preflight request against the policy and computes the
+// CORS headers to add to the response if it is allowed.
+func (p *policy) evaluatePreflight(r *http.Request, origin, reqMethod, reqHeaderList string) Decision {
+	d := Decision{Preflight: true, Origin: origin, Method: strings.ToUpper(reqMethod)}
+	pattern, ok := p.matchOrigin(r, origin)
+	if !ok {
+		return d.deny(ReasonOrigin, p.originError(origin))
+	}
+	d.MatchedOrigin = pattern
+	// Both the method and the headers are checked, so that the error tells about
+	// every failed check; the reason is the first one
+	var methodErr error
+	routed := routeMethods(r)
+	if err := p.checkMethod(r, origin, reqMethod); err != nil {
+		methodErr = err
+	}
+	reqHeaders := engine.ParseHeaderList(reqHeaderList)
+	if !p.areHeadersAllowed(d.Method, reqHeaders) {
+		headersErr := p.headersError(origin, d.Method, p.deniedHeaders(d.Method, reqHeaders))
+		if methodErr != nil {
+			return d.deny(ReasonMethod, joinErrors(methodErr, headersErr))
+		}
+		return d.deny(ReasonHeaders, headersErr)
+	}
+	if methodErr != nil {
+		return d.deny(ReasonMethod, methodErr)
 	}
+	reqHeaders, forbidden := engine.FilterForbiddenHeaders(reqHeaders)
+	if len(forbidden) > 0 && p.denyForbidden {
+		return d.deny(ReasonHeaders, p.headersError(origin, d.Method, forbidden))
+	}
+	reqHeaders = sortedSet(reqHeaders)
+	headers := http.Header{}
+	p.setOriginHeaders(headers, r, origin)
 	// Spec says: Since the list of methods can be unbounded, simply returning the method indicated
 	// by Access-Control-Request-Method (if supported) can be enough
-	headers.Set("Access-Control-Allow-Methods", strings.ToUpper(reqMethod))
-	if len(reqHeaders) > 0 {
+	if routed != nil {
+		headers.Set("Access-Control-Allow-Methods", strings.Join(routed, ", "))
+	} else {
+		headers["Access-Control-Allow-Methods"] = p.allowMethodsValue(reqMethod)
+	}
+	if v, ok := p.methodAllowHeadersValues[d.Method]; ok {
+		headers["Access-Control-Allow-Headers"] = v
+	} else if p.allowHeadersValue != nil {
+		headers["Access-Control-Allow-Headers"] = p.allowHeadersValue
+	} else if len(reqHeaders) > 0 {
 
 		// Spec says: Since the list of headers can be unbounded, simply returning supported headers
 		// from Access-Control-Request-Headers can be enough
 		headers.Set("Access-Control-Allow-Headers", strings.Join(reqHeaders, ", "))
 	}
-	if c.allowCredentials {
-		headers.Set("Access-Control-Allow-Credentials", "true")
+	if maxAge := p.maxAgeHeader(r, origin); maxAge != nil {
+		headers["Access-Control-Max-Age"] = maxAge
+	}
+	d.Allowed = true
+	d.AllowedMethods = []string{strings.ToUpper(reqMethod)}
+	d.AllowedHeaders = reqHeaders
+	d.header = headers
+	return d
+}
+
+// allowMethodsValue returns the Access-Control-Allow-Methods value for a requested
+// method, precomputed for the allowed methods or listing them all in cacheable
+// responses mode
+func (p *policy) allowMethodsValue(method string) []string {
+	if p.staticAllowMethods != nil {
+		return p.staticAllowMethods
+	}
+	if v, ok := p.allowMethodsValues[method]; ok {
+		return v
+	}
+	return []string{strings.ToUpper(method)}
+}
+
+// checkHeaderBudget denies an allowed decision whose headers exceed MaxAddedHeaderBytes
+func (p *policy) checkHeaderBudget(d Decision) Decision {
+	if p.maxAddedHeaderBytes <= 0 {
+		return d
+	}
+	if size := headerSize(d.header); size > p.maxAddedHeaderBytes {
+		return d.deny(ReasonHeaderBudget, &HeaderBudgetError{Size: size, Max: p.maxAddedHeaderBytes})
 	}
-	if c.maxAge > 0 {
-		headers.Set("Access-Control-Max-Age", strconv.Itoa(c.maxAge))
+	return d
+}
+
+// WarmCache precomputes the preflight responses for every combination of the given
+// origins, methods and header lists and inserts them in the preflight cache, so
+// that the first requests after startup don't pay the matching and normalization
+// costs. Header lists are keyed the way browsers send them: lower-cased, sorted
+// and comma separated, methods are upper-cased. Combinations which are not allowed
+// are skipped. WarmCache stops when ctx is done, e.g. when the startup deadline is
+// reached, and returns its error. It does nothing if the preflight cache is
+// disabled (see Options.PreflightCacheSize).
+func (c *Cors) WarmCache(ctx context.Context, origins []string, methods []string, headers [][]string) error {
+	p := c.current()
+	if p.preflightCache == nil {
+		return nil
+	}
+	if len(headers) == 0 {
+		headers = [][]string{nil}
+	}
+	for _, origin := range origins {
+		for _, method := range methods {
+			for _, h := range headers {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				reqHeaders := strings.ToLower(strings.Join(sortedCopy(h), ","))
+				if d := p.evaluatePreflight(nil, origin, method, reqHeaders); d.Allowed {
+					p.cachePreflight(preflightKey(origin, method, reqHeaders), d)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// cachedPreflight returns the cached allowed preflight decision for key if any
+func (p *policy) cachedPreflight(key string) (Decision, bool) {
+	if p.preflightCache == nil {
+		return Decision{}, false
+	}
+	v, ok := p.preflightCache.get(key)
+	if !ok {
+		return Decision{}, false
+	}
+	return v.(Decision), true
+}
+
+// cachePreflight stores an allowed preflight decision if the cache is enabled
+func (p *policy) cachePreflight(key string, d Decision) {
+	if p.preflightCache != nil {
+		p.preflightCache.add(key, d)
 	}
-	c.logf("Preflight response headers: %v", headers)
 }
 
-// handleActualRequest handles simple cross-origin requests, actual request or redirects
-func (c *Cors) handleActualRequest(w http.ResponseWriter, r *http.Request) {
+// handleActualRequest handles simple cross-origin requests, actual request or redirects,
+// and returns their decision
+func (p *policy) handleActualRequest(w http.ResponseWriter, r *http.Request) Decision {
 	headers := w.Header()
-	origin := r.Header.Get("Origin")
 
 	// Always set Vary, see https://github.com/rs/cors/issues/10
-	headers.Add("Vary", "Origin")
+	p.addVary(headers, actualVary...)
+	if p.fetchMetadata {
+		p.addVary(headers, fetchMetadataVary...)
+	}
+
+	d := p.checkActual(r)
+	p.addVary(headers, requestVary(r)...)
+	p.report(r, d)
+	p.compareShadow(r, d)
+	p.setDebugHeader(headers, d)
+	if !d.Allowed {
+		if !p.reportOnly || d.Origin == "" {
+			return d
+		}
+		d.header = p.reportOnlyHeaders(r, d)
+	}
+	for k, v := range d.header {
+		headers[k] = v
+	}
+	p.logDecision(r, d, "Actual response added headers: %v", headers)
+	return d
+}
+
+// checkActual evaluates a simple or actual cross-origin request
+func (p *policy) checkActual(r *http.Request) Decision {
+	origin := headerValue(r.Header, "Origin")
+	d := Decision{Origin: origin, Method: r.Method}
+	if p.fetchMetadata {
+		if err := p.checkFetchMetadata(r, origin); err != nil {
+			return d.deny(ReasonFetchMetadata, err)
+		}
+	}
+	if p.unsafeOrigin {
+		if err := p.checkUnsafeOrigin(r, origin); err != nil {
+			return d.deny(ReasonUnsafeOrigin, err)
+		}
+	}
 	if origin == "" {
-		c.logf("Actual request no headers added: missing origin")
-		return
+		return d
 	}
-	if !c.isOriginAllowed(r, origin) {
-		c.logf("Actual request no headers added: origin '%s' not allowed", origin)
-		return
+	if err := checkOriginSyntax(r, origin); err != nil {
+		return d.deny(ReasonMalformedOrigin, err)
+	}
+	pattern, ok := p.matchOrigin(r, origin)
+	if !ok {
+		return d.deny(ReasonOrigin, p.originError(origin))
 	}
+	d.MatchedOrigin = pattern
 
 	// Note that spec does define a way to specifically disallow a simple method like GET or
 	// POST. Access-Control-Allow-Methods is only used for pre-flight requests and the
 	// spec doesn't instruct to check the allowed methods for simple cross-origin requests.
 	// We think it's a nice feature to be able to have control on those methods though.
-	if !c.isMethodAllowed(r.Method) {
-		c.logf("Actual request no headers added: method '%s' not allowed", r.Method)
+	if err := p.checkMethod(r, origin, r.Method); err != nil {
+		return d.deny(ReasonMethod, err)
+	}
+	if p.strictContentType {
+		if ct := r.Header.Get("Content-Type"); ct != "" && !isSafelistedContentType(ct) &&
+			!p.isHeaderAllowed(d.Method, "Content-Type") {
+			return d.deny(ReasonHeaders, p.headersError(origin, d.Method, []string{"Content-Type"}))
+		}
+	}
+	headers := http.Header{}
+	p.setOriginHeaders(headers, r, origin)
+	p.setActualHeaders(headers)
+	d.Allowed = true
+	d.AllowedMethods = []string{strings.ToUpper(r.Method)}
+	d.header = headers
+	return p.checkHeaderBudget(d)
+}
+
+// setResourcePolicy sets Cross-Origin-Resource-Policy according to rp on the
+// headers of an actual response, whose CORS headers are already set
+func setResourcePolicy(headers http.Header, rp ResourcePolicy) {
+	switch {
+	case rp == ResourcePolicyNone:
+	case rp == ResourcePolicyCrossOrigin || headers.Get("Access-Control-Allow-Origin") != "":
+		headers.Set("Cross-Origin-Resource-Policy", "cross-origin")
+	case rp == ResourcePolicySameSite:
+		headers.Set("Cross-Origin-Resource-Policy", "same-site")
+	default:
+		headers.Set("Cross-Origin-Resource-Policy", "same-origin")
+	}
+}
+
+// setActualHeaders adds the headers specific to actual responses to headers,
+// which already holds the origin headers
+func (p *policy) setActualHeaders(headers http.Header) {
+	if p.exposeHeadersValue != nil {
+		headers["Access-Control-Expose-Headers"] = p.exposeHeadersValue
+	}
+	if p.timingAllowOrigin {
+		headers["Timing-Allow-Origin"] = headers["Access-Control-Allow-Origin"]
+	}
+}
+
+// reportOnlyHeaders builds the headers of a denied request allowed in report only
+// mode, as if the origin, method and headers it asks for were all allowed
+func (p *policy) reportOnlyHeaders(r *http.Request, d Decision) http.Header {
+	if d.Reason == ReasonMalformedOrigin {
+		// Never reflect a malformed origin
+		return nil
+	}
+	headers := http.Header{}
+	p.setOriginHeaders(headers, r, d.Origin)
+	if !d.Preflight {
+		p.setActualHeaders(headers)
+		return headers
+	}
+	headers.Set("Access-Control-Allow-Methods", d.Method)
+	if d.Reason == ReasonRequestHeadersLimit || d.Reason == ReasonMalformedHeaders {
+		// Don't parse what the limits reject
+		return headers
+	}
+	reqHeaders := engine.ParseHeaderList(strings.Join(headerValues(r.Header, "Access-Control-Request-Headers"), ","))
+	if reqHeaders, _ = engine.FilterForbiddenHeaders(reqHeaders); len(reqHeaders) > 0 {
+		headers.Set("Access-Control-Allow-Headers", strings.Join(sortedSet(reqHeaders), ", "))
+	}
+	if maxAge := p.maxAgeHeader(r, d.Origin); maxAge != nil {
+		headers["Access-Control-Max-Age"] = maxAge
+	}
+	if p.allowPrivateNetwork && headerValue(r.Header, "Access-Control-Request-Private-Network") == "true" {
+		headers.Set("Access-Control-Allow-Private-Network", "true")
+	}
+	return headers
+}
+
+// maxAgeHeader returns the Access-Control-Max-Age value of a preflight from
+// origin, or nil if the header is to be omitted
+func (p *policy) maxAgeHeader(r *http.Request, origin string) []string {
+	if p.maxAgeFunc == nil {
+		return p.maxAgeValue
+	}
+	return maxAgeValue(p.maxAgeFunc(r, origin))
+}
+
+// maxAgeValue returns the Access-Control-Max-Age value for a max age in seconds:
+// nil when unset, "0" when negative to disable caching
+func maxAgeValue(maxAge int) []string {
+	switch {
+	case maxAge > 0:
+		return []string{strconv.Itoa(maxAge)}
+	case maxAge < 0:
+		return []string{"0"}
+	}
+	return nil
+}
 
+// setOriginHeaders sets the Access-Control-Allow-Origin and Access-Control-Allow-Credentials
+// headers for an allowed origin
+func (p *policy) setOriginHeaders(headers http.Header, r *http.Request, origin string) {
+	if strings.EqualFold(origin, "null") && p.allowNullOrigin {
+		// Any sandboxed document or local file can claim the null origin, so it is
+		// echoed literally but never granted credentials
+		headers.Set("Access-Control-Allow-Origin", "null")
 		return
 	}
-	if c.allowedOriginsAll {
+	if p.allowedOriginsAll {
 		headers.Set("Access-Control-Allow-Origin", "*")
 	} else {
 		headers.Set("Access-Control-Allow-Origin", origin)
 	}
-	if len(c.exposedHeaders) > 0 {
-		headers.Set("Access-Control-Expose-Headers", strings.Join(c.exposedHeaders, ", "))
+	if !p.insecureCredentials && !engine.IsSecureOrigin(origin) {
+		return
 	}
-	if c.allowCredentials {
+	if p.allowCredentials || (p.allowCredentialsFunc != nil && p.allowCredentialsFunc(r, origin)) {
 		headers.Set("Access-Control-Allow-Credentials", "true")
 	}
-	c.logf("Actual response added headers: %v", headers)
 }
 
 // convenience method. checks if a logger is set.
@@ -334,42 +1528,237 @@ func (c *Cors) logf(format string, a ...interface{}) {
 	}
 }
 
+// report logs a decision, counts it in the shared telemetry and hands denials to
+// the audit sinks and the report collector, if any
+func (p *policy) report(r *http.Request, d Decision) {
+	kind := "Actual request no headers added"
+	if d.Preflight {
+		kind = "Preflight aborted"
+	}
+	if d.Origin == "" && d.Err == nil {
+		if d.Preflight {
+			p.logf(r, "%s: empty origin", kind)
+		} else {
+			p.logf(r, "%s: missing origin", kind)
+		}
+		return
+	}
+	if p.telemetry != nil {
+		p.telemetry.record(TelemetryKey{
+			Policy:    p.name,
+			Preflight: d.Preflight,
+			Allowed:   d.Allowed,
+			Reason:    d.Reason,
+		})
+	}
+	if !d.Allowed && p.collector != nil {
+		p.collector.collectDenial(r, d)
+	}
+	// Hooks, audit and logs see the same sampled decisions
+	if !p.sampled(d) {
+		return
+	}
+	if p.onDecision != nil {
+		p.onDecision(d)
+	}
+	if !d.Allowed {
+		p.audit(r, d)
+	}
+	if d.Allowed {
+		p.logf(r, "Origin '%s' allowed by '%s'", d.Origin, d.MatchedOrigin)
+	} else {
+		p.logf(r, "%s: %v", kind, d.Err)
+	}
+	if p.logger != nil {
+		args := []interface{}{"origin", d.Origin, "method", d.Method, "preflight", d.Preflight}
+		if d.Allowed {
+			p.logger.Debug("cors: request allowed", append(args, "pattern", d.MatchedOrigin)...)
+		} else {
+			p.logger.Info("cors: request denied", append(args, "reason", d.Reason, "error", d.Err.Error())...)
+		}
+	}
+}
+
+// logf logs a line about the request r if it was drawn by LogSampleRate
+func (p *policy) logf(r *http.Request, format string, a ...interface{}) {
+	if state, ok := r.Context().Value(stateKey).(*requestState); ok && state.unlogged {
+		return
+	}
+	p.c.logf(format, a...)
+}
+
+// logDecision logs about the decision d for the request r if it was drawn by
+// LogSampleRate and d is picked by sampling.
+func (p *policy) logDecision(r *http.Request, d Decision, format string, a ...interface{}) {
+	if p.c.Log != nil && p.sampled(d) {
+		p.logf(r, format, a...)
+	}
+}
+
+// drawLog reports whether the lines about the request r are to be logged
+func (p *policy) drawLog(r *http.Request) bool {
+	return p.sample(p.logSampleRate, headerValue(r.Header, "Origin"))
+}
+
+// sampled reports whether the decision d should be reported
+func (p *policy) sampled(d Decision) bool {
+	rate := p.sampleDenials
+	if d.Allowed {
+		rate = p.sampleAllows
+	} else if reasonRate, ok := p.sampleReasons[d.Reason]; ok {
+		rate = reasonRate
+	}
+	return p.sample(rate, d.Origin)
+}
+
+// sample draws whether an event about origin is picked with the given rate
+func (p *policy) sample(rate float64, origin string) bool {
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+	if p.sampleByOrigin {
+		return hashFraction(origin) < rate
+	}
+	return rand.Float64() < rate
+}
+
 // isOriginAllowed checks if a given origin is allowed to perform cross-domain requests
 // on the endpoint
-func (c *Cors) isOriginAllowed(r *http.Request, origin string) bool {
-	if c.allowOriginFunc != nil {
-		return c.allowOriginFunc(r, origin)
+func (p *policy) isOriginAllowed(r *http.Request, origin string) bool {
+	_, ok := p.matchOrigin(r, origin)
+	return ok
+}
+
+// matchOrigin checks if a given origin is allowed and returns the rule which allowed
+// it: the matching pattern, "*" when all origins are allowed, "null" for the null
+// origin, or an empty string when allowed by AllowOriginFunc.
+func (p *policy) matchOrigin(r *http.Request, origin string) (string, bool) {
+	if p.deniedOrigins != nil && p.deniedOrigins.Match(origin) != nil {
+		return "", false
 	}
-	if c.allowedOriginsAll {
-		return true
+	if strings.EqualFold(origin, "null") {
+		return "null", p.allowNullOrigin
 	}
-	origin = strings.ToLower(origin)
-	for _, o := range c.allowedOrigins {
-		if o == origin {
-			return true
+	if p.allowLocalhost && engine.IsLocalhostOrigin(origin) {
+		return "localhost", true
+	}
+	if p.allowOriginFunc != nil {
+		return "", p.callAllowOriginFunc(r, origin)
+	}
+	if p.allowedOriginsAll {
+		return "*", true
+	}
+	if pattern, ok := p.matchStaticOrigin(origin); ok {
+		return pattern, true
+	}
+	if p.originProvider != nil {
+		ctx := context.Background()
+		if r != nil {
+			ctx = r.Context()
+		}
+		if m := p.originProvider.match(ctx, origin); m != nil {
+			return m.String(), true
 		}
 	}
-	for _, w := range c.allowedWOrigins {
-		if w.match(origin) {
-			return true
+	return "", false
+}
+
+// callAllowOriginFunc calls AllowOriginFunc, going through its cache when enabled
+func (p *policy) callAllowOriginFunc(r *http.Request, origin string) bool {
+	if p.originFuncCache == nil {
+		return p.allowOriginFunc(r, origin)
+	}
+	if allowed, ok := p.originFuncCache.get(origin); ok {
+		return allowed.(bool)
+	}
+	allowed := p.allowOriginFunc(r, origin)
+	p.originFuncCache.add(origin, allowed)
+	return allowed
+}
+
+// InvalidateOrigin drops what the caches remember about origin, so that the next
+// request from it calls AllowOriginFunc or matches the allowed origins again.
+func (c *Cors) InvalidateOrigin(origin string) {
+	p := c.current()
+	if p.originFuncCache != nil {
+		p.originFuncCache.remove(origin)
+	}
+	if p.negativeCache != nil && p.origins != nil {
+		p.negativeCache.remove(p.origins.Fingerprint() + origin)
+	}
+}
+
+// matchStaticOrigin matches an origin against AllowedOrigins and AllowedOriginsRegex,
+// going through the negative cache when enabled.
+func (p *policy) matchStaticOrigin(origin string) (string, bool) {
+	if p.negativeCache == nil {
+		if m := p.origins.Match(origin); m != nil {
+			return m.String(), true
 		}
+		return "", false
 	}
-	return false
+	// Entries are keyed by policy so that they are never reused for another one
+	key := p.origins.Fingerprint() + origin
+	if _, denied := p.negativeCache.get(key); denied {
+		atomic.AddUint64(&p.negativeCacheStats.Hits, 1)
+		return "", false
+	}
+	atomic.AddUint64(&p.negativeCacheStats.Misses, 1)
+	if m := p.origins.Match(origin); m != nil {
+		return m.String(), true
+	}
+	p.negativeCache.add(key, nil)
+	return "", false
+}
+
+// NegativeOriginCacheStats returns the hit and miss counters of the denied origins
+// cache. Counters stay at zero when the cache is disabled and are reset by
+// UpdateOptions.
+func (c *Cors) NegativeOriginCacheStats() CacheStats {
+	p := c.current()
+	if p.negativeCacheStats == nil {
+		return CacheStats{}
+	}
+	return CacheStats{
+		Hits:   atomic.LoadUint64(&p.negativeCacheStats.Hits),
+		Misses: atomic.LoadUint64(&p.negativeCacheStats.Misses),
+	}
+}
+
+// checkMethod checks method is allowed, by the route of r when known (see
+// RouteHandler) or else by AllowedMethods
+func (p *policy) checkMethod(r *http.Request, origin, method string) error {
+	if routed := routeMethods(r); routed != nil {
+		if method = strings.ToUpper(method); method == http.MethodOptions && !p.strictMethods {
+			return nil
+		}
+		if !containsString(routed, method) {
+			return &MethodNotAllowedError{Method: method, Origin: origin, Allowed: routed}
+		}
+		return nil
+	}
+	if !p.isMethodAllowed(method) {
+		return &MethodNotAllowedError{Method: method, Origin: origin, Allowed: p.allowedMethods}
+	}
+	return nil
 }
 
 // isMethodAllowed checks if a given method can be used as part of a cross-domain request
 // on the endpoint
-func (c *Cors) isMethodAllowed(method string) bool {
-	if len(c.allowedMethods) == 0 {
+func (p *policy) isMethodAllowed(method string) bool {
+	if len(p.allowedMethods) == 0 {
 		// If no method allowed, always return false, even for preflight request
 		return false
 	}
 	method = strings.ToUpper(method)
-	if method == http.MethodOptions {
+	if method == http.MethodOptions && !p.strictMethods {
 		// Always allow preflight requests
 		return true
 	}
-	for _, m := range c.allowedMethods {
+	for _, m := range p.allowedMethods {
 		if m == method {
 			return true
 		}
@@ -378,23 +1767,61 @@ func (c *Cors) isMethodAllowed(method string) bool {
 }
 
 // areHeadersAllowed checks if a given list of headers are allowed to used within
-// a cross-domain request.
-func (c *Cors) areHeadersAllowed(requestedHeaders []string) bool {
-	if c.allowedHeadersAll || len(requestedHeaders) == 0 {
+// a cross-domain request using method.
+func (p *policy) areHeadersAllowed(method string, requestedHeaders []string) bool {
+	if p.allowedHeadersAll || len(requestedHeaders) == 0 {
 		return true
 	}
 	for _, header := range requestedHeaders {
-		header = http.CanonicalHeaderKey(header)
-		found := false
-		for _, h := range c.allowedHeaders {
-			if h == header {
-				found = true
-				break
-			}
-		}
-		if !found {
+		if !p.isHeaderAllowed(method, header) {
 			return false
 		}
 	}
 	return true
 }
+
+// isHeaderAllowed checks if a header is allowed, all headers being
+func (p *policy) isHeaderAllowed(method, header string) bool {
+	if p.allowedHeadersAll {
+		return true
+	}
+	header = http.CanonicalHeaderKey(header)
+	return engine.IsSafelistedHeader(header) || containsString(p.allowedHeaders, header) ||
+		containsString(p.methodHeaders[strings.ToUpper(method)], header)
+}
+
+// headersFor returns the headers allowed in requests using method
+func (p *policy) headersFor(method string) []string {
+	if extra := p.methodHeaders[strings.ToUpper(method)]; len(extra) > 0 {
+		return sortedSet(append(append([]string(nil), p.allowedHeaders...), extra...))
+	}
+	return p.allowedHeaders
+}
+
+// deniedHeaders returns the requested headers which are not allowed with method
+func (p *policy) deniedHeaders(method string, requestedHeaders []string) []string {
+	var denied []string
+	for _, header := range requestedHeaders {
+		if !p.isHeaderAllowed(method, header) {
+			denied = append(denied, header)
+		}
+	}
+	return denied
+}
+
+// headersError returns the error denying headers to origin for method
+func (p *policy) headersError(origin, method string, headers []string) error {
+	return &HeadersNotAllowedError{Headers: headers, Origin: origin, Allowed: p.headersFor(method)}
+}
+
+// originError returns the error denying origin, naming the denied origin pattern
+// which matched if any
+func (p *policy) originError(origin string) error {
+	err := &OriginNotAllowedError{Origin: origin, Allowed: p.allowedOriginsList}
+	if p.deniedOrigins != nil {
+		if pattern := p.deniedOrigins.Match(origin); pattern != nil {
+			err.Denied = pattern.String()
+		}
+	}
+	return err
+}
@@ -1,11 +1,15 @@
 package cors
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 )
 
 var testHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -20,6 +24,9 @@ var allHeaders = []string{
 	"Access-Control-Allow-Credentials",
 	"Access-Control-Max-Age",
 	"Access-Control-Expose-Headers",
+	"Access-Control-Allow-Private-Network",
+	"Timing-Allow-Origin",
+	"Cross-Origin-Resource-Policy",
 }
 
 func assertHeaders(t *testing.T, resHeaders http.Header, expHeaders map[string]string) {
@@ -32,6 +39,20 @@ func assertHeaders(t *testing.T, resHeaders http.Header, expHeaders map[string]s
 	}
 }
 
+// assertHeaderPlacement checks preflight only headers are never sent on actual responses
+// and conversely
+func assertHeaderPlacement(t *testing.T, req *http.Request, resHeaders http.Header) {
+	misplaced := preflightOnlyHeaders
+	if req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != "" {
+		misplaced = actualOnlyHeaders
+	}
+	for _, name := range misplaced {
+		if v, ok := resHeaders[name]; ok {
+			t.Errorf("Response header %q = %q should not be sent on this response", name, v)
+		}
+	}
+}
+
 func assertResponse(t *testing.T, res *httptest.ResponseRecorder, responseCode int) {
 	if responseCode != res.Code {
 		t.Errorf("assertResponse: expected response code to be %d but got %d. ", responseCode, res.Code)
@@ -57,6 +78,28 @@ func TestSpec(t *testing.T) {
 				"Vary": "Origin",
 			},
 		},
+		{
+			"NoOriginVary",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+			},
+			"POST",
+			map[string]string{},
+			map[string]string{
+				"Vary": "Origin",
+			},
+		},
+		{
+			"NoOriginOptionsVary",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+			},
+			"OPTIONS",
+			map[string]string{},
+			map[string]string{
+				"Vary": "Origin",
+			},
+		},
 		{
 			"MatchAllOrigin",
 			Options{
@@ -79,7 +122,7 @@ func TestSpec(t *testing.T) {
 			},
 			"GET",
 			map[string]string{
-				"Origin": "http://foobar.com",
+				"Origin": "https://foobar.com",
 			},
 			map[string]string{
 				"Vary":                             "Origin",
@@ -116,389 +159,2155 @@ func TestSpec(t *testing.T) {
 			},
 		},
 		{
-			"DisallowedOrigin",
+			"WildcardPortOrigin",
 			Options{
-				AllowedOrigins: []string{"http://foobar.com"},
+				AllowedOrigins: []string{"http://localhost:*"},
 			},
 			"GET",
 			map[string]string{
-				"Origin": "http://barbaz.com",
+				"Origin": "http://localhost:5173",
 			},
 			map[string]string{
-				"Vary": "Origin",
+				"Vary":                        "Origin",
+				"Access-Control-Allow-Origin": "http://localhost:5173",
 			},
 		},
 		{
-			"DisallowedWildcardOrigin",
+			"WildcardPortOriginWithoutPort",
 			Options{
-				AllowedOrigins: []string{"http://*.bar.com"},
+				AllowedOrigins: []string{"http://localhost:*"},
 			},
 			"GET",
 			map[string]string{
-				"Origin": "http://foo.baz.com",
+				"Origin": "http://localhost",
 			},
 			map[string]string{
-				"Vary": "Origin",
+				"Vary":                        "Origin",
+				"Access-Control-Allow-Origin": "http://localhost",
 			},
 		},
 		{
-			"AllowedOriginFuncMatch",
+			"WildcardHostAndPortOrigin",
 			Options{
-				AllowOriginFunc: func(r *http.Request, o string) bool {
-					return regexp.MustCompile("^http://foo").MatchString(o) && r.Header.Get("Authorization") == "secret"
-				},
+				AllowedOrigins: []string{"http://*.bar.com:*"},
 			},
 			"GET",
 			map[string]string{
-				"Origin":        "http://foobar.com",
-				"Authorization": "secret",
+				"Origin": "http://foo.bar.com:8080",
 			},
 			map[string]string{
 				"Vary":                        "Origin",
-				"Access-Control-Allow-Origin": "http://foobar.com",
+				"Access-Control-Allow-Origin": "http://foo.bar.com:8080",
 			},
 		},
 		{
-			"AllowOriginFuncNotMatch",
+			"DisallowedWildcardPortOrigin",
 			Options{
-				AllowOriginFunc: func(r *http.Request, o string) bool {
-					return regexp.MustCompile("^http://foo").MatchString(o) && r.Header.Get("Authorization") == "secret"
-				},
+				AllowedOrigins: []string{"http://localhost:*"},
 			},
 			"GET",
 			map[string]string{
-				"Origin":        "http://foobar.com",
-				"Authorization": "not-secret",
+				"Origin": "http://localhost.evil.com:8080",
 			},
 			map[string]string{
 				"Vary": "Origin",
 			},
 		},
 		{
-			"MaxAge",
+			"NullOrigin",
 			Options{
-				AllowedOrigins: []string{"http://example.com/"},
-				AllowedMethods: []string{"GET"},
-				MaxAge:         10,
+				AllowedOrigins:   []string{"http://foobar.com"},
+				AllowNullOrigin:  true,
+				AllowCredentials: true,
+			},
+			"GET",
+			map[string]string{
+				"Origin": "null",
+			},
+			map[string]string{
+				"Vary":                        "Origin",
+				"Access-Control-Allow-Origin": "null",
+			},
+		},
+		{
+			"NullOriginPreflight",
+			Options{
+				AllowedOrigins:   []string{"*"},
+				AllowNullOrigin:  true,
+				AllowCredentials: true,
 			},
 			"OPTIONS",
 			map[string]string{
-				"Origin":                        "http://example.com/",
+				"Origin":                        "null",
 				"Access-Control-Request-Method": "GET",
 			},
 			map[string]string{
 				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
-				"Access-Control-Allow-Origin":  "http://example.com/",
+				"Access-Control-Allow-Origin":  "null",
 				"Access-Control-Allow-Methods": "GET",
-				"Access-Control-Max-Age":       "10",
 			},
 		},
 		{
-			"AllowedMethod",
+			"DisallowedNullOrigin",
 			Options{
 				AllowedOrigins: []string{"http://foobar.com"},
-				AllowedMethods: []string{"PUT", "DELETE"},
 			},
-			"OPTIONS",
+			"GET",
 			map[string]string{
-				"Origin":                        "http://foobar.com",
-				"Access-Control-Request-Method": "PUT",
+				"Origin": "null",
 			},
 			map[string]string{
-				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
-				"Access-Control-Allow-Origin":  "http://foobar.com",
-				"Access-Control-Allow-Methods": "PUT",
+				"Vary": "Origin",
 			},
 		},
 		{
-			"DisallowedMethod",
+			"NullOriginNotMatchedByAll",
 			Options{
-				AllowedOrigins: []string{"http://foobar.com"},
-				AllowedMethods: []string{"PUT", "DELETE"},
+				AllowedOrigins: []string{"*"},
 			},
-			"OPTIONS",
+			"GET",
 			map[string]string{
-				"Origin":                        "http://foobar.com",
-				"Access-Control-Request-Method": "PATCH",
+				"Origin": "null",
 			},
 			map[string]string{
-				"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Vary": "Origin",
 			},
 		},
 		{
-			"AllowedHeaders",
+			"NullOriginNotMatchedByList",
 			Options{
-				AllowedOrigins: []string{"http://foobar.com"},
-				AllowedHeaders: []string{"X-Header-1", "x-header-2"},
+				AllowedOrigins: []string{"null"},
 			},
-			"OPTIONS",
+			"GET",
 			map[string]string{
-				"Origin":                         "http://foobar.com",
-				"Access-Control-Request-Method":  "GET",
-				"Access-Control-Request-Headers": "X-Header-2, X-HEADER-1",
+				"Origin": "null",
 			},
 			map[string]string{
-				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
-				"Access-Control-Allow-Origin":  "http://foobar.com",
-				"Access-Control-Allow-Methods": "GET",
-				"Access-Control-Allow-Headers": "X-Header-2, X-Header-1",
+				"Vary": "Origin",
 			},
 		},
 		{
-			"DefaultAllowedHeaders",
+			"NullOriginNotMatchedByFunc",
 			Options{
-				AllowedOrigins: []string{"http://foobar.com"},
-				AllowedHeaders: []string{},
+				AllowOriginFunc: func(r *http.Request, origin string) bool { return true },
 			},
-			"OPTIONS",
+			"GET",
 			map[string]string{
-				"Origin":                         "http://foobar.com",
-				"Access-Control-Request-Method":  "GET",
-				"Access-Control-Request-Headers": "Content-Type",
+				"Origin": "null",
 			},
 			map[string]string{
-				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
-				"Access-Control-Allow-Origin":  "http://foobar.com",
-				"Access-Control-Allow-Methods": "GET",
-				"Access-Control-Allow-Headers": "Content-Type",
+				"Vary": "Origin",
 			},
 		},
 		{
-			"AllowedWildcardHeader",
+			"MalformedOriginPath",
 			Options{
-				AllowedOrigins: []string{"http://foobar.com"},
-				AllowedHeaders: []string{"*"},
+				AllowedOrigins: []string{"*"},
 			},
-			"OPTIONS",
+			"GET",
 			map[string]string{
-				"Origin":                         "http://foobar.com",
-				"Access-Control-Request-Method":  "GET",
-				"Access-Control-Request-Headers": "X-Header-2, X-HEADER-1",
+				"Origin": "http://foobar.com/path",
 			},
 			map[string]string{
-				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
-				"Access-Control-Allow-Origin":  "http://foobar.com",
-				"Access-Control-Allow-Methods": "GET",
-				"Access-Control-Allow-Headers": "X-Header-2, X-Header-1",
+				"Vary": "Origin",
 			},
 		},
 		{
-			"DisallowedHeader",
+			"MalformedOriginPreflight",
 			Options{
-				AllowedOrigins: []string{"http://foobar.com"},
-				AllowedHeaders: []string{"X-Header-1", "x-header-2"},
+				AllowOriginFunc: func(r *http.Request, origin string) bool { return true },
 			},
 			"OPTIONS",
 			map[string]string{
-				"Origin":                         "http://foobar.com",
-				"Access-Control-Request-Method":  "GET",
-				"Access-Control-Request-Headers": "X-Header-3, X-Header-1",
+				"Origin":                        "http://user@foobar.com",
+				"Access-Control-Request-Method": "GET",
 			},
 			map[string]string{
 				"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
 			},
 		},
 		{
-			"OriginHeader",
+			"CanonicalOriginDefaultPort",
 			Options{
-				AllowedOrigins: []string{"http://foobar.com"},
+				AllowedOrigins: []string{"https://EXAMPLE.com"},
 			},
-			"OPTIONS",
+			"GET",
 			map[string]string{
-				"Origin":                         "http://foobar.com",
-				"Access-Control-Request-Method":  "GET",
-				"Access-Control-Request-Headers": "origin",
+				"Origin": "https://example.com:443",
 			},
 			map[string]string{
-				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
-				"Access-Control-Allow-Origin":  "http://foobar.com",
-				"Access-Control-Allow-Methods": "GET",
-				"Access-Control-Allow-Headers": "Origin",
+				"Vary":                        "Origin",
+				"Access-Control-Allow-Origin": "https://example.com:443",
 			},
 		},
 		{
-			"ExposedHeader",
+			"CanonicalOriginIDN",
 			Options{
-				AllowedOrigins: []string{"http://foobar.com"},
-				ExposedHeaders: []string{"X-Header-1", "x-header-2"},
+				AllowedOrigins: []string{"https://*.bücher.example"},
 			},
 			"GET",
 			map[string]string{
-				"Origin": "http://foobar.com",
+				"Origin": "https://shop.xn--bcher-kva.example",
 			},
 			map[string]string{
-				"Vary":                          "Origin",
-				"Access-Control-Allow-Origin":   "http://foobar.com",
-				"Access-Control-Expose-Headers": "X-Header-1, X-Header-2",
+				"Vary":                        "Origin",
+				"Access-Control-Allow-Origin": "https://shop.xn--bcher-kva.example",
 			},
 		},
 		{
-			"AllowedCredentials",
+			"ExtensionOrigin",
 			Options{
-				AllowedOrigins:   []string{"http://foobar.com"},
+				AllowedOrigins:   []string{"chrome-extension://*", "capacitor://localhost"},
 				AllowCredentials: true,
 			},
-			"OPTIONS",
+			"GET",
 			map[string]string{
-				"Origin":                        "http://foobar.com",
-				"Access-Control-Request-Method": "GET",
+				"Origin": "chrome-extension://nkbihfbeogaeaoehlefnkodbefgpgknn",
 			},
 			map[string]string{
-				"Vary":                             "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
-				"Access-Control-Allow-Origin":      "http://foobar.com",
-				"Access-Control-Allow-Methods":     "GET",
+				"Vary":                             "Origin",
+				"Access-Control-Allow-Origin":      "chrome-extension://nkbihfbeogaeaoehlefnkodbefgpgknn",
 				"Access-Control-Allow-Credentials": "true",
 			},
 		},
 		{
-			"OptionPassthrough",
+			"AppSchemeOrigin",
 			Options{
-				OptionsPassthrough: true,
+				AllowedOrigins: []string{"chrome-extension://*", "capacitor://localhost"},
 			},
-			"OPTIONS",
+			"GET",
 			map[string]string{
-				"Origin":                        "http://foobar.com",
-				"Access-Control-Request-Method": "GET",
+				"Origin": "capacitor://localhost",
 			},
 			map[string]string{
-				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
-				"Access-Control-Allow-Origin":  "*",
-				"Access-Control-Allow-Methods": "GET",
+				"Vary":                        "Origin",
+				"Access-Control-Allow-Origin": "capacitor://localhost",
 			},
 		},
 		{
-			"NonPreflightOptions",
+			"AppSchemeOriginOtherScheme",
+			Options{
+				AllowedOrigins: []string{"chrome-extension://*", "capacitor://localhost"},
+			},
+			"GET",
+			map[string]string{
+				"Origin": "moz-extension://abc",
+			},
+			map[string]string{
+				"Vary": "Origin",
+			},
+		},
+		{
+			"RegexOrigin",
+			Options{
+				AllowedOriginsRegex: []string{`https://pr-\d+\.preview\.example\.com`},
+			},
+			"GET",
+			map[string]string{
+				"Origin": "https://pr-42.preview.example.com",
+			},
+			map[string]string{
+				"Vary":                        "Origin",
+				"Access-Control-Allow-Origin": "https://pr-42.preview.example.com",
+			},
+		},
+		{
+			"DisallowedRegexOrigin",
+			Options{
+				AllowedOriginsRegex: []string{`https://pr-\d+\.preview\.example\.com`},
+			},
+			"GET",
+			map[string]string{
+				"Origin": "https://pr-42.preview.example.com.evil.com",
+			},
+			map[string]string{
+				"Vary": "Origin",
+			},
+		},
+		{
+			"DisallowedOrigin",
 			Options{
 				AllowedOrigins: []string{"http://foobar.com"},
 			},
-			"OPTIONS",
+			"GET",
 			map[string]string{
-				"Origin": "http://foobar.com",
+				"Origin": "http://barbaz.com",
+			},
+			map[string]string{
+				"Vary": "Origin",
+			},
+		},
+		{
+			"DisallowedWildcardOrigin",
+			Options{
+				AllowedOrigins: []string{"http://*.bar.com"},
+			},
+			"GET",
+			map[string]string{
+				"Origin": "http://foo.baz.com",
+			},
+			map[string]string{
+				"Vary": "Origin",
+			},
+		},
+		{
+			"AllowedOriginFuncMatch",
+			Options{
+				AllowOriginFunc: func(r *http.Request, o string) bool {
+					return regexp.MustCompile("^http://foo").MatchString(o) && r.Header.Get("Authorization") == "secret"
+				},
+			},
+			"GET",
+			map[string]string{
+				"Origin":        "http://foobar.com",
+				"Authorization": "secret",
 			},
 			map[string]string{
 				"Vary":                        "Origin",
 				"Access-Control-Allow-Origin": "http://foobar.com",
 			},
 		},
-	}
-	for i := range cases {
-		tc := cases[i]
-		t.Run(tc.name, func(t *testing.T) {
-			s := New(tc.options)
-
-			req, _ := http.NewRequest(tc.method, "http://example.com/foo", nil)
-			for name, value := range tc.reqHeaders {
-				req.Header.Add(name, value)
-			}
-
-			t.Run("Handler", func(t *testing.T) {
-				res := httptest.NewRecorder()
-				s.Handler(testHandler).ServeHTTP(res, req)
-				assertHeaders(t, res.Header(), tc.resHeaders)
-			})
-		})
-	}
-}
-
+		{
+			"AllowOriginFuncNotMatch",
+			Options{
+				AllowOriginFunc: func(r *http.Request, o string) bool {
+					return regexp.MustCompile("^http://foo").MatchString(o) && r.Header.Get("Authorization") == "secret"
+				},
+			},
+			"GET",
+			map[string]string{
+				"Origin":        "http://foobar.com",
+				"Authorization": "not-secret",
+			},
+			map[string]string{
+				"Vary": "Origin",
+			},
+		},
+		{
+			"MaxAge",
+			Options{
+				AllowedOrigins: []string{"http://example.com"},
+				AllowedMethods: []string{"GET"},
+				MaxAge:         10,
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                        "http://example.com",
+				"Access-Control-Request-Method": "GET",
+			},
+			map[string]string{
+				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "http://example.com",
+				"Access-Control-Allow-Methods": "GET",
+				"Access-Control-Max-Age":       "10",
+			},
+		},
+		{
+			"MaxAgeDisabled",
+			Options{
+				AllowedOrigins: []string{"https://example.com"},
+				MaxAge:         -1,
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                        "https://example.com",
+				"Access-Control-Request-Method": "GET",
+			},
+			map[string]string{
+				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "https://example.com",
+				"Access-Control-Allow-Methods": "GET",
+				"Access-Control-Max-Age":       "0",
+			},
+		},
+		{
+			"MaxAgeDuration",
+			Options{
+				AllowedOrigins: []string{"https://example.com"},
+				MaxAge:         10,
+				MaxAgeDuration: 2*time.Hour + 500*time.Millisecond,
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                        "https://example.com",
+				"Access-Control-Request-Method": "GET",
+			},
+			map[string]string{
+				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "https://example.com",
+				"Access-Control-Allow-Methods": "GET",
+				"Access-Control-Max-Age":       "7200",
+			},
+		},
+		{
+			"MaxAgeDurationDisabled",
+			Options{
+				AllowedOrigins: []string{"https://example.com"},
+				MaxAgeDuration: -time.Second,
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                        "https://example.com",
+				"Access-Control-Request-Method": "GET",
+			},
+			map[string]string{
+				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "https://example.com",
+				"Access-Control-Allow-Methods": "GET",
+				"Access-Control-Max-Age":       "0",
+			},
+		},
+		{
+			"MaxAgeFunc",
+			Options{
+				AllowedOrigins: []string{"https://example.com", "https://*.preview.example.com"},
+				MaxAge:         10,
+				MaxAgeFunc: func(r *http.Request, origin string) int {
+					if strings.HasSuffix(origin, ".preview.example.com") {
+						return 0
+					}
+					return 7200
+				},
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                        "https://example.com",
+				"Access-Control-Request-Method": "GET",
+			},
+			map[string]string{
+				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "https://example.com",
+				"Access-Control-Allow-Methods": "GET",
+				"Access-Control-Max-Age":       "7200",
+			},
+		},
+		{
+			"MaxAgeFuncOmitted",
+			Options{
+				AllowedOrigins: []string{"https://example.com", "https://*.preview.example.com"},
+				MaxAge:         10,
+				MaxAgeFunc: func(r *http.Request, origin string) int {
+					if strings.HasSuffix(origin, ".preview.example.com") {
+						return 0
+					}
+					return 7200
+				},
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                        "https://pr-1.preview.example.com",
+				"Access-Control-Request-Method": "GET",
+			},
+			map[string]string{
+				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "https://pr-1.preview.example.com",
+				"Access-Control-Allow-Methods": "GET",
+			},
+		},
+		{
+			"AllowedMethod",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+				AllowedMethods: []string{"PUT", "DELETE"},
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                        "http://foobar.com",
+				"Access-Control-Request-Method": "PUT",
+			},
+			map[string]string{
+				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "http://foobar.com",
+				"Access-Control-Allow-Methods": "PUT",
+			},
+		},
+		{
+			"DisallowedMethod",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+				AllowedMethods: []string{"PUT", "DELETE"},
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                        "http://foobar.com",
+				"Access-Control-Request-Method": "PATCH",
+			},
+			map[string]string{
+				"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+			},
+		},
+		{
+			"AllowedHeaders",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+				AllowedHeaders: []string{"X-Header-1", "x-header-2"},
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                         "http://foobar.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "X-Header-2, X-HEADER-1",
+			},
+			map[string]string{
+				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "http://foobar.com",
+				"Access-Control-Allow-Methods": "GET",
+				"Access-Control-Allow-Headers": "X-Header-1, X-Header-2",
+			},
+		},
+		{
+			"DefaultAllowedHeaders",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+				AllowedHeaders: []string{},
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                         "http://foobar.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "Content-Type",
+			},
+			map[string]string{
+				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "http://foobar.com",
+				"Access-Control-Allow-Methods": "GET",
+				"Access-Control-Allow-Headers": "Content-Type",
+			},
+		},
+		{
+			"AllowedWildcardHeader",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+				AllowedHeaders: []string{"*"},
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                         "http://foobar.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "X-Header-2, X-HEADER-1",
+			},
+			map[string]string{
+				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "http://foobar.com",
+				"Access-Control-Allow-Methods": "GET",
+				"Access-Control-Allow-Headers": "X-Header-1, X-Header-2",
+			},
+		},
+		{
+			"AllowedWildcardHeaderForbidden",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+				AllowedHeaders: []string{"*"},
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                         "http://foobar.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "x-header-1, cookie, :authority, sec-fetch-mode, proxy-authorization",
+			},
+			map[string]string{
+				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "http://foobar.com",
+				"Access-Control-Allow-Methods": "GET",
+				"Access-Control-Allow-Headers": "X-Header-1",
+			},
+		},
+		{
+			"DenyForbiddenHeaders",
+			Options{
+				AllowedOrigins:       []string{"http://foobar.com"},
+				AllowedHeaders:       []string{"*"},
+				DenyForbiddenHeaders: true,
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                         "http://foobar.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "x-header-1, host",
+			},
+			map[string]string{
+				"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+			},
+		},
+		{
+			"AutoAllowAuthHeaders",
+			Options{
+				AllowedOrigins:       []string{"https://foobar.com"},
+				AllowedHeaders:       []string{"X-Header-1"},
+				AllowCredentials:     true,
+				AutoAllowAuthHeaders: true,
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                         "https://foobar.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "authorization, x-header-1",
+			},
+			map[string]string{
+				"Vary":                             "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":      "https://foobar.com",
+				"Access-Control-Allow-Methods":     "GET",
+				"Access-Control-Allow-Headers":     "Authorization, X-Header-1",
+				"Access-Control-Allow-Credentials": "true",
+			},
+		},
+		{
+			"AutoAllowAuthHeadersWithoutCredentials",
+			Options{
+				AllowedOrigins:       []string{"http://foobar.com"},
+				AutoAllowAuthHeaders: true,
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                         "http://foobar.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "authorization",
+			},
+			map[string]string{
+				"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+			},
+		},
+		{
+			"StrictMethodCheckActualOptions",
+			Options{
+				AllowedOrigins:    []string{"http://foobar.com"},
+				StrictMethodCheck: true,
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin": "http://foobar.com",
+			},
+			map[string]string{
+				"Vary": "Origin",
+			},
+		},
+		{
+			"StrictMethodCheckPreflightOptions",
+			Options{
+				AllowedOrigins:    []string{"http://foobar.com"},
+				StrictMethodCheck: true,
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                        "http://foobar.com",
+				"Access-Control-Request-Method": "OPTIONS",
+			},
+			map[string]string{
+				"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+			},
+		},
+		{
+			"DeniedOrigin",
+			Options{
+				AllowedOrigins: []string{"https://*.example.com"},
+				DeniedOrigins:  []string{"https://legacy.example.com"},
+			},
+			"GET",
+			map[string]string{
+				"Origin": "https://legacy.example.com",
+			},
+			map[string]string{
+				"Vary": "Origin",
+			},
+		},
+		{
+			"DeniedOriginOtherAllowed",
+			Options{
+				AllowedOrigins: []string{"https://*.example.com"},
+				DeniedOrigins:  []string{"https://legacy.example.com"},
+			},
+			"GET",
+			map[string]string{
+				"Origin": "https://app.example.com",
+			},
+			map[string]string{
+				"Vary":                        "Origin",
+				"Access-Control-Allow-Origin": "https://app.example.com",
+			},
+		},
+		{
+			"DeniedOriginWildcardWithAllowAll",
+			Options{
+				DeniedOrigins: []string{"http://*.evil.com"},
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                        "http://www.evil.com",
+				"Access-Control-Request-Method": "GET",
+			},
+			map[string]string{
+				"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+			},
+		},
+		{
+			"AllowedHeadersStatic",
+			Options{
+				AllowedOrigins:             []string{"http://foobar.com"},
+				AllowedHeaders:             []string{"X-Header-1", "x-header-2"},
+				AllowedHeadersResponseMode: AllowedHeadersStatic,
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                         "http://foobar.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "X-Header-2",
+			},
+			map[string]string{
+				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "http://foobar.com",
+				"Access-Control-Allow-Methods": "GET",
+				"Access-Control-Allow-Headers": "Origin, X-Header-1, X-Header-2",
+			},
+		},
+		{
+			"AllowedHeadersStaticWildcard",
+			Options{
+				AllowedOrigins:             []string{"http://foobar.com"},
+				AllowedHeaders:             []string{"*"},
+				AllowedHeadersResponseMode: AllowedHeadersStatic,
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                        "http://foobar.com",
+				"Access-Control-Request-Method": "GET",
+			},
+			map[string]string{
+				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "http://foobar.com",
+				"Access-Control-Allow-Methods": "GET",
+				"Access-Control-Allow-Headers": "*",
+			},
+		},
+		{
+			"AllowedHeadersStaticWildcardCredentials",
+			Options{
+				AllowedOrigins:             []string{"https://foobar.com"},
+				AllowedHeaders:             []string{"*"},
+				AllowCredentials:           true,
+				AllowedHeadersResponseMode: AllowedHeadersStatic,
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                         "https://foobar.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "X-Header-1",
+			},
+			map[string]string{
+				"Vary":                             "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":      "https://foobar.com",
+				"Access-Control-Allow-Methods":     "GET",
+				"Access-Control-Allow-Headers":     "X-Header-1",
+				"Access-Control-Allow-Credentials": "true",
+			},
+		},
+		{
+			"SafelistedHeaders",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+				AllowedHeaders: []string{"X-Header-1"},
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                         "http://foobar.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "accept-language,x-header-1",
+			},
+			map[string]string{
+				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "http://foobar.com",
+				"Access-Control-Allow-Methods": "GET",
+				"Access-Control-Allow-Headers": "Accept-Language, X-Header-1",
+			},
+		},
+		{
+			"StrictContentTypeSafelisted",
+			Options{
+				AllowedOrigins:    []string{"http://foobar.com"},
+				AllowedHeaders:    []string{"X-Header-1"},
+				StrictContentType: true,
+			},
+			"POST",
+			map[string]string{
+				"Origin":       "http://foobar.com",
+				"Content-Type": "text/plain; charset=utf-8",
+			},
+			map[string]string{
+				"Vary":                        "Origin",
+				"Access-Control-Allow-Origin": "http://foobar.com",
+			},
+		},
+		{
+			"StrictContentTypeDenied",
+			Options{
+				AllowedOrigins:    []string{"http://foobar.com"},
+				AllowedHeaders:    []string{"X-Header-1"},
+				StrictContentType: true,
+			},
+			"POST",
+			map[string]string{
+				"Origin":       "http://foobar.com",
+				"Content-Type": "application/json",
+			},
+			map[string]string{
+				"Vary": "Origin",
+			},
+		},
+		{
+			"StrictContentTypeAllowed",
+			Options{
+				AllowedOrigins:    []string{"http://foobar.com"},
+				StrictContentType: true,
+			},
+			"POST",
+			map[string]string{
+				"Origin":       "http://foobar.com",
+				"Content-Type": "application/json",
+			},
+			map[string]string{
+				"Vary":                        "Origin",
+				"Access-Control-Allow-Origin": "http://foobar.com",
+			},
+		},
+		{
+			"DeduplicatedHeaderValues",
+			Options{
+				AllowedOrigins:     []string{"http://foobar.com"},
+				AllowedMethods:     []string{"GET", "get", "PUT", "GET"},
+				AllowedHeaders:     []string{"X-Header-2", "x-header-1", "X-Header-2"},
+				ExposedHeaders:     []string{"X-Exposed-B", "x-exposed-a", "X-Exposed-B"},
+				CacheableResponses: true,
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                         "http://foobar.com",
+				"Access-Control-Request-Method":  "PUT",
+				"Access-Control-Request-Headers": "x-header-2,x-header-1,x-header-2",
+			},
+			map[string]string{
+				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "http://foobar.com",
+				"Access-Control-Allow-Methods": "GET, PUT",
+				"Access-Control-Allow-Headers": "Origin, X-Header-1, X-Header-2",
+			},
+		},
+		{
+			"DeduplicatedExposedHeaders",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+				ExposedHeaders: []string{"X-Exposed-B", "x-exposed-a", "X-Exposed-B"},
+			},
+			"GET",
+			map[string]string{
+				"Origin": "http://foobar.com",
+			},
+			map[string]string{
+				"Vary":                          "Origin",
+				"Access-Control-Allow-Origin":   "http://foobar.com",
+				"Access-Control-Expose-Headers": "X-Exposed-A, X-Exposed-B",
+			},
+		},
+		{
+			"DisallowedHeader",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+				AllowedHeaders: []string{"X-Header-1", "x-header-2"},
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                         "http://foobar.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "X-Header-3, X-Header-1",
+			},
+			map[string]string{
+				"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+			},
+		},
+		{
+			"OriginHeader",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                         "http://foobar.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "origin",
+			},
+			map[string]string{
+				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "http://foobar.com",
+				"Access-Control-Allow-Methods": "GET",
+				"Access-Control-Allow-Headers": "Origin",
+			},
+		},
+		{
+			"ExposedHeader",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+				ExposedHeaders: []string{"X-Header-1", "x-header-2"},
+			},
+			"GET",
+			map[string]string{
+				"Origin": "http://foobar.com",
+			},
+			map[string]string{
+				"Vary":                          "Origin",
+				"Access-Control-Allow-Origin":   "http://foobar.com",
+				"Access-Control-Expose-Headers": "X-Header-1, X-Header-2",
+			},
+		},
+		{
+			"TimingAllowOrigin",
+			Options{
+				AllowedOrigins:        []string{"http://foobar.com"},
+				EmitTimingAllowOrigin: true,
+			},
+			"GET",
+			map[string]string{
+				"Origin": "http://foobar.com",
+			},
+			map[string]string{
+				"Vary":                        "Origin",
+				"Access-Control-Allow-Origin": "http://foobar.com",
+				"Timing-Allow-Origin":         "http://foobar.com",
+			},
+		},
+		{
+			"TimingAllowOriginAll",
+			Options{
+				AllowedOrigins:        []string{"*"},
+				EmitTimingAllowOrigin: true,
+			},
+			"GET",
+			map[string]string{
+				"Origin": "http://foobar.com",
+			},
+			map[string]string{
+				"Vary":                        "Origin",
+				"Access-Control-Allow-Origin": "*",
+				"Timing-Allow-Origin":         "*",
+			},
+		},
+		{
+			"ResourcePolicyAllowedOrigin",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+				ResourcePolicy: ResourcePolicySameSite,
+			},
+			"GET",
+			map[string]string{
+				"Origin": "http://foobar.com",
+			},
+			map[string]string{
+				"Vary":                         "Origin",
+				"Access-Control-Allow-Origin":  "http://foobar.com",
+				"Cross-Origin-Resource-Policy": "cross-origin",
+			},
+		},
+		{
+			"ResourcePolicyDisallowedOrigin",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+				ResourcePolicy: ResourcePolicySameSite,
+			},
+			"GET",
+			map[string]string{
+				"Origin": "http://barbaz.com",
+			},
+			map[string]string{
+				"Vary":                         "Origin",
+				"Cross-Origin-Resource-Policy": "same-site",
+			},
+		},
+		{
+			"ResourcePolicyNoOrigin",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+				ResourcePolicy: ResourcePolicySameOrigin,
+			},
+			"GET",
+			map[string]string{},
+			map[string]string{
+				"Vary":                         "Origin",
+				"Cross-Origin-Resource-Policy": "same-origin",
+			},
+		},
+		{
+			"TimingAllowOriginPreflight",
+			Options{
+				AllowedOrigins:        []string{"http://foobar.com"},
+				EmitTimingAllowOrigin: true,
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                        "http://foobar.com",
+				"Access-Control-Request-Method": "GET",
+			},
+			map[string]string{
+				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "http://foobar.com",
+				"Access-Control-Allow-Methods": "GET",
+			},
+		},
+		{
+			"AllowedCredentials",
+			Options{
+				AllowedOrigins:   []string{"https://foobar.com"},
+				AllowCredentials: true,
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                        "https://foobar.com",
+				"Access-Control-Request-Method": "GET",
+			},
+			map[string]string{
+				"Vary":                             "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":      "https://foobar.com",
+				"Access-Control-Allow-Methods":     "GET",
+				"Access-Control-Allow-Credentials": "true",
+			},
+		},
+		{
+			"AllowCredentialsFuncFirstParty",
+			Options{
+				AllowedOrigins: []string{"https://app.com", "https://partner.com"},
+				AllowCredentialsFunc: func(r *http.Request, origin string) bool {
+					return origin == "https://app.com"
+				},
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                        "https://app.com",
+				"Access-Control-Request-Method": "GET",
+			},
+			map[string]string{
+				"Vary":                             "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":      "https://app.com",
+				"Access-Control-Allow-Methods":     "GET",
+				"Access-Control-Allow-Credentials": "true",
+			},
+		},
+		{
+			"AllowCredentialsFuncPartner",
+			Options{
+				AllowedOrigins: []string{"https://app.com", "https://partner.com"},
+				AllowCredentialsFunc: func(r *http.Request, origin string) bool {
+					return origin == "https://app.com"
+				},
+			},
+			"GET",
+			map[string]string{
+				"Origin": "https://partner.com",
+			},
+			map[string]string{
+				"Vary":                        "Origin",
+				"Access-Control-Allow-Origin": "https://partner.com",
+			},
+		},
+		{
+			"AllowedCredentialsInsecureOrigin",
+			Options{
+				AllowedOrigins:   []string{"http://foobar.com"},
+				AllowCredentials: true,
+			},
+			"GET",
+			map[string]string{
+				"Origin": "http://foobar.com",
+			},
+			map[string]string{
+				"Vary":                        "Origin",
+				"Access-Control-Allow-Origin": "http://foobar.com",
+			},
+		},
+		{
+			"AllowedCredentialsLocalhost",
+			Options{
+				AllowedOrigins:   []string{"http://localhost:*"},
+				AllowCredentials: true,
+			},
+			"GET",
+			map[string]string{
+				"Origin": "http://localhost:3000",
+			},
+			map[string]string{
+				"Vary":                             "Origin",
+				"Access-Control-Allow-Origin":      "http://localhost:3000",
+				"Access-Control-Allow-Credentials": "true",
+			},
+		},
+		{
+			"AllowInsecureCredentials",
+			Options{
+				AllowedOrigins:           []string{"http://foobar.com"},
+				AllowCredentials:         true,
+				AllowInsecureCredentials: true,
+			},
+			"GET",
+			map[string]string{
+				"Origin": "http://foobar.com",
+			},
+			map[string]string{
+				"Vary":                             "Origin",
+				"Access-Control-Allow-Origin":      "http://foobar.com",
+				"Access-Control-Allow-Credentials": "true",
+			},
+		},
+		{
+			"AllowedPrivateNetwork",
+			Options{
+				AllowedOrigins:      []string{"http://foobar.com"},
+				AllowPrivateNetwork: true,
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                                 "http://foobar.com",
+				"Access-Control-Request-Method":          "GET",
+				"Access-Control-Request-Private-Network": "true",
+			},
+			map[string]string{
+				"Vary":                                 "Origin, Access-Control-Request-Method, Access-Control-Request-Headers, Access-Control-Request-Private-Network",
+				"Access-Control-Allow-Origin":          "http://foobar.com",
+				"Access-Control-Allow-Methods":         "GET",
+				"Access-Control-Allow-Private-Network": "true",
+			},
+		},
+		{
+			"DisallowedPrivateNetwork",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                                 "http://foobar.com",
+				"Access-Control-Request-Method":          "GET",
+				"Access-Control-Request-Private-Network": "true",
+			},
+			map[string]string{
+				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "http://foobar.com",
+				"Access-Control-Allow-Methods": "GET",
+			},
+		},
+		{
+			"OptionPassthrough",
+			Options{
+				OptionsPassthrough: true,
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                        "http://foobar.com",
+				"Access-Control-Request-Method": "GET",
+			},
+			map[string]string{
+				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "*",
+				"Access-Control-Allow-Methods": "GET",
+			},
+		},
+		{
+			"NonPreflightOptions",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin": "http://foobar.com",
+			},
+			map[string]string{
+				"Vary":                        "Origin",
+				"Access-Control-Allow-Origin": "http://foobar.com",
+			},
+		},
+	}
+	for i := range cases {
+		tc := cases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			s := New(tc.options)
+
+			req, _ := http.NewRequest(tc.method, "http://example.com/foo", nil)
+			for name, value := range tc.reqHeaders {
+				req.Header.Add(name, value)
+			}
+
+			t.Run("Handler", func(t *testing.T) {
+				res := httptest.NewRecorder()
+				s.Handler(testHandler).ServeHTTP(res, req)
+				assertHeaders(t, res.Header(), tc.resHeaders)
+				assertHeaderPlacement(t, req, res.Header())
+			})
+
+			// Check and Handler must agree on every header but Vary and
+			// Cross-Origin-Resource-Policy, which Handler adds unconditionally
+			t.Run("Check", func(t *testing.T) {
+				res := httptest.NewRecorder()
+				s.Handler(testHandler).ServeHTTP(res, req)
+				d := s.Check(req)
+				for _, name := range allHeaders[1 : len(allHeaders)-1] {
+					got, want := d.header[name], res.Header()[name]
+					if !reflect.DeepEqual(got, want) {
+						t.Errorf("Check header %q = %q, Handler sent %q", name, got, want)
+					}
+				}
+				if allowed := res.Header().Get("Access-Control-Allow-Origin") != ""; d.Allowed != allowed {
+					t.Errorf("Check allowed = %v, Handler allowed = %v", d.Allowed, allowed)
+				}
+			})
+		})
+	}
+}
+
 func TestDebug(t *testing.T) {
 	s := New(Options{
-		Debug: true,
+		Debug: true,
+	})
+
+	if s.Log == nil {
+		t.Error("Logger not created when debug=true")
+	}
+}
+
+func TestDefault(t *testing.T) {
+	s := New(Options{})
+	if s.Log != nil {
+		t.Error("c.log should be nil when Default")
+	}
+	if !s.current().allowedOriginsAll {
+		t.Error("c.allowedOriginsAll should be true when Default")
+	}
+	if s.current().allowedHeaders == nil {
+		t.Error("c.allowedHeaders must not be nil when Default")
+	}
+	if s.current().allowedMethods == nil {
+		t.Error("c.allowedMethods must not be nil when Default")
+	}
+}
+
+func TestHandlePreflightInvalidOriginAbortion(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"http://foo.com"},
+	})
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "http://example.com/")
+
+	s.current().handlePreflight(res, req)
+
+	assertHeaders(t, res.Header(), map[string]string{
+		"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+	})
+}
+
+func TestHandlePreflightNoOptionsAbortion(t *testing.T) {
+	s := New(Options{
+		// Intentionally left blank.
+	})
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+
+	s.current().handlePreflight(res, req)
+
+	assertHeaders(t, res.Header(), map[string]string{})
+}
+
+func TestHandleActualRequestInvalidOriginAbortion(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"http://foo.com"},
+	})
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "http://example.com/")
+
+	s.current().handleActualRequest(res, req)
+
+	assertHeaders(t, res.Header(), map[string]string{
+		"Vary": "Origin",
+	})
+}
+
+func TestHandleActualRequestInvalidMethodAbortion(t *testing.T) {
+	s := New(Options{
+		AllowedMethods:   []string{"POST"},
+		AllowCredentials: true,
+	})
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "http://example.com/")
+
+	s.current().handleActualRequest(res, req)
+
+	assertHeaders(t, res.Header(), map[string]string{
+		"Vary": "Origin",
+	})
+}
+
+func TestIsMethodAllowedReturnsFalseWithNoMethods(t *testing.T) {
+	s := New(Options{
+		// Intentionally left blank.
+	})
+	s.current().allowedMethods = []string{}
+	if s.current().isMethodAllowed("") {
+		t.Error("IsMethodAllowed should return false when c.allowedMethods is nil.")
+	}
+}
+
+func TestIsMethodAllowedReturnsTrueWithOptions(t *testing.T) {
+	s := New(Options{
+		// Intentionally left blank.
+	})
+	if !s.current().isMethodAllowed("OPTIONS") {
+		t.Error("IsMethodAllowed should return true when c.allowedMethods is nil.")
+	}
+}
+
+func TestStrictMethodCheck(t *testing.T) {
+	s := New(Options{
+		AllowedMethods:    []string{"GET"},
+		StrictMethodCheck: true,
+	})
+	if s.current().isMethodAllowed("OPTIONS") {
+		t.Error("OPTIONS allowed while not listed in strict mode")
+	}
+	s = New(Options{
+		AllowedMethods:    []string{"GET", "OPTIONS"},
+		StrictMethodCheck: true,
+	})
+	if !s.current().isMethodAllowed("options") {
+		t.Error("OPTIONS not allowed while listed in strict mode")
+	}
+}
+
+func TestImplyHeadForGet(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins:  []string{"http://foo.com"},
+		AllowedMethods:  []string{"GET", "PUT"},
+		ImplyHeadForGet: true,
+	})
+	if !s.current().isMethodAllowed("HEAD") {
+		t.Error("HEAD not allowed while GET is")
+	}
+
+	req, _ := http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "http://foo.com")
+	req.Header.Add("Access-Control-Request-Method", "HEAD")
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	assertHeaders(t, res.Header(), map[string]string{
+		"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+		"Access-Control-Allow-Origin":  "http://foo.com",
+		"Access-Control-Allow-Methods": "HEAD",
+	})
+
+	s = New(Options{
+		AllowedMethods:  []string{"PUT"},
+		ImplyHeadForGet: true,
+	})
+	if s.current().isMethodAllowed("HEAD") {
+		t.Error("HEAD allowed while GET is not")
+	}
+}
+
+func TestMethodHeaderPolicy(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"http://foo.com"},
+		AllowedMethods: []string{"GET", "POST", "DELETE"},
+		AllowedHeaders: []string{"X-Request-Id"},
+		MethodHeaderPolicy: map[string][]string{
+			"post":   {"content-type"},
+			"DELETE": {"X-Admin-Token"},
+		},
 	})
+	cases := []struct {
+		method  string
+		headers string
+		allowed bool
+	}{
+		{"POST", "Content-Type, X-Request-Id", true},
+		{"POST", "X-Admin-Token", false},
+		{"DELETE", "X-Admin-Token", true},
+		{"DELETE", "Content-Type", false},
+		{"GET", "X-Admin-Token", false},
+		{"GET", "X-Request-Id", true},
+	}
+	for _, tc := range cases {
+		req, _ := http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+		req.Header.Add("Origin", "http://foo.com")
+		req.Header.Add("Access-Control-Request-Method", tc.method)
+		req.Header.Add("Access-Control-Request-Headers", tc.headers)
+		d := s.Check(req)
+		if d.Allowed != tc.allowed {
+			t.Errorf("%s with %s: allowed = %v, want %v", tc.method, tc.headers, d.Allowed, tc.allowed)
+		}
+	}
 
-	if s.Log == nil {
-		t.Error("Logger not created when debug=true")
+	s = New(Options{
+		AllowedOrigins:             []string{"http://foo.com"},
+		AllowedMethods:             []string{"GET", "DELETE"},
+		AllowedHeadersResponseMode: AllowedHeadersStatic,
+		MethodHeaderPolicy:         map[string][]string{"DELETE": {"X-Admin-Token"}},
+	})
+	for method, want := range map[string]string{
+		"GET":    "Accept, Content-Type, Origin",
+		"DELETE": "Accept, Content-Type, Origin, X-Admin-Token",
+	} {
+		req, _ := http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+		req.Header.Add("Origin", "http://foo.com")
+		req.Header.Add("Access-Control-Request-Method", method)
+		res := httptest.NewRecorder()
+		s.Handler(testHandler).ServeHTTP(res, req)
+		if got := res.Header().Get("Access-Control-Allow-Headers"); got != want {
+			t.Errorf("%s: Access-Control-Allow-Headers = %q, want %q", method, got, want)
+		}
 	}
 }
 
-func TestDefault(t *testing.T) {
-	s := New(Options{})
-	if s.Log != nil {
-		t.Error("c.log should be nil when Default")
+func TestSkip(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"http://foo.com"},
+		DenyWithStatus: http.StatusForbidden,
+		SkipPaths:      []string{"/healthz", "/webhooks/"},
+		Skip:           func(r *http.Request) bool { return r.Header.Get("X-Internal") != "" },
+	})
+	cases := []struct {
+		path    string
+		header  string
+		skipped bool
+	}{
+		{"/healthz", "", true},
+		{"/healthz/deep", "", false},
+		{"/webhooks/github", "", true},
+		{"/webhooks", "", false},
+		{"/api", "1", true},
+		{"/api", "", false},
 	}
-	if !s.allowedOriginsAll {
-		t.Error("c.allowedOriginsAll should be true when Default")
+	for _, tc := range cases {
+		req, _ := http.NewRequest("GET", "http://example.com"+tc.path, nil)
+		req.Header.Add("Origin", "http://bar.com")
+		if tc.header != "" {
+			req.Header.Add("X-Internal", tc.header)
+		}
+		res := httptest.NewRecorder()
+		s.Handler(testHandler).ServeHTTP(res, req)
+		if skipped := res.Code == http.StatusOK && res.Header().Get("Vary") == ""; skipped != tc.skipped {
+			t.Errorf("%s: skipped = %v, want %v (status %d, Vary %q)", tc.path, skipped, tc.skipped, res.Code, res.Header().Get("Vary"))
+		}
 	}
-	if s.allowedHeaders == nil {
-		t.Error("c.allowedHeaders must not be nil when Default")
+}
+
+type recordingLogger struct {
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, a ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, a...))
+}
+
+func TestSampling(t *testing.T) {
+	cases := []struct {
+		name    string
+		options Options
+		origin  string
+		logged  bool
+	}{
+		{"AllowsDefault", Options{AllowedOrigins: []string{"http://foo.com"}}, "http://foo.com", true},
+		{"DenialsDefault", Options{AllowedOrigins: []string{"http://foo.com"}}, "http://bar.com", true},
+		{"AllowsNone", Options{AllowedOrigins: []string{"http://foo.com"}, SampleAllows: -1}, "http://foo.com", false},
+		{"DenialsNone", Options{AllowedOrigins: []string{"http://foo.com"}, SampleDenials: -1}, "http://bar.com", false},
+		{"DenialsNoneAllowed", Options{AllowedOrigins: []string{"http://foo.com"}, SampleDenials: -1}, "http://foo.com", true},
+		{"ReasonNone", Options{AllowedOrigins: []string{"http://foo.com"}, SampleReasons: map[string]float64{ReasonOrigin: -1}}, "http://bar.com", false},
+		{"OtherReasonNone", Options{AllowedOrigins: []string{"http://foo.com"}, SampleReasons: map[string]float64{ReasonMethod: -1}}, "http://bar.com", true},
+		{"ReasonAll", Options{AllowedOrigins: []string{"http://foo.com"}, SampleDenials: -1, SampleReasons: map[string]float64{ReasonOrigin: 1}}, "http://bar.com", true},
 	}
-	if s.allowedMethods == nil {
-		t.Error("c.allowedMethods must not be nil when Default")
+	for i := range cases {
+		tc := cases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			s := New(tc.options)
+			l := &recordingLogger{}
+			s.Log = l
+			req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+			req.Header.Add("Origin", tc.origin)
+			s.current().handleActualRequest(httptest.NewRecorder(), req)
+			if logged := len(l.lines) > 0; logged != tc.logged {
+				t.Errorf("logged = %v, want %v", logged, tc.logged)
+			}
+		})
 	}
 }
 
-func TestHandlePreflightInvalidOriginAbortion(t *testing.T) {
+func TestLogSampleRate(t *testing.T) {
+	for _, tc := range []struct {
+		rate   float64
+		logged bool
+	}{{0, true}, {1, true}, {-1, false}} {
+		s := New(Options{AllowedOrigins: []string{"http://foo.com"}, LogSampleRate: tc.rate})
+		l := &recordingLogger{}
+		s.Log = l
+		req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+		req.Header.Add("Origin", "http://bar.com")
+		s.Handler(testHandler).ServeHTTP(httptest.NewRecorder(), req)
+		if logged := len(l.lines) > 0; logged != tc.logged {
+			t.Errorf("rate %v: logged = %v (%q), want %v", tc.rate, logged, l.lines, tc.logged)
+		}
+	}
+
+	// A request is drawn once: all its lines are logged or none
+	s := New(Options{AllowedOrigins: []string{"http://foo.com"}, LogSampleRate: 0.5})
+	l := &recordingLogger{}
+	s.Log = l
+	for i := 0; i < 50; i++ {
+		req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+		req.Header.Add("Origin", "http://foo.com")
+		s.Handler(testHandler).ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if n := len(l.lines); n%3 != 0 || n == 0 || n == 150 {
+		t.Errorf("%d lines logged for 50 requests of 3 lines at rate 0.5", n)
+	}
+}
+
+func TestSamplingByOrigin(t *testing.T) {
+	s := New(Options{SampleAllows: 0.5, SampleByOrigin: true})
+	for _, origin := range []string{"http://foo.com", "http://bar.com", "http://baz.com"} {
+		d := Decision{Allowed: true, Origin: origin}
+		want := s.current().sampled(d)
+		for i := 0; i < 10; i++ {
+			if s.current().sampled(d) != want {
+				t.Fatalf("sampling of %q is not deterministic", origin)
+			}
+		}
+	}
+}
+
+func TestPreflightCache(t *testing.T) {
 	s := New(Options{
-		AllowedOrigins: []string{"http://foo.com"},
+		AllowedOrigins:     []string{"http://foo.com"},
+		AllowedHeaders:     []string{"X-Header-1"},
+		PreflightCacheSize: 10,
 	})
-	res := httptest.NewRecorder()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.WarmCache(ctx, []string{"http://foo.com"}, []string{"GET"}, nil); err != context.Canceled {
+		t.Fatalf("WarmCache with a done context returned %v", err)
+	}
+	if n := s.current().preflightCache.len(); n != 0 {
+		t.Fatalf("WarmCache with a done context cached %d entries", n)
+	}
+	err := s.WarmCache(context.Background(), []string{"http://foo.com", "http://bar.com"}, []string{"get"},
+		[][]string{{"X-Header-1"}, {"X-Header-2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := s.current().preflightCache.len(); n != 1 {
+		t.Fatalf("WarmCache should only cache allowed combinations, got %d entries", n)
+	}
+
 	req, _ := http.NewRequest("OPTIONS", "http://example.com/foo", nil)
-	req.Header.Add("Origin", "http://example.com/")
+	req.Header.Add("Origin", "http://foo.com")
+	req.Header.Add("Access-Control-Request-Method", "GET")
+	req.Header.Add("Access-Control-Request-Headers", "x-header-1")
+	if _, ok := s.current().cachedPreflight(preflightKey("http://foo.com", "GET", "x-header-1")); !ok {
+		t.Fatal("warmed entry not found in cache")
+	}
+	for i := 0; i < 2; i++ {
+		res := httptest.NewRecorder()
+		s.Handler(testHandler).ServeHTTP(res, req)
+		assertHeaders(t, res.Header(), map[string]string{
+			"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+			"Access-Control-Allow-Origin":  "http://foo.com",
+			"Access-Control-Allow-Methods": "GET",
+			"Access-Control-Allow-Headers": "X-Header-1",
+		})
+	}
+}
 
-	s.handlePreflight(res, req)
+func TestPreflightCacheDisabledWithOriginFunc(t *testing.T) {
+	s := New(Options{
+		AllowOriginFunc:    func(r *http.Request, origin string) bool { return true },
+		PreflightCacheSize: 10,
+	})
+	if s.current().preflightCache != nil {
+		t.Error("preflight cache must be disabled when AllowOriginFunc is set")
+	}
+	if err := s.WarmCache(context.Background(), []string{"http://foo.com"}, []string{"GET"}, nil); err != nil {
+		t.Error(err)
+	}
+}
 
-	assertHeaders(t, res.Header(), map[string]string{
-		"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+func TestNegativeOriginCache(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins:          []string{"http://*.foo.com"},
+		NegativeOriginCacheSize: 10,
 	})
+	for i := 0; i < 3; i++ {
+		if s.current().isOriginAllowed(nil, "http://bar.com") {
+			t.Fatal("http://bar.com should not be allowed")
+		}
+	}
+	if !s.current().isOriginAllowed(nil, "http://a.foo.com") {
+		t.Fatal("http://a.foo.com should be allowed")
+	}
+	if stats := s.NegativeOriginCacheStats(); stats.Hits != 2 || stats.Misses != 2 {
+		t.Errorf("NegativeOriginCacheStats() = %+v, want 2 hits and 2 misses", stats)
+	}
+	if n := s.current().negativeCache.len(); n != 1 {
+		t.Errorf("only denied origins should be cached, got %d entries", n)
+	}
 }
 
-func TestHandlePreflightNoOptionsAbortion(t *testing.T) {
+func TestOriginFuncCache(t *testing.T) {
+	calls := 0
+	allowed := map[string]bool{"http://foo.com": true}
 	s := New(Options{
-		// Intentionally left blank.
+		AllowOriginFunc: func(r *http.Request, origin string) bool {
+			calls++
+			return allowed[origin]
+		},
+		OriginFuncCacheSize: 10,
 	})
-	res := httptest.NewRecorder()
-	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	for i := 0; i < 3; i++ {
+		if !s.current().isOriginAllowed(nil, "http://foo.com") {
+			t.Fatal("http://foo.com should be allowed")
+		}
+		if s.current().isOriginAllowed(nil, "http://bar.com") {
+			t.Fatal("http://bar.com should not be allowed")
+		}
+	}
+	if calls != 2 {
+		t.Errorf("AllowOriginFunc called %d times, want 2", calls)
+	}
 
-	s.handlePreflight(res, req)
+	allowed["http://bar.com"] = true
+	s.InvalidateOrigin("http://bar.com")
+	if !s.current().isOriginAllowed(nil, "http://bar.com") || calls != 3 {
+		t.Errorf("invalidated origin not checked again, %d calls", calls)
+	}
+}
 
-	assertHeaders(t, res.Header(), map[string]string{})
+func TestInvalidateOriginNegativeCache(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins:          []string{"http://*.foo.com"},
+		NegativeOriginCacheSize: 10,
+	})
+	s.current().isOriginAllowed(nil, "http://bar.com")
+	s.InvalidateOrigin("http://bar.com")
+	if n := s.current().negativeCache.len(); n != 0 {
+		t.Errorf("negative cache holds %d entries after invalidation, want 0", n)
+	}
 }
 
-func TestHandleActualRequestInvalidOriginAbortion(t *testing.T) {
+func TestAllowLocalhost(t *testing.T) {
+	s := AllowLocalhost()
+	for origin, allowed := range map[string]bool{
+		"http://localhost:3000": true,
+		"https://127.0.0.1":     true,
+		"http://[::1]:5173":     true,
+		"http://example.com":    false,
+	} {
+		if got := s.current().isOriginAllowed(nil, origin); got != allowed {
+			t.Errorf("isOriginAllowed(%q) = %v, want %v", origin, got, allowed)
+		}
+	}
+
+	// Added to the other origin options
+	s = New(Options{AllowedOrigins: []string{"https://example.com"}, AllowLocalhost: true})
+	if !s.current().isOriginAllowed(nil, "https://example.com") || !s.current().isOriginAllowed(nil, "http://localhost:8080") {
+		t.Error("AllowLocalhost should extend AllowedOrigins")
+	}
+	if s.current().isOriginAllowed(nil, "https://other.com") {
+		t.Error("AllowLocalhost alone should not allow all origins")
+	}
+}
+
+func TestCacheableResponses(t *testing.T) {
 	s := New(Options{
-		AllowedOrigins: []string{"http://foo.com"},
+		AllowedOrigins:     []string{"https://*.example.com"},
+		AllowedMethods:     []string{"GET", "PUT", "DELETE"},
+		AllowedHeaders:     []string{"X-Header-1", "X-Header-2"},
+		CacheableResponses: true,
+		MaxAge:             600,
 	})
-	res := httptest.NewRecorder()
-	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
-	req.Header.Add("Origin", "http://example.com/")
+	var first http.Header
+	for _, tc := range []struct{ method, headers string }{
+		{"PUT", "x-header-1"},
+		{"DELETE", ""},
+		{"GET", "X-Header-2, x-header-1"},
+	} {
+		req, _ := http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+		req.Header.Add("Origin", "https://app.example.com")
+		req.Header.Add("Access-Control-Request-Method", tc.method)
+		if tc.headers != "" {
+			req.Header.Add("Access-Control-Request-Headers", tc.headers)
+		}
+		res := httptest.NewRecorder()
+		s.Handler(testHandler).ServeHTTP(res, req)
+		if first == nil {
+			first = res.Header()
+			assertHeaders(t, first, map[string]string{
+				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "https://app.example.com",
+				"Access-Control-Allow-Methods": "DELETE, GET, PUT",
+				"Access-Control-Allow-Headers": "Origin, X-Header-1, X-Header-2",
+				"Access-Control-Max-Age":       "600",
+			})
+		} else if !reflect.DeepEqual(res.Header(), first) {
+			t.Errorf("%s %q: headers = %v, want %v", tc.method, tc.headers, res.Header(), first)
+		}
+	}
+}
+
+func TestStrict(t *testing.T) {
+	s := Strict("https://foo.com", "https://*.bar.com")
+	if s.current().allowedOriginsAll || s.current().allowedHeadersAll || s.current().allowCredentials {
+		t.Error("Strict should not allow all origins, all headers or credentials")
+	}
+	if !s.current().isOriginAllowed(nil, "https://a.bar.com") || s.current().isOriginAllowed(nil, "https://baz.com") {
+		t.Error("Strict should only allow the given origins")
+	}
+	if s.current().isMethodAllowed("DELETE") {
+		t.Error("Strict should only allow simple methods")
+	}
+	if !s.current().strictMethods || s.current().isMethodAllowed("OPTIONS") {
+		t.Error("Strict should check methods strictly")
+	}
+	if s := Strict(); s.current().isOriginAllowed(nil, "https://foo.com") {
+		t.Error("Strict without origins should not allow any origin")
+	}
+	for _, origins := range [][]string{{"http://foo.com"}, {"https://foo.com/"}, {"*"}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Strict(%q) should panic", origins)
+				}
+			}()
+			Strict(origins...)
+		}()
+	}
+}
 
-	s.handleActualRequest(res, req)
+func TestMaxAddedHeaderBytes(t *testing.T) {
+	options := Options{
+		AllowedOrigins:      []string{"http://foobar.com"},
+		ExposedHeaders:      []string{"X-Header-1", "X-Header-2"},
+		MaxAddedHeaderBytes: 90,
+		Telemetry:           NewTelemetry(),
+	}
+	s := New(options)
 
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "http://foobar.com")
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
 	assertHeaders(t, res.Header(), map[string]string{
 		"Vary": "Origin",
 	})
+
+	req, _ = http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "http://foobar.com")
+	req.Header.Add("Access-Control-Request-Method", "GET")
+	res = httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	assertHeaders(t, res.Header(), map[string]string{
+		"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+		"Access-Control-Allow-Origin":  "http://foobar.com",
+		"Access-Control-Allow-Methods": "GET",
+	})
+
+	counts := options.Telemetry.Snapshot()
+	if len(counts) != 2 || counts[1].Reason != ReasonHeaderBudget || counts[1].Preflight {
+		t.Errorf("actual request should be denied with the header budget reason, got %+v", counts)
+	}
 }
 
-func TestHandleActualRequestInvalidMethodAbortion(t *testing.T) {
+func TestMaxPreflightHeaderLimits(t *testing.T) {
 	s := New(Options{
-		AllowedMethods:   []string{"POST"},
-		AllowCredentials: true,
+		AllowedOrigins:           []string{"http://foobar.com"},
+		AllowedHeaders:           []string{"*"},
+		MaxPreflightHeaderBytes:  20,
+		MaxPreflightHeaderTokens: 3,
+	})
+	cases := []struct {
+		headers []string
+		reason  string
+	}{
+		{[]string{"x-a,x-b", "x-c"}, ""},
+		{[]string{"x-a,x-b,x-c,x-d"}, ReasonRequestHeadersLimit},
+		{[]string{"x-a,x-b", "x-c", "x-d"}, ReasonRequestHeadersLimit},
+		{[]string{"x-a-very-long-header-name"}, ReasonRequestHeadersLimit},
+	}
+	for _, tc := range cases {
+		req, _ := http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+		req.Header.Add("Origin", "http://foobar.com")
+		req.Header.Add("Access-Control-Request-Method", "GET")
+		for _, h := range tc.headers {
+			req.Header.Add("Access-Control-Request-Headers", h)
+		}
+		d := s.Check(req)
+		if d.Reason != tc.reason || d.Allowed != (tc.reason == "") {
+			t.Errorf("Check(%q) = %+v, want reason %q", tc.headers, d, tc.reason)
+		}
+	}
+}
+
+func TestStrictRequestHeaders(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins:       []string{"http://foobar.com"},
+		AllowedHeaders:       []string{"*"},
+		StrictRequestHeaders: true,
+	})
+	cases := []struct {
+		headers []string
+		reason  string
+	}{
+		{nil, ""},
+		{[]string{"content-type,x-a"}, ""},
+		{[]string{"x-a,content-type"}, ReasonMalformedHeaders},
+		{[]string{"Content-Type"}, ReasonMalformedHeaders},
+		{[]string{"content-type, x-a"}, ReasonMalformedHeaders},
+		{[]string{"x-a,x-a"}, ReasonMalformedHeaders},
+		{[]string{"x-a,"}, ReasonMalformedHeaders},
+		{[]string{""}, ReasonMalformedHeaders},
+		{[]string{"x-a", "x-b"}, ReasonMalformedHeaders},
+	}
+	for _, tc := range cases {
+		req, _ := http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+		req.Header.Add("Origin", "http://foobar.com")
+		req.Header.Add("Access-Control-Request-Method", "GET")
+		for _, h := range tc.headers {
+			req.Header.Add("Access-Control-Request-Headers", h)
+		}
+		d := s.Check(req)
+		if d.Reason != tc.reason || d.Allowed != (tc.reason == "") {
+			t.Errorf("Check(%q) = %+v, want reason %q", tc.headers, d, tc.reason)
+		}
+	}
+}
+
+func TestUpdateOptions(t *testing.T) {
+	s := New(Options{AllowedOrigins: []string{"http://foo.com"}})
+	handler := s.Handler(testHandler)
+	serve := func(origin string) string {
+		req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+		req.Header.Add("Origin", origin)
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res.Header().Get("Access-Control-Allow-Origin")
+	}
+
+	if got := serve("http://bar.com"); got != "" {
+		t.Errorf("http://bar.com should not be allowed before the update, got %q", got)
+	}
+	if err := s.UpdateOptions(Options{AllowedOrigins: []string{"http://foo.com", "http://bar.com"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := serve("http://bar.com"); got != "http://bar.com" {
+		t.Errorf("http://bar.com should be allowed after the update, got %q", got)
+	}
+	if err := s.UpdateOptions(Options{AllowedOriginsRegex: []string{"("}}); err == nil {
+		t.Error("invalid options should be rejected")
+	}
+	if got := serve("http://bar.com"); got != "http://bar.com" {
+		t.Errorf("previous options should be kept after a failed update, got %q", got)
+	}
+}
+
+func TestUpdateOptionsConcurrent(t *testing.T) {
+	s := New(Options{AllowedOrigins: []string{"http://foo.com"}})
+	handler := s.Handler(testHandler)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			s.UpdateOptions(Options{AllowedOrigins: []string{"http://foo.com"}, MaxAge: i})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+		req.Header.Add("Origin", "http://foo.com")
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		if got := res.Header().Get("Access-Control-Allow-Origin"); got != "http://foo.com" {
+			t.Fatalf("Access-Control-Allow-Origin = %q, want http://foo.com", got)
+		}
+	}
+	<-done
+}
+
+func TestUpdateOptionsDuringRequest(t *testing.T) {
+	var s *Cors
+	s = New(Options{
+		AllowedOrigins: []string{"http://foo.com"},
+		Skip: func(r *http.Request) bool {
+			// The update lands while the request is being handled
+			if err := s.UpdateOptions(Options{AllowedOrigins: []string{"http://bar.com"}}); err != nil {
+				t.Fatal(err)
+			}
+			return false
+		},
 	})
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "http://foo.com")
 	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	if got := res.Header().Get("Access-Control-Allow-Origin"); got != "http://foo.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want the options the request came in with", got)
+	}
+}
+
+func TestPassthroughFunc(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"http://foobar.com"},
+		PassthroughFunc: func(r *http.Request) bool {
+			return strings.HasPrefix(r.URL.Path, "/dav/")
+		},
+	})
+	handler := s.Handler(testHandler)
+	for path, body := range map[string]string{"/dav/file.txt": "bar", "/api/users": ""} {
+		req, _ := http.NewRequest("OPTIONS", "http://example.com"+path, nil)
+		req.Header.Add("Origin", "http://foobar.com")
+		req.Header.Add("Access-Control-Request-Method", "GET")
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		assertResponse(t, res, http.StatusOK)
+		if got := res.Body.String(); got != body {
+			t.Errorf("%s: body = %q, want %q", path, got, body)
+		}
+		if got := res.Header().Get("Access-Control-Allow-Origin"); got != "http://foobar.com" {
+			t.Errorf("%s: Access-Control-Allow-Origin = %q", path, got)
+		}
+	}
+}
+
+func TestStrictHeaderPlacement(t *testing.T) {
+	leaky := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Max-Age", "600")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Leak")
+		w.Write([]byte("bar"))
+	})
+	s := New(Options{
+		AllowedOrigins:        []string{"http://foobar.com"},
+		OptionsPassthrough:    true,
+		StrictHeaderPlacement: true,
+	})
+
 	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
-	req.Header.Add("Origin", "http://example.com/")
+	req.Header.Add("Origin", "http://foobar.com")
+	res := httptest.NewRecorder()
+	s.Handler(leaky).ServeHTTP(res, req)
+	assertHeaderPlacement(t, req, res.Header())
+	if res.Header().Get("Access-Control-Expose-Headers") != "X-Leak" {
+		t.Error("Access-Control-Expose-Headers should be kept on actual responses")
+	}
 
-	s.handleActualRequest(res, req)
+	req, _ = http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "http://foobar.com")
+	req.Header.Add("Access-Control-Request-Method", "GET")
+	res = httptest.NewRecorder()
+	s.Handler(leaky).ServeHTTP(res, req)
+	assertHeaderPlacement(t, req, res.Header())
+}
 
-	assertHeaders(t, res.Header(), map[string]string{
-		"Vary": "Origin",
+type recordingLevelLogger struct {
+	entries []string
+}
+
+func (l *recordingLevelLogger) log(level, msg string, args ...interface{}) {
+	l.entries = append(l.entries, fmt.Sprint(level, " ", msg, " ", args))
+}
+
+func (l *recordingLevelLogger) Debug(msg string, args ...interface{}) { l.log("DEBUG", msg, args...) }
+func (l *recordingLevelLogger) Info(msg string, args ...interface{})  { l.log("INFO", msg, args...) }
+func (l *recordingLevelLogger) Warn(msg string, args ...interface{})  { l.log("WARN", msg, args...) }
+
+func TestLevelLogger(t *testing.T) {
+	l := &recordingLevelLogger{}
+	s := New(Options{
+		AllowedOrigins: []string{"http://foo.com"},
+		Logger:         l,
 	})
+	for _, origin := range []string{"http://foo.com", "http://bar.com", ""} {
+		req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+		if origin != "" {
+			req.Header.Add("Origin", origin)
+		}
+		s.Handler(testHandler).ServeHTTP(httptest.NewRecorder(), req)
+	}
+	want := []string{
+		"DEBUG cors: request allowed [origin http://foo.com method GET preflight false pattern http://foo.com]",
+		"INFO cors: request denied [origin http://bar.com method GET preflight false reason origin error origin 'http://bar.com' not allowed]",
+	}
+	if !reflect.DeepEqual(l.entries, want) {
+		t.Errorf("logged %q, want %q", l.entries, want)
+	}
 }
 
-func TestIsMethodAllowedReturnsFalseWithNoMethods(t *testing.T) {
+func TestReportOnly(t *testing.T) {
+	var denied []Decision
 	s := New(Options{
-		// Intentionally left blank.
+		AllowedOrigins: []string{"http://foo.com"},
+		AllowedMethods: []string{"GET"},
+		ExposedHeaders: []string{"X-Exposed"},
+		MaxAge:         10,
+		ReportOnly:     true,
+		OnDecision: func(d Decision) {
+			if !d.Allowed {
+				denied = append(denied, d)
+			}
+		},
 	})
-	s.allowedMethods = []string{}
-	if s.isMethodAllowed("") {
-		t.Error("IsMethodAllowed should return false when c.allowedMethods is nil.")
+
+	req, _ := http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "http://bar.com")
+	req.Header.Add("Access-Control-Request-Method", "PUT")
+	req.Header.Add("Access-Control-Request-Headers", "X-Header-1, Cookie")
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	assertHeaders(t, res.Header(), map[string]string{
+		"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+		"Access-Control-Allow-Origin":  "http://bar.com",
+		"Access-Control-Allow-Methods": "PUT",
+		"Access-Control-Allow-Headers": "X-Header-1",
+		"Access-Control-Max-Age":       "10",
+	})
+
+	req, _ = http.NewRequest("DELETE", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "http://foo.com")
+	res = httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	assertHeaders(t, res.Header(), map[string]string{
+		"Vary":                          "Origin",
+		"Access-Control-Allow-Origin":   "http://foo.com",
+		"Access-Control-Expose-Headers": "X-Exposed",
+	})
+
+	if len(denied) != 2 || denied[0].Reason != ReasonOrigin || denied[1].Reason != ReasonMethod {
+		t.Errorf("reported denials = %+v, want origin then method", denied)
+	}
+	if d := s.Check(req); d.Allowed {
+		t.Error("Check() allowed a request denied by the policy")
 	}
 }
 
-func TestIsMethodAllowedReturnsTrueWithOptions(t *testing.T) {
+func TestPrecomputedHeaderValues(t *testing.T) {
 	s := New(Options{
-		// Intentionally left blank.
+		AllowedOrigins: []string{"http://foobar.com"},
+		AllowedMethods: []string{"get", "PUT"},
+		ExposedHeaders: []string{"x-a", "x-b"},
+		MaxAge:         30,
 	})
-	if !s.isMethodAllowed("OPTIONS") {
-		t.Error("IsMethodAllowed should return true when c.allowedMethods is nil.")
+	p := s.current()
+	if v := p.allowMethodsValue("PUT"); len(v) != 1 || &v[0] != &p.allowMethodsValues["PUT"][0] {
+		t.Errorf("allowMethodsValue(PUT) = %v, want the precomputed value", v)
+	}
+	if v := p.allowMethodsValue("options"); !reflect.DeepEqual(v, []string{"OPTIONS"}) {
+		t.Errorf("allowMethodsValue(options) = %v, want [OPTIONS]", v)
+	}
+	if !reflect.DeepEqual(p.exposeHeadersValue, []string{"X-A, X-B"}) || !reflect.DeepEqual(p.maxAgeValue, []string{"30"}) {
+		t.Errorf("precomputed values = %v, %v", p.exposeHeadersValue, p.maxAgeValue)
+	}
+}
+
+func BenchmarkPreflight(b *testing.B) {
+	h := New(Options{
+		AllowedOrigins: []string{"http://foobar.com"},
+		AllowedMethods: []string{"GET", "PUT"},
+		MaxAge:         30,
+	}).Handler(testHandler)
+	req, _ := http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "http://foobar.com")
+	req.Header.Add("Access-Control-Request-Method", "PUT")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
+
+func TestRouteHandler(t *testing.T) {
+	routes := map[string][]string{"/items": {"GET", "POST", "PUT", "PURGE"}}
+	routed := func(r *http.Request, method string) bool {
+		if r.URL.Path == "/any" {
+			return true
+		}
+		return containsString(routes[r.URL.Path], method)
+	}
+	preflight := func(h http.Handler, origin, path, method string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("OPTIONS", "http://example.com"+path, nil)
+		req.Header.Add("Origin", origin)
+		req.Header.Add("Access-Control-Request-Method", method)
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		return res
 	}
+	preflightVary := "Origin, Access-Control-Request-Method, Access-Control-Request-Headers"
+
+	// Routed methods are intersected with AllowedMethods
+	h := New(Options{
+		AllowedOrigins: []string{"http://foo.com"},
+		AllowedMethods: []string{"GET", "POST", "DELETE", "PURGE"},
+	}).RouteHandler(testHandler, routed)
+	res := preflight(h, "http://foo.com", "/items", "PURGE")
+	assertHeaders(t, res.Header(), map[string]string{
+		"Vary":                         preflightVary,
+		"Access-Control-Allow-Origin":  "http://foo.com",
+		"Access-Control-Allow-Methods": "GET, POST, PURGE",
+	})
+	for _, method := range []string{"PUT", "DELETE"} {
+		res = preflight(h, "http://foo.com", "/items", method)
+		assertResponse(t, res, http.StatusOK)
+		assertHeaders(t, res.Header(), map[string]string{"Vary": preflightVary})
+	}
+	// Routes matching any method don't allow methods outside AllowedMethods
+	res = preflight(h, "http://foo.com", "/any", "FOO")
+	assertHeaders(t, res.Header(), map[string]string{"Vary": preflightVary})
+
+	// Standard routed methods are allowed with AllowRoutedMethods
+	h = New(Options{
+		AllowedOrigins:     []string{"http://foo.com"},
+		AllowRoutedMethods: true,
+	}).RouteHandler(testHandler, routed)
+	res = preflight(h, "http://foo.com", "/items", "PUT")
+	assertHeaders(t, res.Header(), map[string]string{
+		"Vary":                         preflightVary,
+		"Access-Control-Allow-Origin":  "http://foo.com",
+		"Access-Control-Allow-Methods": "GET, POST, PUT",
+	})
+	res = preflight(h, "http://foo.com", "/items", "PURGE")
+	assertHeaders(t, res.Header(), map[string]string{"Vary": preflightVary})
+
+	// 405 responses are only sent to allowed origins, with PreflightMethodNotAllowed
+	var decisions []Decision
+	h = New(Options{
+		AllowedOrigins:            []string{"http://foo.com"},
+		AllowedMethods:            []string{"GET", "POST", "DELETE"},
+		PreflightMethodNotAllowed: true,
+		OnDecision:                func(d Decision) { decisions = append(decisions, d) },
+	}).RouteHandler(testHandler, routed)
+	res = preflight(h, "http://foo.com", "/items", "DELETE")
+	assertResponse(t, res, http.StatusMethodNotAllowed)
+	if allow := res.Header().Get("Allow"); allow != "GET, POST" {
+		t.Errorf("Allow = %q, want %q", allow, "GET, POST")
+	}
+	res = preflight(h, "http://bar.com", "/items", "DELETE")
+	assertResponse(t, res, http.StatusOK)
+	if allow := res.Header().Get("Allow"); allow != "" {
+		t.Errorf("disallowed origin: Allow = %q, want none", allow)
+	}
+	if len(decisions) != 2 || decisions[0].Reason != ReasonMethod || decisions[1].Reason != ReasonOrigin {
+		t.Errorf("decisions = %+v", decisions)
+	}
+
+	// Routed methods are intersected with the methods of the resolved policy
+	h = New(Options{
+		AllowedOrigins: []string{"http://foo.com"},
+		AllowedMethods: []string{"GET"},
+		PolicyResolver: PolicyResolverFunc(func(r *http.Request) (*Options, error) {
+			return &Options{AllowedOrigins: []string{"http://foo.com"}, AllowedMethods: []string{"PUT", "PURGE"}}, nil
+		}),
+	}).RouteHandler(testHandler, routed)
+	res = preflight(h, "http://foo.com", "/items", "PUT")
+	assertHeaders(t, res.Header(), map[string]string{
+		"Vary":                         preflightVary,
+		"Access-Control-Allow-Origin":  "http://foo.com",
+		"Access-Control-Allow-Methods": "PURGE, PUT",
+	})
+	res = preflight(h, "http://foo.com", "/items", "GET")
+	assertHeaders(t, res.Header(), map[string]string{"Vary": preflightVary})
 }
@@ -0,0 +1,270 @@
+// Package corstest provides a conformance suite checking that a handler answers
+// CORS requests as the Fetch standard expects, along with request builders. It
+// is meant to run against the handlers applications actually serve, once wrapped
+// in their other middlewares, to catch regressions of their composition:
+//
+//	func TestCORS(t *testing.T) {
+//		corstest.Run(t, newRouter(), corstest.Policy{
+//			AllowedOrigin:    "https://app.example.com",
+//			DisallowedOrigin: "https://evil.example.com",
+//			AllowedMethod:    "PUT",
+//			AllowedHeader:    "Authorization",
+//			Credentials:      true,
+//		})
+//	}
+package corstest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Target is the URL requests are sent to unless Policy.Target is set
+const Target = "http://api.example.com/"
+
+// Policy describes what the handler under test is expected to allow, the cases
+// of values left empty being skipped
+type Policy struct {
+	// Target is the URL requests are sent to, Target by default
+	Target string
+
+	// AllowedOrigin is an origin the handler allows, it is required
+	AllowedOrigin string
+
+	// DisallowedOrigin is an origin the handler denies
+	DisallowedOrigin string
+
+	// AllowedMethod is a method allowed by preflights, GET by default
+	AllowedMethod string
+
+	// DisallowedMethod is a non simple method denied by preflights
+	DisallowedMethod string
+
+	// AllowedHeader is a non safelisted request header allowed by preflights
+	AllowedHeader string
+
+	// DisallowedHeader is a non safelisted request header denied by preflights
+	DisallowedHeader string
+
+	// Credentials is set when the handler allows credentials
+	Credentials bool
+}
+
+// Case is a conformance case
+type Case struct {
+	// Name identifies the case, it is used as the name of its subtest
+	Name string
+
+	// Request builds the request sent to the handler
+	Request func() *http.Request
+
+	// Check returns an error describing how the response does not conform
+	Check func(res *httptest.ResponseRecorder) error
+}
+
+// Preflight returns a preflight request from origin for method with the given
+// request headers
+func Preflight(origin, method string, headers ...string) *http.Request {
+	return preflight(Target, origin, method, headers...)
+}
+
+func preflight(target, origin, method string, headers ...string) *http.Request {
+	req := httptest.NewRequest(http.MethodOptions, target, nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", method)
+	if len(headers) > 0 {
+		req.Header.Set("Access-Control-Request-Headers", strings.ToLower(strings.Join(headers, ",")))
+	}
+	return req
+}
+
+// Request returns a cross-origin request from origin with method, or a same-origin
+// request if origin is empty
+func Request(method, origin string) *http.Request {
+	return request(Target, method, origin)
+}
+
+func request(target, method, origin string) *http.Request {
+	req := httptest.NewRequest(method, target, nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	return req
+}
+
+// Run runs the conformance cases of p against h, each one as a subtest
+func Run(t *testing.T, h http.Handler, p Policy) {
+	t.Helper()
+	if p.AllowedOrigin == "" {
+		t.Fatal("corstest: Policy.AllowedOrigin is required")
+	}
+	for _, c := range Cases(p) {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			h.ServeHTTP(res, c.Request())
+			if err := c.Check(res); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// Cases returns the conformance cases of p
+func Cases(p Policy) []Case {
+	target := p.Target
+	if target == "" {
+		target = Target
+	}
+	method := p.AllowedMethod
+	if method == "" {
+		method = http.MethodGet
+	}
+	var headers []string
+	if p.AllowedHeader != "" {
+		headers = append(headers, p.AllowedHeader)
+	}
+
+	cases := []Case{{
+		Name:    "PreflightAllowed",
+		Request: func() *http.Request { return preflight(target, p.AllowedOrigin, method, headers...) },
+		Check: func(res *httptest.ResponseRecorder) error {
+			if res.Code < 200 || res.Code > 299 {
+				return fmt.Errorf("status %d, preflights must get an ok status", res.Code)
+			}
+			if err := checkAllowOrigin(res, p); err != nil {
+				return err
+			}
+			if !listHas(res.Header(), "Access-Control-Allow-Methods", method, !p.Credentials, true) && !isSimpleMethod(method) {
+				return fmt.Errorf("Access-Control-Allow-Methods %q does not allow %s", res.Header().Get("Access-Control-Allow-Methods"), method)
+			}
+			for _, header := range headers {
+				if !listHas(res.Header(), "Access-Control-Allow-Headers", header, !p.Credentials, false) {
+					return fmt.Errorf("Access-Control-Allow-Headers %q does not allow %s", res.Header().Get("Access-Control-Allow-Headers"), header)
+				}
+			}
+			return checkNoActualHeaders(res)
+		},
+	}, {
+		Name:    "ActualAllowed",
+		Request: func() *http.Request { return request(target, http.MethodGet, p.AllowedOrigin) },
+		Check: func(res *httptest.ResponseRecorder) error {
+			if err := checkAllowOrigin(res, p); err != nil {
+				return err
+			}
+			return checkNoPreflightHeaders(res)
+		},
+	}, {
+		Name:    "SameOrigin",
+		Request: func() *http.Request { return request(target, http.MethodGet, "") },
+		Check: func(res *httptest.ResponseRecorder) error {
+			if res.Header().Get("Access-Control-Allow-Credentials") != "" {
+				return fmt.Errorf("Access-Control-Allow-Credentials set on a request without Origin")
+			}
+			return checkNoPreflightHeaders(res)
+		},
+	}}
+
+	if p.DisallowedOrigin != "" {
+		cases = append(cases, Case{
+			Name:    "PreflightDisallowedOrigin",
+			Request: func() *http.Request { return preflight(target, p.DisallowedOrigin, method, headers...) },
+			Check: func(res *httptest.ResponseRecorder) error {
+				if err := checkDenied(res, p.DisallowedOrigin); err != nil {
+					return err
+				}
+				return checkNoActualHeaders(res)
+			},
+		}, Case{
+			Name:    "ActualDisallowedOrigin",
+			Request: func() *http.Request { return request(target, http.MethodGet, p.DisallowedOrigin) },
+			Check:   func(res *httptest.ResponseRecorder) error { return checkDenied(res, p.DisallowedOrigin) },
+		})
+	}
+	if p.DisallowedMethod != "" {
+		cases = append(cases, Case{
+			Name:    "PreflightDisallowedMethod",
+			Request: func() *http.Request { return preflight(target, p.AllowedOrigin, p.DisallowedMethod) },
+			Check: func(res *httptest.ResponseRecorder) error {
+				if res.Header().Get("Access-Control-Allow-Origin") != "" &&
+					listHas(res.Header(), "Access-Control-Allow-Methods", p.DisallowedMethod, !p.Credentials, true) {
+					return fmt.Errorf("preflight for %s allowed", p.DisallowedMethod)
+				}
+				return checkNoActualHeaders(res)
+			},
+		})
+	}
+	if p.DisallowedHeader != "" {
+		cases = append(cases, Case{
+			Name:    "PreflightDisallowedHeader",
+			Request: func() *http.Request { return preflight(target, p.AllowedOrigin, method, p.DisallowedHeader) },
+			Check: func(res *httptest.ResponseRecorder) error {
+				if res.Header().Get("Access-Control-Allow-Origin") != "" &&
+					listHas(res.Header(), "Access-Control-Allow-Headers", p.DisallowedHeader, !p.Credentials, false) {
+					return fmt.Errorf("preflight for header %s allowed", p.DisallowedHeader)
+				}
+				return checkNoActualHeaders(res)
+			},
+		})
+	}
+	return cases
+}
+
+// checkAllowOrigin checks the response allows the origin of p, with credentials if
+// expected
+func checkAllowOrigin(res *httptest.ResponseRecorder, p Policy) error {
+	h := res.Header()
+	allowed := h.Get("Access-Control-Allow-Origin")
+	switch {
+	case allowed == "":
+		return fmt.Errorf("Access-Control-Allow-Origin missing for %s", p.AllowedOrigin)
+	case allowed == "*" && p.Credentials:
+		return fmt.Errorf(`Access-Control-Allow-Origin "*" can't be used with credentials`)
+	case allowed != "*" && allowed != p.AllowedOrigin:
+		return fmt.Errorf("Access-Control-Allow-Origin %q, want %q", allowed, p.AllowedOrigin)
+	case len(h.Values("Access-Control-Allow-Origin")) > 1:
+		return fmt.Errorf("Access-Control-Allow-Origin set several times: %q", h.Values("Access-Control-Allow-Origin"))
+	}
+	if allowed != "*" && !listHas(h, "Vary", "Origin", true, false) {
+		return fmt.Errorf("Vary %q does not list Origin although the response depends on it", h.Values("Vary"))
+	}
+	if credentials := h.Get("Access-Control-Allow-Credentials"); p.Credentials && credentials != "true" {
+		return fmt.Errorf("Access-Control-Allow-Credentials %q, want true", credentials)
+	}
+	return nil
+}
+
+// checkDenied checks the response does not allow origin
+func checkDenied(res *httptest.ResponseRecorder, origin string) error {
+	if allowed := res.Header().Get("Access-Control-Allow-Origin"); allowed != "" {
+		return fmt.Errorf("Access-Control-Allow-Origin %q allows %s", allowed, origin)
+	}
+	return nil
+}
+
+// checkNoPreflightHeaders checks the response of a request other than a preflight
+// has no headers only meaningful for preflights
+func checkNoPreflightHeaders(res *httptest.ResponseRecorder) error {
+	for _, name := range []string{"Access-Control-Allow-Methods", "Access-Control-Allow-Headers", "Access-Control-Max-Age"} {
+		if v := res.Header().Get(name); v != "" {
+			return fmt.Errorf("%s %q set outside of a preflight", name, v)
+		}
+	}
+	return nil
+}
+
+// checkNoActualHeaders checks the response of a preflight has no headers only
+// meaningful for actual requests
+func checkNoActualHeaders(res *httptest.ResponseRecorder) error {
+	if v := res.Header().Get("Access-Control-Expose-Headers"); v != "" {
+		return fmt.Errorf("Access-Control-Expose-Headers %q set on a preflight", v)
+	}
+	return nil
+}
+
+func isSimpleMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodPost
+}
@@ -0,0 +1,92 @@
+package corstest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/cors"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+})
+
+func TestRun(t *testing.T) {
+	h := cors.New(cors.Options{
+		AllowedOrigins:   []string{"https://*.example.com"},
+		AllowedMethods:   []string{"GET", "PUT"},
+		AllowedHeaders:   []string{"Authorization"},
+		ExposedHeaders:   []string{"X-Request-Id"},
+		AllowCredentials: true,
+	}).Handler(okHandler)
+	Run(t, h, Policy{
+		AllowedOrigin:    "https://app.example.com",
+		DisallowedOrigin: "https://example.org",
+		AllowedMethod:    "PUT",
+		DisallowedMethod: "DELETE",
+		AllowedHeader:    "Authorization",
+		DisallowedHeader: "X-Debug",
+		Credentials:      true,
+	})
+}
+
+func TestRunAllowAll(t *testing.T) {
+	Run(t, cors.AllowAll().Handler(okHandler), Policy{
+		AllowedOrigin: "https://app.example.com",
+		AllowedMethod: "DELETE",
+		AllowedHeader: "X-Debug",
+	})
+}
+
+func TestCasesDetectFailures(t *testing.T) {
+	// A handler allowing everything with credentials, the classic mistake
+	broken := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		w.Header().Set("Access-Control-Allow-Methods", "*")
+		w.Header().Set("Access-Control-Expose-Headers", "*")
+	})
+	p := Policy{
+		AllowedOrigin:    "https://app.example.com",
+		DisallowedOrigin: "https://example.org",
+		AllowedMethod:    "PUT",
+		Credentials:      true,
+	}
+	failed := map[string]bool{}
+	for _, c := range Cases(p) {
+		res := httptest.NewRecorder()
+		broken.ServeHTTP(res, c.Request())
+		failed[c.Name] = c.Check(res) != nil
+	}
+	for _, name := range []string{"PreflightAllowed", "ActualAllowed", "PreflightDisallowedOrigin", "ActualDisallowedOrigin", "SameOrigin"} {
+		if !failed[name] {
+			t.Errorf("%s: failure not detected", name)
+		}
+	}
+}
+
+func TestCasesDetectExposedHeadersOnPreflights(t *testing.T) {
+	h := cors.New(cors.Options{AllowedOrigins: []string{"https://app.example.com"}}).Handler(okHandler)
+	leaky := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-Id")
+		h.ServeHTTP(w, r)
+	})
+	for _, c := range Cases(Policy{AllowedOrigin: "https://app.example.com", DisallowedOrigin: "https://example.org"}) {
+		res := httptest.NewRecorder()
+		leaky.ServeHTTP(res, c.Request())
+		if failed := c.Check(res) != nil; failed != (c.Name == "PreflightAllowed" || c.Name == "PreflightDisallowedOrigin") {
+			t.Errorf("%s: failed = %v", c.Name, failed)
+		}
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	req := Preflight("https://app.example.com", "PUT", "Authorization", "X-Debug")
+	if req.Method != http.MethodOptions ||
+		req.Header.Get("Origin") != "https://app.example.com" ||
+		req.Header.Get("Access-Control-Request-Method") != "PUT" ||
+		req.Header.Get("Access-Control-Request-Headers") != "authorization,x-debug" {
+		t.Errorf("unexpected preflight %v %v", req.Method, req.Header)
+	}
+}
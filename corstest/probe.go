@@ -0,0 +1,202 @@
+package corstest
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Exchange is a request sent by Probe and the response it got
+type Exchange struct {
+	Method string      `json:"method"`
+	Header http.Header `json:"requestHeader"`
+
+	Status         int         `json:"status"`
+	ResponseHeader http.Header `json:"responseHeader"`
+}
+
+// Result is the outcome of a Probe
+type Result struct {
+	// Preflight is the preflight request and its response, nil if the request
+	// didn't need one
+	Preflight *Exchange `json:"preflight,omitempty"`
+
+	// Actual is the actual request and its response, nil if a browser would not
+	// have sent it because the preflight failed
+	Actual *Exchange `json:"actual,omitempty"`
+
+	// Allowed is set when a browser would let the page read the response
+	Allowed bool `json:"allowed"`
+
+	// Reason explains why a browser would block the request
+	Reason string `json:"reason,omitempty"`
+}
+
+// Prober performs requests the way browsers do for cross-origin fetches
+type Prober struct {
+	// Client sends the requests, http.DefaultClient by default. Redirects are
+	// not followed.
+	Client *http.Client
+
+	// Credentials is set to probe requests sent with credentials (cookies or
+	// HTTP authentication), which browsers check more strictly
+	Credentials bool
+}
+
+// Probe performs a preflight, if a browser would, then the actual request from
+// origin with method and headers against url, using the default Prober.
+func Probe(url, origin, method string, headers http.Header) (Result, error) {
+	var p Prober
+	return p.Probe(url, origin, method, headers)
+}
+
+// Probe performs a preflight, if a browser would, then the actual request from
+// origin with method and headers against url, and reports whether a browser would
+// let the page read the response. Responses are checked as the Fetch standard
+// does, except that redirects are not followed. An error is only returned if a
+// request can't be sent.
+func (p *Prober) Probe(url, origin, method string, headers http.Header) (Result, error) {
+	var result Result
+	client := *http.DefaultClient
+	if p.Client != nil {
+		client = *p.Client
+	}
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	method = strings.ToUpper(method)
+
+	if unsafe := unsafeHeaders(headers); !isSimpleMethod(method) || len(unsafe) > 0 {
+		h := http.Header{"Origin": {origin}, "Access-Control-Request-Method": {method}}
+		if len(unsafe) > 0 {
+			h.Set("Access-Control-Request-Headers", strings.Join(unsafe, ","))
+		}
+		ex, err := send(&client, url, http.MethodOptions, h)
+		if err != nil {
+			return result, err
+		}
+		result.Preflight = ex
+		if result.Reason = p.checkPreflight(ex, origin, method, unsafe); result.Reason != "" {
+			return result, nil
+		}
+	}
+
+	h := http.Header{}
+	for name, values := range headers {
+		h[http.CanonicalHeaderKey(name)] = values
+	}
+	h.Set("Origin", origin)
+	ex, err := send(&client, url, method, h)
+	if err != nil {
+		return result, err
+	}
+	result.Actual = ex
+	result.Reason = p.checkOrigin(ex, origin)
+	result.Allowed = result.Reason == ""
+	return result, nil
+}
+
+func send(client *http.Client, url, method string, h http.Header) (*Exchange, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = h
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+	return &Exchange{Method: method, Header: h, Status: res.StatusCode, ResponseHeader: res.Header}, nil
+}
+
+// checkOrigin returns why a browser would fail the CORS check of a response
+func (p *Prober) checkOrigin(ex *Exchange, origin string) string {
+	allowed := ex.ResponseHeader.Values("Access-Control-Allow-Origin")
+	switch {
+	case len(allowed) == 0:
+		return "no Access-Control-Allow-Origin header"
+	case len(allowed) > 1:
+		return fmt.Sprintf("several Access-Control-Allow-Origin headers: %q", allowed)
+	case allowed[0] == "*" && p.Credentials:
+		return `Access-Control-Allow-Origin "*" is not allowed for requests with credentials`
+	case allowed[0] != "*" && allowed[0] != origin:
+		return fmt.Sprintf("Access-Control-Allow-Origin %q does not match %q", allowed[0], origin)
+	}
+	if p.Credentials && ex.ResponseHeader.Get("Access-Control-Allow-Credentials") != "true" {
+		return "Access-Control-Allow-Credentials is not true for a request with credentials"
+	}
+	return ""
+}
+
+// checkPreflight returns why a browser would fail a preflight
+func (p *Prober) checkPreflight(ex *Exchange, origin, method string, headers []string) string {
+	if ex.Status < 200 || ex.Status > 299 {
+		return fmt.Sprintf("preflight status %d is not ok", ex.Status)
+	}
+	if reason := p.checkOrigin(ex, origin); reason != "" {
+		return "preflight: " + reason
+	}
+	h := ex.ResponseHeader
+	if !isSimpleMethod(method) && !listHas(h, "Access-Control-Allow-Methods", method, !p.Credentials, true) {
+		return fmt.Sprintf("method %s is not in Access-Control-Allow-Methods %q", method, h.Get("Access-Control-Allow-Methods"))
+	}
+	for _, header := range headers {
+		// "*" never covers Authorization
+		if !listHas(h, "Access-Control-Allow-Headers", header, !p.Credentials && header != "authorization", false) {
+			return fmt.Sprintf("header %s is not in Access-Control-Allow-Headers %q", header, h.Get("Access-Control-Allow-Headers"))
+		}
+	}
+	return ""
+}
+
+// listHas reports whether the comma-separated lists of h[name] hold value, or "*"
+// if wildcard is set. Methods are compared case-sensitively, as browsers do.
+func listHas(h http.Header, name, value string, wildcard, caseSensitive bool) bool {
+	for _, v := range h.Values(name) {
+		for _, item := range strings.Split(v, ",") {
+			item = strings.TrimSpace(item)
+			if item == value || !caseSensitive && strings.EqualFold(item, value) || wildcard && item == "*" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unsafeHeaders returns the lower-cased, sorted names of the headers which are not
+// CORS-safelisted, as browsers list them in Access-Control-Request-Headers
+func unsafeHeaders(headers http.Header) []string {
+	var unsafe []string
+	for name, values := range headers {
+		name = strings.ToLower(name)
+		switch name {
+		case "accept", "accept-language", "content-language":
+			continue
+		case "content-type":
+			if len(values) == 1 && isSafelistedContentType(values[0]) {
+				continue
+			}
+		}
+		unsafe = append(unsafe, name)
+	}
+	sort.Strings(unsafe)
+	return unsafe
+}
+
+func isSafelistedContentType(value string) bool {
+	mediaType, _, err := mime.ParseMediaType(value)
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "application/x-www-form-urlencoded", "multipart/form-data", "text/plain":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package corstest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/cors"
+)
+
+func TestProbe(t *testing.T) {
+	ts := httptest.NewServer(cors.New(cors.Options{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{"GET", "POST", "PUT"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		AllowCredentials: true,
+	}).Handler(okHandler))
+	defer ts.Close()
+
+	cases := []struct {
+		name        string
+		origin      string
+		method      string
+		headers     http.Header
+		credentials bool
+		preflight   bool
+		allowed     bool
+		reason      string
+	}{
+		{"Simple", "https://app.example.com", "GET", nil, true, false, true, ""},
+		{"SafelistedContentType", "https://app.example.com", "POST", http.Header{"Content-Type": {"text/plain"}}, false, false, true, ""},
+		{"Preflighted", "https://app.example.com", "put", http.Header{"Authorization": {"Bearer x"}}, true, true, true, ""},
+		{"DisallowedOrigin", "https://evil.example.com", "GET", nil, false, false, false, "no Access-Control-Allow-Origin"},
+		{"DisallowedMethod", "https://app.example.com", "DELETE", nil, false, true, false, "preflight: no Access-Control-Allow-Origin"},
+		{"DisallowedHeader", "https://app.example.com", "GET", http.Header{"X-Debug": {"1"}}, false, true, false, "preflight: no Access-Control-Allow-Origin"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := Prober{Credentials: tc.credentials}
+			res, err := p.Probe(ts.URL, tc.origin, tc.method, tc.headers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (res.Preflight != nil) != tc.preflight {
+				t.Errorf("preflight = %v, want %v", res.Preflight != nil, tc.preflight)
+			}
+			if res.Allowed != tc.allowed || !strings.HasPrefix(res.Reason, tc.reason) {
+				t.Errorf("allowed = %v (%s), want %v (%s)", res.Allowed, res.Reason, tc.allowed, tc.reason)
+			}
+			if tc.allowed && res.Actual == nil {
+				t.Error("actual request not sent")
+			}
+		})
+	}
+}
+
+func TestProbeWildcardWithCredentials(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "*")
+		w.Header().Set("Access-Control-Allow-Headers", "*")
+	}))
+	defer ts.Close()
+
+	res, err := Probe(ts.URL, "https://app.example.com", "PUT", http.Header{"X-Debug": {"1"}})
+	if err != nil || !res.Allowed {
+		t.Errorf("without credentials: %+v, %v", res, err)
+	}
+	p := Prober{Credentials: true}
+	res, err = p.Probe(ts.URL, "https://app.example.com", "PUT", http.Header{"X-Debug": {"1"}})
+	if err != nil || res.Allowed || res.Actual != nil {
+		t.Errorf("with credentials: %+v, %v", res, err)
+	}
+	res, _ = Probe(ts.URL, "https://app.example.com", "PUT", http.Header{"Authorization": {"Bearer x"}})
+	if res.Allowed {
+		t.Error(`Authorization covered by "*"`)
+	}
+}
@@ -0,0 +1,45 @@
+package cors
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// isSafeMethod reports whether method is one which must not change state
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// checkUnsafeOrigin applies Options.EnforceOriginForUnsafeMethods to the actual
+// request r, origin being its Origin header
+func (p *policy) checkUnsafeOrigin(r *http.Request, origin string) error {
+	if isSafeMethod(r.Method) {
+		return nil
+	}
+	source := "Origin"
+	if origin == "" {
+		referer := headerValue(r.Header, "Referer")
+		if referer == "" {
+			return nil
+		}
+		source = "Referer"
+		if u, err := url.Parse(referer); err == nil && u.Scheme != "" && u.Host != "" {
+			origin = u.Scheme + "://" + u.Host
+		} else {
+			origin = referer
+		}
+	}
+	if p.isSameOrigin(r, origin) {
+		return nil
+	}
+	if checkOriginSyntax(r, origin) == nil {
+		if _, ok := p.matchOrigin(r, origin); ok {
+			return nil
+		}
+	}
+	return &UnsafeOriginError{Method: r.Method, Origin: origin, Source: source}
+}
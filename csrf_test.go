@@ -0,0 +1,95 @@
+package cors
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEnforceOriginForUnsafeMethodsScheme(t *testing.T) {
+	cases := []struct {
+		name    string
+		options Options
+		tls     bool
+		header  map[string]string
+		status  int
+	}{
+		{"CrossScheme", Options{}, false, map[string]string{"Origin": "https://example.com"}, http.StatusForbidden},
+		{"CrossSchemeReferer", Options{}, false, map[string]string{"Referer": "https://example.com/form"}, http.StatusForbidden},
+		{"DowngradedScheme", Options{}, true, map[string]string{"Origin": "http://example.com"}, http.StatusForbidden},
+		{"TLS", Options{}, true, map[string]string{"Origin": "https://example.com"}, http.StatusOK},
+		{"UntrustedProto", Options{}, false, map[string]string{
+			"Origin":            "https://example.com",
+			"X-Forwarded-Proto": "https",
+		}, http.StatusForbidden},
+		{"ForwardedProto", Options{TrustForwardedProto: true}, false, map[string]string{
+			"Origin":            "https://example.com",
+			"X-Forwarded-Proto": "https, http",
+		}, http.StatusOK},
+		{"Forwarded", Options{TrustForwardedProto: true}, false, map[string]string{
+			"Origin":    "https://example.com",
+			"Forwarded": `for=192.0.2.60;proto="https";by=203.0.113.43`,
+		}, http.StatusOK},
+		{"ForwardedHTTP", Options{TrustForwardedProto: true}, true, map[string]string{
+			"Origin":            "https://example.com",
+			"X-Forwarded-Proto": "http",
+		}, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		tc.options.AllowedOrigins = []string{"https://foo.com"}
+		tc.options.EnforceOriginForUnsafeMethods = true
+		req, _ := http.NewRequest("POST", "http://example.com/foo", nil)
+		if tc.tls {
+			req.TLS = &tls.ConnectionState{}
+		}
+		for name, value := range tc.header {
+			req.Header.Add(name, value)
+		}
+		res := httptest.NewRecorder()
+		New(tc.options).Handler(testHandler).ServeHTTP(res, req)
+		if res.Code != tc.status {
+			t.Errorf("%s: status = %d, want %d", tc.name, res.Code, tc.status)
+		}
+	}
+}
+
+func TestEnforceOriginForUnsafeMethods(t *testing.T) {
+	cases := []struct {
+		name   string
+		method string
+		header map[string]string
+		status int
+	}{
+		{"NoHeaders", "POST", nil, http.StatusOK},
+		{"SafeMethod", "GET", map[string]string{"Origin": "http://bar.com"}, http.StatusOK},
+		{"AllowedOrigin", "DELETE", map[string]string{"Origin": "http://foo.com"}, http.StatusOK},
+		{"SameOrigin", "POST", map[string]string{"Origin": "http://example.com"}, http.StatusOK},
+		{"DisallowedOrigin", "POST", map[string]string{"Origin": "http://bar.com"}, http.StatusForbidden},
+		{"NullOrigin", "PUT", map[string]string{"Origin": "null"}, http.StatusForbidden},
+		{"AllowedReferer", "PATCH", map[string]string{"Referer": "http://foo.com/page?q=1"}, http.StatusOK},
+		{"SameOriginReferer", "POST", map[string]string{"Referer": "http://example.com/form"}, http.StatusOK},
+		{"DisallowedReferer", "POST", map[string]string{"Referer": "http://bar.com/form"}, http.StatusForbidden},
+	}
+	s := New(Options{
+		AllowedOrigins:                []string{"http://foo.com"},
+		AllowedMethods:                []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+		EnforceOriginForUnsafeMethods: true,
+	})
+	for _, tc := range cases {
+		req, _ := http.NewRequest(tc.method, "http://example.com/foo", nil)
+		for name, value := range tc.header {
+			req.Header.Add(name, value)
+		}
+		res := httptest.NewRecorder()
+		s.Handler(testHandler).ServeHTTP(res, req)
+		if res.Code != tc.status {
+			t.Errorf("%s: status = %d, want %d", tc.name, res.Code, tc.status)
+		}
+		d := s.Check(req)
+		if rejected := errors.Is(d.Err, ErrUnsafeOrigin); rejected != (tc.status == http.StatusForbidden) {
+			t.Errorf("%s: Check error = %v", tc.name, d.Err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// setDebugHeader explains a denial in the X-Cors-Debug response header when the
+// Debug option is set, as browsers only tell about missing CORS headers
+func (p *policy) setDebugHeader(h http.Header, d Decision) {
+	if !p.debug || d.Allowed || d.Origin == "" {
+		return
+	}
+	h.Set("X-Cors-Debug", sanitizeHeaderValue(explainDenial(d)))
+}
+
+// explainDenial describes why d was denied in a single line
+func explainDenial(d Decision) string {
+	var originErr *OriginNotAllowedError
+	var methodErr *MethodNotAllowedError
+	var headersErr *HeadersNotAllowedError
+	var why []string
+	switch {
+	case errors.As(d.Err, &originErr):
+		if originErr.Denied != "" {
+			why = append(why, fmt.Sprintf("matched denied pattern %s", originErr.Denied))
+		} else {
+			why = append(why, fmt.Sprintf("no pattern matched; candidates: %s", listOrNone(originErr.Allowed)))
+		}
+		return fmt.Sprintf("origin %s denied: %s", d.Origin, strings.Join(why, "; "))
+	case errors.As(d.Err, &methodErr):
+		why = append(why, fmt.Sprintf("method %s not allowed; allowed: %s", methodErr.Method, listOrNone(methodErr.Allowed)))
+	}
+	if errors.As(d.Err, &headersErr) {
+		allowed := "*"
+		if headersErr.Allowed != nil {
+			allowed = strings.Join(headersErr.Allowed, ", ")
+		}
+		why = append(why, fmt.Sprintf("headers %s not allowed; allowed: %s", strings.Join(headersErr.Headers, ", "), allowed))
+	}
+	if len(why) == 0 && d.Err != nil {
+		why = append(why, d.Err.Error())
+	}
+	return fmt.Sprintf("origin %s denied (%s): %s", d.Origin, d.Reason, strings.Join(why, "; "))
+}
+
+// listOrNone joins values, or returns "none" for an empty list
+func listOrNone(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, ", ")
+}
+
+// sanitizeHeaderValue replaces the control characters of s, which may come from
+// request headers, so that it can be sent as a header value
+func sanitizeHeaderValue(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return '?'
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,55 @@
+package cors
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDebugHeader(t *testing.T) {
+	options := Options{
+		AllowedOrigins: []string{"https://app.com", "https://*.app.com"},
+		DeniedOrigins:  []string{"https://legacy.app.com"},
+		AllowedMethods: []string{"GET"},
+		AllowedHeaders: []string{"X-Header-1"},
+		Debug:          true,
+	}
+	s := New(options)
+	s.Log = log.New(ioutil.Discard, "", 0)
+	cases := []struct {
+		origin, method, headers string
+		want                    string
+	}{
+		{"https://app.com", "GET", "", ""},
+		{"https://evil.com", "GET", "", "origin https://evil.com denied: no pattern matched; candidates: https://app.com, https://*.app.com"},
+		{"https://legacy.app.com", "GET", "", "origin https://legacy.app.com denied: matched denied pattern https://legacy.app.com"},
+		{"https://app.com", "PUT", "x-header-2", "origin https://app.com denied (method): method PUT not allowed; allowed: GET; " +
+			"headers X-Header-2 not allowed; allowed: Origin, X-Header-1"},
+		{"https://app.com/\x01", "GET", "", "origin https://app.com/? denied (malformed-origin): malformed origin 'https://app.com/?'"},
+	}
+	for _, tc := range cases {
+		req, _ := http.NewRequest("OPTIONS", "http://api.com/foo", nil)
+		req.Header.Add("Origin", tc.origin)
+		req.Header.Add("Access-Control-Request-Method", tc.method)
+		if tc.headers != "" {
+			req.Header.Add("Access-Control-Request-Headers", tc.headers)
+		}
+		res := httptest.NewRecorder()
+		s.Handler(testHandler).ServeHTTP(res, req)
+		if got := res.Header().Get("X-Cors-Debug"); got != tc.want {
+			t.Errorf("%s %s: X-Cors-Debug = %q, want %q", tc.origin, tc.method, got, tc.want)
+		}
+	}
+
+	options.Debug = false
+	s = New(options)
+	req, _ := http.NewRequest("GET", "http://api.com/foo", nil)
+	req.Header.Add("Origin", "https://evil.com")
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	if got := res.Header().Get("X-Cors-Debug"); got != "" {
+		t.Errorf("X-Cors-Debug = %q without Debug", got)
+	}
+}
@@ -0,0 +1,100 @@
+package cors
+
+import "net/http"
+
+// Decision is the outcome of the CORS policy evaluation for a request.
+type Decision struct {
+	// Preflight is set for preflight requests
+	Preflight bool
+
+	// Origin is the request origin. Requests without an Origin header are not
+	// cross-origin requests: they are never allowed nor denied and Err is nil,
+	// unless rejected by Options.EnforceFetchMetadata.
+	Origin string
+
+	// Method is the method checked: the requested method for preflight requests,
+	// the request method otherwise
+	Method string
+
+	// Allowed is set when the request passes the policy
+	Allowed bool
+
+	// MatchedOrigin is the rule which allowed the origin: the matching pattern, "*"
+	// when all origins are allowed, "null" for the null origin, or an empty string
+	// when allowed by AllowOriginFunc.
+	MatchedOrigin string
+
+	// AllowedMethods and AllowedHeaders are the methods and headers granted to the
+	// request. They must not be modified.
+	AllowedMethods []string
+	AllowedHeaders []string
+
+	// Reason is the denial reason (ReasonOrigin, ReasonMethod...), empty when allowed
+	Reason string
+
+	// Err describes why the request was denied
+	Err error
+
+	// Message is the text describing the denial to the client, from
+	// Options.Messages or Err. It is only set on the decisions given to
+	// Options.ErrorHandler.
+	Message string
+
+	// CORS headers to add to the response when allowed, shared with the preflight
+	// cache and must not be modified
+	header http.Header
+}
+
+// deny returns a copy of d denied for the given reason
+func (d Decision) deny(reason string, err error) Decision {
+	d.Allowed = false
+	d.AllowedMethods = nil
+	d.AllowedHeaders = nil
+	d.Reason = reason
+	d.Err = err
+	d.header = nil
+	return d
+}
+
+// Check evaluates the policy for r without writing anything, so that the same
+// policy can be applied to protocols where the middleware model doesn't fit, such
+// as WebSocket upgrades. OPTIONS requests with an Access-Control-Request-Method
+// header are evaluated as preflight requests.
+func (c *Cors) Check(r *http.Request) Decision {
+	p, err := c.current().resolve(r)
+	if err != nil {
+		return c.current().resolveError(nil, r, err)
+	}
+	if isPreflight(r) {
+		return p.checkPreflight(r)
+	}
+	return p.checkActual(r)
+}
+
+// isPreflight reports whether r is a CORS preflight request
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && headerValue(r.Header, "Access-Control-Request-Method") != ""
+}
+
+// CheckWebSocketOrigin reports whether the origin of a WebSocket handshake is
+// allowed by the policy. It is meant to be used as the CheckOrigin function of a
+// gorilla/websocket Upgrader:
+//
+//	upgrader := websocket.Upgrader{CheckOrigin: c.CheckWebSocketOrigin}
+//
+// Requests without an Origin header come from non-browser clients and are allowed.
+func (c *Cors) CheckWebSocketOrigin(r *http.Request) bool {
+	origin := headerValue(r.Header, "Origin")
+	if origin == "" {
+		return true
+	}
+	if checkOriginSyntax(r, origin) != nil {
+		return false
+	}
+	p, err := c.current().resolve(r)
+	if err != nil {
+		return false
+	}
+	_, ok := p.matchOrigin(r, origin)
+	return ok
+}
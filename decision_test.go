@@ -0,0 +1,240 @@
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"http://foo.com", "http://*.bar.com"},
+		AllowedMethods: []string{"GET", "PUT"},
+		AllowedHeaders: []string{"X-Header-1"},
+	})
+	cases := []struct {
+		name       string
+		method     string
+		reqHeaders map[string]string
+		want       Decision
+	}{
+		{
+			"NoOrigin",
+			"GET",
+			map[string]string{},
+			Decision{Method: "GET"},
+		},
+		{
+			"Actual",
+			"GET",
+			map[string]string{"Origin": "http://a.bar.com"},
+			Decision{Origin: "http://a.bar.com", Method: "GET", Allowed: true, MatchedOrigin: "http://*.bar.com", AllowedMethods: []string{"GET"}},
+		},
+		{
+			"ActualDisallowedOrigin",
+			"GET",
+			map[string]string{"Origin": "http://baz.com"},
+			Decision{Origin: "http://baz.com", Method: "GET", Reason: ReasonOrigin, Err: &OriginNotAllowedError{Origin: "http://baz.com", Allowed: []string{"http://foo.com", "http://*.bar.com"}}},
+		},
+		{
+			"ActualDisallowedMethod",
+			"DELETE",
+			map[string]string{"Origin": "http://foo.com"},
+			Decision{Origin: "http://foo.com", Method: "DELETE", MatchedOrigin: "http://foo.com", Reason: ReasonMethod, Err: &MethodNotAllowedError{Method: "DELETE", Origin: "http://foo.com", Allowed: []string{"GET", "PUT"}}},
+		},
+		{
+			"Preflight",
+			"OPTIONS",
+			map[string]string{"Origin": "http://foo.com", "Access-Control-Request-Method": "PUT", "Access-Control-Request-Headers": "x-header-1"},
+			Decision{Preflight: true, Origin: "http://foo.com", Method: "PUT", Allowed: true, MatchedOrigin: "http://foo.com", AllowedMethods: []string{"PUT"}, AllowedHeaders: []string{"X-Header-1"}},
+		},
+		{
+			"PreflightDisallowedHeaders",
+			"OPTIONS",
+			map[string]string{"Origin": "http://foo.com", "Access-Control-Request-Method": "PUT", "Access-Control-Request-Headers": "x-header-2"},
+			Decision{Preflight: true, Origin: "http://foo.com", Method: "PUT", MatchedOrigin: "http://foo.com", Reason: ReasonHeaders, Err: &HeadersNotAllowedError{Headers: []string{"X-Header-2"}, Origin: "http://foo.com", Allowed: []string{"Origin", "X-Header-1"}}},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tc.method, "http://example.com/foo", nil)
+			for name, value := range tc.reqHeaders {
+				req.Header.Add(name, value)
+			}
+			got := s.Check(req)
+			got.header = nil
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Check() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckWebSocketOrigin(t *testing.T) {
+	s := New(Options{AllowedOrigins: []string{"https://*.foo.com"}, AllowedMethods: []string{"POST"}})
+	cases := map[string]bool{
+		"":                     true,
+		"https://app.foo.com":  true,
+		"https://app.evil.com": false,
+	}
+	for origin, want := range cases {
+		req, _ := http.NewRequest("GET", "http://example.com/ws", nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if got := s.CheckWebSocketOrigin(req); got != want {
+			t.Errorf("CheckWebSocketOrigin() with origin %q = %v, want %v", origin, got, want)
+		}
+	}
+}
+
+func TestCheckHeaderCasing(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"http://foo.com"},
+		AllowedHeaders: []string{"X-Header-1", "X-Header-2"},
+	})
+	cases := []struct {
+		name    string
+		method  string
+		header  http.Header
+		allowed bool
+	}{
+		{"LowerCase", "GET", http.Header{"origin": {"http://foo.com"}}, true},
+		{"MixedCase", "GET", http.Header{"oRiGiN": {"http://foo.com"}}, true},
+		{"CanonicalWins", "GET", http.Header{"Origin": {"http://evil.com"}, "origin": {"http://foo.com"}}, false},
+		{"ByteOrderWins", "GET", http.Header{"oRIGIN": {"http://evil.com"}, "origin": {"http://foo.com"}}, false},
+		{"Preflight", "OPTIONS", http.Header{
+			"ORIGIN":                         {"http://foo.com"},
+			"access-control-request-method":  {"GET"},
+			"Access-Control-Request-Headers": {"x-header-1"},
+			"ACCESS-CONTROL-REQUEST-HEADERS": {"x-header-2"},
+		}, true},
+		{"PreflightSplitHeaders", "OPTIONS", http.Header{
+			"Origin":                         {"http://foo.com"},
+			"Access-Control-Request-Method":  {"GET"},
+			"Access-Control-Request-Headers": {"x-header-1"},
+			"access-control-request-headers": {"x-header-3"},
+		}, false},
+	}
+	for _, tc := range cases {
+		for i := 0; i < 10; i++ {
+			req := &http.Request{Method: tc.method, Header: tc.header}
+			if d := s.Check(req); d.Allowed != tc.allowed {
+				t.Fatalf("%s: Check().Allowed = %v, want %v (%v)", tc.name, d.Allowed, tc.allowed, d.Err)
+			}
+		}
+	}
+}
+
+func TestOnDecision(t *testing.T) {
+	var got []Decision
+	s := New(Options{
+		AllowedOrigins: []string{"http://foo.com"},
+		OnDecision:     func(d Decision) { got = append(got, d) },
+	})
+	h := s.Handler(testHandler)
+
+	req, _ := http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "http://foo.com")
+	req.Header.Add("Access-Control-Request-Method", "DELETE")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	req, _ = http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "http://bar.com")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	req, _ = http.NewRequest("GET", "http://example.com/foo", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(got) != 2 {
+		t.Fatalf("got %d decisions, want 2", len(got))
+	}
+	if !got[0].Preflight || got[0].Allowed || got[0].Reason != ReasonMethod {
+		t.Errorf("preflight decision = %+v, want denied by method", got[0])
+	}
+	if got[1].Preflight || got[1].Allowed || got[1].Reason != ReasonOrigin {
+		t.Errorf("actual decision = %+v, want denied by origin", got[1])
+	}
+}
+
+func TestOnDecisionSampled(t *testing.T) {
+	var decisions []Decision
+	audited := 0
+	s := New(Options{
+		AllowedOrigins: []string{"http://foo.com"},
+		AllowedMethods: []string{"GET"},
+		SampleAllows:   -1,
+		SampleDenials:  -1,
+		SampleReasons:  map[string]float64{ReasonMethod: 1},
+		OnDecision:     func(d Decision) { decisions = append(decisions, d) },
+		AuditFunc:      func(d Decision, r *http.Request) { audited++ },
+	})
+	h := s.Handler(testHandler)
+	for _, tc := range []struct{ origin, method string }{
+		{"http://foo.com", "GET"},
+		{"http://bar.com", "GET"},
+		{"http://foo.com", "PUT"},
+	} {
+		req, _ := http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+		req.Header.Add("Origin", tc.origin)
+		req.Header.Add("Access-Control-Request-Method", tc.method)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if len(decisions) != 1 || decisions[0].Reason != ReasonMethod {
+		t.Errorf("OnDecision called with %+v, want the method denial only", decisions)
+	}
+	if audited != 1 {
+		t.Errorf("AuditFunc called %d times, want 1", audited)
+	}
+}
+
+func TestCheckRepeatedOrigin(t *testing.T) {
+	s := New(Options{AllowedOrigins: []string{"http://foo.com"}})
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "http://foo.com")
+	req.Header.Add("Origin", "http://bar.com")
+	d := s.Check(req)
+	if d.Allowed || d.Reason != ReasonMalformedOrigin {
+		t.Errorf("Check() = %+v, want denied as malformed", d)
+	}
+	if _, ok := d.Err.(*MalformedOriginError); !ok {
+		t.Errorf("Check().Err = %T, want *MalformedOriginError", d.Err)
+	}
+	if s.CheckWebSocketOrigin(req) {
+		t.Error("CheckWebSocketOrigin() allowed a repeated Origin header")
+	}
+}
+
+func TestCheckErrorContext(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"https://*.foo.com"},
+		DeniedOrigins:  []string{"https://legacy.foo.com"},
+		AllowedHeaders: []string{"X-Header-1"},
+	})
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "https://legacy.foo.com")
+	var originErr *OriginNotAllowedError
+	if err := s.Check(req).Err; !errors.As(err, &originErr) {
+		t.Fatalf("errors.As(%v, *OriginNotAllowedError) failed", err)
+	}
+	if originErr.Denied != "https://legacy.foo.com" || !reflect.DeepEqual(originErr.Allowed, []string{"https://*.foo.com"}) {
+		t.Errorf("origin error = %+v", originErr)
+	}
+
+	req, _ = http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "https://app.foo.com")
+	req.Header.Add("Access-Control-Request-Method", "GET")
+	req.Header.Add("Access-Control-Request-Headers", "x-header-1,x-header-2,x-header-3")
+	var headersErr *HeadersNotAllowedError
+	if err := s.Check(req).Err; !errors.As(fmt.Errorf("wrapped: %w", err), &headersErr) {
+		t.Fatalf("errors.As(%v, *HeadersNotAllowedError) failed", err)
+	}
+	if !reflect.DeepEqual(headersErr.Headers, []string{"X-Header-2", "X-Header-3"}) || headersErr.Origin != "https://app.foo.com" {
+		t.Errorf("headers error = %+v", headersErr)
+	}
+}
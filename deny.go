@@ -0,0 +1,140 @@
+package cors
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// blocks reports whether the response to r, denied or not by d, is to be replaced
+// by a DenyWithStatus error response
+func (p *policy) blocks(r *http.Request, d Decision) bool {
+	if d.Reason == ReasonFetchMetadata || d.Reason == ReasonUnsafeOrigin {
+		return !p.reportOnly
+	}
+	if p.denyStatus == 0 || d.Allowed || d.Origin == "" || p.reportOnly {
+		return false
+	}
+	if d.Preflight && p.passthrough(r) {
+		return false
+	}
+	return d.Preflight || !p.isSameOrigin(r, d.Origin)
+}
+
+// writeDenial answers a blocked request with the DenyWithStatus status, through
+// ErrorHandler if any
+func (p *policy) writeDenial(w http.ResponseWriter, r *http.Request, d Decision) {
+	status := p.denyStatus
+	if status == 0 {
+		status = http.StatusForbidden
+	}
+	p.logf(r, "Request blocked with status %d", status)
+	p.writeError(w, r, d, status)
+}
+
+// writeMethodNotAllowed answers a preflight for a method which is not allowed with
+// a 405 status and the allowed methods in the Allow header
+func (p *policy) writeMethodNotAllowed(w http.ResponseWriter, r *http.Request, d Decision) {
+	allowed := routeMethods(r)
+	if allowed == nil {
+		allowed = p.allowedMethods
+	}
+	p.logf(r, "Preflight aborted: method '%s' not allowed, allowed: %v", d.Method, allowed)
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	p.writeError(w, r, d, http.StatusMethodNotAllowed)
+}
+
+// writeError writes an error response with status for the denial d, through
+// ErrorHandler if any
+func (p *policy) writeError(w http.ResponseWriter, r *http.Request, d Decision, status int) {
+	if p.errorHandler != nil {
+		d.Message = p.message(d)
+		p.errorHandler(w, r, status, d)
+		return
+	}
+	if p.messages != nil {
+		http.Error(w, p.message(d), status)
+		return
+	}
+	http.Error(w, http.StatusText(status), status)
+}
+
+// writePreflight writes the response of a preflight request answered by the
+// middleware
+func (p *policy) writePreflight(w http.ResponseWriter, r *http.Request, d Decision) {
+	if !d.Allowed && d.Origin != "" && !p.reportOnly {
+		switch {
+		case p.methodNotAllowed && d.Reason == ReasonMethod:
+			p.writeMethodNotAllowed(w, r, d)
+		case p.blocks(r, d):
+			p.writeDenial(w, r, d)
+		case p.errorHandler != nil:
+			d.Message = p.message(d)
+			p.errorHandler(w, r, http.StatusOK, d)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+		return
+	}
+	if p.preflightBody == nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	contentType := p.preflightContentType
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(p.preflightBody)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(p.preflightBody)
+}
+
+// isSameOrigin reports whether origin designates the scheme and host r was sent
+// to, as browsers send an Origin header on same-origin POST requests too
+func (p *policy) isSameOrigin(r *http.Request, origin string) bool {
+	if r.Host == "" {
+		return false
+	}
+	scheme := p.requestScheme(r)
+	host := strings.ToLower(r.Host)
+	if scheme == "https" && strings.HasSuffix(host, ":443") || scheme == "http" && strings.HasSuffix(host, ":80") {
+		host = host[:strings.LastIndexByte(host, ':')]
+	}
+	return strings.EqualFold(origin, scheme+"://"+host)
+}
+
+// requestScheme returns the scheme r was sent with: https when received over
+// TLS, or as told by the proxy in front of the server with TrustForwardedProto
+func (p *policy) requestScheme(r *http.Request) string {
+	if p.trustForwardedProto {
+		proto := headerValue(r.Header, "X-Forwarded-Proto")
+		if proto == "" {
+			proto = forwardedProto(headerValue(r.Header, "Forwarded"))
+		}
+		if i := strings.IndexByte(proto, ','); i >= 0 {
+			proto = proto[:i]
+		}
+		if proto = strings.ToLower(strings.TrimSpace(proto)); proto == "http" || proto == "https" {
+			return proto
+		}
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// forwardedProto returns the proto parameter of the first element of a
+// Forwarded header (RFC 7239)
+func forwardedProto(forwarded string) string {
+	if i := strings.IndexByte(forwarded, ','); i >= 0 {
+		forwarded = forwarded[:i]
+	}
+	for _, pair := range strings.Split(forwarded, ";") {
+		if i := strings.IndexByte(pair, '='); i >= 0 && strings.EqualFold(strings.TrimSpace(pair[:i]), "proto") {
+			return strings.Trim(strings.TrimSpace(pair[i+1:]), `"`)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,177 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDenyWithStatus(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"https://app.com"},
+		DenyWithStatus: http.StatusForbidden,
+	})
+	handler := s.Handler(testHandler)
+	cases := []struct {
+		name    string
+		method  string
+		host    string
+		headers map[string]string
+		code    int
+		body    string
+	}{
+		{"Allowed", "GET", "api.com", map[string]string{"Origin": "https://app.com"}, http.StatusOK, "bar"},
+		{"SameOrigin", "POST", "api.com", map[string]string{}, http.StatusOK, "bar"},
+		{"SameOriginWithOrigin", "POST", "api.com", map[string]string{"Origin": "http://api.com"}, http.StatusOK, "bar"},
+		{"SameOriginDefaultPort", "POST", "api.com:80", map[string]string{"Origin": "http://api.com"}, http.StatusOK, "bar"},
+		{"CrossSchemeOrigin", "POST", "api.com", map[string]string{"Origin": "https://api.com"}, http.StatusForbidden, "Forbidden\n"},
+		{"Denied", "GET", "api.com", map[string]string{"Origin": "https://evil.com"}, http.StatusForbidden, "Forbidden\n"},
+		{"DeniedPreflight", "OPTIONS", "api.com", map[string]string{
+			"Origin":                        "https://evil.com",
+			"Access-Control-Request-Method": "GET",
+		}, http.StatusForbidden, "Forbidden\n"},
+		{"AllowedPreflight", "OPTIONS", "api.com", map[string]string{
+			"Origin":                        "https://app.com",
+			"Access-Control-Request-Method": "GET",
+		}, http.StatusOK, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tc.method, "http://"+tc.host+"/foo", nil)
+			for name, value := range tc.headers {
+				req.Header.Add(name, value)
+			}
+			res := httptest.NewRecorder()
+			handler.ServeHTTP(res, req)
+			assertResponse(t, res, tc.code)
+			if body := res.Body.String(); body != tc.body {
+				t.Errorf("body = %q, want %q", body, tc.body)
+			}
+		})
+	}
+}
+
+func TestDenyWithStatusErrorHandler(t *testing.T) {
+	var got Decision
+	s := New(Options{
+		AllowedOrigins: []string{"https://app.com"},
+		DenyWithStatus: http.StatusForbidden,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, status int, d Decision) {
+			got = d
+			w.WriteHeader(status)
+			w.Write([]byte(d.Reason))
+		},
+	})
+	req, _ := http.NewRequest("GET", "http://api.com/foo", nil)
+	req.Header.Add("Origin", "https://evil.com")
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	assertResponse(t, res, http.StatusForbidden)
+	if body := res.Body.String(); body != ReasonOrigin || got.Origin != "https://evil.com" {
+		t.Errorf("error handler wrote %q for %+v", body, got)
+	}
+
+	// Report only mode never blocks
+	s = New(Options{AllowedOrigins: []string{"https://app.com"}, DenyWithStatus: http.StatusForbidden, ReportOnly: true})
+	res = httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	assertResponse(t, res, http.StatusOK)
+}
+
+func TestPreflightResponseBody(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins:        []string{"https://app.com"},
+		PreflightResponseBody: []byte(`{"ok":true}`),
+		PreflightContentType:  "application/json",
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, status int, d Decision) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			w.Write([]byte(`{"ok":false}`))
+		},
+	})
+	handler := s.Handler(testHandler)
+	for origin, body := range map[string]string{"https://app.com": `{"ok":true}`, "https://evil.com": `{"ok":false}`} {
+		req, _ := http.NewRequest("OPTIONS", "http://api.com/foo", nil)
+		req.Header.Add("Origin", origin)
+		req.Header.Add("Access-Control-Request-Method", "GET")
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		assertResponse(t, res, http.StatusOK)
+		if got := res.Body.String(); got != body {
+			t.Errorf("%s: body = %q, want %q", origin, got, body)
+		}
+		if got := res.Header().Get("Content-Type"); got != "application/json" {
+			t.Errorf("%s: Content-Type = %q", origin, got)
+		}
+	}
+}
+
+func TestPreflightMethodNotAllowed(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins:            []string{"http://foo.com"},
+		AllowedMethods:            []string{"GET", "PUT"},
+		PreflightMethodNotAllowed: true,
+		DenyWithStatus:            http.StatusForbidden,
+	})
+	preflight := func(origin, method string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+		req.Header.Add("Origin", origin)
+		req.Header.Add("Access-Control-Request-Method", method)
+		res := httptest.NewRecorder()
+		s.Handler(testHandler).ServeHTTP(res, req)
+		return res
+	}
+
+	res := preflight("http://foo.com", "DELETE")
+	assertResponse(t, res, http.StatusMethodNotAllowed)
+	if allow := res.Header().Get("Allow"); allow != "GET, PUT" {
+		t.Errorf("Allow = %q, want %q", allow, "GET, PUT")
+	}
+	if got := res.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want none", got)
+	}
+
+	// Other denials keep their status
+	res = preflight("http://bar.com", "DELETE")
+	assertResponse(t, res, http.StatusForbidden)
+	assertResponse(t, preflight("http://foo.com", "PUT"), http.StatusOK)
+}
+
+func TestPreflightMethodNotAllowedRouted(t *testing.T) {
+	routed := func(r *http.Request, method string) bool { return method == "GET" || method == "PUT" }
+	preflight := func(options Options, method string) *httptest.ResponseRecorder {
+		options.AllowedOrigins = []string{"http://foo.com"}
+		options.AllowedMethods = []string{"GET", "PUT", "DELETE"}
+		req, _ := http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+		req.Header.Add("Origin", "http://foo.com")
+		req.Header.Add("Access-Control-Request-Method", method)
+		res := httptest.NewRecorder()
+		New(options).RouteHandler(testHandler, routed).ServeHTTP(res, req)
+		return res
+	}
+
+	// Unrouted methods are denied like disallowed ones, without a 405 by default
+	res := preflight(Options{}, "DELETE")
+	assertResponse(t, res, http.StatusOK)
+	if allow := res.Header().Get("Allow"); allow != "" {
+		t.Errorf("Allow = %q, want none", allow)
+	}
+	assertResponse(t, preflight(Options{DenyWithStatus: http.StatusForbidden}, "DELETE"), http.StatusForbidden)
+	assertResponse(t, preflight(Options{PreflightMethodNotAllowed: true, ReportOnly: true}, "DELETE"), http.StatusOK)
+
+	var status int
+	res = preflight(Options{
+		PreflightMethodNotAllowed: true,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, s int, d Decision) {
+			status = s
+			w.WriteHeader(s)
+		},
+	}, "DELETE")
+	assertResponse(t, res, http.StatusMethodNotAllowed)
+	if status != http.StatusMethodNotAllowed {
+		t.Errorf("ErrorHandler status = %d, want %d", status, http.StatusMethodNotAllowed)
+	}
+	if allow := res.Header().Get("Allow"); allow != "GET, PUT" {
+		t.Errorf("Allow = %q, want %q", allow, "GET, PUT")
+	}
+}
@@ -0,0 +1,135 @@
+package cors
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Number of items of each list shown by Options.String and (*Cors).Describe
+const describeMaxItems = 10
+
+// summary builds the single-line description of a policy
+type summary struct {
+	b        strings.Builder
+	maxItems int
+}
+
+func (s *summary) field(name, value string) {
+	if s.b.Len() > 0 {
+		s.b.WriteByte(' ')
+	}
+	s.b.WriteString(name)
+	s.b.WriteByte('=')
+	s.b.WriteString(value)
+}
+
+// list adds a list, truncated to maxItems unless it is zero, if not empty
+func (s *summary) list(name string, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	shown, more := items, 0
+	if s.maxItems > 0 && len(items) > s.maxItems {
+		shown, more = items[:s.maxItems], len(items)-s.maxItems
+	}
+	value := "[" + strings.Join(shown, " ")
+	if more > 0 {
+		value += fmt.Sprintf(" ...+%d more", more)
+	}
+	s.field(name, value+"]")
+}
+
+// flag adds a boolean, if set
+func (s *summary) flag(name string, set bool) {
+	if set {
+		s.field(name, "true")
+	}
+}
+
+func (s *summary) String() string {
+	return "cors{" + s.b.String() + "}"
+}
+
+// String summarizes the options on a single line suitable for startup logs, lists
+// being truncated to 10 items, see Describe.
+func (o Options) String() string {
+	return o.Describe(describeMaxItems)
+}
+
+// Describe summarizes the options on a single line, such as
+//
+//	cors{origins=[https://a.com https://*.b.com ...+998 more] methods=[GET PUT] credentials=true maxAge=600}
+//
+// Lists are truncated to maxItems, zero meaning no truncation, and only set
+// options are shown. Functions, providers and loggers are only reported by their
+// presence, and messages and response bodies are left out, so that the summary
+// can be logged as is.
+func (o Options) Describe(maxItems int) string {
+	s := &summary{maxItems: maxItems}
+	if o.Name != "" {
+		s.field("name", o.Name)
+	}
+	s.list("origins", o.AllowedOrigins)
+	s.list("originsRegex", o.AllowedOriginsRegex)
+	s.list("deniedOrigins", o.DeniedOrigins)
+	s.flag("originFunc", o.AllowOriginFunc != nil || o.AllowOriginVaryFunc != nil)
+	s.flag("originProvider", o.OriginProvider != nil)
+	s.flag("policyResolver", o.PolicyResolver != nil)
+	s.flag("localhost", o.AllowLocalhost)
+	s.flag("nullOrigin", o.AllowNullOrigin)
+	s.list("methods", o.AllowedMethods)
+	s.list("headers", o.AllowedHeaders)
+	s.list("exposedHeaders", o.ExposedHeaders)
+	s.flag("credentials", o.AllowCredentials)
+	s.flag("credentialsFunc", o.AllowCredentialsFunc != nil)
+	switch {
+	case o.MaxAgeFunc != nil:
+		s.field("maxAge", "func")
+	case o.MaxAgeDuration != 0:
+		s.field("maxAge", o.MaxAgeDuration.String())
+	case o.MaxAge != 0:
+		s.field("maxAge", fmt.Sprint(o.MaxAge))
+	}
+	s.flag("privateNetwork", o.AllowPrivateNetwork)
+	s.flag("passthrough", o.OptionsPassthrough || o.PassthroughFunc != nil)
+	s.flag("reportOnly", o.ReportOnly)
+	s.flag("debug", o.Debug)
+	s.flag("shadow", o.ShadowPolicy != nil)
+	return s.String()
+}
+
+// Describe summarizes the policy in effect on a single line like Options.String,
+// defaults being resolved, e.g. the allowed methods when none were configured.
+func (c *Cors) Describe() string {
+	v := c.view()
+	s := &summary{maxItems: describeMaxItems}
+	if name := c.current().name; name != "" {
+		s.field("name", name)
+	}
+	if v.AllowAllOrigins {
+		s.field("origins", "*")
+	} else {
+		s.list("origins", v.AllowedOrigins)
+	}
+	s.list("deniedOrigins", v.DeniedOrigins)
+	s.flag("originFunc", v.AllowOriginFunc)
+	s.flag("originProvider", v.OriginProvider)
+	s.flag("policyResolver", v.PolicyResolver)
+	s.flag("localhost", v.AllowLocalhost)
+	s.flag("nullOrigin", v.AllowNullOrigin)
+	s.list("methods", v.AllowedMethods)
+	s.list("headers", v.AllowedHeaders)
+	s.list("exposedHeaders", v.ExposedHeaders)
+	s.flag("credentials", v.AllowCredentials)
+	s.flag("credentialsFunc", v.AllowCredentialsFunc)
+	if v.MaxAgeFunc {
+		s.field("maxAge", "func")
+	} else if v.MaxAge != 0 {
+		s.field("maxAge", fmt.Sprint(v.MaxAge))
+	}
+	s.flag("privateNetwork", v.AllowPrivateNetwork)
+	s.flag("passthrough", v.OptionsPassthrough)
+	s.flag("reportOnly", v.ReportOnly)
+	s.flag("frozen", v.Frozen)
+	return s.String()
+}
@@ -0,0 +1,53 @@
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOptionsString(t *testing.T) {
+	o := Options{
+		Name:             "api",
+		AllowedOrigins:   []string{"https://a.com", "https://*.b.com"},
+		AllowedMethods:   []string{"GET", "PUT"},
+		AllowCredentials: true,
+		MaxAgeDuration:   10 * time.Minute,
+		AllowOriginFunc:  func(r *http.Request, origin string) bool { return true },
+		Messages:         &Messages{OriginNotAllowed: "secret"},
+	}
+	want := "cors{name=api origins=[https://a.com https://*.b.com] originFunc=true methods=[GET PUT] credentials=true maxAge=10m0s}"
+	if got := o.String(); got != want {
+		t.Errorf("String = %s, want %s", got, want)
+	}
+	if got := fmt.Sprint(o); got != want {
+		t.Errorf("Sprint = %s, want %s", got, want)
+	}
+}
+
+func TestOptionsDescribeTruncates(t *testing.T) {
+	var o Options
+	for i := 0; i < 1000; i++ {
+		o.AllowedOrigins = append(o.AllowedOrigins, fmt.Sprintf("https://tenant%d.example.com", i))
+	}
+	want := "cors{origins=[https://tenant0.example.com https://tenant1.example.com ...+998 more]}"
+	if got := o.Describe(2); got != want {
+		t.Errorf("Describe(2) = %s, want %s", got, want)
+	}
+	if got := o.String(); !strings.HasSuffix(got, "https://tenant9.example.com ...+990 more]}") {
+		t.Errorf("String = %s", got)
+	}
+	if got := o.Describe(0); strings.Contains(got, "more") {
+		t.Errorf("Describe(0) truncated: %s", got)
+	}
+}
+
+func TestCorsDescribe(t *testing.T) {
+	s := New(Options{AllowedHeaders: []string{"X-Header-1"}, MaxAge: 600})
+	want := "cors{origins=* methods=[GET POST HEAD] headers=[Origin X-Header-1] maxAge=600}"
+	if got := s.Describe(); got != want {
+		t.Errorf("Describe = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,59 @@
+// Package devproxy provides a reverse proxy enforcing a CORS policy in front of
+// a target server, so that frontend developers can reproduce production CORS
+// behavior locally, against a service running without it or a remote staging
+// environment:
+//
+//	options, _ := cors.FromEnv("CORS")
+//	log.Fatal(devproxy.ListenAndServe("localhost:8081", "http://localhost:8080", options))
+//
+// Requests are checked by the same code as the production middleware. Preflights
+// are answered by the proxy, and CORS headers set by the target are replaced by
+// the ones of the policy.
+package devproxy
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/go-chi/cors"
+)
+
+var errNotAbsolute = errors.New("target is not an absolute URL")
+
+// New returns a handler proxying requests to target, with the CORS policy of
+// options applied. An error is returned if target is not an absolute URL or the
+// options are invalid (see cors.Options.Validate).
+func New(target string, options cors.Options) (http.Handler, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if !u.IsAbs() || u.Host == "" {
+		return nil, &url.Error{Op: "parse", URL: target, Err: errNotAbsolute}
+	}
+	options.OverrideUpstreamHeaders = true
+	c, err := cors.NewWithError(options)
+	if err != nil {
+		return nil, err
+	}
+	proxy := httputil.NewSingleHostReverseProxy(u)
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+		// Virtual hosts route on the host of the target, not the one of the proxy
+		r.Host = u.Host
+	}
+	return c.Handler(proxy), nil
+}
+
+// ListenAndServe listens on addr and proxies requests to target with the CORS
+// policy of options applied, see New.
+func ListenAndServe(addr, target string, options cors.Options) error {
+	h, err := New(target, options)
+	if err != nil {
+		return err
+	}
+	return http.ListenAndServe(addr, h)
+}
@@ -0,0 +1,79 @@
+package devproxy
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/cors"
+)
+
+func TestProxy(t *testing.T) {
+	var upstreamHost string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamHost = r.Host
+		// A permissive upstream the policy must override
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Write([]byte("upstream " + r.URL.Path))
+	}))
+	defer upstream.Close()
+
+	h, err := New(upstream.URL, cors.Options{
+		AllowedOrigins: []string{"http://localhost:3000"},
+		AllowedMethods: []string{"GET", "PUT"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := httptest.NewServer(h)
+	defer proxy.Close()
+
+	do := func(method, origin string) *http.Response {
+		req, _ := http.NewRequest(method, proxy.URL+"/api/items", nil)
+		req.Header.Set("Origin", origin)
+		if method == http.MethodOptions {
+			req.Header.Set("Access-Control-Request-Method", "PUT")
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Header.Set("X-Body", string(body))
+		return res
+	}
+
+	res := do("GET", "http://localhost:3000")
+	if got := res.Header.Get("Access-Control-Allow-Origin"); got != "http://localhost:3000" {
+		t.Errorf("allowed origin: Access-Control-Allow-Origin = %q", got)
+	}
+	if got := res.Header.Get("X-Body"); got != "upstream /api/items" {
+		t.Errorf("body = %q", got)
+	}
+	if upstreamHost != upstream.Listener.Addr().String() {
+		t.Errorf("upstream Host = %q", upstreamHost)
+	}
+
+	res = do("GET", "https://evil.example.com")
+	if got := res.Header.Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("denied origin: Access-Control-Allow-Origin = %q", got)
+	}
+
+	res = do("OPTIONS", "http://localhost:3000")
+	if got := res.Header.Get("Access-Control-Allow-Methods"); got != "PUT" || res.Header.Get("X-Body") != "" {
+		t.Errorf("preflight forwarded or denied: %v", res.Header)
+	}
+}
+
+func TestNewError(t *testing.T) {
+	for _, target := range []string{"localhost:8080", "/api", "http://[::1"} {
+		if _, err := New(target, cors.Options{}); err == nil {
+			t.Errorf("%s: expected an error", target)
+		}
+	}
+	if _, err := New("http://localhost:8080", cors.Options{AllowedOrigins: []string{"https://*.com"}}); err == nil {
+		t.Error("invalid options: expected an error")
+	}
+}
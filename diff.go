@@ -0,0 +1,148 @@
+package cors
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Equal reports whether o and other configure the same policy, see Diff
+func (o Options) Equal(other Options) bool {
+	return len(o.Diff(other)) == 0
+}
+
+// Diff lists the fields differing between o and other, one readable line per
+// field, e.g.
+//
+//	AllowedOrigins: -"https://old.example.com" +"https://new.example.com"
+//	MaxAge: 600 -> 0
+//
+// Nil and empty lists or maps are considered equal, and lists holding the same items in
+// another order are reported as such. Functions are only compared by presence,
+// as Go can't compare them. Messages and ShadowPolicy are compared by value,
+// other pointers and interfaces (providers, loggers, telemetry...) by identity.
+func (o Options) Diff(other Options) []string {
+	var diff []string
+	a, b := reflect.ValueOf(o), reflect.ValueOf(other)
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Name
+		if line := diffField(a.Field(i), b.Field(i)); line != "" {
+			diff = append(diff, name+": "+line)
+		}
+	}
+	return diff
+}
+
+// diffField describes how x differs from y, or returns an empty string if they
+// are equal
+func diffField(x, y reflect.Value) string {
+	switch x.Kind() {
+	case reflect.Func:
+		if x.IsNil() != y.IsNil() {
+			return fmt.Sprintf("%s -> %s", funcState(x), funcState(y))
+		}
+		return ""
+	case reflect.Slice:
+		if x.Type().Elem().Kind() == reflect.String {
+			return diffList(x.Interface().([]string), y.Interface().([]string))
+		}
+		if x.Len() == 0 && y.Len() == 0 || reflect.DeepEqual(x.Interface(), y.Interface()) {
+			return ""
+		}
+		return fmt.Sprintf("%q -> %q", x.Interface(), y.Interface())
+	case reflect.Map:
+		if x.Len() == 0 && y.Len() == 0 || reflect.DeepEqual(x.Interface(), y.Interface()) {
+			return ""
+		}
+		return fmt.Sprintf("%q -> %q", x.Interface(), y.Interface())
+	case reflect.Ptr:
+		switch v := x.Interface().(type) {
+		case *Options:
+			w := y.Interface().(*Options)
+			if v == nil || w == nil {
+				return diffIdentity(x, y, v == w)
+			}
+			if d := v.Diff(*w); len(d) > 0 {
+				return "{" + strings.Join(d, "; ") + "}"
+			}
+			return ""
+		case *Messages:
+			if !reflect.DeepEqual(v, y.Interface()) {
+				return fmt.Sprintf("%+v -> %+v", x.Interface(), y.Interface())
+			}
+			return ""
+		}
+		return diffIdentity(x, y, x.Pointer() == y.Pointer())
+	case reflect.Interface:
+		if x.IsNil() || y.IsNil() || !x.Elem().Type().Comparable() || !y.Elem().Type().Comparable() {
+			return diffIdentity(x, y, x.IsNil() && y.IsNil() || reflect.DeepEqual(x.Interface(), y.Interface()))
+		}
+		return diffIdentity(x, y, x.Interface() == y.Interface())
+	}
+	if x.Interface() != y.Interface() {
+		return fmt.Sprintf("%v -> %v", x.Interface(), y.Interface())
+	}
+	return ""
+}
+
+// diffList describes the items removed from x and added in y
+func diffList(x, y []string) string {
+	var changes []string
+	in := func(list []string, s string) bool {
+		for _, item := range list {
+			if item == s {
+				return true
+			}
+		}
+		return false
+	}
+	for _, s := range x {
+		if !in(y, s) {
+			changes = append(changes, fmt.Sprintf("-%q", s))
+		}
+	}
+	for _, s := range y {
+		if !in(x, s) {
+			changes = append(changes, fmt.Sprintf("+%q", s))
+		}
+	}
+	if len(changes) > 0 {
+		return strings.Join(changes, " ")
+	}
+	if len(x) != len(y) {
+		return fmt.Sprintf("%q -> %q", x, y)
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return fmt.Sprintf("order %q -> %q", x, y)
+		}
+	}
+	return ""
+}
+
+func funcState(v reflect.Value) string {
+	if v.IsNil() {
+		return "unset"
+	}
+	return "set"
+}
+
+func ptrState(v reflect.Value) string {
+	if v.IsNil() {
+		return "nil"
+	}
+	return "set"
+}
+
+// diffIdentity describes the change of a pointer or interface x to y, same
+// telling whether they are the same
+func diffIdentity(x, y reflect.Value, same bool) string {
+	switch {
+	case same:
+		return ""
+	case x.IsNil() || y.IsNil():
+		return fmt.Sprintf("%s -> %s", ptrState(x), ptrState(y))
+	}
+	return "replaced"
+}
@@ -0,0 +1,52 @@
+package cors
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestOptionsDiff(t *testing.T) {
+	allow := func(r *http.Request, origin string) bool { return true }
+	a := Options{
+		AllowedOrigins: []string{"https://a.com", "https://b.com"},
+		AllowedMethods: []string{"GET", "PUT"},
+		MaxAge:         600,
+		Messages:       &Messages{OriginNotAllowed: "denied"},
+		ShadowPolicy:   &Options{AllowedOrigins: []string{"https://a.com"}},
+	}
+	b := Options{
+		AllowedOrigins:  []string{"https://a.com", "https://c.com"},
+		AllowedMethods:  []string{"PUT", "GET"},
+		ExposedHeaders:  []string{},
+		Messages:        &Messages{OriginNotAllowed: "denied"},
+		ShadowPolicy:    &Options{AllowedOrigins: []string{"https://a.com"}, Debug: true},
+		AllowOriginFunc: allow,
+	}
+	want := []string{
+		`AllowedOrigins: -"https://b.com" +"https://c.com"`,
+		`AllowOriginFunc: unset -> set`,
+		`AllowedMethods: order ["GET" "PUT"] -> ["PUT" "GET"]`,
+		`MaxAge: 600 -> 0`,
+		`ShadowPolicy: {Debug: false -> true}`,
+	}
+	if diff := a.Diff(b); !reflect.DeepEqual(diff, want) {
+		t.Errorf("Diff =\n%q\nwant\n%q", diff, want)
+	}
+	if a.Equal(b) {
+		t.Error("Equal = true, want false")
+	}
+	m := Options{MethodHeaderPolicy: map[string][]string{"DELETE": {"X-Admin-Token"}}}
+	if diff := m.Diff(Options{}); len(diff) != 1 {
+		t.Errorf("Diff of method header policies = %q", diff)
+	}
+
+	c := a
+	c.AllowedOrigins = append([]string(nil), a.AllowedOrigins...)
+	c.Messages = &Messages{OriginNotAllowed: "denied"}
+	c.ExposedHeaders = []string{}
+	c.MethodHeaderPolicy = map[string][]string{}
+	if diff := a.Diff(c); len(diff) > 0 || !a.Equal(c) {
+		t.Errorf("Diff of equal options = %q", diff)
+	}
+}
@@ -0,0 +1,172 @@
+package cors
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// options has the fields of Options but none of its methods, so that they can be
+// (un)marshaled by encoding/json without recursion
+type options Options
+
+// optionsJSON is the JSON form of Options, its durations and body shadowing the
+// fields of the embedded options
+type optionsJSON struct {
+	options
+	PreflightResponseBody  string       `json:"preflightResponseBody,omitempty"`
+	OriginFuncCacheTTL     jsonDuration `json:"originFuncCacheTTL,omitempty"`
+	OriginProviderTTL      jsonDuration `json:"originProviderTTL,omitempty"`
+	MaxAgeDuration         jsonDuration `json:"maxAgeDuration,omitempty"`
+	NegativeOriginCacheTTL jsonDuration `json:"negativeOriginCacheTTL,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler. Fields are named in lower camel case
+// (allowedOrigins, maxAgeDuration...), as in YAML, and functions, providers,
+// resolvers, loggers and telemetry, which can't be serialized, are left out.
+// Durations are written as strings like "10m" or "1h30m", as YAML libraries do,
+// and PreflightResponseBody as a string rather than base64.
+func (o Options) MarshalJSON() ([]byte, error) {
+	return json.Marshal(optionsJSON{
+		options:                options(o),
+		PreflightResponseBody:  string(o.PreflightResponseBody),
+		OriginFuncCacheTTL:     jsonDuration(o.OriginFuncCacheTTL),
+		OriginProviderTTL:      jsonDuration(o.OriginProviderTTL),
+		MaxAgeDuration:         jsonDuration(o.MaxAgeDuration),
+		NegativeOriginCacheTTL: jsonDuration(o.NegativeOriginCacheTTL),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler, reading the form written by
+// MarshalJSON. Durations may also be numbers of nanoseconds. Fields absent from
+// data are left untouched, so that functions can be set beforehand.
+func (o *Options) UnmarshalJSON(data []byte) error {
+	v := optionsJSON{
+		options:                options(*o),
+		PreflightResponseBody:  string(o.PreflightResponseBody),
+		OriginFuncCacheTTL:     jsonDuration(o.OriginFuncCacheTTL),
+		OriginProviderTTL:      jsonDuration(o.OriginProviderTTL),
+		MaxAgeDuration:         jsonDuration(o.MaxAgeDuration),
+		NegativeOriginCacheTTL: jsonDuration(o.NegativeOriginCacheTTL),
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*o = Options(v.options)
+	o.PreflightResponseBody = nil
+	if v.PreflightResponseBody != "" {
+		o.PreflightResponseBody = []byte(v.PreflightResponseBody)
+	}
+	o.OriginFuncCacheTTL = time.Duration(v.OriginFuncCacheTTL)
+	o.OriginProviderTTL = time.Duration(v.OriginProviderTTL)
+	o.MaxAgeDuration = time.Duration(v.MaxAgeDuration)
+	o.NegativeOriginCacheTTL = time.Duration(v.NegativeOriginCacheTTL)
+	return nil
+}
+
+// jsonDuration is a time.Duration written as a string in JSON
+type jsonDuration time.Duration
+
+func (d jsonDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *jsonDuration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n int64
+		if json.Unmarshal(data, &n) != nil {
+			return fmt.Errorf("cors: invalid duration %s", data)
+		}
+		*d = jsonDuration(n)
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("cors: invalid duration %q", s)
+	}
+	*d = jsonDuration(v)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler, m being written as
+// "most-specific" or "first"
+func (m OriginMatchMode) MarshalText() ([]byte, error) {
+	switch m {
+	case MatchMostSpecific:
+		return []byte("most-specific"), nil
+	case MatchFirst:
+		return []byte("first"), nil
+	}
+	return nil, fmt.Errorf("cors: invalid origin match mode %d", int(m))
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (m *OriginMatchMode) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "most-specific", "":
+		*m = MatchMostSpecific
+	case "first":
+		*m = MatchFirst
+	default:
+		return fmt.Errorf("cors: invalid origin match mode %q", text)
+	}
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler, m being written as "echo" or
+// "static"
+func (m AllowedHeadersResponseMode) MarshalText() ([]byte, error) {
+	switch m {
+	case AllowedHeadersEcho:
+		return []byte("echo"), nil
+	case AllowedHeadersStatic:
+		return []byte("static"), nil
+	}
+	return nil, fmt.Errorf("cors: invalid allowed headers response mode %d", int(m))
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (m *AllowedHeadersResponseMode) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "echo", "":
+		*m = AllowedHeadersEcho
+	case "static":
+		*m = AllowedHeadersStatic
+	default:
+		return fmt.Errorf("cors: invalid allowed headers response mode %q", text)
+	}
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler, ResourcePolicyNone being empty
+func (rp ResourcePolicy) MarshalText() ([]byte, error) {
+	switch rp {
+	case ResourcePolicyNone:
+		return []byte{}, nil
+	case ResourcePolicySameOrigin:
+		return []byte("same-origin"), nil
+	case ResourcePolicySameSite:
+		return []byte("same-site"), nil
+	case ResourcePolicyCrossOrigin:
+		return []byte("cross-origin"), nil
+	}
+	return nil, fmt.Errorf("cors: invalid resource policy %d", int(rp))
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (rp *ResourcePolicy) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "":
+		*rp = ResourcePolicyNone
+	case "same-origin":
+		*rp = ResourcePolicySameOrigin
+	case "same-site":
+		*rp = ResourcePolicySameSite
+	case "cross-origin":
+		*rp = ResourcePolicyCrossOrigin
+	default:
+		return fmt.Errorf("cors: invalid resource policy %q", text)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package cors
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOptionsJSON(t *testing.T) {
+	options := Options{
+		AllowedOrigins:             []string{"https://*.example.com", "http://localhost:*"},
+		AllowedMethods:             []string{"GET", "PUT"},
+		AllowedHeaders:             []string{"X-Header-1"},
+		AllowCredentials:           true,
+		MaxAgeDuration:             90 * time.Minute,
+		OriginFuncCacheTTL:         30 * time.Second,
+		OriginMatchMode:            MatchFirst,
+		AllowedHeadersResponseMode: AllowedHeadersStatic,
+		ResourcePolicy:             ResourcePolicySameSite,
+		PreflightResponseBody:      []byte(`{"ok":true}`),
+		Messages:                   &Messages{OriginNotAllowed: "{origin} is not allowed"},
+		ShadowPolicy:               &Options{AllowedOrigins: []string{"https://example.com"}},
+		AllowOriginFunc:            func(r *http.Request, origin string) bool { return true },
+	}
+	data, err := json.Marshal(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"allowedOrigins":["https://*.example.com","http://localhost:*"]`,
+		`"maxAgeDuration":"1h30m0s"`,
+		`"originFuncCacheTTL":"30s"`,
+		`"originMatchMode":"first"`,
+		`"allowedHeadersResponseMode":"static"`,
+		`"resourcePolicy":"same-site"`,
+		`"preflightResponseBody":"{\"ok\":true}"`,
+		`"messages":{"originNotAllowed":"{origin} is not allowed"}`,
+		`"shadowPolicy":{"allowedOrigins":["https://example.com"]}`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("%s does not contain %s", data, want)
+		}
+	}
+	if strings.Contains(string(data), "allowOriginFunc") {
+		t.Errorf("%s contains a function", data)
+	}
+
+	var got Options
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	options.AllowOriginFunc = nil
+	if !reflect.DeepEqual(got, options) {
+		t.Errorf("round trip = %+v, want %+v", got, options)
+	}
+}
+
+func TestOptionsUnmarshalJSON(t *testing.T) {
+	options := Options{AllowedOrigins: []string{"https://example.com"}, MaxAge: 60}
+	err := json.Unmarshal([]byte(`{"maxAge": 600, "negativeOriginCacheTTL": 1000000000}`), &options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options.MaxAge != 600 || options.NegativeOriginCacheTTL != time.Second || len(options.AllowedOrigins) != 1 {
+		t.Errorf("unexpected options %+v", options)
+	}
+
+	for _, data := range []string{
+		`{"maxAgeDuration": "ten minutes"}`,
+		`{"originMatchMode": "last"}`,
+		`{"allowedHeadersResponseMode": "mirror"}`,
+	} {
+		if err := json.Unmarshal([]byte(data), &options); err == nil {
+			t.Errorf("%s: expected an error", data)
+		}
+	}
+}
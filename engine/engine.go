@@ -0,0 +1,355 @@
+// Package engine evaluates CORS requests against a policy. It only works on
+// strings and depends on neither net, net/http nor os, so that it builds for
+// targets without a network stack such as GOOS=wasip1, where edge runtimes hand
+// request headers to a filter rather than an *http.Request:
+//
+//	p, err := engine.New(engine.Config{AllowedOrigins: []string{"https://*.example.com"}})
+//	...
+//	res := p.Evaluate(engine.Request{Method: "GET", Origin: origin})
+//	for name, value := range res.Headers {
+//		...
+//	}
+//
+// The cors package builds on the same origin matching and header parsing, and
+// Evaluate decides as cors.Cors.Check does for the options Config shares with
+// cors.Options.
+package engine
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Denial reasons, equal to the cors package ones
+const (
+	// ReasonOrigin is set when the origin is not allowed
+	ReasonOrigin = "origin"
+	// ReasonMalformedOrigin is set when the Origin header is not a serialized origin
+	ReasonMalformedOrigin = "malformed-origin"
+	// ReasonMethod is set when the method is not allowed
+	ReasonMethod = "method"
+	// ReasonHeaders is set when a requested header is not allowed
+	ReasonHeaders = "headers"
+)
+
+// Config is the subset of cors.Options the engine evaluates, with the same
+// defaults and JSON names.
+type Config struct {
+	// AllowedOrigins is a list of origins a cross-domain request can be executed
+	// from. Default value is all origins, as is an entry set to "*".
+	AllowedOrigins []string `json:"allowedOrigins,omitempty"`
+
+	// AllowedOriginsRegex is a list of regular expressions matching whole origins
+	AllowedOriginsRegex []string `json:"allowedOriginsRegex,omitempty"`
+
+	// DeniedOrigins is a list of origins denied even if otherwise allowed
+	DeniedOrigins []string `json:"deniedOrigins,omitempty"`
+
+	// AllowNullOrigin allows the "null" origin of sandboxed documents
+	AllowNullOrigin bool `json:"allowNullOrigin,omitempty"`
+
+	// OriginMatchMode defines which pattern is reported when several match
+	OriginMatchMode MatchMode `json:"originMatchMode,omitempty"`
+
+	// AllowedMethods is a list of methods the client is allowed to use. Default
+	// value is simple methods (HEAD, GET and POST).
+	AllowedMethods []string `json:"allowedMethods,omitempty"`
+
+	// AllowedHeaders is a list of non simple headers the client is allowed to use.
+	// Default value is Origin, Accept and Content-Type, "*" allows all headers.
+	AllowedHeaders []string `json:"allowedHeaders,omitempty"`
+
+	// ExposedHeaders lists the headers which are safe to expose to the API of a
+	// CORS API specification
+	ExposedHeaders []string `json:"exposedHeaders,omitempty"`
+
+	// AllowCredentials allows secure origins to send cookies and HTTP
+	// authentication
+	AllowCredentials bool `json:"allowCredentials,omitempty"`
+
+	// MaxAge is how long, in seconds, the results of a preflight request can be
+	// cached. Zero omits the header and a negative value disables caching.
+	MaxAge int `json:"maxAge,omitempty"`
+
+	// AllowPrivateNetwork answers Private Network Access preflight requests
+	AllowPrivateNetwork bool `json:"allowPrivateNetwork,omitempty"`
+}
+
+// Policy is a compiled Config. It is safe for concurrent use.
+type Policy struct {
+	allowedOriginsAll   bool
+	origins             *OriginMatcher
+	deniedOrigins       *OriginMatcher
+	allowNullOrigin     bool
+	allowedMethods      []string
+	allowedHeaders      []string
+	allowedHeadersAll   bool
+	exposeHeadersValue  string
+	allowCredentials    bool
+	maxAgeValue         string
+	allowPrivateNetwork bool
+}
+
+// New compiles config, failing on invalid regular expressions
+func New(config Config) (*Policy, error) {
+	p := &Policy{
+		allowNullOrigin:     config.AllowNullOrigin,
+		allowCredentials:    config.AllowCredentials,
+		allowPrivateNetwork: config.AllowPrivateNetwork,
+	}
+
+	// Allowed Origins
+	if len(config.AllowedOrigins) == 0 && len(config.AllowedOriginsRegex) == 0 {
+		// Default is all origins
+		p.allowedOriginsAll = true
+	}
+	for _, origin := range config.AllowedOrigins {
+		if origin == "*" {
+			p.allowedOriginsAll = true
+			break
+		}
+	}
+	if len(config.DeniedOrigins) > 0 {
+		p.deniedOrigins, _ = NewOriginMatcher(config.DeniedOrigins, nil, MatchMostSpecific)
+	}
+	if !p.allowedOriginsAll {
+		origins, err := NewOriginMatcher(config.AllowedOrigins, config.AllowedOriginsRegex, config.OriginMatchMode)
+		if err != nil {
+			return nil, err
+		}
+		p.origins = origins
+	}
+
+	// Allowed Headers
+	if len(config.AllowedHeaders) == 0 {
+		p.allowedHeaders = []string{"Accept", "Content-Type", "Origin"}
+	} else {
+		// Origin is always allowed as some browsers always request it at preflight
+		p.allowedHeaders = []string{"Origin"}
+		for _, h := range config.AllowedHeaders {
+			if h == "*" {
+				p.allowedHeadersAll = true
+				p.allowedHeaders = nil
+				break
+			}
+			p.allowedHeaders = append(p.allowedHeaders, canonicalHeaderKey(h))
+		}
+	}
+
+	// Allowed Methods
+	if len(config.AllowedMethods) == 0 {
+		p.allowedMethods = []string{"GET", "POST", "HEAD"}
+	} else {
+		for _, m := range config.AllowedMethods {
+			p.allowedMethods = append(p.allowedMethods, strings.ToUpper(m))
+		}
+	}
+
+	exposed := make([]string, 0, len(config.ExposedHeaders))
+	for _, h := range config.ExposedHeaders {
+		exposed = append(exposed, canonicalHeaderKey(h))
+	}
+	p.exposeHeadersValue = strings.Join(sortedSet(exposed), ", ")
+	switch {
+	case config.MaxAge > 0:
+		p.maxAgeValue = strconv.Itoa(config.MaxAge)
+	case config.MaxAge < 0:
+		p.maxAgeValue = "0"
+	}
+	return p, nil
+}
+
+// Request is what the engine needs to know about a request, taken from its method
+// and headers
+type Request struct {
+	// Method is the request method
+	Method string
+
+	// Origin is the Origin header, empty for same-origin requests
+	Origin string
+
+	// RequestMethod and RequestHeaders are the Access-Control-Request-Method and
+	// Access-Control-Request-Headers headers of preflight requests
+	RequestMethod  string
+	RequestHeaders string
+
+	// RequestPrivateNetwork is set when Access-Control-Request-Private-Network is
+	// "true"
+	RequestPrivateNetwork bool
+}
+
+// Result is the outcome of Evaluate
+type Result struct {
+	// Preflight is set for preflight requests: OPTIONS requests with an
+	// Access-Control-Request-Method header
+	Preflight bool
+
+	// Allowed is set when the request passes the policy. Requests without an
+	// origin are neither allowed nor denied.
+	Allowed bool
+
+	// MatchedOrigin is the rule which allowed the origin: the matching pattern,
+	// "*" when all origins are allowed or "null" for the null origin
+	MatchedOrigin string
+
+	// Reason is the denial reason (ReasonOrigin, ReasonMethod...), empty when
+	// allowed
+	Reason string
+
+	// Headers are the CORS headers to add to the response when allowed, in
+	// canonical form
+	Headers map[string]string
+}
+
+// Evaluate checks r against the policy
+func (p *Policy) Evaluate(r Request) Result {
+	res := Result{Preflight: r.Method == "OPTIONS" && r.RequestMethod != ""}
+	if r.Origin == "" {
+		return res
+	}
+	if !IsSerializedOrigin(r.Origin) {
+		return res.deny(ReasonMalformedOrigin)
+	}
+	pattern, ok := p.matchOrigin(r.Origin)
+	if !ok {
+		return res.deny(ReasonOrigin)
+	}
+	res.MatchedOrigin = pattern
+	method := r.Method
+	if res.Preflight {
+		method = r.RequestMethod
+	}
+	methodAllowed := p.isMethodAllowed(method)
+	var reqHeaders []string
+	if res.Preflight {
+		reqHeaders = ParseHeaderList(r.RequestHeaders)
+		if !p.areHeadersAllowed(reqHeaders) {
+			// The method is reported first when both are denied
+			if !methodAllowed {
+				return res.deny(ReasonMethod)
+			}
+			return res.deny(ReasonHeaders)
+		}
+	}
+	if !methodAllowed {
+		return res.deny(ReasonMethod)
+	}
+
+	res.Allowed = true
+	res.Headers = map[string]string{}
+	p.setOriginHeaders(res.Headers, r.Origin)
+	if !res.Preflight {
+		if p.exposeHeadersValue != "" {
+			res.Headers["Access-Control-Expose-Headers"] = p.exposeHeadersValue
+		}
+		return res
+	}
+	res.Headers["Access-Control-Allow-Methods"] = strings.ToUpper(r.RequestMethod)
+	if reqHeaders, _ = FilterForbiddenHeaders(reqHeaders); len(reqHeaders) > 0 {
+		res.Headers["Access-Control-Allow-Headers"] = strings.Join(sortedSet(reqHeaders), ", ")
+	}
+	if p.maxAgeValue != "" {
+		res.Headers["Access-Control-Max-Age"] = p.maxAgeValue
+	}
+	if p.allowPrivateNetwork && r.RequestPrivateNetwork {
+		res.Headers["Access-Control-Allow-Private-Network"] = "true"
+	}
+	return res
+}
+
+// deny returns a copy of res denied for the given reason
+func (res Result) deny(reason string) Result {
+	res.Allowed = false
+	res.Reason = reason
+	res.Headers = nil
+	return res
+}
+
+// matchOrigin checks if a given origin is allowed and returns the rule which
+// allowed it
+func (p *Policy) matchOrigin(origin string) (string, bool) {
+	if p.deniedOrigins != nil && p.deniedOrigins.Match(origin) != nil {
+		return "", false
+	}
+	if strings.EqualFold(origin, "null") {
+		return "null", p.allowNullOrigin
+	}
+	if p.allowedOriginsAll {
+		return "*", true
+	}
+	if m := p.origins.Match(origin); m != nil {
+		return m.raw, true
+	}
+	return "", false
+}
+
+// isMethodAllowed checks if a given method can be used as part of a cross-domain
+// request, OPTIONS being always allowed
+func (p *Policy) isMethodAllowed(method string) bool {
+	method = strings.ToUpper(method)
+	if method == "OPTIONS" {
+		return true
+	}
+	for _, m := range p.allowedMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// areHeadersAllowed checks if canonical header names are all allowed
+func (p *Policy) areHeadersAllowed(headers []string) bool {
+	if p.allowedHeadersAll {
+		return true
+	}
+	for _, h := range headers {
+		if !IsSafelistedHeader(h) && !containsString(p.allowedHeaders, h) {
+			return false
+		}
+	}
+	return true
+}
+
+// setOriginHeaders sets Access-Control-Allow-Origin and
+// Access-Control-Allow-Credentials for an allowed origin
+func (p *Policy) setOriginHeaders(headers map[string]string, origin string) {
+	if strings.EqualFold(origin, "null") && p.allowNullOrigin {
+		// Any sandboxed document can claim the null origin, it is never granted
+		// credentials
+		headers["Access-Control-Allow-Origin"] = "null"
+		return
+	}
+	if p.allowedOriginsAll {
+		headers["Access-Control-Allow-Origin"] = "*"
+	} else {
+		headers["Access-Control-Allow-Origin"] = origin
+	}
+	if p.allowCredentials && IsSecureOrigin(origin) {
+		headers["Access-Control-Allow-Credentials"] = "true"
+	}
+}
+
+// containsString reports whether s contains v
+func containsString(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedSet returns the values of s sorted and without duplicates
+func sortedSet(s []string) []string {
+	out := append([]string(nil), s...)
+	sort.Strings(out)
+	for i := 1; i < len(out); i++ {
+		if out[i] == out[i-1] {
+			out = append(out[:i], out[i+1:]...)
+			i--
+		}
+	}
+	return out
+}
@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	p, err := New(Config{
+		AllowedOrigins:   []string{"https://*.example.com", "https://app.example.com"},
+		DeniedOrigins:    []string{"https://evil.example.com"},
+		AllowedMethods:   []string{"get", "PUT"},
+		AllowedHeaders:   []string{"x-token"},
+		ExposedHeaders:   []string{"x-total", "X-Page"},
+		AllowCredentials: true,
+		MaxAge:           600,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name string
+		req  Request
+		want Result
+	}{
+		{"same origin", Request{Method: "GET"}, Result{}},
+		{"actual", Request{Method: "GET", Origin: "https://app.example.com"}, Result{
+			Allowed:       true,
+			MatchedOrigin: "https://app.example.com",
+			Headers: map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers":    "X-Page, X-Total",
+			},
+		}},
+		{"preflight", Request{Method: "OPTIONS", Origin: "https://a.example.com", RequestMethod: "put", RequestHeaders: "x-token, accept"}, Result{
+			Preflight:     true,
+			Allowed:       true,
+			MatchedOrigin: "https://*.example.com",
+			Headers: map[string]string{
+				"Access-Control-Allow-Origin":      "https://a.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Allow-Methods":     "PUT",
+				"Access-Control-Allow-Headers":     "Accept, X-Token",
+				"Access-Control-Max-Age":           "600",
+			},
+		}},
+		{"denied origin", Request{Method: "GET", Origin: "https://evil.example.com"}, Result{Reason: ReasonOrigin}},
+		{"unknown origin", Request{Method: "GET", Origin: "https://example.org"}, Result{Reason: ReasonOrigin}},
+		{"malformed origin", Request{Method: "GET", Origin: "https://app.example.com/path"}, Result{Reason: ReasonMalformedOrigin}},
+		{"null origin", Request{Method: "GET", Origin: "null"}, Result{Reason: ReasonOrigin}},
+		{"method", Request{Method: "DELETE", Origin: "https://app.example.com"}, Result{MatchedOrigin: "https://app.example.com", Reason: ReasonMethod}},
+		{"headers", Request{Method: "OPTIONS", Origin: "https://app.example.com", RequestMethod: "PUT", RequestHeaders: "X-Other"},
+			Result{Preflight: true, MatchedOrigin: "https://app.example.com", Reason: ReasonHeaders}},
+		{"method and headers", Request{Method: "OPTIONS", Origin: "https://app.example.com", RequestMethod: "PATCH", RequestHeaders: "X-Other"},
+			Result{Preflight: true, MatchedOrigin: "https://app.example.com", Reason: ReasonMethod}},
+	}
+	for _, tc := range cases {
+		if got := p.Evaluate(tc.req); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: Evaluate() = %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestEvaluateDefaults(t *testing.T) {
+	p, err := New(Config{AllowNullOrigin: true, AllowPrivateNetwork: true, AllowCredentials: true, MaxAge: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := p.Evaluate(Request{Method: "OPTIONS", Origin: "https://foo.com", RequestMethod: "POST", RequestHeaders: "Content-Type", RequestPrivateNetwork: true})
+	want := map[string]string{
+		"Access-Control-Allow-Origin":          "*",
+		"Access-Control-Allow-Credentials":     "true",
+		"Access-Control-Allow-Methods":         "POST",
+		"Access-Control-Allow-Headers":         "Content-Type",
+		"Access-Control-Max-Age":               "0",
+		"Access-Control-Allow-Private-Network": "true",
+	}
+	if !res.Allowed || res.MatchedOrigin != "*" || !reflect.DeepEqual(res.Headers, want) {
+		t.Errorf("Evaluate() = %+v", res)
+	}
+	res = p.Evaluate(Request{Method: "GET", Origin: "null"})
+	if !res.Allowed || !reflect.DeepEqual(res.Headers, map[string]string{"Access-Control-Allow-Origin": "null"}) {
+		t.Errorf("null origin: Evaluate() = %+v", res)
+	}
+	if res = p.Evaluate(Request{Method: "PUT", Origin: "https://foo.com"}); res.Reason != ReasonMethod {
+		t.Errorf("PUT: Evaluate() = %+v, want denied by method", res)
+	}
+	if res = p.Evaluate(Request{Method: "GET", Origin: "http://foo.com"}); res.Headers["Access-Control-Allow-Credentials"] != "" {
+		t.Errorf("insecure origin: Evaluate() = %+v, want no credentials", res)
+	}
+}
+
+func TestNewInvalidRegex(t *testing.T) {
+	if _, err := New(Config{AllowedOriginsRegex: []string{"("}}); err == nil {
+		t.Error("invalid regex should return an error")
+	}
+}
+
+func TestConfigJSON(t *testing.T) {
+	var c Config
+	err := json.Unmarshal([]byte(`{"allowedOrigins":["https://foo.com"],"originMatchMode":"first","maxAge":60}`), &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Config{AllowedOrigins: []string{"https://foo.com"}, OriginMatchMode: MatchFirst, MaxAge: 60}); !reflect.DeepEqual(c, want) {
+		t.Errorf("Config = %+v, want %+v", c, want)
+	}
+	if err := json.Unmarshal([]byte(`{"originMatchMode":"last"}`), &c); err == nil {
+		t.Error("invalid match mode should return an error")
+	}
+}
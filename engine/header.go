@@ -0,0 +1,145 @@
+package engine
+
+import "strings"
+
+const toLower = 'a' - 'A'
+
+// ParseHeaderList tokenizes a comma or space separated list of header names and
+// canonicalizes them (Content-Type)
+func ParseHeaderList(headerList string) []string {
+	l := len(headerList)
+	h := make([]byte, 0, l)
+	upper := true
+	// Estimate the number headers in order to allocate the right splice size
+	t := 0
+	for i := 0; i < l; i++ {
+		if headerList[i] == ',' {
+			t++
+		}
+	}
+	headers := make([]string, 0, t)
+	for i := 0; i < l; i++ {
+		b := headerList[i]
+		if b >= 'a' && b <= 'z' {
+			if upper {
+				h = append(h, b-toLower)
+			} else {
+				h = append(h, b)
+			}
+		} else if b >= 'A' && b <= 'Z' {
+			if !upper {
+				h = append(h, b+toLower)
+			} else {
+				h = append(h, b)
+			}
+		} else if b == '-' || b == '_' || b == '.' || b == ':' || (b >= '0' && b <= '9') {
+			h = append(h, b)
+		}
+
+		if b == ' ' || b == ',' || i == l-1 {
+			if len(h) > 0 {
+				// Flush the found header
+				headers = append(headers, string(h))
+				h = h[:0]
+				upper = true
+			}
+		} else {
+			upper = b == '-'
+		}
+	}
+	return headers
+}
+
+// forbiddenHeaders are the request header names a browser never lets scripts set,
+// in canonical form. Origin is left out: it has always been part of the allowed
+// headers and is echoed as such.
+var forbiddenHeaders = map[string]bool{
+	"Accept-Charset":                 true,
+	"Accept-Encoding":                true,
+	"Access-Control-Request-Headers": true,
+	"Access-Control-Request-Method":  true,
+	"Connection":                     true,
+	"Content-Length":                 true,
+	"Cookie":                         true,
+	"Cookie2":                        true,
+	"Date":                           true,
+	"Dnt":                            true,
+	"Expect":                         true,
+	"Host":                           true,
+	"Keep-Alive":                     true,
+	"Referer":                        true,
+	"Set-Cookie":                     true,
+	"Te":                             true,
+	"Trailer":                        true,
+	"Transfer-Encoding":              true,
+	"Upgrade":                        true,
+	"Via":                            true,
+}
+
+// IsForbiddenHeader reports whether the canonical header name is a forbidden
+// request header or an HTTP/2 pseudo-header
+func IsForbiddenHeader(name string) bool {
+	return forbiddenHeaders[name] || strings.HasPrefix(name, "Proxy-") ||
+		strings.HasPrefix(name, "Sec-") || strings.HasPrefix(name, ":")
+}
+
+// FilterForbiddenHeaders splits canonical header names in allowed and forbidden
+// ones. headers is returned as is when none is forbidden.
+func FilterForbiddenHeaders(headers []string) (allowed, forbidden []string) {
+	for i, h := range headers {
+		if !IsForbiddenHeader(h) {
+			if forbidden != nil {
+				allowed = append(allowed, h)
+			}
+			continue
+		}
+		if forbidden == nil {
+			allowed = append([]string(nil), headers[:i]...)
+		}
+		forbidden = append(forbidden, h)
+	}
+	if forbidden == nil {
+		return headers, nil
+	}
+	return allowed, forbidden
+}
+
+// safelistedHeaders are the CORS-safelisted request header names, in canonical
+// form, which browsers may send without a preflight and which are always allowed.
+// Content-Type is only safelisted for some values.
+var safelistedHeaders = map[string]bool{
+	"Accept":           true,
+	"Accept-Language":  true,
+	"Content-Language": true,
+}
+
+// IsSafelistedHeader reports whether the canonical header name is always allowed
+func IsSafelistedHeader(name string) bool {
+	return safelistedHeaders[name]
+}
+
+// canonicalHeaderKey returns the canonical form of a header name, as
+// http.CanonicalHeaderKey does: names holding other bytes than token ones are
+// returned unchanged.
+func canonicalHeaderKey(name string) string {
+	for i := 0; i < len(name); i++ {
+		b := name[i]
+		if (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') {
+			continue
+		}
+		if b == ' ' || strings.IndexByte("!#$%&'*+-.^_`|~", b) < 0 {
+			return name
+		}
+	}
+	h := []byte(name)
+	upper := true
+	for i, b := range h {
+		if upper && b >= 'a' && b <= 'z' {
+			h[i] = b - toLower
+		} else if !upper && b >= 'A' && b <= 'Z' {
+			h[i] = b + toLower
+		}
+		upper = b == '-'
+	}
+	return string(h)
+}
@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHeaderList(t *testing.T) {
+	h := ParseHeaderList("header, second-header, THIRD-HEADER, Numb3r3d-H34d3r, Header_with_underscore Header.with.full.stop")
+	e := []string{"Header", "Second-Header", "Third-Header", "Numb3r3d-H34d3r", "Header_with_underscore", "Header.with.full.stop"}
+	if h[0] != e[0] || h[1] != e[1] || h[2] != e[2] || h[3] != e[3] || h[4] != e[4] || h[5] != e[5] {
+		t.Errorf("%v != %v", h, e)
+	}
+}
+
+func TestParseHeaderListEmpty(t *testing.T) {
+	if len(ParseHeaderList("")) != 0 {
+		t.Error("should be empty slice")
+	}
+	if len(ParseHeaderList(" , ")) != 0 {
+		t.Error("should be empty slice")
+	}
+}
+
+func BenchmarkParseHeaderList(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ParseHeaderList("header, second-header, THIRD-HEADER")
+	}
+}
+
+func BenchmarkParseHeaderListSingle(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ParseHeaderList("header")
+	}
+}
+
+func BenchmarkParseHeaderListNormalized(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ParseHeaderList("Header1, Header2, Third-Header")
+	}
+}
+
+func TestFilterForbiddenHeaders(t *testing.T) {
+	allowed, forbidden := FilterForbiddenHeaders([]string{"X-A", "Host", "Sec-Fetch-Dest", "X-B", ":path"})
+	if !reflect.DeepEqual(allowed, []string{"X-A", "X-B"}) {
+		t.Errorf("allowed = %v", allowed)
+	}
+	if !reflect.DeepEqual(forbidden, []string{"Host", "Sec-Fetch-Dest", ":path"}) {
+		t.Errorf("forbidden = %v", forbidden)
+	}
+	headers := []string{"X-A", "Origin"}
+	if allowed, forbidden = FilterForbiddenHeaders(headers); !reflect.DeepEqual(allowed, headers) || forbidden != nil {
+		t.Errorf("FilterForbiddenHeaders(%v) = %v, %v", headers, allowed, forbidden)
+	}
+}
+
+func TestCanonicalHeaderKey(t *testing.T) {
+	cases := map[string]string{
+		"x-requested-with": "X-Requested-With",
+		"CONTENT-TYPE":     "Content-Type",
+		"x_custom":         "X_custom",
+		"bad header":       "bad header",
+		"*":                "*",
+	}
+	for in, want := range cases {
+		if got := canonicalHeaderKey(in); got != want {
+			t.Errorf("canonicalHeaderKey(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
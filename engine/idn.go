@@ -0,0 +1,152 @@
+package engine
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultPorts are the ports implied by URL schemes, stripped from origins
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+}
+
+// CanonicalOrigin normalizes an origin the way browsers serialize it: lower-cased,
+// without the default port of its scheme, and with an ASCII (punycode) host.
+// Strings which don't look like origins are only lower-cased.
+func CanonicalOrigin(origin string) string {
+	origin = strings.ToLower(origin)
+	i := strings.Index(origin, "://")
+	if i < 0 {
+		return origin
+	}
+	scheme, host := origin[:i], origin[i+3:]
+	if j := strings.LastIndexByte(host, ':'); j >= 0 && !strings.HasSuffix(host, "]") {
+		if port := host[j+1:]; port == defaultPorts[scheme] {
+			host = host[:j]
+		}
+	}
+	if !isASCII(host) {
+		host = toASCIIHost(host)
+	} else if len(host) == len(origin)-i-3 {
+		// Nothing changed, avoid an allocation
+		return origin
+	}
+	return scheme + "://" + host
+}
+
+// isASCII reports whether s only contains ASCII characters
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// toASCIIHost converts the non-ASCII labels of a lower-cased host name to their
+// punycode form (RFC 3492), prefixed with "xn--"
+func toASCIIHost(host string) string {
+	labels := strings.Split(host, ".")
+	for i, label := range labels {
+		if !isASCII(label) {
+			labels[i] = "xn--" + punycode(label)
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+// Punycode parameters, see RFC 3492 section 5
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+)
+
+// punycode encodes s following RFC 3492
+func punycode(s string) string {
+	runes := []rune(s)
+	out := make([]byte, 0, len(s)+8)
+	for _, r := range runes {
+		if r < utf8.RuneSelf {
+			out = append(out, byte(r))
+		}
+	}
+	basic := len(out)
+	handled := basic
+	if basic > 0 {
+		out = append(out, '-')
+	}
+	n, delta, bias := rune(punyInitialN), 0, punyInitialBias
+	for handled < len(runes) {
+		// Next code point to insert: the smallest one not handled yet
+		m := rune(utf8.MaxRune)
+		for _, r := range runes {
+			if r >= n && r < m {
+				m = r
+			}
+		}
+		delta += int(m-n) * (handled + 1)
+		n = m
+		for _, r := range runes {
+			if r < n {
+				delta++
+			}
+			if r != n {
+				continue
+			}
+			q := delta
+			for k := punyBase; ; k += punyBase {
+				t := k - bias
+				if t < punyTMin {
+					t = punyTMin
+				} else if t > punyTMax {
+					t = punyTMax
+				}
+				if q < t {
+					break
+				}
+				out = append(out, punyDigit(t+(q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			out = append(out, punyDigit(q))
+			bias = punyAdapt(delta, handled+1, handled == basic)
+			delta = 0
+			handled++
+		}
+		delta++
+		n++
+	}
+	return string(out)
+}
+
+// punyDigit encodes a punycode digit
+func punyDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+// punyAdapt is the bias adaptation function of RFC 3492 section 6.1
+func punyAdapt(delta, numPoints int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := 0
+	for delta > ((punyBase-punyTMin)*punyTMax)/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
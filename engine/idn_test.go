@@ -0,0 +1,40 @@
+package engine
+
+import "testing"
+
+func TestPunycode(t *testing.T) {
+	cases := map[string]string{
+		"münchen": "mnchen-3ya",
+		"bücher":  "bcher-kva",
+		"例え":      "r8jz45g",
+		"テスト":     "zckzah",
+		"ü":       "tda",
+	}
+	for in, want := range cases {
+		if got := punycode(in); got != want {
+			t.Errorf("punycode(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestCanonicalOrigin(t *testing.T) {
+	cases := map[string]string{
+		"https://EXAMPLE.com":       "https://example.com",
+		"https://example.com:443":   "https://example.com",
+		"http://example.com:80":     "http://example.com",
+		"http://example.com:443":    "http://example.com:443",
+		"wss://example.com:443":     "wss://example.com",
+		"https://[::1]:443":         "https://[::1]",
+		"https://[::1]":             "https://[::1]",
+		"https://Bücher.example":    "https://xn--bcher-kva.example",
+		"https://例え.テスト:443":        "https://xn--r8jz45g.xn--zckzah",
+		"https://*.example.com:443": "https://*.example.com",
+		"null":                      "null",
+		"Brand.COM":                 "brand.com",
+	}
+	for in, want := range cases {
+		if got := CanonicalOrigin(in); got != want {
+			t.Errorf("CanonicalOrigin(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
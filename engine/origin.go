@@ -0,0 +1,465 @@
+package engine
+
+import (
+	"errors"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MatchMode defines which pattern wins when several configured origin patterns
+// match the same request origin.
+type MatchMode int
+
+const (
+	// MatchMostSpecific picks the most specific matching pattern: exact origins
+	// first, then wildcards with the longest literal part, then regular expressions.
+	MatchMostSpecific MatchMode = iota
+
+	// MatchFirst picks the first matching pattern in configuration order, origins
+	// being considered before regular expressions. Unlike
+	// MatchMostSpecific, which looks exact origins and wildcards up in indexes,
+	// patterns are tried one after the other.
+	MatchFirst
+)
+
+// MarshalText implements encoding.TextMarshaler, m being written as
+// "most-specific" or "first"
+func (m MatchMode) MarshalText() ([]byte, error) {
+	switch m {
+	case MatchMostSpecific:
+		return []byte("most-specific"), nil
+	case MatchFirst:
+		return []byte("first"), nil
+	}
+	return nil, errors.New("engine: invalid origin match mode " + strconv.Itoa(int(m)))
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (m *MatchMode) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "most-specific", "":
+		*m = MatchMostSpecific
+	case "first":
+		*m = MatchFirst
+	default:
+		return errors.New("engine: invalid origin match mode " + strconv.Quote(string(text)))
+	}
+	return nil
+}
+
+// Kinds of origin patterns, from the most to the least specific
+const (
+	patternExact = iota
+	patternWildcard
+	patternRegex
+)
+
+// Pattern is a compiled origin pattern
+type Pattern struct {
+	// Pattern as configured, reported when it matches
+	raw  string
+	kind int
+
+	// Lower-cased origin for exact patterns
+	origin string
+	// Split pattern for wildcard patterns
+	w wildcard
+	// Compiled expression for regex patterns
+	re *regexp.Regexp
+	// Set when the pattern matches any port
+	anyPort bool
+}
+
+// newOriginPattern compiles an origin, possibly with wildcards
+func newOriginPattern(raw string) *Pattern {
+	p := &Pattern{raw: raw, kind: patternExact}
+	origin := CanonicalOrigin(raw)
+	if strings.HasSuffix(origin, ":*") {
+		// Any port: the port is trimmed from the origin before matching
+		p.anyPort = true
+		origin = origin[:len(origin)-2]
+	}
+	if i := strings.IndexByte(origin, '*'); i >= 0 {
+		// Split the origin in two: start and end string without the *
+		p.kind = patternWildcard
+		p.w = wildcard{origin[0:i], origin[i+1:]}
+	} else {
+		p.origin = origin
+	}
+	return p
+}
+
+// newRegexOriginPattern compiles a regular expression matching whole origins
+func newRegexOriginPattern(raw string) (*Pattern, error) {
+	re, err := regexp.Compile("^(?:" + raw + ")$")
+	if err != nil {
+		return nil, err
+	}
+	return &Pattern{raw: raw, kind: patternRegex, re: re}, nil
+}
+
+// String returns the pattern as configured
+func (p *Pattern) String() string {
+	return p.raw
+}
+
+// Match reports whether origin matches the pattern
+func (p *Pattern) Match(origin string) bool {
+	return p.match(CanonicalOrigin(origin))
+}
+
+// match checks a lower-cased origin against the pattern
+func (p *Pattern) match(origin string) bool {
+	if p.anyPort {
+		origin = TrimPort(origin)
+	}
+	switch p.kind {
+	case patternExact:
+		return p.origin == origin
+	case patternWildcard:
+		return p.w.match(origin)
+	default:
+		return p.re.MatchString(origin)
+	}
+}
+
+// moreSpecific reports whether p should win over o in MatchMostSpecific mode
+func (p *Pattern) moreSpecific(o *Pattern) bool {
+	if p.kind != o.kind {
+		return p.kind < o.kind
+	}
+	if p.kind == patternWildcard {
+		if l, ol := len(p.w.prefix)+len(p.w.suffix), len(o.w.prefix)+len(o.w.suffix); l != ol {
+			return l > ol
+		}
+	}
+	// A fixed port is more specific than any port
+	return !p.anyPort && o.anyPort
+}
+
+// OriginMatcher resolves the pattern matching an origin
+type OriginMatcher struct {
+	// Patterns in resolution order: the first matching one wins
+	patterns []*Pattern
+
+	// Hash identifying the matcher configuration
+	fingerprint string
+
+	// Lookup structures for MatchMostSpecific, nil in MatchFirst mode
+	index *originIndex
+}
+
+// NewOriginMatcher compiles origin patterns and regular expressions, ordering them
+// according to mode. Origin patterns may hold a "*" wildcard and end with ":*" to
+// match any port; regular expressions must match whole origins.
+func NewOriginMatcher(origins, regexes []string, mode MatchMode) (*OriginMatcher, error) {
+	m := &OriginMatcher{}
+	for _, origin := range origins {
+		m.patterns = append(m.patterns, newOriginPattern(origin))
+	}
+	for _, re := range regexes {
+		p, err := newRegexOriginPattern(re)
+		if err != nil {
+			return nil, err
+		}
+		m.patterns = append(m.patterns, p)
+	}
+	h := fnv.New64a()
+	h.Write([]byte(strconv.Itoa(int(mode))))
+	for _, list := range [][]string{origins, regexes} {
+		for _, s := range list {
+			h.Write([]byte("\x00" + strconv.Quote(s)))
+		}
+		h.Write([]byte("\x00"))
+	}
+	m.fingerprint = strconv.FormatUint(h.Sum64(), 16) + "\x00"
+	if mode == MatchMostSpecific {
+		sort.SliceStable(m.patterns, func(i, j int) bool {
+			return m.patterns[i].moreSpecific(m.patterns[j])
+		})
+		m.index = newOriginIndex(m.patterns)
+	}
+	return m, nil
+}
+
+// Match returns the pattern winning for origin, or nil if none matches
+func (m *OriginMatcher) Match(origin string) *Pattern {
+	origin = CanonicalOrigin(origin)
+	if m.index != nil {
+		if i := m.index.match(m.patterns, origin); i >= 0 {
+			return m.patterns[i]
+		}
+		return nil
+	}
+	for _, p := range m.patterns {
+		if p.match(origin) {
+			return p
+		}
+	}
+	return nil
+}
+
+// Patterns returns the patterns in resolution order. It must not be modified.
+func (m *OriginMatcher) Patterns() []*Pattern {
+	return m.patterns
+}
+
+// Fingerprint returns a string identifying the origins, regular expressions and
+// mode the matcher was compiled from, suitable as a cache key prefix
+func (m *OriginMatcher) Fingerprint() string {
+	return m.fingerprint
+}
+
+// originIndex finds the winning pattern without trying all of them: exact origins
+// are hashed and wildcards are stored in tries of their reversed suffix. Patterns
+// are referred to by position in the sorted pattern list, the lowest matching
+// position winning as with a linear scan.
+type originIndex struct {
+	exact            map[string]int
+	exactAnyPort     map[string]int
+	wildcards        *suffixNode
+	wildcardsAnyPort *suffixNode
+	regexes          []int
+}
+
+// suffixNode is a node of a trie keyed by the bytes of wildcard suffixes, from the
+// last one to the first
+type suffixNode struct {
+	children map[byte]*suffixNode
+	// Positions of the wildcard patterns whose suffix ends at this node
+	patterns []int
+}
+
+// newOriginIndex indexes patterns sorted by specificity
+func newOriginIndex(patterns []*Pattern) *originIndex {
+	idx := &originIndex{
+		exact:            map[string]int{},
+		exactAnyPort:     map[string]int{},
+		wildcards:        &suffixNode{},
+		wildcardsAnyPort: &suffixNode{},
+	}
+	for i, p := range patterns {
+		switch p.kind {
+		case patternExact:
+			exact := idx.exact
+			if p.anyPort {
+				exact = idx.exactAnyPort
+			}
+			if _, dup := exact[p.origin]; !dup {
+				exact[p.origin] = i
+			}
+		case patternWildcard:
+			n := idx.wildcards
+			if p.anyPort {
+				n = idx.wildcardsAnyPort
+			}
+			n.insert(p.w.suffix, i)
+		default:
+			idx.regexes = append(idx.regexes, i)
+		}
+	}
+	return idx
+}
+
+// insert adds the pattern at position i under suffix
+func (n *suffixNode) insert(suffix string, i int) {
+	for j := len(suffix) - 1; j >= 0; j-- {
+		child := n.children[suffix[j]]
+		if child == nil {
+			if n.children == nil {
+				n.children = map[byte]*suffixNode{}
+			}
+			child = &suffixNode{}
+			n.children[suffix[j]] = child
+		}
+		n = child
+	}
+	n.patterns = append(n.patterns, i)
+}
+
+// candidates calls fn with the position of every pattern whose suffix ends s
+func (n *suffixNode) candidates(s string, fn func(i int)) {
+	for j := len(s); n != nil; j-- {
+		for _, i := range n.patterns {
+			fn(i)
+		}
+		if j == 0 {
+			return
+		}
+		n = n.children[s[j-1]]
+	}
+}
+
+// match returns the position of the winning pattern for a lower-cased origin, or
+// -1 if none matches
+func (idx *originIndex) match(patterns []*Pattern, origin string) int {
+	best := -1
+	consider := func(i int) {
+		if (best < 0 || i < best) && patterns[i].match(origin) {
+			best = i
+		}
+	}
+	trimmed := TrimPort(origin)
+	if i, ok := idx.exact[origin]; ok {
+		consider(i)
+	}
+	if i, ok := idx.exactAnyPort[trimmed]; ok {
+		consider(i)
+	}
+	if best >= 0 {
+		// Exact patterns come before all others
+		return best
+	}
+	idx.wildcards.candidates(origin, consider)
+	idx.wildcardsAnyPort.candidates(trimmed, consider)
+	if best >= 0 {
+		return best
+	}
+	for _, i := range idx.regexes {
+		if patterns[i].match(origin) {
+			return i
+		}
+	}
+	return -1
+}
+
+type wildcard struct {
+	prefix string
+	suffix string
+}
+
+func (w wildcard) match(s string) bool {
+	return len(s) >= len(w.prefix+w.suffix) && strings.HasPrefix(s, w.prefix) && strings.HasSuffix(s, w.suffix)
+}
+
+// TrimPort removes the numeric port from an origin, if any
+func TrimPort(origin string) string {
+	i := strings.LastIndexByte(origin, ':')
+	if i < 0 || i == len(origin)-1 {
+		return origin
+	}
+	for j := i + 1; j < len(origin); j++ {
+		if origin[j] < '0' || origin[j] > '9' {
+			return origin
+		}
+	}
+	return origin[:i]
+}
+
+// Application schemes of browser extensions and webview shells, whose origins
+// are considered potentially trustworthy
+var secureAppSchemes = map[string]bool{
+	"chrome-extension":     true,
+	"moz-extension":        true,
+	"safari-web-extension": true,
+	"ms-browser-extension": true,
+	"capacitor":            true,
+	"ionic":                true,
+	"tauri":                true,
+	"app":                  true,
+}
+
+// IsSecureOrigin reports whether an origin is served over TLS (https, wss), over
+// http from the local host, or from a known application scheme
+// (chrome-extension://, capacitor://...), which browsers consider potentially
+// trustworthy
+func IsSecureOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	i := strings.Index(origin, "://")
+	if i <= 0 {
+		return false
+	}
+	switch scheme := origin[:i]; scheme {
+	case "https", "wss":
+		return true
+	case "http":
+		host := TrimPort(origin[i+3:])
+		return host == "localhost" || strings.HasSuffix(host, ".localhost") ||
+			host == "127.0.0.1" || host == "[::1]"
+	default:
+		return secureAppSchemes[scheme]
+	}
+}
+
+// IsSerializedOrigin reports whether origin has the shape of a serialized origin
+// as sent by browsers: "null" or scheme://host[:port], without user info, path,
+// query or fragment. Internationalized host names are accepted in their Unicode
+// form.
+func IsSerializedOrigin(origin string) bool {
+	if !isASCII(origin) {
+		origin = CanonicalOrigin(origin)
+	}
+	return isSerializedOrigin(origin)
+}
+
+// isSerializedOrigin is IsSerializedOrigin for ASCII origins
+func isSerializedOrigin(s string) bool {
+	if s == "null" {
+		return true
+	}
+	i := strings.Index(s, "://")
+	if i <= 0 {
+		return false
+	}
+	for j := 0; j < i; j++ {
+		b := s[j]
+		if !(b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || j > 0 && (b >= '0' && b <= '9' || b == '+' || b == '-' || b == '.')) {
+			return false
+		}
+	}
+	host := s[i+3:]
+	if strings.HasPrefix(host, "[") {
+		// IPv6 address
+		end := strings.IndexByte(host, ']')
+		if end < 0 {
+			return false
+		}
+		for _, b := range []byte(host[1:end]) {
+			if !(b >= '0' && b <= '9' || b >= 'a' && b <= 'f' || b >= 'A' && b <= 'F' || b == ':' || b == '.') {
+				return false
+			}
+		}
+		host = "x" + host[end+1:]
+	}
+	if j := strings.LastIndexByte(host, ':'); j >= 0 {
+		port := host[j+1:]
+		if port == "" || len(port) > 5 {
+			return false
+		}
+		for _, b := range []byte(port) {
+			if b < '0' || b > '9' {
+				return false
+			}
+		}
+		host = host[:j]
+	}
+	if host == "" {
+		return false
+	}
+	for _, b := range []byte(host) {
+		if !(b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b == '-' || b == '.' || b == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// IsLocalhostOrigin reports whether origin is an http or https origin on the
+// loopback host, with any port
+func IsLocalhostOrigin(origin string) bool {
+	origin = CanonicalOrigin(origin)
+	var host string
+	switch {
+	case strings.HasPrefix(origin, "http://"):
+		host = origin[len("http://"):]
+	case strings.HasPrefix(origin, "https://"):
+		host = origin[len("https://"):]
+	default:
+		return false
+	}
+	host = TrimPort(host)
+	return host == "localhost" || host == "127.0.0.1" || host == "[::1]"
+}
@@ -0,0 +1,184 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestOriginMatcher(t *testing.T) {
+	origins := []string{"http://*.com", "http://*.bar.com", "http://foo.bar.com:*", "http://foo.bar.com"}
+	regexes := []string{`http://[a-z]+\.bar\.com`}
+	cases := []struct {
+		mode   MatchMode
+		origin string
+		want   string
+	}{
+		{MatchMostSpecific, "http://foo.bar.com", "http://foo.bar.com"},
+		{MatchMostSpecific, "http://FOO.bar.com:8080", "http://foo.bar.com:*"},
+		{MatchMostSpecific, "http://baz.bar.com", "http://*.bar.com"},
+		{MatchMostSpecific, "http://baz.com", "http://*.com"},
+		{MatchFirst, "http://foo.bar.com", "http://*.com"},
+		{MatchFirst, "http://foo.bar.com:8080", "http://foo.bar.com:*"},
+		{MatchMostSpecific, "https://foo.bar.com", ""},
+	}
+	for _, tc := range cases {
+		m, err := NewOriginMatcher(origins, regexes, tc.mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if p := m.Match(tc.origin); p != nil {
+			got = p.raw
+		}
+		if got != tc.want {
+			t.Errorf("mode %d: match(%q) = %q, want %q", tc.mode, tc.origin, got, tc.want)
+		}
+	}
+}
+
+func TestOriginMatcherRegexPriority(t *testing.T) {
+	m, err := NewOriginMatcher([]string{"https://*.example.com"}, []string{`https://pr-\d+\.example\.com`}, MatchMostSpecific)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := m.Match("https://pr-1.example.com"); p == nil || p.raw != "https://*.example.com" {
+		t.Errorf("wildcard should win over regex, got %v", p)
+	}
+	m, _ = NewOriginMatcher(nil, []string{`https://pr-\d+\.example\.com`}, MatchFirst)
+	if p := m.Match("https://pr-1.example.com"); p == nil || p.kind != patternRegex {
+		t.Errorf("regex should match, got %v", p)
+	}
+}
+
+func TestOriginMatcherInvalidRegex(t *testing.T) {
+	if _, err := NewOriginMatcher(nil, []string{"("}, MatchMostSpecific); err == nil {
+		t.Error("invalid regex should return an error")
+	}
+}
+
+func TestOriginIndex(t *testing.T) {
+	origins := []string{
+		"http://*.com", "http://*.bar.com", "http://*.bar.com:*", "http://foo.bar.com:*",
+		"http://foo.bar.com", "http://foo.*", "http://f*.bar.com", "http://*", "https://*:*",
+	}
+	regexes := []string{`http://[a-z]+\.baz\.org`}
+	indexed, err := NewOriginMatcher(origins, regexes, MatchMostSpecific)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Same patterns in the same order, without index
+	linear := &OriginMatcher{patterns: indexed.patterns}
+	for _, origin := range []string{
+		"http://foo.bar.com", "http://foo.bar.com:8080", "http://fab.bar.com", "http://baz.bar.com:81",
+		"http://baz.com", "http://foo.org", "http://a.baz.org", "https://a.baz.org:443", "https://x",
+		"ftp://foo.bar.com", "http://", "",
+	} {
+		want, got := linear.Match(origin), indexed.Match(origin)
+		if got != want {
+			t.Errorf("match(%q) = %v, want %v", origin, got, want)
+		}
+	}
+}
+
+func BenchmarkOriginMatcher(b *testing.B) {
+	var origins []string
+	for i := 0; i < 5000; i++ {
+		origins = append(origins, fmt.Sprintf("https://customer-%d.com", i), fmt.Sprintf("https://*.customer-%d.com", i))
+	}
+	m, _ := NewOriginMatcher(origins, nil, MatchMostSpecific)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Match("https://app.customer-4999.com")
+	}
+}
+
+func TestWildcard(t *testing.T) {
+	w := wildcard{"foo", "bar"}
+	if !w.match("foobar") {
+		t.Error("foo*bar should match foobar")
+	}
+	if !w.match("foobazbar") {
+		t.Error("foo*bar should match foobazbar")
+	}
+	if w.match("foobaz") {
+		t.Error("foo*bar should not match foobaz")
+	}
+
+	w = wildcard{"foo", "oof"}
+	if w.match("foof") {
+		t.Error("foo*oof should not match foof")
+	}
+}
+
+func TestTrimPort(t *testing.T) {
+	cases := map[string]string{
+		"http://localhost:3000": "http://localhost",
+		"http://localhost":      "http://localhost",
+		"http://localhost:":     "http://localhost:",
+		"http://[::1]:8080":     "http://[::1]",
+		"http://[::1]":          "http://[::1]",
+		"http://foo.com:8o":     "http://foo.com:8o",
+	}
+	for in, want := range cases {
+		if got := TrimPort(in); got != want {
+			t.Errorf("TrimPort(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func BenchmarkWildcard(b *testing.B) {
+	w := wildcard{"foo", "bar"}
+	b.Run("match", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			w.match("foobazbar")
+		}
+	})
+	b.Run("too short", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			w.match("fobar")
+		}
+	})
+}
+
+func TestIsSecureOrigin(t *testing.T) {
+	for _, origin := range []string{"https://foo.com", "HTTPS://foo.com:8443", "http://localhost", "http://localhost:3000", "http://app.localhost", "http://127.0.0.1:8080", "http://[::1]", "wss://foo.com", "chrome-extension://abc", "moz-extension://abc", "capacitor://localhost"} {
+		if !IsSecureOrigin(origin) {
+			t.Errorf("IsSecureOrigin(%q) = false, want true", origin)
+		}
+	}
+	for _, origin := range []string{"http://foo.com", "http://localhost.com", "http://127.0.0.2", "null", "localhost", "ws://localhost", "ws://foo.com", "ftp://foo.com", "evil://foo.com"} {
+		if IsSecureOrigin(origin) {
+			t.Errorf("IsSecureOrigin(%q) = true, want false", origin)
+		}
+	}
+}
+
+func TestIsSerializedOrigin(t *testing.T) {
+	for _, origin := range []string{"null", "http://foo.com", "https://FOO.com:8443", "http://[::1]:3000", "chrome-extension://abc", "http://foo_bar.com", "app+x.y-z://host"} {
+		if !isSerializedOrigin(origin) {
+			t.Errorf("isSerializedOrigin(%q) = false, want true", origin)
+		}
+	}
+	for _, origin := range []string{"", "Null", "foo.com", "://foo.com", "1http://foo.com", "http://", "http://foo.com/", "http://foo.com?x",
+		"http://u@foo.com", "http://foo.com:", "http://foo.com:123456", "http://foo.com:8o", "http://foo.com, http://bar.com",
+		"http://[::1", "http://[zz]", "http://foo .com", "http://caf\u00e9.com", "http://foo.com\r\nX: y"} {
+		if isSerializedOrigin(origin) {
+			t.Errorf("isSerializedOrigin(%q) = true, want false", origin)
+		}
+	}
+}
+
+func TestIsLocalhostOrigin(t *testing.T) {
+	for _, origin := range []string{"http://localhost", "https://localhost:8443", "http://LOCALHOST:3000", "http://127.0.0.1:8080", "http://[::1]:5173"} {
+		if !IsLocalhostOrigin(origin) {
+			t.Errorf("IsLocalhostOrigin(%q) = false, want true", origin)
+		}
+	}
+	for _, origin := range []string{"http://localhost.evil.com", "http://evil.com/localhost", "ws://localhost", "http://127.0.0.2", "null"} {
+		if IsLocalhostOrigin(origin) {
+			t.Errorf("IsLocalhostOrigin(%q) = true, want false", origin)
+		}
+	}
+}
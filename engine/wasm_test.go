@@ -0,0 +1,25 @@
+//go:build wasm
+// +build wasm
+
+package engine
+
+import "testing"
+
+// TestWasmEvaluate evaluates a policy the way an edge filter would: no server, no
+// request object, only the method and headers handed over by the host.
+func TestWasmEvaluate(t *testing.T) {
+	p, err := New(Config{
+		AllowedOrigins: []string{"https://*.example.com"},
+		AllowedMethods: []string{"GET", "PUT"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := p.Evaluate(Request{Method: "OPTIONS", Origin: "https://app.example.com", RequestMethod: "PUT"})
+	if !res.Allowed || !res.Preflight {
+		t.Errorf("preflight result = %+v, want allowed", res)
+	}
+	if res = p.Evaluate(Request{Method: "GET", Origin: "https://example.org"}); res.Allowed || res.Reason != ReasonOrigin {
+		t.Errorf("actual result = %+v, want denied by origin", res)
+	}
+}
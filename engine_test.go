@@ -0,0 +1,109 @@
+package cors
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/cors/engine"
+)
+
+// TestEngineAgreesWithCheck checks that engine.Evaluate decides as Check for the
+// options both understand
+func TestEngineAgreesWithCheck(t *testing.T) {
+	configs := []engine.Config{
+		{},
+		{
+			AllowedOrigins:   []string{"https://*.example.com", "https://app.example.com:*"},
+			DeniedOrigins:    []string{"https://evil.example.com"},
+			AllowedMethods:   []string{"get", "PUT", "DELETE"},
+			AllowedHeaders:   []string{"x-token", "Authorization"},
+			ExposedHeaders:   []string{"x-total", "X-Page"},
+			AllowCredentials: true,
+			MaxAge:           600,
+		},
+		{
+			AllowedOriginsRegex: []string{`https://pr-\d+\.example\.com`},
+			AllowedOrigins:      []string{"https://*.example.com"},
+			OriginMatchMode:     engine.MatchFirst,
+			AllowedHeaders:      []string{"*"},
+			AllowNullOrigin:     true,
+			AllowPrivateNetwork: true,
+			MaxAge:              -1,
+		},
+	}
+	requests := []engine.Request{
+		{Method: "GET"},
+		{Method: "GET", Origin: "https://app.example.com"},
+		{Method: "POST", Origin: "http://app.example.com"},
+		{Method: "GET", Origin: "https://app.example.com:8443"},
+		{Method: "GET", Origin: "https://pr-12.example.com"},
+		{Method: "DELETE", Origin: "https://app.example.com"},
+		{Method: "PATCH", Origin: "https://app.example.com"},
+		{Method: "GET", Origin: "https://evil.example.com"},
+		{Method: "GET", Origin: "https://example.org"},
+		{Method: "GET", Origin: "null"},
+		{Method: "GET", Origin: "https://app.example.com/"},
+		{Method: "GET", Origin: "https://bücher.example.com"},
+		{Method: "OPTIONS", Origin: "https://app.example.com"},
+		{Method: "OPTIONS", Origin: "https://app.example.com", RequestMethod: "put", RequestHeaders: "X-Token, accept"},
+		{Method: "OPTIONS", Origin: "https://a.example.com", RequestMethod: "GET", RequestHeaders: "x-other"},
+		{Method: "OPTIONS", Origin: "https://a.example.com", RequestMethod: "PATCH", RequestHeaders: "x-other"},
+		{Method: "OPTIONS", Origin: "https://a.example.com", RequestMethod: "POST", RequestHeaders: "Content-Type, Sec-Fetch-Mode",
+			RequestPrivateNetwork: true},
+		{Method: "OPTIONS", Origin: "null", RequestMethod: "GET"},
+		{Method: "OPTIONS", Origin: "https://example.org", RequestMethod: "GET"},
+	}
+	for i, config := range configs {
+		p, err := engine.New(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := New(Options{
+			AllowedOrigins:      config.AllowedOrigins,
+			AllowedOriginsRegex: config.AllowedOriginsRegex,
+			DeniedOrigins:       config.DeniedOrigins,
+			AllowNullOrigin:     config.AllowNullOrigin,
+			OriginMatchMode:     OriginMatchMode(config.OriginMatchMode),
+			AllowedMethods:      config.AllowedMethods,
+			AllowedHeaders:      config.AllowedHeaders,
+			ExposedHeaders:      config.ExposedHeaders,
+			AllowCredentials:    config.AllowCredentials,
+			MaxAgeDuration:      time.Duration(config.MaxAge) * time.Second,
+			AllowPrivateNetwork: config.AllowPrivateNetwork,
+		})
+		for _, req := range requests {
+			r, _ := http.NewRequest(req.Method, "http://api.example.com/", nil)
+			if req.Origin != "" {
+				r.Header.Set("Origin", req.Origin)
+			}
+			if req.RequestMethod != "" {
+				r.Header.Set("Access-Control-Request-Method", req.RequestMethod)
+			}
+			if req.RequestHeaders != "" {
+				r.Header.Set("Access-Control-Request-Headers", req.RequestHeaders)
+			}
+			if req.RequestPrivateNetwork {
+				r.Header.Set("Access-Control-Request-Private-Network", "true")
+			}
+			d := c.Check(r)
+			want := engine.Result{
+				Preflight:     d.Preflight,
+				Allowed:       d.Allowed,
+				MatchedOrigin: d.MatchedOrigin,
+				Reason:        d.Reason,
+			}
+			if d.header != nil {
+				want.Headers = map[string]string{}
+				for k, v := range d.header {
+					want.Headers[k] = strings.Join(v, ", ")
+				}
+			}
+			if got := p.Evaluate(req); !reflect.DeepEqual(got, want) {
+				t.Errorf("config %d, %+v: Evaluate() = %+v, Check() = %+v", i, req, got, want)
+			}
+		}
+	}
+}
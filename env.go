@@ -0,0 +1,120 @@
+package cors
+
+import (
+	"encoding"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// FromEnv returns Options read from environment variables named after the fields
+// of Options in upper snake case, after prefix and an underscore: with the "CORS"
+// prefix, AllowedOrigins is read from CORS_ALLOWED_ORIGINS, AllowCredentials from
+// CORS_ALLOW_CREDENTIALS, MaxAge from CORS_MAX_AGE and OriginFuncCacheTTL from
+// CORS_ORIGIN_FUNC_CACHE_TTL. Unset and empty variables leave fields to their
+// zero value. Values are parsed as follows:
+//
+//   - lists are comma-separated, items being trimmed and empty ones dropped:
+//     CORS_ALLOWED_ORIGINS=https://example.com,https://*.example.com
+//   - booleans are parsed by strconv.ParseBool: 1, t, true, 0, f, false...
+//   - durations are parsed by time.ParseDuration, like 10m or 1h30m
+//   - numbers are decimal, like 600 for MaxAge which is in seconds
+//   - OriginMatchMode is most-specific or first, AllowedHeadersResponseMode
+//     echo or static, ResourcePolicy same-origin, same-site or cross-origin
+//
+// Fields which can't be expressed as a single value (functions, providers,
+// Messages, ShadowPolicy...) are not read. An error names the first variable
+// that could not be parsed.
+func FromEnv(prefix string) (Options, error) {
+	var options Options
+	if prefix = strings.TrimSuffix(prefix, "_"); prefix != "" {
+		prefix += "_"
+	}
+	v := reflect.ValueOf(&options).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Tag.Get("json") == "-" {
+			continue
+		}
+		name := prefix + upperSnakeCase(field.Name)
+		value := strings.TrimSpace(os.Getenv(name))
+		if value == "" {
+			continue
+		}
+		if err := setFromEnv(v.Field(i), value); err != nil {
+			return Options{}, fmt.Errorf("cors: invalid %s: %v", name, err)
+		}
+	}
+	return options, nil
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// setFromEnv parses value into the field f
+func setFromEnv(f reflect.Value, value string) error {
+	if reflect.PtrTo(f.Type()).Implements(textUnmarshalerType) {
+		return f.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+	switch {
+	case f.Type() == durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(d))
+	case f.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case f.Kind() == reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(n))
+	case f.Kind() == reflect.Float64:
+		x, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		f.SetFloat(x)
+	case f.Kind() == reflect.String:
+		f.SetString(value)
+	case f.Type() == reflect.TypeOf([]byte(nil)):
+		f.SetBytes([]byte(value))
+	case f.Type() == reflect.TypeOf([]string(nil)):
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		f.Set(reflect.ValueOf(list))
+	}
+	return nil
+}
+
+// upperSnakeCase converts a Go identifier to upper snake case, e.g.
+// OriginFuncCacheTTL to ORIGIN_FUNC_CACHE_TTL
+func upperSnakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) &&
+			(unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
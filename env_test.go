@@ -0,0 +1,82 @@
+package cors
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func setenv(t *testing.T, env map[string]string) {
+	for name, value := range env {
+		os.Setenv(name, value)
+	}
+	t.Cleanup(func() {
+		for name := range env {
+			os.Unsetenv(name)
+		}
+	})
+}
+
+func TestFromEnv(t *testing.T) {
+	setenv(t, map[string]string{
+		"APP_CORS_ALLOWED_ORIGINS":               "https://example.com, https://*.example.com,",
+		"APP_CORS_ALLOWED_METHODS":               "GET,PUT",
+		"APP_CORS_ALLOW_CREDENTIALS":             "true",
+		"APP_CORS_MAX_AGE":                       "600",
+		"APP_CORS_ORIGIN_FUNC_CACHE_TTL":         "1m30s",
+		"APP_CORS_ORIGIN_MATCH_MODE":             "first",
+		"APP_CORS_SAMPLE_DENIALS":                "0.5",
+		"APP_CORS_NAME":                          "api",
+		"APP_CORS_ALLOWED_HEADERS":               "",
+		"APP_CORS_ALLOWED_HEADERS_RESPONSE_MODE": "static",
+	})
+	options, err := FromEnv("APP_CORS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Options{
+		AllowedOrigins:             []string{"https://example.com", "https://*.example.com"},
+		AllowedMethods:             []string{"GET", "PUT"},
+		AllowCredentials:           true,
+		MaxAge:                     600,
+		OriginFuncCacheTTL:         90 * time.Second,
+		OriginMatchMode:            MatchFirst,
+		SampleDenials:              0.5,
+		Name:                       "api",
+		AllowedHeadersResponseMode: AllowedHeadersStatic,
+	}
+	if !reflect.DeepEqual(options, want) {
+		t.Errorf("FromEnv = %+v, want %+v", options, want)
+	}
+}
+
+func TestFromEnvError(t *testing.T) {
+	for name, value := range map[string]string{
+		"CORS_ALLOW_CREDENTIALS": "yes please",
+		"CORS_MAX_AGE":           "10m",
+		"CORS_MAX_AGE_DURATION":  "600",
+		"CORS_ORIGIN_MATCH_MODE": "last",
+	} {
+		t.Run(name, func(t *testing.T) {
+			setenv(t, map[string]string{name: value})
+			if _, err := FromEnv("CORS_"); err == nil {
+				t.Errorf("%s=%s: expected an error", name, value)
+			}
+		})
+	}
+}
+
+func TestUpperSnakeCase(t *testing.T) {
+	for name, want := range map[string]string{
+		"AllowedOrigins":      "ALLOWED_ORIGINS",
+		"OriginFuncCacheTTL":  "ORIGIN_FUNC_CACHE_TTL",
+		"MaxAge":              "MAX_AGE",
+		"AllowedOriginsRegex": "ALLOWED_ORIGINS_REGEX",
+		"Debug":               "DEBUG",
+	} {
+		if got := upperSnakeCase(name); got != want {
+			t.Errorf("upperSnakeCase(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Sentinel errors wrapped by the error types of the package, so that denials can
+// be matched with errors.Is(err, cors.ErrOriginNotAllowed)
+var (
+	ErrOriginNotAllowed    = errors.New("cors: origin not allowed")
+	ErrMalformedOrigin     = errors.New("cors: malformed origin")
+	ErrMethodNotAllowed    = errors.New("cors: method not allowed")
+	ErrHeadersNotAllowed   = errors.New("cors: headers not allowed")
+	ErrMalformedHeaders    = errors.New("cors: malformed requested headers")
+	ErrHeaderBudget        = errors.New("cors: header budget exceeded")
+	ErrRequestHeadersLimit = errors.New("cors: requested headers limit exceeded")
+	ErrFetchMetadata       = errors.New("cors: cross-site request rejected")
+	ErrUnsafeOrigin        = errors.New("cors: state-changing request from a disallowed origin")
+)
+
+// OriginNotAllowedError is reported when the request origin is not allowed.
+// Allowed is the snapshot of the AllowedOrigins and AllowedOriginsRegex of the
+// policy, and Denied the DeniedOrigins pattern which matched if any. Allowed is
+// shared and must not be modified.
+type OriginNotAllowedError struct {
+	Origin  string
+	Allowed []string
+	Denied  string
+}
+
+func (e *OriginNotAllowedError) Error() string {
+	return fmt.Sprintf("origin '%s' not allowed", e.Origin)
+}
+
+// Unwrap returns ErrOriginNotAllowed
+func (e *OriginNotAllowedError) Unwrap() error {
+	return ErrOriginNotAllowed
+}
+
+// MalformedOriginError is reported when the Origin header is not a single,
+// well-formed serialized origin (scheme://host[:port] or null)
+type MalformedOriginError struct {
+	Origin string
+}
+
+func (e *MalformedOriginError) Error() string {
+	return fmt.Sprintf("malformed origin '%s'", e.Origin)
+}
+
+// Unwrap returns ErrMalformedOrigin
+func (e *MalformedOriginError) Unwrap() error {
+	return ErrMalformedOrigin
+}
+
+// MethodNotAllowedError is reported when the request method, or the method requested
+// by a preflight, is not allowed for Origin. Allowed is the snapshot of the allowed
+// methods of the policy, which is shared and must not be modified.
+type MethodNotAllowedError struct {
+	Method  string
+	Origin  string
+	Allowed []string
+}
+
+func (e *MethodNotAllowedError) Error() string {
+	return fmt.Sprintf("method '%s' not allowed", e.Method)
+}
+
+// Unwrap returns ErrMethodNotAllowed
+func (e *MethodNotAllowedError) Unwrap() error {
+	return ErrMethodNotAllowed
+}
+
+// MalformedRequestHeadersError is reported when Options.StrictRequestHeaders is set
+// and Access-Control-Request-Headers is not a single sorted, lower-cased and comma
+// separated list of header names
+type MalformedRequestHeadersError struct {
+	Value string
+}
+
+func (e *MalformedRequestHeadersError) Error() string {
+	return fmt.Sprintf("malformed requested headers '%s'", e.Value)
+}
+
+// Unwrap returns ErrMalformedHeaders
+func (e *MalformedRequestHeadersError) Unwrap() error {
+	return ErrMalformedHeaders
+}
+
+// HeadersNotAllowedError is reported when a header requested by a preflight is not
+// allowed for Origin. Headers are the denied headers and Allowed the snapshot of
+// the allowed headers of the policy, nil when all are, which is shared and must
+// not be modified.
+type HeadersNotAllowedError struct {
+	Headers []string
+	Origin  string
+	Allowed []string
+}
+
+func (e *HeadersNotAllowedError) Error() string {
+	return fmt.Sprintf("headers '%v' not allowed", e.Headers)
+}
+
+// Unwrap returns ErrHeadersNotAllowed
+func (e *HeadersNotAllowedError) Unwrap() error {
+	return ErrHeadersNotAllowed
+}
+
+// HeaderBudgetError is reported when the CORS headers of a response would exceed
+// Options.MaxAddedHeaderBytes
+type HeaderBudgetError struct {
+	Size int
+	Max  int
+}
+
+func (e *HeaderBudgetError) Error() string {
+	return fmt.Sprintf("%d bytes of headers exceed the budget of %d", e.Size, e.Max)
+}
+
+// Unwrap returns ErrHeaderBudget
+func (e *HeaderBudgetError) Unwrap() error {
+	return ErrHeaderBudget
+}
+
+// RequestHeadersLimitError is reported when the Access-Control-Request-Headers of a
+// preflight exceeds Options.MaxPreflightHeaderBytes or Options.MaxPreflightHeaderTokens.
+// Unit is either "bytes" or "tokens".
+type RequestHeadersLimitError struct {
+	Unit string
+	Size int
+	Max  int
+}
+
+func (e *RequestHeadersLimitError) Error() string {
+	return fmt.Sprintf("%d %s of requested headers exceed the limit of %d", e.Size, e.Unit, e.Max)
+}
+
+// Unwrap returns ErrRequestHeadersLimit
+func (e *RequestHeadersLimitError) Unwrap() error {
+	return ErrRequestHeadersLimit
+}
+
+// FetchMetadataError is reported when Options.EnforceFetchMetadata rejects a
+// cross-site request. Site and Mode are the Sec-Fetch-Site and Sec-Fetch-Mode
+// headers of the request, Origin its origin if any.
+type FetchMetadataError struct {
+	Site   string
+	Mode   string
+	Origin string
+}
+
+func (e *FetchMetadataError) Error() string {
+	if e.Origin == "" {
+		return fmt.Sprintf("%s %s request rejected", e.Site, e.Mode)
+	}
+	return fmt.Sprintf("%s %s request from '%s' rejected", e.Site, e.Mode, e.Origin)
+}
+
+// Unwrap returns ErrFetchMetadata
+func (e *FetchMetadataError) Unwrap() error {
+	return ErrFetchMetadata
+}
+
+// UnsafeOriginError is reported when Options.EnforceOriginForUnsafeMethods
+// rejects a state-changing request. Source is the header Origin was read from,
+// "Origin" or "Referer".
+type UnsafeOriginError struct {
+	Method string
+	Origin string
+	Source string
+}
+
+func (e *UnsafeOriginError) Error() string {
+	return fmt.Sprintf("%s request from '%s' (%s) rejected", e.Method, e.Origin, e.Source)
+}
+
+// Unwrap returns ErrUnsafeOrigin
+func (e *UnsafeOriginError) Unwrap() error {
+	return ErrUnsafeOrigin
+}
+
+// joinedError reports several failed checks. Its Is and As methods look into each
+// of them, like the errors.Join errors of newer Go versions do.
+type joinedError struct {
+	errs []error
+}
+
+func joinErrors(errs ...error) error {
+	return &joinedError{errs: errs}
+}
+
+func (e *joinedError) Error() string {
+	msgs := make([]string, len(e.errs))
+	for i, err := range e.errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the joined errors
+func (e *joinedError) Unwrap() []error {
+	return e.errs
+}
+
+// Is reports whether any of the joined errors matches target
+func (e *joinedError) Is(target error) bool {
+	for _, err := range e.errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first joined error matching target
+func (e *joinedError) As(target interface{}) bool {
+	for _, err := range e.errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestSentinelErrors(t *testing.T) {
+	cases := []struct {
+		err      error
+		sentinel error
+	}{
+		{&OriginNotAllowedError{Origin: "http://foo.com"}, ErrOriginNotAllowed},
+		{&MalformedOriginError{Origin: "foo"}, ErrMalformedOrigin},
+		{&MethodNotAllowedError{Method: "PUT"}, ErrMethodNotAllowed},
+		{&HeadersNotAllowedError{Headers: []string{"X-Foo"}}, ErrHeadersNotAllowed},
+		{&MalformedRequestHeadersError{Value: "X-Foo"}, ErrMalformedHeaders},
+		{&HeaderBudgetError{Size: 2, Max: 1}, ErrHeaderBudget},
+		{&RequestHeadersLimitError{Unit: "bytes", Size: 2, Max: 1}, ErrRequestHeadersLimit},
+	}
+	for _, tc := range cases {
+		if !errors.Is(fmt.Errorf("wrapped: %w", tc.err), tc.sentinel) {
+			t.Errorf("%T does not match %v", tc.err, tc.sentinel)
+		}
+		if errors.Is(tc.err, ErrOriginNotAllowed) != (tc.sentinel == ErrOriginNotAllowed) {
+			t.Errorf("%T matches ErrOriginNotAllowed", tc.err)
+		}
+	}
+}
+
+func TestJoinedPreflightErrors(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"http://foo.com"},
+		AllowedMethods: []string{"GET"},
+		AllowedHeaders: []string{"X-Header-1"},
+	})
+	req, _ := http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "http://foo.com")
+	req.Header.Add("Access-Control-Request-Method", "PUT")
+	req.Header.Add("Access-Control-Request-Headers", "x-header-2")
+	d := s.Check(req)
+	if d.Reason != ReasonMethod {
+		t.Errorf("Reason = %q, want %q", d.Reason, ReasonMethod)
+	}
+	if !errors.Is(d.Err, ErrMethodNotAllowed) || !errors.Is(d.Err, ErrHeadersNotAllowed) || errors.Is(d.Err, ErrOriginNotAllowed) {
+		t.Errorf("Err = %v does not join the method and headers errors", d.Err)
+	}
+	var headersErr *HeadersNotAllowedError
+	if !errors.As(d.Err, &headersErr) || headersErr.Headers[0] != "X-Header-2" {
+		t.Errorf("errors.As(%v, *HeadersNotAllowedError) = %+v", d.Err, headersErr)
+	}
+	if want := "method 'PUT' not allowed\nheaders '[X-Header-2]' not allowed"; d.Err.Error() != want {
+		t.Errorf("Err.Error() = %q, want %q", d.Err.Error(), want)
+	}
+}
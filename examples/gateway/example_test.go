@@ -0,0 +1,43 @@
+package gateway_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/go-chi/cors/examples/gateway"
+)
+
+func allowOrigin(url, origin string) string {
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Origin", origin)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		panic(err)
+	}
+	res.Body.Close()
+	return fmt.Sprintf("%q", res.Header.Get("Access-Control-Allow-Origin"))
+}
+
+func Example() {
+	g := gateway.New("https://partner-a.com")
+	srv := httptest.NewServer(g)
+	defer srv.Close()
+
+	fmt.Println("public:", allowOrigin(srv.URL+"/public/prices", "https://anyone.com"))
+	fmt.Println("partner a:", allowOrigin(srv.URL+"/partner/orders", "https://partner-a.com"))
+	fmt.Println("partner b:", allowOrigin(srv.URL+"/partner/orders", "https://partner-b.com"))
+
+	if err := g.Reload("https://partner-b.com"); err != nil {
+		panic(err)
+	}
+	fmt.Println("reloaded, partner a:", allowOrigin(srv.URL+"/partner/orders", "https://partner-a.com"))
+	fmt.Println("reloaded, partner b:", allowOrigin(srv.URL+"/partner/orders", "https://partner-b.com"))
+
+	// Output:
+	// public: "*"
+	// partner a: "https://partner-a.com"
+	// partner b: ""
+	// reloaded, partner a: ""
+	// reloaded, partner b: "https://partner-b.com"
+}
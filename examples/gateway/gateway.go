@@ -0,0 +1,49 @@
+// Package gateway is an example API gateway applying a different CORS policy per
+// route, whose partner origins can be reloaded while serving.
+package gateway
+
+import (
+	"net/http"
+
+	"github.com/go-chi/cors"
+)
+
+// Gateway routes public and partner APIs
+type Gateway struct {
+	http.Handler
+
+	partners *cors.Cors
+}
+
+// New creates a gateway allowing any origin to read the public API, and only the
+// given origins to call the partner API.
+func New(partners ...string) *Gateway {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	g := &Gateway{partners: cors.New(partnerOptions(partners))}
+
+	mux := http.NewServeMux()
+	mux.Handle("/public/", cors.AllowAll().Handler(ok))
+	mux.Handle("/partner/", g.partners.Handler(ok))
+	g.Handler = mux
+	return g
+}
+
+// Reload replaces the partner origins, requests in flight completing with the
+// previous ones.
+func (g *Gateway) Reload(partners ...string) error {
+	return g.partners.UpdateOptions(partnerOptions(partners))
+}
+
+func partnerOptions(partners []string) cors.Options {
+	if len(partners) == 0 {
+		// Never fall back to allowing every origin
+		partners = []string{"https://none.invalid"}
+	}
+	return cors.Options{
+		AllowedOrigins:   partners,
+		AllowedMethods:   []string{http.MethodGet, http.MethodPost},
+		AllowCredentials: true,
+	}
+}
@@ -0,0 +1,43 @@
+package multitenant_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	multitenant "github.com/go-chi/cors/examples/multi-tenant"
+)
+
+func get(url, tenant, origin string) string {
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("X-Tenant", tenant)
+	req.Header.Set("Origin", origin)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		panic(err)
+	}
+	res.Body.Close()
+	return fmt.Sprintf("%q", res.Header.Get("Access-Control-Allow-Origin"))
+}
+
+func Example() {
+	reg := multitenant.NewRegistry()
+	srv := httptest.NewServer(multitenant.NewHandler(reg))
+	defer srv.Close()
+	url := srv.URL + "/api/orders"
+
+	fmt.Println("before signup:", get(url, "acme", "https://shop.acme.com"))
+
+	reg.Register("acme", "https://shop.acme.com")
+	fmt.Println("after signup:", get(url, "acme", "https://shop.acme.com"))
+	fmt.Println("other tenant:", get(url, "globex", "https://shop.acme.com"))
+
+	reg.Unregister("https://shop.acme.com")
+	fmt.Println("after removal:", get(url, "acme", "https://shop.acme.com"))
+
+	// Output:
+	// before signup: ""
+	// after signup: "https://shop.acme.com"
+	// other tenant: ""
+	// after removal: ""
+}
@@ -0,0 +1,60 @@
+// Package multitenant is an example SaaS API whose customers register their own
+// frontend origins at runtime, without restarting the server.
+package multitenant
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/cors"
+)
+
+// Registry holds the origins registered by tenants
+type Registry struct {
+	mu      sync.RWMutex
+	origins map[string]string // origin -> tenant
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{origins: map[string]string{}}
+}
+
+// Register allows origin for tenant
+func (reg *Registry) Register(tenant, origin string) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.origins[strings.ToLower(origin)] = tenant
+}
+
+// Unregister removes origin
+func (reg *Registry) Unregister(origin string) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	delete(reg.origins, strings.ToLower(origin))
+}
+
+// allowed reports whether origin was registered by the tenant the request is for,
+// named by the X-Tenant header
+func (reg *Registry) allowed(r *http.Request, origin string) bool {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	tenant, ok := reg.origins[strings.ToLower(origin)]
+	return ok && tenant == r.Header.Get("X-Tenant")
+}
+
+// NewHandler returns the API handler, consulting reg on every request.
+func NewHandler(reg *Registry) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	c := cors.New(cors.Options{
+		AllowOriginFunc: reg.allowed,
+		AllowedMethods:  []string{http.MethodGet, http.MethodPost},
+		AllowedHeaders:  []string{"Content-Type", "X-Tenant"},
+	})
+	return c.Handler(mux)
+}
@@ -0,0 +1,55 @@
+package spaapi_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	spaapi "github.com/go-chi/cors/examples/spa-api"
+)
+
+func Example() {
+	srv := httptest.NewServer(spaapi.NewHandler("https://app.example.com"))
+	defer srv.Close()
+
+	// The browser checks the authenticated PUT first
+	req, _ := http.NewRequest(http.MethodOptions, srv.URL+"/api/me", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "PUT")
+	req.Header.Set("Access-Control-Request-Headers", "authorization,content-type")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		panic(err)
+	}
+	res.Body.Close()
+	fmt.Println("preflight:", res.StatusCode)
+	fmt.Println("allow-headers:", res.Header.Get("Access-Control-Allow-Headers"))
+	fmt.Println("allow-credentials:", res.Header.Get("Access-Control-Allow-Credentials"))
+
+	// Then sends it, and reads the exposed request id
+	req, _ = http.NewRequest(http.MethodPut, srv.URL+"/api/me", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		panic(err)
+	}
+	res.Body.Close()
+	fmt.Println("expose-headers:", res.Header.Get("Access-Control-Expose-Headers"))
+
+	// Other sites don't get any CORS header
+	req, _ = http.NewRequest(http.MethodGet, srv.URL+"/api/me", nil)
+	req.Header.Set("Origin", "https://evil.example.net")
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		panic(err)
+	}
+	res.Body.Close()
+	fmt.Printf("other site: %q\n", res.Header.Get("Access-Control-Allow-Origin"))
+
+	// Output:
+	// preflight: 200
+	// allow-headers: Authorization, Content-Type
+	// allow-credentials: true
+	// expose-headers: X-Request-Id
+	// other site: ""
+}
@@ -0,0 +1,32 @@
+// Package spaapi is an example JSON API consumed by a single page application
+// served from another origin, authenticating with cookies or an Authorization
+// header.
+package spaapi
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/cors"
+)
+
+// NewHandler returns the API handler, callable from the frontend origin only.
+func NewHandler(frontend string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/me", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "42")
+		fmt.Fprint(w, `{"name":"gopher"}`)
+	})
+
+	c := cors.New(cors.Options{
+		AllowedOrigins:       []string{frontend},
+		AllowedMethods:       []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete},
+		AllowedHeaders:       []string{"Content-Type"},
+		ExposedHeaders:       []string{"X-Request-Id"},
+		AllowCredentials:     true,
+		AutoAllowAuthHeaders: true,
+		MaxAge:               600,
+	})
+	return c.Handler(mux)
+}
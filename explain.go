@@ -0,0 +1,196 @@
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/cors/engine"
+)
+
+// Explanation is the trace of the checks performed on a request, see Explain
+type Explanation struct {
+	// Decision is the decision Check takes for the request
+	Decision Decision `json:"-"`
+
+	// Steps are the checks performed, in order
+	Steps []ExplanationStep `json:"steps"`
+}
+
+// ExplanationStep is a single check of an Explanation
+type ExplanationStep struct {
+	// Check names the check: "origin-syntax", "denied-origin", "origin-pattern",
+	// "method", "header"...
+	Check string `json:"check"`
+
+	// Subject is what was checked: a pattern, a method or a header name
+	Subject string `json:"subject,omitempty"`
+
+	// Passed is set when the check succeeded, e.g. the pattern matched
+	Passed bool `json:"passed"`
+
+	// Detail adds context to the outcome
+	Detail string `json:"detail,omitempty"`
+}
+
+// String renders the explanation as one line per step followed by the outcome
+func (e Explanation) String() string {
+	var b strings.Builder
+	for _, s := range e.Steps {
+		outcome := "FAIL"
+		if s.Passed {
+			outcome = "PASS"
+		}
+		fmt.Fprintf(&b, "%s %s", outcome, s.Check)
+		if s.Subject != "" {
+			fmt.Fprintf(&b, " %s", s.Subject)
+		}
+		if s.Detail != "" {
+			fmt.Fprintf(&b, " (%s)", s.Detail)
+		}
+		b.WriteByte('\n')
+	}
+	d := e.Decision
+	switch {
+	case d.Origin == "":
+		b.WriteString("not a cross-origin request\n")
+	case d.Allowed:
+		fmt.Fprintf(&b, "allowed by %q\n", d.MatchedOrigin)
+	default:
+		fmt.Fprintf(&b, "denied (%s): %v\n", d.Reason, d.Err)
+	}
+	return b.String()
+}
+
+// Explain traces every check the policy performs on r, each origin pattern tried,
+// the method and each requested header, without writing any response nor
+// reporting the decision. It is meant to debug CORS failures, e.g. from an
+// internal admin endpoint, and is much slower than Check.
+func (c *Cors) Explain(r *http.Request) Explanation {
+	e := Explanation{Decision: c.Check(r)}
+	p, err := c.current().resolve(r)
+	if err != nil {
+		e.add("policy", "", false, err.Error())
+		return e
+	}
+	origin := headerValue(r.Header, "Origin")
+	if p.fetchMetadata && !isPreflight(r) {
+		if err := p.checkFetchMetadata(r, origin); err != nil {
+			e.add("fetch-metadata", headerValue(r.Header, "Sec-Fetch-Site"), false, err.Error())
+			return e
+		}
+		e.add("fetch-metadata", headerValue(r.Header, "Sec-Fetch-Site"), true, "")
+	}
+	if p.unsafeOrigin && !isPreflight(r) && !isSafeMethod(r.Method) {
+		if err := p.checkUnsafeOrigin(r, origin); err != nil {
+			e.add("unsafe-origin", r.Method, false, err.Error())
+			return e
+		}
+		e.add("unsafe-origin", r.Method, true, "")
+	}
+	if origin == "" {
+		e.add("origin", "", false, "no Origin header")
+		return e
+	}
+	preflight := isPreflight(r)
+	if err := checkOriginSyntax(r, origin); err != nil {
+		e.add("origin-syntax", origin, false, err.Error())
+		return e
+	}
+	e.add("origin-syntax", origin, true, "")
+	if !p.explainOrigin(&e, r, origin) {
+		return e
+	}
+
+	method := r.Method
+	if preflight {
+		method = strings.ToUpper(headerValue(r.Header, "Access-Control-Request-Method"))
+	}
+	e.add("method", method, p.isMethodAllowed(method), "allowed: "+listOrNone(p.allowedMethods))
+	if !preflight {
+		if ct := r.Header.Get("Content-Type"); p.strictContentType && ct != "" {
+			e.add("content-type", ct, isSafelistedContentType(ct) || p.isHeaderAllowed(method, "Content-Type"), "")
+		}
+		return e
+	}
+
+	values := headerValues(r.Header, "Access-Control-Request-Headers")
+	if err := p.checkRequestHeadersLimits(values); err != nil {
+		e.add("request-headers-limit", "", false, err.Error())
+		return e
+	}
+	if p.strictReqHeaders && len(values) > 0 {
+		value := strings.Join(values, ",")
+		e.add("request-headers-syntax", value, len(values) == 1 && value != "" && isFetchHeaderList(value), "")
+	}
+	for _, header := range engine.ParseHeaderList(strings.Join(values, ",")) {
+		allowed, detail := p.isHeaderAllowed(method, header), ""
+		switch {
+		case engine.IsSafelistedHeader(header):
+			detail = "safelisted"
+		case engine.IsForbiddenHeader(header):
+			detail = "forbidden header, never echoed"
+			allowed = allowed && !p.denyForbidden
+		case p.allowedHeadersAll:
+			detail = "all headers allowed"
+		case !containsString(p.allowedHeaders, header) && containsString(p.methodHeaders[method], header):
+			detail = "allowed for " + method + " only"
+		}
+		e.add("header", header, allowed, detail)
+	}
+	return e
+}
+
+// explainOrigin adds the origin matching steps to e, in the order matchOrigin
+// performs them, and reports whether the origin is allowed
+func (p *policy) explainOrigin(e *Explanation, r *http.Request, origin string) bool {
+	if p.deniedOrigins != nil {
+		if m := p.deniedOrigins.Match(origin); m != nil {
+			e.add("denied-origin", m.String(), false, "origin is explicitly denied")
+			return false
+		}
+	}
+	if strings.EqualFold(origin, "null") {
+		e.add("null-origin", origin, p.allowNullOrigin, "only allowed by AllowNullOrigin")
+		return p.allowNullOrigin
+	}
+	if p.allowLocalhost && engine.IsLocalhostOrigin(origin) {
+		e.add("localhost", origin, true, "")
+		return true
+	}
+	if p.allowOriginFunc != nil {
+		allowed := p.callAllowOriginFunc(r, origin)
+		e.add("origin-func", origin, allowed, "AllowOriginFunc")
+		return allowed
+	}
+	if p.allowedOriginsAll {
+		e.add("origin-pattern", "*", true, "all origins allowed")
+		return true
+	}
+	allowed := false
+	if p.origins != nil {
+		winner := p.origins.Match(origin)
+		for _, o := range p.origins.Patterns() {
+			detail := ""
+			if o == winner {
+				detail = "selected"
+				allowed = true
+			}
+			e.add("origin-pattern", o.String(), o.Match(origin), detail)
+		}
+	}
+	if !allowed && p.originProvider != nil {
+		m := p.originProvider.match(r.Context(), origin)
+		subject := ""
+		if m != nil {
+			subject = m.String()
+		}
+		e.add("origin-provider", subject, m != nil, "OriginProvider")
+		allowed = m != nil
+	}
+	return allowed
+}
+
+func (e *Explanation) add(check, subject string, passed bool, detail string) {
+	e.Steps = append(e.Steps, ExplanationStep{Check: check, Subject: subject, Passed: passed, Detail: detail})
+}
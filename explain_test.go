@@ -0,0 +1,43 @@
+package cors
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"https://app.com", "https://*.app.com"},
+		AllowedMethods: []string{"GET", "PUT"},
+		AllowedHeaders: []string{"X-Header-1"},
+	})
+	req, _ := http.NewRequest("OPTIONS", "http://api.com/foo", nil)
+	req.Header.Add("Origin", "https://admin.app.com")
+	req.Header.Add("Access-Control-Request-Method", "PUT")
+	req.Header.Add("Access-Control-Request-Headers", "accept,cookie,x-header-1,x-header-2")
+	e := s.Explain(req)
+	want := "PASS origin-syntax https://admin.app.com\n" +
+		"FAIL origin-pattern https://app.com\n" +
+		"PASS origin-pattern https://*.app.com (selected)\n" +
+		"PASS method PUT (allowed: GET, PUT)\n" +
+		"PASS header Accept (safelisted)\n" +
+		"FAIL header Cookie (forbidden header, never echoed)\n" +
+		"PASS header X-Header-1\n" +
+		"FAIL header X-Header-2\n" +
+		"denied (headers): headers '[Cookie X-Header-2]' not allowed\n"
+	if got := e.String(); got != want {
+		t.Errorf("Explain() =\n%s\nwant\n%s", got, want)
+	}
+
+	req, _ = http.NewRequest("GET", "http://api.com/foo", nil)
+	req.Header.Add("Origin", "https://evil.com")
+	e = s.Explain(req)
+	if e.Decision.Allowed || len(e.Steps) != 3 || e.Steps[1].Passed || e.Steps[2].Passed {
+		t.Errorf("Explain() = %+v for a denied origin", e)
+	}
+
+	req, _ = http.NewRequest("GET", "http://api.com/foo", nil)
+	if got, want := s.Explain(req).String(), "FAIL origin (no Origin header)\nnot a cross-origin request\n"; got != want {
+		t.Errorf("Explain() = %q, want %q", got, want)
+	}
+}
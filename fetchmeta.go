@@ -0,0 +1,31 @@
+package cors
+
+import "net/http"
+
+// Vary values of actual responses when Options.EnforceFetchMetadata is set
+var fetchMetadataVary = []string{"Sec-Fetch-Site", "Sec-Fetch-Mode", "Sec-Fetch-Dest"}
+
+// checkFetchMetadata applies the resource isolation policy of
+// Options.EnforceFetchMetadata to the actual request r
+func (p *policy) checkFetchMetadata(r *http.Request, origin string) error {
+	site := headerValue(r.Header, "Sec-Fetch-Site")
+	switch site {
+	case "", "same-origin", "same-site", "none":
+		// Not sent by the browser, or not cross-site
+		return nil
+	}
+	mode := headerValue(r.Header, "Sec-Fetch-Mode")
+	if mode == "navigate" && r.Method == http.MethodGet {
+		// Top-level navigations can't be blocked without breaking links, but
+		// resources embedded through object and embed elements can
+		if dest := headerValue(r.Header, "Sec-Fetch-Dest"); dest != "object" && dest != "embed" {
+			return nil
+		}
+	}
+	if origin != "" && checkOriginSyntax(r, origin) == nil {
+		if _, ok := p.matchOrigin(r, origin); ok {
+			return nil
+		}
+	}
+	return &FetchMetadataError{Site: site, Mode: mode, Origin: origin}
+}
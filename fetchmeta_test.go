@@ -0,0 +1,79 @@
+package cors
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEnforceFetchMetadata(t *testing.T) {
+	cases := []struct {
+		name   string
+		method string
+		header map[string]string
+		status int
+	}{
+		{"NoMetadata", "POST", map[string]string{"Origin": "http://bar.com"}, http.StatusOK},
+		{"SameOrigin", "POST", map[string]string{"Sec-Fetch-Site": "same-origin", "Sec-Fetch-Mode": "cors"}, http.StatusOK},
+		{"SameSite", "GET", map[string]string{"Sec-Fetch-Site": "same-site", "Sec-Fetch-Mode": "no-cors"}, http.StatusOK},
+		{"UserInitiated", "GET", map[string]string{"Sec-Fetch-Site": "none", "Sec-Fetch-Mode": "navigate"}, http.StatusOK},
+		{"Navigation", "GET", map[string]string{"Sec-Fetch-Site": "cross-site", "Sec-Fetch-Mode": "navigate", "Sec-Fetch-Dest": "document"}, http.StatusOK},
+		{"EmbedNavigation", "GET", map[string]string{"Sec-Fetch-Site": "cross-site", "Sec-Fetch-Mode": "navigate", "Sec-Fetch-Dest": "embed"}, http.StatusForbidden},
+		{"CrossSiteImage", "GET", map[string]string{"Sec-Fetch-Site": "cross-site", "Sec-Fetch-Mode": "no-cors", "Sec-Fetch-Dest": "image"}, http.StatusForbidden},
+		{"CrossSiteFormPost", "POST", map[string]string{"Origin": "http://bar.com", "Sec-Fetch-Site": "cross-site", "Sec-Fetch-Mode": "navigate"}, http.StatusForbidden},
+		{"AllowedOrigin", "POST", map[string]string{"Origin": "http://foo.com", "Sec-Fetch-Site": "cross-site", "Sec-Fetch-Mode": "cors"}, http.StatusOK},
+	}
+	var decisions []Decision
+	s := New(Options{
+		AllowedOrigins:       []string{"http://foo.com"},
+		AllowedMethods:       []string{"GET", "POST"},
+		EnforceFetchMetadata: true,
+		OnDecision:           func(d Decision) { decisions = append(decisions, d) },
+	})
+	for _, tc := range cases {
+		req, _ := http.NewRequest(tc.method, "http://example.com/foo", nil)
+		for name, value := range tc.header {
+			req.Header.Add(name, value)
+		}
+		res := httptest.NewRecorder()
+		s.Handler(testHandler).ServeHTTP(res, req)
+		if res.Code != tc.status {
+			t.Errorf("%s: status = %d, want %d", tc.name, res.Code, tc.status)
+		}
+		d := s.Check(req)
+		if rejected := errors.Is(d.Err, ErrFetchMetadata); rejected != (tc.status == http.StatusForbidden) {
+			t.Errorf("%s: Check error = %v", tc.name, d.Err)
+		}
+	}
+
+	var rejected int
+	for _, d := range decisions {
+		if d.Reason == ReasonFetchMetadata {
+			rejected++
+		}
+	}
+	if rejected != 3 {
+		t.Errorf("%d rejections reported, want 3", rejected)
+	}
+
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	req.Header.Set("Sec-Fetch-Mode", "no-cors")
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	if got, want := res.Header().Get("Vary"), "Origin, Sec-Fetch-Site, Sec-Fetch-Mode, Sec-Fetch-Dest"; got != want {
+		t.Errorf("Vary = %q, want %q", got, want)
+	}
+
+	s = New(Options{
+		AllowedOrigins:       []string{"http://foo.com"},
+		EnforceFetchMetadata: true,
+		ReportOnly:           true,
+	})
+	res = httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Errorf("ReportOnly: status = %d, want %d", res.Code, http.StatusOK)
+	}
+}
@@ -0,0 +1,177 @@
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/go-chi/cors/engine"
+)
+
+// ErrFrozen is returned by the methods changing the configuration of a frozen handler
+var ErrFrozen = errors.New("cors: configuration is frozen")
+
+// PolicyHealth describes the integrity of the configuration in effect
+type PolicyHealth struct {
+	// Frozen is set once Freeze was called
+	Frozen bool
+	// Fingerprint is a hash of the configuration in effect, recomputed on every call
+	Fingerprint string
+	// Tampered is set when the configuration of a frozen handler no longer matches
+	// the fingerprint recorded by Freeze
+	Tampered bool
+}
+
+// Freeze prevents any further change of the configuration: UpdateOptions and the
+// other methods mutating the handler return ErrFrozen afterwards, and the origins
+// of the OriginProvider are pinned, being loaded first if they never were, so
+// that neither the provider nor an OriginStore changes them anymore. The
+// fingerprint of the configuration, including these origins, is recorded and
+// verified by Health, proving the policy didn't change since boot. Freeze can be
+// called several times.
+func (c *Cors) Freeze() {
+	for p := c.current(); p != nil; p = p.shadow {
+		if p.originProvider != nil {
+			p.originProvider.pin()
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.frozenFingerprint == "" {
+		c.frozenFingerprint = c.current().fingerprint()
+	}
+}
+
+// policyHealth computes the integrity of the configuration in effect
+func (c *Cors) policyHealth() PolicyHealth {
+	c.mu.Lock()
+	frozen := c.frozenFingerprint
+	c.mu.Unlock()
+	h := PolicyHealth{
+		Frozen:      frozen != "",
+		Fingerprint: c.current().fingerprint(),
+	}
+	h.Tampered = h.Frozen && h.Fingerprint != frozen
+	return h
+}
+
+// fingerprint hashes the compiled configuration field by field, so that none
+// can be left out: the policy, including the digest of the options it was
+// compiled from (see optionsDigest), and its shadow policy. Functions are only
+// accounted for by their presence, interfaces by their type, and pointers to
+// runtime state (caches, providers, collectors...) by their presence, except
+// for the origins loaded from the OriginProvider which are part of the policy
+// in effect.
+func (p *policy) fingerprint() string {
+	h := fnv.New64a()
+	writeCanonical(h, reflect.ValueOf(p).Elem())
+	for ; p != nil; p = p.shadow {
+		if p.originProvider != nil {
+			io.WriteString(h, "originProvider:")
+			writeCanonical(h, reflect.ValueOf(p.originProvider.loaded()))
+		}
+	}
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// optionsDigest hashes every field of options, accounting for the ones which
+// don't survive compilation, such as cache sizes, in fingerprints
+func optionsDigest(options Options) string {
+	h := fnv.New64a()
+	writeCanonical(h, reflect.ValueOf(options))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// Types whose values are part of fingerprints when pointed to, other pointers
+// being only accounted for by their presence
+var fingerprintedTypes = map[reflect.Type]bool{
+	reflect.TypeOf(policy{}):               true,
+	reflect.TypeOf(Options{}):              true,
+	reflect.TypeOf(Messages{}):             true,
+	reflect.TypeOf(engine.OriginMatcher{}): true,
+}
+
+// isPlainData reports whether writeCanonical encodes v entirely, v holding no
+// function, channel, interface nor pointer to runtime state
+func isPlainData(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer, reflect.Interface:
+		return v.IsNil()
+	case reflect.Ptr:
+		return v.IsNil() || fingerprintedTypes[v.Type().Elem()] && isPlainData(v.Elem())
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !isPlainData(v.Field(i)) {
+				return false
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if !isPlainData(v.Index(i)) {
+				return false
+			}
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			if !isPlainData(v.MapIndex(k)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// writeCanonical writes a deterministic encoding of v to w, see fingerprint
+func writeCanonical(w io.Writer, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		fmt.Fprint(w, !v.IsNil())
+	case reflect.Interface:
+		if v.IsNil() {
+			io.WriteString(w, "nil")
+		} else {
+			io.WriteString(w, v.Elem().Type().String())
+		}
+	case reflect.Ptr:
+		switch {
+		case v.IsNil():
+			io.WriteString(w, "nil")
+		case fingerprintedTypes[v.Type().Elem()]:
+			writeCanonical(w, v.Elem())
+		default:
+			io.WriteString(w, "set")
+		}
+	case reflect.Struct:
+		t := v.Type()
+		io.WriteString(w, "{")
+		for i := 0; i < t.NumField(); i++ {
+			io.WriteString(w, t.Field(i).Name+":")
+			writeCanonical(w, v.Field(i))
+			io.WriteString(w, ";")
+		}
+		io.WriteString(w, "}")
+	case reflect.Slice, reflect.Array:
+		fmt.Fprintf(w, "[%d:", v.Len())
+		for i := 0; i < v.Len(); i++ {
+			writeCanonical(w, v.Index(i))
+			io.WriteString(w, ",")
+		}
+		io.WriteString(w, "]")
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		fmt.Fprintf(w, "map[%d:", v.Len())
+		for _, k := range keys {
+			fmt.Fprintf(w, "%q=", fmt.Sprint(k))
+			writeCanonical(w, v.MapIndex(k))
+			io.WriteString(w, ",")
+		}
+		io.WriteString(w, "]")
+	default:
+		fmt.Fprintf(w, "%q", fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,180 @@
+package cors
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-chi/cors/engine"
+)
+
+func TestFreeze(t *testing.T) {
+	s := New(Options{AllowedOrigins: []string{"http://foo.com"}, MaxAge: 10})
+	if h := s.Health().Policy; h.Frozen || h.Tampered || h.Fingerprint == "" {
+		t.Errorf("Health().Policy = %+v, want not frozen with a fingerprint", h)
+	}
+	if err := s.UpdateOptions(Options{AllowedOrigins: []string{"http://bar.com"}}); err != nil {
+		t.Fatalf("UpdateOptions() = %v", err)
+	}
+
+	s.Freeze()
+	before := s.Health().Policy
+	if !before.Frozen || before.Tampered {
+		t.Errorf("Health().Policy = %+v, want frozen and intact", before)
+	}
+	if err := s.UpdateOptions(Options{}); err != ErrFrozen {
+		t.Errorf("UpdateOptions() = %v, want ErrFrozen", err)
+	}
+	if !s.current().isOriginAllowed(nil, "http://bar.com") {
+		t.Error("configuration changed by a rejected update")
+	}
+
+	// Simulate a change bypassing Freeze
+	s.current().maxAge = 20
+	if h := s.Health().Policy; !h.Tampered || h.Fingerprint == before.Fingerprint {
+		t.Errorf("Health().Policy = %+v, want tampered", h)
+	}
+}
+
+func TestFreezeOriginProvider(t *testing.T) {
+	origins := []string{"http://foo.com"}
+	s := New(Options{
+		OriginProvider: OriginProviderFunc(func(ctx context.Context) ([]string, error) {
+			return origins, nil
+		}),
+		OriginProviderTTL: time.Minute,
+	})
+	now := time.Now()
+	s.current().originProvider.now = func() time.Time { return now }
+
+	// Origins are loaded by Freeze and part of the fingerprint
+	empty := s.Health().Policy.Fingerprint
+	s.Freeze()
+	frozen := s.Health().Policy
+	if frozen.Tampered || frozen.Fingerprint == empty {
+		t.Errorf("Health().Policy = %+v, want intact with the loaded origins", frozen)
+	}
+	if !s.current().isOriginAllowed(nil, "http://foo.com") {
+		t.Error("provided origin should be allowed")
+	}
+
+	// and never reloaded afterwards
+	origins = []string{"http://bar.com"}
+	now = now.Add(2 * time.Minute)
+	if s.current().isOriginAllowed(nil, "http://bar.com") || !s.current().isOriginAllowed(nil, "http://foo.com") {
+		t.Error("origins changed after Freeze")
+	}
+	if h := s.Health().Policy; h != frozen {
+		t.Errorf("Health().Policy = %+v, want %+v", h, frozen)
+	}
+
+	// Origins changed behind Freeze's back
+	s.current().originProvider.matcher, _ = engine.NewOriginMatcher(origins, nil, engine.MatchMostSpecific)
+	if h := s.Health().Policy; !h.Tampered {
+		t.Errorf("Health().Policy = %+v, want tampered", h)
+	}
+}
+
+func TestFreezeTampering(t *testing.T) {
+	resolver := PolicyResolverFunc(func(r *http.Request) (*Options, error) { return nil, nil })
+	cases := map[string]func(p *policy){
+		"resolver removed":           func(p *policy) { p.resolver = nil },
+		"upstream headers kept":      func(p *policy) { p.overrideUpstream = false },
+		"vary merge disabled":        func(p *policy) { p.mergeVary = false },
+		"shadow policy loosened":     func(p *policy) { p.shadow.allowedOriginsAll = true },
+		"routed methods all allowed": func(p *policy) { p.allowRoutedMethods = true },
+	}
+	for name, tamper := range cases {
+		s := New(Options{
+			AllowedOrigins:          []string{"http://foo.com"},
+			PolicyResolver:          resolver,
+			OverrideUpstreamHeaders: true,
+			ShadowPolicy:            &Options{AllowedOrigins: []string{"http://bar.com"}},
+		})
+		s.Freeze()
+		tamper(s.current())
+		if h := s.Health().Policy; !h.Tampered {
+			t.Errorf("%s: Health().Policy = %+v, want tampered", name, h)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	a := New(Options{AllowedOrigins: []string{"http://foo.com"}})
+	b := New(Options{AllowedOrigins: []string{"http://foo.com"}})
+	c := New(Options{AllowedOrigins: []string{"http://foo.com"}, AllowCredentials: true})
+	if a.current().fingerprint() != b.current().fingerprint() {
+		t.Error("equal configurations have different fingerprints")
+	}
+	if a.current().fingerprint() == c.current().fingerprint() {
+		t.Error("different configurations have the same fingerprint")
+	}
+}
+
+// Values making Options fields compile when the sample of their kind doesn't
+var fingerprintSamples = map[string]interface{}{
+	"AllowedOrigins":      []string{"https://foo.com"},
+	"AllowedOriginsRegex": []string{`^https://foo\.com$`},
+	"DeniedOrigins":       []string{"https://bar.com"},
+	"AllowedMethods":      []string{"PUT"},
+	"SkipPaths":           []string{"/skip"},
+	"MethodHeaderPolicy":  map[string][]string{"PUT": {"X-Put"}},
+	"SampleReasons":       map[string]float64{ReasonOrigin: 0.5},
+	"DenyWithStatus":      http.StatusTeapot,
+	"OriginProvider": OriginProviderFunc(func(ctx context.Context) ([]string, error) {
+		return nil, nil
+	}),
+	"PolicyResolver": PolicyResolverFunc(func(r *http.Request) (*Options, error) { return nil, nil }),
+	"AuditWriter":    ioutil.Discard,
+	"Logger":         &recordingLevelLogger{},
+	"Telemetry":      NewTelemetry(),
+}
+
+// TestFingerprintCoversOptions fails when the fingerprint ignores an option, as
+// Health could then not tell it was changed
+func TestFingerprintCoversOptions(t *testing.T) {
+	base := New(Options{}).current().fingerprint()
+	typ := reflect.TypeOf(Options{})
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		var o Options
+		v := reflect.ValueOf(&o).Elem().Field(i)
+		if sample, ok := fingerprintSamples[field.Name]; ok {
+			v.Set(reflect.ValueOf(sample))
+		} else {
+			switch v.Kind() {
+			case reflect.Bool:
+				v.SetBool(true)
+			case reflect.Int, reflect.Int64:
+				v.SetInt(1)
+			case reflect.Float64:
+				v.SetFloat(0.5)
+			case reflect.String:
+				v.SetString("x")
+			case reflect.Slice:
+				v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+				if v.Index(0).Kind() == reflect.String {
+					v.Index(0).SetString("X-Foo")
+				}
+			case reflect.Ptr:
+				v.Set(reflect.New(v.Type().Elem()))
+			case reflect.Func:
+				v.Set(reflect.MakeFunc(v.Type(), func([]reflect.Value) []reflect.Value {
+					panic("not called")
+				}))
+			default:
+				t.Fatalf("%s: no sample value for %s, add one to fingerprintSamples", field.Name, v.Type())
+			}
+		}
+		p, err := newPolicy(&Cors{}, o)
+		if err != nil {
+			t.Fatalf("%s: %v, add a valid sample to fingerprintSamples", field.Name, err)
+		}
+		if p.fingerprint() == base {
+			t.Errorf("%s is not part of the fingerprint", field.Name)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package cors
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/go-chi/cors/engine"
+)
+
+// Hosts selects the Cors handler applied to a request by its Host, for servers
+// answering on several domains which each have their own frontends.
+type Hosts struct {
+	matcher  *engine.OriginMatcher
+	policies map[string]*Cors
+	fallback *Cors
+}
+
+// NewHosts creates a Hosts from policies keyed by host name. Keys are either exact
+// host names or contain a "*" wildcard such as "*.example.com"; exact names win
+// over wildcards, then the longest wildcard wins. Ports are ignored. Requests for
+// other hosts use fallback, or get no CORS headers at all if it is nil.
+func NewHosts(policies map[string]*Cors, fallback *Cors) *Hosts {
+	hosts := make([]string, 0, len(policies))
+	for host := range policies {
+		hosts = append(hosts, host)
+	}
+	// Map iteration order must not decide between equally specific patterns
+	sort.Strings(hosts)
+	matcher, _ := engine.NewOriginMatcher(hosts, nil, engine.MatchMostSpecific)
+	return &Hosts{matcher: matcher, policies: policies, fallback: fallback}
+}
+
+// policy returns the Cors handler for the request host, nil if none applies
+func (h *Hosts) policy(r *http.Request) *Cors {
+	if p := h.matcher.Match(engine.TrimPort(r.Host)); p != nil {
+		return h.policies[p.String()]
+	}
+	return h.fallback
+}
+
+// Handler applies the Cors handler selected by the request host before next.
+func (h *Hosts) Handler(next http.Handler) http.Handler {
+	handlers := make(map[*Cors]http.Handler, len(h.policies)+1)
+	for _, c := range h.policies {
+		handlers[c] = c.Handler(next)
+	}
+	if h.fallback != nil {
+		handlers[h.fallback] = h.fallback.Handler(next)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c := h.policy(r); c != nil {
+			handlers[c].ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Check evaluates r against the policy selected by its host. Requests for hosts
+// without policy get a Decision that is not allowed, without reason.
+func (h *Hosts) Check(r *http.Request) Decision {
+	if c := h.policy(r); c != nil {
+		return c.Check(r)
+	}
+	return Decision{Preflight: isPreflight(r), Origin: headerValue(r.Header, "Origin")}
+}
@@ -0,0 +1,55 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHosts(t *testing.T) {
+	h := NewHosts(map[string]*Cors{
+		"brand-a.com":     New(Options{AllowedOrigins: []string{"https://www.brand-a.com"}}),
+		"*.brand-b.com":   New(Options{AllowedOrigins: []string{"https://www.brand-b.com"}}),
+		"api.brand-b.com": New(Options{AllowedOrigins: []string{"https://app.brand-b.com"}}),
+	}, nil).Handler(testHandler)
+
+	cases := []struct {
+		host, origin, allowed string
+	}{
+		{"brand-a.com", "https://www.brand-a.com", "https://www.brand-a.com"},
+		{"BRAND-A.com:8443", "https://www.brand-a.com", "https://www.brand-a.com"},
+		{"brand-a.com", "https://www.brand-b.com", ""},
+		{"cdn.brand-b.com", "https://www.brand-b.com", "https://www.brand-b.com"},
+		{"api.brand-b.com", "https://www.brand-b.com", ""},
+		{"api.brand-b.com", "https://app.brand-b.com", "https://app.brand-b.com"},
+		{"other.com", "https://www.brand-a.com", ""},
+	}
+	for _, tc := range cases {
+		req, _ := http.NewRequest("GET", "http://"+tc.host+"/foo", nil)
+		req.Header.Add("Origin", tc.origin)
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		if got := res.Header().Get("Access-Control-Allow-Origin"); got != tc.allowed {
+			t.Errorf("%s from %s: Access-Control-Allow-Origin = %q, want %q", tc.host, tc.origin, got, tc.allowed)
+		}
+		if res.Body.String() != "bar" {
+			t.Errorf("%s from %s: next handler not called", tc.host, tc.origin)
+		}
+	}
+}
+
+func TestHostsFallback(t *testing.T) {
+	h := NewHosts(map[string]*Cors{
+		"brand-a.com": New(Options{AllowedOrigins: []string{"https://www.brand-a.com"}}),
+	}, New(Options{AllowedOrigins: []string{"https://www.default.com"}}))
+
+	req, _ := http.NewRequest("GET", "http://other.com/foo", nil)
+	req.Header.Add("Origin", "https://www.default.com")
+	if d := h.Check(req); !d.Allowed {
+		t.Errorf("Check() = %+v, want allowed by the fallback", d)
+	}
+	req.Host = "brand-a.com"
+	if d := h.Check(req); d.Allowed {
+		t.Errorf("Check() = %+v, want denied", d)
+	}
+}
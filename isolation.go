@@ -0,0 +1,64 @@
+package cors
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// IsolationOptions configures the cross-origin isolation headers set by
+// IsolationHandler.
+type IsolationOptions struct {
+	// OpenerPolicy is the Cross-Origin-Opener-Policy value. Default value is
+	// "same-origin", which cross-origin isolation requires.
+	OpenerPolicy string `json:"openerPolicy,omitempty" yaml:"openerPolicy,omitempty"`
+
+	// EmbedderPolicy is the Cross-Origin-Embedder-Policy value, "require-corp" or
+	// "credentialless". Default value is "require-corp".
+	EmbedderPolicy string `json:"embedderPolicy,omitempty" yaml:"embedderPolicy,omitempty"`
+
+	// ReportTo names the Reporting API endpoint receiving the violations of both
+	// policies, if any, e.g. ReportingGroup when Options.ReportingEndpoint is set.
+	ReportTo string `json:"reportTo,omitempty" yaml:"reportTo,omitempty"`
+
+	// ReportOnly sends the Report-Only variants of the headers, to find what
+	// isolation would break before enforcing it.
+	ReportOnly bool `json:"reportOnly,omitempty" yaml:"reportOnly,omitempty"`
+}
+
+// IsolationHandler applies the policy in front of next like Handler does, and
+// sets Cross-Origin-Opener-Policy and Cross-Origin-Embedder-Policy on the
+// responses, so that documents get cross-origin isolated (e.g. to use
+// SharedArrayBuffer) from the same configuration as CORS. As isolated documents
+// can only embed resources allowing them through CORS or
+// Cross-Origin-Resource-Policy, actual responses get "same-origin" CORP, or
+// "cross-origin" when the origin is allowed, unless Options.ResourcePolicy is
+// set. Requests exempted by SkipPaths or Skip are passed to next untouched.
+func (c *Cors) IsolationHandler(next http.Handler, options IsolationOptions) http.Handler {
+	opener, embedder := options.OpenerPolicy, options.EmbedderPolicy
+	if opener == "" {
+		opener = "same-origin"
+	}
+	if embedder == "" {
+		embedder = "require-corp"
+	}
+	if options.ReportTo != "" {
+		opener += "; report-to=" + strconv.Quote(options.ReportTo)
+		embedder += "; report-to=" + strconv.Quote(options.ReportTo)
+	}
+	openerHeader, embedderHeader := "Cross-Origin-Opener-Policy", "Cross-Origin-Embedder-Policy"
+	if options.ReportOnly {
+		openerHeader += "-Report-Only"
+		embedderHeader += "-Report-Only"
+	}
+	return c.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if p := c.requestPolicy(r); !isPreflight(r) && !p.skips(r) {
+			headers := w.Header()
+			headers.Set(openerHeader, opener)
+			headers.Set(embedderHeader, embedder)
+			if p.resourcePolicy == ResourcePolicyNone {
+				setResourcePolicy(headers, ResourcePolicySameOrigin)
+			}
+		}
+		next.ServeHTTP(w, r)
+	}))
+}
@@ -0,0 +1,109 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsolationHandler(t *testing.T) {
+	cases := []struct {
+		name      string
+		options   Options
+		isolation IsolationOptions
+		method    string
+		reqHeader map[string]string
+		resHeader map[string]string
+	}{
+		{
+			"Defaults",
+			Options{AllowedOrigins: []string{"http://foo.com"}},
+			IsolationOptions{},
+			"GET",
+			nil,
+			map[string]string{
+				"Cross-Origin-Opener-Policy":   "same-origin",
+				"Cross-Origin-Embedder-Policy": "require-corp",
+				"Cross-Origin-Resource-Policy": "same-origin",
+			},
+		},
+		{
+			"AllowedOrigin",
+			Options{AllowedOrigins: []string{"http://foo.com"}},
+			IsolationOptions{EmbedderPolicy: "credentialless"},
+			"GET",
+			map[string]string{"Origin": "http://foo.com"},
+			map[string]string{
+				"Access-Control-Allow-Origin":  "http://foo.com",
+				"Cross-Origin-Opener-Policy":   "same-origin",
+				"Cross-Origin-Embedder-Policy": "credentialless",
+				"Cross-Origin-Resource-Policy": "cross-origin",
+			},
+		},
+		{
+			"ResourcePolicy",
+			Options{AllowedOrigins: []string{"http://foo.com"}, ResourcePolicy: ResourcePolicySameSite},
+			IsolationOptions{},
+			"GET",
+			map[string]string{"Origin": "http://bar.com"},
+			map[string]string{
+				"Cross-Origin-Opener-Policy":   "same-origin",
+				"Cross-Origin-Embedder-Policy": "require-corp",
+				"Cross-Origin-Resource-Policy": "same-site",
+			},
+		},
+		{
+			"ReportOnly",
+			Options{AllowedOrigins: []string{"http://foo.com"}},
+			IsolationOptions{ReportOnly: true, ReportTo: "isolation"},
+			"GET",
+			nil,
+			map[string]string{
+				"Cross-Origin-Opener-Policy-Report-Only":   `same-origin; report-to="isolation"`,
+				"Cross-Origin-Embedder-Policy-Report-Only": `require-corp; report-to="isolation"`,
+				"Cross-Origin-Resource-Policy":             "same-origin",
+			},
+		},
+		{
+			"Preflight",
+			Options{AllowedOrigins: []string{"http://foo.com"}},
+			IsolationOptions{},
+			"OPTIONS",
+			map[string]string{"Origin": "http://foo.com", "Access-Control-Request-Method": "GET"},
+			map[string]string{
+				"Access-Control-Allow-Origin":  "http://foo.com",
+				"Access-Control-Allow-Methods": "GET",
+			},
+		},
+		{
+			"Skipped",
+			Options{AllowedOrigins: []string{"http://foo.com"}, SkipPaths: []string{"/foo"}},
+			IsolationOptions{},
+			"GET",
+			nil,
+			map[string]string{},
+		},
+	}
+	names := []string{
+		"Access-Control-Allow-Origin",
+		"Access-Control-Allow-Methods",
+		"Cross-Origin-Opener-Policy",
+		"Cross-Origin-Embedder-Policy",
+		"Cross-Origin-Opener-Policy-Report-Only",
+		"Cross-Origin-Embedder-Policy-Report-Only",
+		"Cross-Origin-Resource-Policy",
+	}
+	for _, tc := range cases {
+		req, _ := http.NewRequest(tc.method, "http://example.com/foo", nil)
+		for name, value := range tc.reqHeader {
+			req.Header.Add(name, value)
+		}
+		res := httptest.NewRecorder()
+		New(tc.options).IsolationHandler(testHandler, tc.isolation).ServeHTTP(res, req)
+		for _, name := range names {
+			if got, want := res.Header().Get(name), tc.resHeader[name]; got != want {
+				t.Errorf("%s: %s = %q, want %q", tc.name, name, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package cors
+
+import "reflect"
+
+// Merge returns o with the fields set in override layered on top, so that an
+// organization-wide base policy can be shared and refined per service:
+//
+//   - a nil slice keeps the base list, while a non-nil empty slice clears it
+//     (e.g. ExposedHeaders: []string{}); lists are replaced, never concatenated
+//   - other fields (booleans, numbers, durations, strings, maps, functions,
+//     pointers and interfaces) override the base when not zero; maps are
+//     replaced as a whole
+//
+// As a consequence a boolean set in the base can't be unset by an override, nor
+// a number reset to zero. Neither o nor override are modified and the result
+// does not share its lists with them.
+func (o Options) Merge(override Options) Options {
+	merged := o
+	m := reflect.ValueOf(&merged).Elem()
+	v := reflect.ValueOf(override)
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		switch {
+		case f.Kind() == reflect.Slice && !f.IsNil():
+			m.Field(i).Set(f)
+		case f.Kind() != reflect.Slice && !f.IsZero():
+			m.Field(i).Set(f)
+		}
+		switch dst := m.Field(i); {
+		case dst.Kind() == reflect.Slice && !dst.IsNil():
+			clone := reflect.MakeSlice(dst.Type(), dst.Len(), dst.Len())
+			reflect.Copy(clone, dst)
+			dst.Set(clone)
+		case dst.Kind() == reflect.Map && !dst.IsNil():
+			clone := reflect.MakeMapWithSize(dst.Type(), dst.Len())
+			for _, k := range dst.MapKeys() {
+				clone.SetMapIndex(k, dst.MapIndex(k))
+			}
+			dst.Set(clone)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,58 @@
+package cors
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOptionsMerge(t *testing.T) {
+	base := Options{
+		AllowedOrigins:   []string{"https://*.example.com"},
+		AllowedHeaders:   []string{"Authorization"},
+		ExposedHeaders:   []string{"X-Request-Id"},
+		AllowCredentials: true,
+		MaxAge:           600,
+		Messages:         &Messages{OriginNotAllowed: "denied"},
+	}
+	allow := func(r *http.Request, origin string) bool { return true }
+	merged := base.Merge(Options{
+		AllowedMethods:     []string{"GET", "PUT"},
+		ExposedHeaders:     []string{},
+		OriginFuncCacheTTL: time.Minute,
+		AllowOriginFunc:    allow,
+		Name:               "billing",
+	})
+
+	if merged.AllowOriginFunc == nil {
+		t.Error("AllowOriginFunc not overridden")
+	}
+	merged.AllowOriginFunc = nil
+	want := Options{
+		AllowedOrigins:     []string{"https://*.example.com"},
+		AllowedMethods:     []string{"GET", "PUT"},
+		AllowedHeaders:     []string{"Authorization"},
+		ExposedHeaders:     []string{},
+		AllowCredentials:   true,
+		MaxAge:             600,
+		OriginFuncCacheTTL: time.Minute,
+		Messages:           base.Messages,
+		Name:               "billing",
+	}
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("Merge = %+v, want %+v", merged, want)
+	}
+
+	merged.AllowedOrigins[0] = "https://example.org"
+	if base.AllowedOrigins[0] != "https://*.example.com" {
+		t.Error("Merge result shares its lists with the base")
+	}
+
+	base = Options{MethodHeaderPolicy: map[string][]string{"DELETE": {"X-Admin-Token"}}}
+	merged = base.Merge(Options{})
+	merged.MethodHeaderPolicy["PUT"] = []string{"Content-Type"}
+	if len(base.MethodHeaderPolicy) != 1 {
+		t.Error("Merge result shares its maps with the base")
+	}
+}
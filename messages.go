@@ -0,0 +1,73 @@
+package cors
+
+import (
+	"errors"
+	"strings"
+)
+
+// Messages overrides the text of denials surfaced to clients through
+// Options.ErrorHandler, e.g. to localize them or strip internal details. Each
+// message may hold the {origin}, {method} and {headers} placeholders, replaced by
+// the request origin, the checked method and the denied headers. Empty messages
+// default to the text of Decision.Err.
+type Messages struct {
+	OriginNotAllowed    string `json:"originNotAllowed,omitempty" yaml:"originNotAllowed,omitempty"`
+	MalformedOrigin     string `json:"malformedOrigin,omitempty" yaml:"malformedOrigin,omitempty"`
+	MethodNotAllowed    string `json:"methodNotAllowed,omitempty" yaml:"methodNotAllowed,omitempty"`
+	HeadersNotAllowed   string `json:"headersNotAllowed,omitempty" yaml:"headersNotAllowed,omitempty"`
+	MalformedHeaders    string `json:"malformedHeaders,omitempty" yaml:"malformedHeaders,omitempty"`
+	RequestHeadersLimit string `json:"requestHeadersLimit,omitempty" yaml:"requestHeadersLimit,omitempty"`
+	HeaderBudget        string `json:"headerBudget,omitempty" yaml:"headerBudget,omitempty"`
+	Policy              string `json:"policy,omitempty" yaml:"policy,omitempty"`
+	FetchMetadata       string `json:"fetchMetadata,omitempty" yaml:"fetchMetadata,omitempty"`
+	UnsafeOrigin        string `json:"unsafeOrigin,omitempty" yaml:"unsafeOrigin,omitempty"`
+}
+
+// template returns the message for a denial reason
+func (m *Messages) template(reason string) string {
+	switch reason {
+	case ReasonOrigin:
+		return m.OriginNotAllowed
+	case ReasonMalformedOrigin:
+		return m.MalformedOrigin
+	case ReasonMethod:
+		return m.MethodNotAllowed
+	case ReasonHeaders:
+		return m.HeadersNotAllowed
+	case ReasonMalformedHeaders:
+		return m.MalformedHeaders
+	case ReasonRequestHeadersLimit:
+		return m.RequestHeadersLimit
+	case ReasonHeaderBudget:
+		return m.HeaderBudget
+	case ReasonPolicy:
+		return m.Policy
+	case ReasonFetchMetadata:
+		return m.FetchMetadata
+	case ReasonUnsafeOrigin:
+		return m.UnsafeOrigin
+	}
+	return ""
+}
+
+// message returns the text describing the denial d to the client
+func (p *policy) message(d Decision) string {
+	if p.messages != nil {
+		if msg := p.messages.template(d.Reason); msg != "" {
+			var headers []string
+			var headersErr *HeadersNotAllowedError
+			if errors.As(d.Err, &headersErr) {
+				headers = headersErr.Headers
+			}
+			return strings.NewReplacer(
+				"{origin}", d.Origin,
+				"{method}", d.Method,
+				"{headers}", strings.Join(headers, ", "),
+			).Replace(msg)
+		}
+	}
+	if d.Err != nil {
+		return d.Err.Error()
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMessages(t *testing.T) {
+	messages := &Messages{
+		OriginNotAllowed:  "Origine {origin} non autorisée",
+		HeadersNotAllowed: "En-têtes non autorisés : {headers}",
+	}
+	var got []string
+	s := New(Options{
+		AllowedOrigins: []string{"https://app.com"},
+		AllowedMethods: []string{"GET"},
+		AllowedHeaders: []string{"X-Header-1"},
+		Messages:       messages,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, status int, d Decision) {
+			got = append(got, d.Message)
+		},
+	})
+	cases := []struct {
+		origin, method, headers string
+	}{
+		{"https://evil.com", "GET", ""},
+		{"https://app.com", "GET", "x-header-2,x-header-3"},
+		{"https://app.com", "PUT", ""},
+	}
+	for _, tc := range cases {
+		req, _ := http.NewRequest("OPTIONS", "http://api.com/foo", nil)
+		req.Header.Add("Origin", tc.origin)
+		req.Header.Add("Access-Control-Request-Method", tc.method)
+		if tc.headers != "" {
+			req.Header.Add("Access-Control-Request-Headers", tc.headers)
+		}
+		s.Handler(testHandler).ServeHTTP(httptest.NewRecorder(), req)
+	}
+	want := []string{
+		"Origine https://evil.com non autorisée",
+		"En-têtes non autorisés : X-Header-2, X-Header-3",
+		"method 'PUT' not allowed",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got messages %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("message %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestMessagesDefaultErrorResponse(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"https://app.com"},
+		DenyWithStatus: http.StatusForbidden,
+		Messages:       &Messages{OriginNotAllowed: "Cross-origin access denied"},
+	})
+	req, _ := http.NewRequest("GET", "http://api.com/foo", nil)
+	req.Header.Add("Origin", "https://evil.com")
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	assertResponse(t, res, http.StatusForbidden)
+	if body := res.Body.String(); body != "Cross-origin access denied\n" {
+		t.Errorf("body = %q", body)
+	}
+}
@@ -0,0 +1,327 @@
+package cors
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/cors/engine"
+)
+
+// MigrationNote describes a change made, or left to review, by
+// MigrateLegacyOptions
+type MigrationNote struct {
+	// Field is the option concerned
+	Field string
+
+	// Message explains the change
+	Message string
+
+	// Semantic is set when the migrated options don't behave like the legacy
+	// ones did with earlier versions, so that the change deserves a review
+	Semantic bool
+}
+
+func (n MigrationNote) String() string {
+	if n.Semantic {
+		return n.Field + ": " + n.Message + " (behavior change)"
+	}
+	return n.Field + ": " + n.Message
+}
+
+// MigratedOptions are legacy options converted by MigrateLegacyOptions to the
+// functional options of NewStrict
+type MigratedOptions struct {
+	options Options
+}
+
+// fieldOptions are the functional options setting a single field of Options,
+// in the order they are applied
+var fieldOptions = []struct {
+	field  string
+	name   string
+	option func(o Options) Option
+}{
+	{"AllowedOrigins", "WithAllowedOrigins", func(o Options) Option { return WithAllowedOrigins(o.AllowedOrigins...) }},
+	{"AllowedOriginsRegex", "WithAllowedOriginsRegex", func(o Options) Option {
+		return WithAllowedOriginsRegex(o.AllowedOriginsRegex...)
+	}},
+	{"DeniedOrigins", "WithDeniedOrigins", func(o Options) Option { return WithDeniedOrigins(o.DeniedOrigins...) }},
+	{"AllowOriginFunc", "WithAllowOriginFunc", func(o Options) Option { return WithAllowOriginFunc(o.AllowOriginFunc) }},
+	{"AllowLocalhost", "WithLocalhost", func(Options) Option { return WithLocalhost() }},
+	{"AllowNullOrigin", "WithNullOrigin", func(Options) Option { return WithNullOrigin() }},
+	{"AllowedMethods", "WithAllowedMethods", func(o Options) Option { return WithAllowedMethods(o.AllowedMethods...) }},
+	{"AllowedHeaders", "WithAllowedHeaders", func(o Options) Option { return WithAllowedHeaders(o.AllowedHeaders...) }},
+	{"ExposedHeaders", "WithExposedHeaders", func(o Options) Option { return WithExposedHeaders(o.ExposedHeaders...) }},
+	{"AllowCredentials", "WithCredentials", func(Options) Option { return WithCredentials() }},
+	{"MaxAgeDuration", "WithMaxAge", func(o Options) Option { return WithMaxAge(o.MaxAgeDuration) }},
+	{"AllowPrivateNetwork", "WithPrivateNetwork", func(Options) Option { return WithPrivateNetwork() }},
+	{"OptionsPassthrough", "WithOptionsPassthrough", func(Options) Option { return WithOptionsPassthrough() }},
+	{"Logger", "WithLogger", func(o Options) Option { return WithLogger(o.Logger) }},
+	{"Debug", "WithDebug", func(Options) Option { return WithDebug() }},
+}
+
+// split returns the options without a functional option of their own, and the
+// fieldOptions indexes of the set fields
+func (m MigratedOptions) split() (rest Options, fields []int) {
+	rest = m.options
+	v := reflect.ValueOf(&rest).Elem()
+	for i, fo := range fieldOptions {
+		f := v.FieldByName(fo.field)
+		if f.IsZero() {
+			continue
+		}
+		fields = append(fields, i)
+		f.Set(reflect.Zero(f.Type()))
+	}
+	return rest, fields
+}
+
+// Options returns the functional options configuring the migrated policy, to
+// pass to NewStrict. Options without a functional option of their own are set
+// first with WithOptions.
+func (m MigratedOptions) Options() []Option {
+	rest, fields := m.split()
+	var opts []Option
+	if !reflect.ValueOf(rest).IsZero() {
+		opts = append(opts, WithOptions(rest))
+	}
+	for _, i := range fields {
+		opts = append(opts, fieldOptions[i].option(m.options))
+	}
+	return opts
+}
+
+// Source writes the migrated options as the Go source of a file of package pkg
+// declaring a variable name holding the functional options, for go:generate
+// tools. Functions and interfaces can't be written out: they are left as
+// comments to set in code.
+func (m MigratedOptions) Source(pkg, name string) ([]byte, error) {
+	rest, fields := m.split()
+	imports := map[string]bool{}
+	var lit bytes.Buffer
+	lit.WriteString("{\n")
+	if !reflect.ValueOf(rest).IsZero() {
+		lit.WriteString("cors.WithOptions(cors.Options")
+		writeOptionsLiteral(&lit, reflect.ValueOf(rest), imports)
+		lit.WriteString("),\n")
+	}
+	v := reflect.ValueOf(m.options)
+	for _, i := range fields {
+		fo := fieldOptions[i]
+		f := v.FieldByName(fo.field)
+		switch f.Kind() {
+		case reflect.Func, reflect.Interface:
+			fmt.Fprintf(&lit, "// cors.%s: set in code\n", fo.name)
+		case reflect.Bool:
+			fmt.Fprintf(&lit, "cors.%s(),\n", fo.name)
+		case reflect.Slice:
+			args := goValue(f, imports)
+			fmt.Fprintf(&lit, "cors.%s(%s),\n", fo.name, args[len("[]string{"):len(args)-1])
+		default:
+			fmt.Fprintf(&lit, "cors.%s(%s),\n", fo.name, goValue(f, imports))
+		}
+	}
+	lit.WriteString("}")
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by cors.MigratedOptions. DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	if imports["time"] {
+		src.WriteString("\t\"time\"\n\n")
+	}
+	fmt.Fprintf(&src, "\t\"github.com/go-chi/cors\"\n)\n\nvar %s = []cors.Option%s\n", name, lit.String())
+	return format.Source(src.Bytes())
+}
+
+// MigrateLegacyOptions converts options written for earlier versions to the
+// functional options of NewStrict, e.g. MaxAge in seconds to WithMaxAge and a
+// duration, keeping their behavior where possible, and reports the changes
+// applying to them, so that large codebases can upgrade mechanically. See
+// MigratedOptions.Source to write the converted options back as Go code.
+func MigrateLegacyOptions(old Options) (MigratedOptions, []MigrationNote) {
+	o := old
+	var notes []MigrationNote
+	note := func(field, message string, semantic bool) {
+		notes = append(notes, MigrationNote{Field: field, Message: message, Semantic: semantic})
+	}
+
+	switch {
+	case o.MaxAge > 0 && o.MaxAgeDuration == 0:
+		o.MaxAgeDuration = time.Duration(o.MaxAge) * time.Second
+		o.MaxAge = 0
+		note("MaxAge", "converted to WithMaxAge("+o.MaxAgeDuration.String()+")", false)
+	case o.MaxAge < 0 && o.MaxAgeDuration == 0:
+		// Earlier versions omitted the header, negative values now disable caching
+		o.MaxAge = 0
+		note("MaxAge", "negative value dropped as it omitted Access-Control-Max-Age, "+
+			"use WithMaxAge with a negative duration to disable preflight caching", false)
+	case o.MaxAge != 0:
+		o.MaxAge = 0
+		note("MaxAge", "dropped in favor of MaxAgeDuration", false)
+	}
+
+	anyOrigin := len(o.AllowedOrigins) == 0 && len(o.AllowedOriginsRegex) == 0 && o.AllowOriginFunc == nil &&
+		o.AllowOriginVaryFunc == nil && o.OriginProvider == nil && o.PolicyResolver == nil
+	for _, origin := range o.AllowedOrigins {
+		anyOrigin = anyOrigin || origin == "*"
+	}
+	if anyOrigin && o.AllowCredentials {
+		// Browsers reject credentials along with "*", which NewStrict now refuses
+		o.AllowCredentials = false
+		note("AllowCredentials", `dropped as browsers never honored it with allowed origin "*", `+
+			"list the allowed origins to allow credentials", false)
+	}
+	if o.AllowCredentials && !o.AllowInsecureCredentials {
+		for _, origin := range o.AllowedOrigins {
+			if strings.Contains(origin, "://") && !engine.IsSecureOrigin(origin) {
+				note("AllowCredentials", "no longer granted to plain http:// origins like "+origin+
+					", set AllowInsecureCredentials to keep granting them", true)
+				break
+			}
+		}
+	}
+	if o.Debug {
+		note("Debug", "denials are now also explained in an X-Cors-Debug response header, "+
+			"set Cors.Log instead to keep logs only", true)
+	}
+	if (o.AllowOriginFunc != nil || o.AllowOriginVaryFunc != nil) && o.PolicyResolver == nil {
+		note("AllowOriginFunc", "only decides origins, see PolicyResolver for methods, "+
+			"headers or credentials depending on the request", false)
+	}
+	return MigratedOptions{options: o}, notes
+}
+
+// OptionsSource writes o as the Go source of a file of package pkg declaring a
+// variable name holding them, for go:generate tools persisting loaded
+// configurations. Functions and interfaces can't be written out: they
+// are left as comments to set in code.
+func OptionsSource(pkg, name string, o Options) ([]byte, error) {
+	var lit bytes.Buffer
+	imports := map[string]bool{}
+	writeOptionsLiteral(&lit, reflect.ValueOf(o), imports)
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by cors.OptionsSource. DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	if imports["time"] {
+		src.WriteString("\t\"time\"\n\n")
+	}
+	fmt.Fprintf(&src, "\t\"github.com/go-chi/cors\"\n)\n\nvar %s = cors.Options%s\n", name, lit.String())
+	return format.Source(src.Bytes())
+}
+
+// Names of the constants of the enumerated option types, by value
+var optionConstants = map[reflect.Type][]string{
+	reflect.TypeOf(OriginMatchMode(0)):            {"MatchMostSpecific", "MatchFirst"},
+	reflect.TypeOf(AllowedHeadersResponseMode(0)): {"AllowedHeadersEcho", "AllowedHeadersStatic"},
+	reflect.TypeOf(ResourcePolicy(0)): {"ResourcePolicyNone", "ResourcePolicySameOrigin",
+		"ResourcePolicySameSite", "ResourcePolicyCrossOrigin"},
+}
+
+// writeOptionsLiteral writes the composite literal of the struct v, without its
+// type, skipping zero fields
+func writeOptionsLiteral(b *bytes.Buffer, v reflect.Value, imports map[string]bool) {
+	b.WriteString("{\n")
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := v.Field(i)
+		if f.IsZero() {
+			continue
+		}
+		name := t.Field(i).Name
+		switch f.Kind() {
+		case reflect.Func, reflect.Interface:
+			fmt.Fprintf(b, "// %s: set in code\n", name)
+			continue
+		case reflect.Ptr:
+			switch f.Interface().(type) {
+			case *Options:
+				b.WriteString(name + ": &cors.Options")
+			case *Messages:
+				b.WriteString(name + ": &cors.Messages")
+			default:
+				fmt.Fprintf(b, "// %s: set in code\n", name)
+				continue
+			}
+			writeOptionsLiteral(b, f.Elem(), imports)
+			b.WriteString(",\n")
+			continue
+		}
+		fmt.Fprintf(b, "%s: %s,\n", name, goValue(f, imports))
+	}
+	b.WriteString("}")
+}
+
+// goValue returns the Go expression of v, a field of Options that is not a
+// function, interface or pointer
+func goValue(v reflect.Value, imports map[string]bool) string {
+	if names, ok := optionConstants[v.Type()]; ok {
+		if i := int(v.Int()); i >= 0 && i < len(names) {
+			return "cors." + names[i]
+		}
+		return fmt.Sprintf("cors.%s(%d)", v.Type().Name(), v.Int())
+	}
+	switch x := v.Interface().(type) {
+	case time.Duration:
+		imports["time"] = true
+		return goDuration(x)
+	case []byte:
+		return fmt.Sprintf("[]byte(%q)", x)
+	case []string:
+		quoted := make([]string, len(x))
+		for i, s := range x {
+			quoted[i] = strconv.Quote(s)
+		}
+		return "[]string{" + strings.Join(quoted, ", ") + "}"
+	case map[string][]string:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		entries := make([]string, len(keys))
+		for i, k := range keys {
+			entries[i] = strconv.Quote(k) + ": " + goValue(reflect.ValueOf(x[k]), imports)
+		}
+		return "map[string][]string{" + strings.Join(entries, ", ") + "}"
+	case map[string]float64:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		entries := make([]string, len(keys))
+		for i, k := range keys {
+			entries[i] = strconv.Quote(k) + ": " + strconv.FormatFloat(x[k], 'g', -1, 64)
+		}
+		return "map[string]float64{" + strings.Join(entries, ", ") + "}"
+	case string:
+		return strconv.Quote(x)
+	case float64:
+		return strconv.FormatFloat(x, 'g', -1, 64)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// goDuration returns the Go expression of d in the largest whole unit
+func goDuration(d time.Duration) string {
+	units := []struct {
+		d    time.Duration
+		name string
+	}{
+		{time.Hour, "time.Hour"},
+		{time.Minute, "time.Minute"},
+		{time.Second, "time.Second"},
+		{time.Millisecond, "time.Millisecond"},
+	}
+	for _, u := range units {
+		if d%u.d == 0 {
+			return fmt.Sprintf("%d * %s", d/u.d, u.name)
+		}
+	}
+	return fmt.Sprintf("time.Duration(%d)", int64(d))
+}
@@ -0,0 +1,174 @@
+package cors
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMigrateLegacyOptions(t *testing.T) {
+	m, notes := MigrateLegacyOptions(Options{
+		AllowedOrigins:   []string{"*"},
+		AllowCredentials: true,
+		MaxAge:           600,
+		Debug:            true,
+	})
+	want := Options{
+		AllowedOrigins: []string{"*"},
+		MaxAgeDuration: 10 * time.Minute,
+		Debug:          true,
+	}
+	var o Options
+	for _, opt := range m.Options() {
+		opt(&o)
+	}
+	if d := want.Diff(o); len(d) > 0 {
+		t.Errorf("migrated options differ: %q", d)
+	}
+	if _, err := NewStrict(m.Options()...); err != nil {
+		t.Errorf("migrated options are invalid: %v", err)
+	}
+	var fields []string
+	semantic := map[string]bool{}
+	for _, n := range notes {
+		fields = append(fields, n.Field)
+		semantic[n.Field] = n.Semantic
+	}
+	if want := []string{"MaxAge", "AllowCredentials", "Debug"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("notes on %q, want %q", fields, want)
+	}
+	if semantic["MaxAge"] || semantic["AllowCredentials"] || !semantic["Debug"] {
+		t.Errorf("unexpected behavior changes: %v", notes)
+	}
+
+	// Negative MaxAge omitted the header
+	m, _ = MigrateLegacyOptions(Options{MaxAge: -1})
+	if m.options.MaxAge != 0 || m.options.MaxAgeDuration != 0 || len(m.Options()) != 0 {
+		t.Errorf("negative MaxAge migrated to %+v", m.options)
+	}
+
+	// Options without changes to report
+	m, notes = MigrateLegacyOptions(Options{
+		AllowedOrigins:   []string{"https://foo.com"},
+		AllowedMethods:   []string{"GET", "PUT"},
+		AllowCredentials: true,
+	})
+	if len(notes) != 0 {
+		t.Errorf("notes = %v", notes)
+	}
+	if len(m.Options()) != 3 {
+		t.Errorf("%d options, want 3", len(m.Options()))
+	}
+
+	// Credentials granted to "*" by default
+	_, notes = MigrateLegacyOptions(Options{AllowCredentials: true})
+	if len(notes) != 1 || notes[0].Field != "AllowCredentials" || notes[0].Semantic {
+		t.Errorf("notes = %v", notes)
+	}
+
+	_, notes = MigrateLegacyOptions(Options{
+		AllowedOrigins:   []string{"https://foo.com", "http://bar.com"},
+		AllowCredentials: true,
+	})
+	if len(notes) != 1 || notes[0].Field != "AllowCredentials" || !notes[0].Semantic {
+		t.Errorf("notes = %v", notes)
+	}
+
+	_, notes = MigrateLegacyOptions(Options{
+		AllowOriginFunc: func(r *http.Request, origin string) bool { return true },
+	})
+	if len(notes) != 1 || notes[0].Field != "AllowOriginFunc" || notes[0].Semantic {
+		t.Errorf("notes = %v", notes)
+	}
+}
+
+func TestMigratedOptionsSource(t *testing.T) {
+	m, _ := MigrateLegacyOptions(Options{
+		AllowedOrigins:   []string{"https://foo.com", "https://*.bar.com"},
+		AllowOriginFunc:  func(r *http.Request, origin string) bool { return true },
+		AllowedMethods:   []string{"GET"},
+		AllowCredentials: true,
+		MaxAge:           90,
+		ResourcePolicy:   ResourcePolicySameSite,
+	})
+	src, err := m.Source("config", "corsOptions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `// Code generated by cors.MigratedOptions. DO NOT EDIT.
+
+package config
+
+import (
+	"time"
+
+	"github.com/go-chi/cors"
+)
+
+var corsOptions = []cors.Option{
+	cors.WithOptions(cors.Options{
+		ResourcePolicy: cors.ResourcePolicySameSite,
+	}),
+	cors.WithAllowedOrigins("https://foo.com", "https://*.bar.com"),
+	// cors.WithAllowOriginFunc: set in code
+	cors.WithAllowedMethods("GET"),
+	cors.WithCredentials(),
+	cors.WithMaxAge(90 * time.Second),
+}
+`
+	if string(src) != want {
+		t.Errorf("source:\n%s\nwant:\n%s", src, want)
+	}
+}
+
+func TestOptionsSource(t *testing.T) {
+	src, err := OptionsSource("config", "corsOptions", Options{
+		AllowedOrigins:     []string{"https://foo.com", "https://*.bar.com"},
+		AllowCredentials:   true,
+		MaxAgeDuration:     90 * time.Second,
+		ResourcePolicy:     ResourcePolicySameSite,
+		MethodHeaderPolicy: map[string][]string{"PUT": {"X-Put"}, "DELETE": nil},
+		SampleReasons:      map[string]float64{ReasonOrigin: 0.01},
+		Messages:           &Messages{OriginNotAllowed: "nope"},
+		AllowOriginFunc:    func(r *http.Request, origin string) bool { return true },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `// Code generated by cors.OptionsSource. DO NOT EDIT.
+
+package config
+
+import (
+	"time"
+
+	"github.com/go-chi/cors"
+)
+
+var corsOptions = cors.Options{
+	AllowedOrigins: []string{"https://foo.com", "https://*.bar.com"},
+	// AllowOriginFunc: set in code
+	MethodHeaderPolicy: map[string][]string{"DELETE": []string{}, "PUT": []string{"X-Put"}},
+	ResourcePolicy:     cors.ResourcePolicySameSite,
+	AllowCredentials:   true,
+	MaxAgeDuration:     90 * time.Second,
+	Messages: &cors.Messages{
+		OriginNotAllowed: "nope",
+	},
+	SampleReasons: map[string]float64{"origin": 0.01},
+}
+`
+	if string(src) != want {
+		t.Errorf("source:\n%s\nwant:\n%s", src, want)
+	}
+
+	src, err = OptionsSource("config", "corsOptions", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(src), `"time"`) {
+		t.Errorf("unused time import:\n%s", src)
+	}
+}
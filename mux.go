@@ -0,0 +1,72 @@
+package cors
+
+import (
+	"net/http"
+	"sort"
+)
+
+// Methods probed on routers in addition to the allowed ones with AllowRoutedMethods
+var muxMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// muxProbeMethod is a method no route handles, used to tell whether a router
+// routes on methods at all
+const muxProbeMethod = "CORSPROBE"
+
+// MuxHandler applies the policy in front of mux, allowing the methods its
+// patterns route for the requested path (Go 1.22 method patterns such as
+// "PUT /items/{id}"), see RouteHandler. Patterns without a method, and muxes
+// ignoring methods (before Go 1.22 or with GODEBUG=httpmuxgo121=1), leave the
+// path to AllowedMethods.
+func (c *Cors) MuxHandler(mux *http.ServeMux) http.Handler {
+	match := func(r *http.Request, method string) string {
+		probe := r.Clone(r.Context())
+		probe.Method = method
+		_, pattern := mux.Handler(probe)
+		return pattern
+	}
+	return c.RouteHandler(mux, func(r *http.Request, method string) bool {
+		pattern := match(r, method)
+		// A pattern matching any method tells nothing about the routed ones
+		return pattern != "" && match(r, muxProbeMethod) != pattern
+	})
+}
+
+// RouteHandler applies the policy in front of next, restricting the allowed
+// methods to the ones routed reports next routes for the path of the request:
+// preflights are answered with these methods, and actual requests checked
+// against them. Routes are probed for AllowedMethods, the ones of the options
+// returned by PolicyResolver if any, and the standard methods
+// (GET, HEAD, POST, PUT, PATCH, DELETE) as well when AllowRoutedMethods is set,
+// in which case routed methods are allowed without being listed in
+// AllowedMethods. Requests for paths no route handles are checked against
+// AllowedMethods. Preflights for a method the path doesn't route are denied like
+// any other method, with a 405 status if PreflightMethodNotAllowed is set.
+func (c *Cors) RouteHandler(next http.Handler, routed func(r *http.Request, method string) bool) http.Handler {
+	return c.handler(next, routed)
+}
+
+// routedMethods returns the sorted methods allowed and routed for the path of r.
+// Only allowed methods, and the standard ones with AllowRoutedMethods, are
+// probed so that routes matching any method don't let arbitrary ones through.
+func routedMethods(r *http.Request, p *policy, routed func(r *http.Request, method string) bool) []string {
+	candidates := p.allowedMethods
+	if p.allowRoutedMethods {
+		candidates = append(append([]string{}, muxMethods...), candidates...)
+	}
+	var methods []string
+	for _, method := range candidates {
+		if method == http.MethodOptions || containsString(methods, method) || !routed(r, method) {
+			continue
+		}
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
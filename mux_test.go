@@ -0,0 +1,157 @@
+//go:build go1.22
+// +build go1.22
+
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+// muxRoutesMethods reports whether http.ServeMux supports method patterns,
+// which GODEBUG=httpmuxgo121=1 (the default for modules before go 1.22) disables
+func muxRoutesMethods() bool {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /probe", func(w http.ResponseWriter, r *http.Request) {})
+	req, _ := http.NewRequest("GET", "http://example.com/probe", nil)
+	_, pattern := mux.Handler(req)
+	return pattern != ""
+}
+
+// withMuxMethods reports whether the test can run in this process, with a mux
+// supporting method patterns or not as wanted, and runs it in a child process
+// with the matching GODEBUG setting otherwise.
+func withMuxMethods(t *testing.T, methods bool) bool {
+	if muxRoutesMethods() == methods {
+		return true
+	}
+	if os.Getenv("CORS_TEST_MUX") != "" {
+		t.Fatalf("GODEBUG didn't switch the mux to method patterns = %v", methods)
+	}
+	setting := "httpmuxgo121=1"
+	if methods {
+		setting = "httpmuxgo121=0"
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^"+t.Name()+"$")
+	cmd.Env = append(os.Environ(), "GODEBUG="+setting, "CORS_TEST_MUX=1")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("%s: %v\n%s", setting, err, out)
+	}
+	return false
+}
+
+func TestMuxHandler(t *testing.T) {
+	if !withMuxMethods(t, true) {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("PUT /items/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("PURGE /cache", func(w http.ResponseWriter, r *http.Request) {})
+	h := New(Options{
+		AllowedOrigins:            []string{"http://foo.com"},
+		AllowedMethods:            []string{"GET", "HEAD", "POST", "PUT", "PURGE"},
+		PreflightMethodNotAllowed: true,
+	}).MuxHandler(mux)
+
+	preflight := func(path, method string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("OPTIONS", "http://example.com"+path, nil)
+		req.Header.Add("Origin", "http://foo.com")
+		req.Header.Add("Access-Control-Request-Method", method)
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		return res
+	}
+
+	res := preflight("/items/1", "PUT")
+	assertResponse(t, res, http.StatusOK)
+	assertHeaders(t, res.Header(), map[string]string{
+		"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+		"Access-Control-Allow-Origin":  "http://foo.com",
+		"Access-Control-Allow-Methods": "GET, HEAD, PUT",
+	})
+
+	res = preflight("/cache", "PURGE")
+	assertResponse(t, res, http.StatusOK)
+	assertHeaders(t, res.Header(), map[string]string{
+		"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+		"Access-Control-Allow-Origin":  "http://foo.com",
+		"Access-Control-Allow-Methods": "PURGE",
+	})
+
+	res = preflight("/items/1", "POST")
+	assertResponse(t, res, http.StatusMethodNotAllowed)
+	if allow := res.Header().Get("Allow"); allow != "GET, HEAD, PUT" {
+		t.Errorf("Allow = %q, want %q", allow, "GET, HEAD, PUT")
+	}
+	if got := res.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want none", got)
+	}
+
+	// Unrouted paths fall back to AllowedMethods
+	res = preflight("/unknown", "POST")
+	assertHeaders(t, res.Header(), map[string]string{
+		"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+		"Access-Control-Allow-Origin":  "http://foo.com",
+		"Access-Control-Allow-Methods": "POST",
+	})
+
+	actual := func(method string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "http://example.com/items/1", nil)
+		req.Header.Add("Origin", "http://foo.com")
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		return res
+	}
+	res = actual("PUT")
+	assertResponse(t, res, http.StatusOK)
+	if got := res.Header().Get("Access-Control-Allow-Origin"); got != "http://foo.com" {
+		t.Errorf("routed method: Access-Control-Allow-Origin = %q", got)
+	}
+	// mux answers 405 itself to methods it doesn't route
+	res = actual("DELETE")
+	assertResponse(t, res, http.StatusMethodNotAllowed)
+	if got := res.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("unrouted method: Access-Control-Allow-Origin = %q", got)
+	}
+}
+
+func TestMuxHandlerMethodBlind(t *testing.T) {
+	if !withMuxMethods(t, false) {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/items/", func(w http.ResponseWriter, r *http.Request) {})
+	h := New(Options{
+		AllowedOrigins:     []string{"http://foo.com"},
+		AllowedMethods:     []string{"GET", "POST"},
+		AllowRoutedMethods: true,
+	}).MuxHandler(mux)
+
+	preflight := func(method string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("OPTIONS", "http://example.com/items/1", nil)
+		req.Header.Add("Origin", "http://foo.com")
+		req.Header.Add("Access-Control-Request-Method", method)
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		return res
+	}
+
+	// Patterns matching any method don't allow every probed method
+	res := preflight("PUT")
+	assertResponse(t, res, http.StatusOK)
+	assertHeaders(t, res.Header(), map[string]string{
+		"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+	})
+
+	res = preflight("POST")
+	assertResponse(t, res, http.StatusOK)
+	assertHeaders(t, res.Header(), map[string]string{
+		"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+		"Access-Control-Allow-Origin":  "http://foo.com",
+		"Access-Control-Allow-Methods": "POST",
+	})
+}
@@ -0,0 +1,144 @@
+package cors
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Option sets a part of the configuration built by NewStrict
+type Option func(*Options)
+
+// WithOptions starts from options, the following Options layering on top of them
+func WithOptions(options Options) Option {
+	return func(o *Options) {
+		*o = options
+	}
+}
+
+// WithAllowedOrigins adds origin patterns to AllowedOrigins
+func WithAllowedOrigins(origins ...string) Option {
+	return func(o *Options) {
+		o.AllowedOrigins = append(o.AllowedOrigins, origins...)
+	}
+}
+
+// WithAllowedOriginsRegex adds regular expressions to AllowedOriginsRegex
+func WithAllowedOriginsRegex(expressions ...string) Option {
+	return func(o *Options) {
+		o.AllowedOriginsRegex = append(o.AllowedOriginsRegex, expressions...)
+	}
+}
+
+// WithDeniedOrigins adds origin patterns to DeniedOrigins
+func WithDeniedOrigins(origins ...string) Option {
+	return func(o *Options) {
+		o.DeniedOrigins = append(o.DeniedOrigins, origins...)
+	}
+}
+
+// WithAllowOriginFunc sets AllowOriginFunc
+func WithAllowOriginFunc(f func(r *http.Request, origin string) bool) Option {
+	return func(o *Options) {
+		o.AllowOriginFunc = f
+	}
+}
+
+// WithLocalhost sets AllowLocalhost
+func WithLocalhost() Option {
+	return func(o *Options) {
+		o.AllowLocalhost = true
+	}
+}
+
+// WithNullOrigin sets AllowNullOrigin
+func WithNullOrigin() Option {
+	return func(o *Options) {
+		o.AllowNullOrigin = true
+	}
+}
+
+// WithAllowedMethods adds methods to AllowedMethods
+func WithAllowedMethods(methods ...string) Option {
+	return func(o *Options) {
+		o.AllowedMethods = append(o.AllowedMethods, methods...)
+	}
+}
+
+// WithAllowedHeaders adds headers to AllowedHeaders
+func WithAllowedHeaders(headers ...string) Option {
+	return func(o *Options) {
+		o.AllowedHeaders = append(o.AllowedHeaders, headers...)
+	}
+}
+
+// WithExposedHeaders adds headers to ExposedHeaders
+func WithExposedHeaders(headers ...string) Option {
+	return func(o *Options) {
+		o.ExposedHeaders = append(o.ExposedHeaders, headers...)
+	}
+}
+
+// WithCredentials sets AllowCredentials
+func WithCredentials() Option {
+	return func(o *Options) {
+		o.AllowCredentials = true
+	}
+}
+
+// WithMaxAge sets MaxAgeDuration, how long preflight results can be cached
+func WithMaxAge(d time.Duration) Option {
+	return func(o *Options) {
+		o.MaxAgeDuration = d
+	}
+}
+
+// WithPrivateNetwork sets AllowPrivateNetwork
+func WithPrivateNetwork() Option {
+	return func(o *Options) {
+		o.AllowPrivateNetwork = true
+	}
+}
+
+// WithOptionsPassthrough sets OptionsPassthrough
+func WithOptionsPassthrough() Option {
+	return func(o *Options) {
+		o.OptionsPassthrough = true
+	}
+}
+
+// WithLogger sets Logger
+func WithLogger(logger LevelLogger) Option {
+	return func(o *Options) {
+		o.Logger = logger
+	}
+}
+
+// WithDebug sets Debug
+func WithDebug() Option {
+	return func(o *Options) {
+		o.Debug = true
+	}
+}
+
+// NewStrict creates a Cors handler configured by opts, as an alternative to New
+// and its Options struct. Options are validated as they are applied (see
+// Options.Validate), so the error points at the first one making the
+// configuration invalid, e.g. WithCredentials after WithAllowedOrigins("*").
+// Unlike New, credentials also require the allowed origins to be configured
+// rather than left to their "*" default.
+func NewStrict(opts ...Option) (*Cors, error) {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+		if err := options.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if options.AllowCredentials && len(options.AllowedOrigins) == 0 && len(options.AllowedOriginsRegex) == 0 &&
+		options.AllowOriginFunc == nil && options.AllowOriginVaryFunc == nil && options.OriginProvider == nil &&
+		options.PolicyResolver == nil {
+		return nil, errors.New("cors: credentials require allowed origins, see WithAllowedOrigins")
+	}
+	return New(options), nil
+}
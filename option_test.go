@@ -0,0 +1,50 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewStrict(t *testing.T) {
+	s, err := NewStrict(
+		WithAllowedOrigins("https://foo.com"),
+		WithAllowedOrigins("https://*.bar.com"),
+		WithAllowedMethods("GET", "PUT"),
+		WithAllowedHeaders("Authorization"),
+		WithCredentials(),
+		WithMaxAge(5*time.Minute),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "https://app.bar.com")
+	req.Header.Add("Access-Control-Request-Method", "PUT")
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	assertHeaders(t, res.Header(), map[string]string{
+		"Vary":                             "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+		"Access-Control-Allow-Origin":      "https://app.bar.com",
+		"Access-Control-Allow-Methods":     "PUT",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Max-Age":           "300",
+	})
+}
+
+func TestNewStrictError(t *testing.T) {
+	cases := map[string][]Option{
+		"credentials with *":          {WithAllowedOrigins("*"), WithCredentials()},
+		"* with credentials":          {WithCredentials(), WithAllowedOrigins("*")},
+		"credentials without origins": {WithCredentials()},
+		"origin with path":            {WithAllowedOrigins("https://foo.com/")},
+		"invalid method":              {WithAllowedMethods("GET PUT")},
+		"invalid regex":               {WithAllowedOriginsRegex("(")},
+	}
+	for name, opts := range cases {
+		if _, err := NewStrict(opts...); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package cors
+
+// OriginMatchMode defines which pattern wins when several configured origin
+// patterns match the same request origin.
+type OriginMatchMode int
+
+// Values equal those of engine.MatchMode, which does the matching
+const (
+	// MatchMostSpecific picks the most specific matching pattern: exact origins
+	// first, then wildcards with the longest literal part, then regular expressions.
+	MatchMostSpecific OriginMatchMode = iota
+
+	// MatchFirst picks the first matching pattern in configuration order,
+	// AllowedOrigins being considered before AllowedOriginsRegex. Unlike
+	// MatchMostSpecific, which looks exact origins and wildcards up in indexes,
+	// patterns are tried one after the other.
+	MatchFirst
+)
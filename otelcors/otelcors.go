@@ -0,0 +1,64 @@
+// Package otelcors records CORS decisions on OpenTelemetry spans.
+//
+// It lives in its own module so that github.com/go-chi/cors doesn't depend on
+// OpenTelemetry.
+package otelcors
+
+import (
+	"net/http"
+
+	"github.com/go-chi/cors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attribute keys set on spans
+const (
+	AllowedKey      = attribute.Key("cors.allowed")
+	OriginKey       = attribute.Key("cors.origin")
+	PreflightKey    = attribute.Key("cors.preflight")
+	DenialReasonKey = attribute.Key("cors.denial_reason")
+)
+
+// Handler creates a CORS middleware like c.Handler which also records the decision
+// it took on the span found in the request context, once it returned. The span
+// has to be started by an outer middleware, otelhttp for instance. Requests
+// without Origin header are not CORS requests and are left untouched, as are the
+// ones c skips.
+func Handler(c *cors.Cors) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		h := c.Handler(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			span := trace.SpanFromContext(r.Context())
+			if !span.IsRecording() {
+				h.ServeHTTP(w, r)
+				return
+			}
+			// The decision the handler took is recorded, the policy being
+			// evaluated once
+			ctx := cors.WithDecision(r.Context())
+			h.ServeHTTP(w, r.WithContext(ctx))
+			if d, ok := cors.FromContext(ctx); ok && d.Origin != "" {
+				Record(span, d)
+			}
+		})
+	}
+}
+
+// Record sets the attributes describing d on span and adds a "cors.decision"
+// event, carrying the error of denied decisions.
+func Record(span trace.Span, d cors.Decision) {
+	attrs := []attribute.KeyValue{
+		AllowedKey.Bool(d.Allowed),
+		OriginKey.String(d.Origin),
+		PreflightKey.Bool(d.Preflight),
+	}
+	if !d.Allowed && d.Reason != "" {
+		attrs = append(attrs, DenialReasonKey.String(d.Reason))
+	}
+	span.SetAttributes(attrs...)
+	if d.Err != nil {
+		attrs = append(attrs, attribute.String("error", d.Err.Error()))
+	}
+	span.AddEvent("cors.decision", trace.WithAttributes(attrs...))
+}
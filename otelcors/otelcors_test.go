@@ -0,0 +1,96 @@
+package otelcors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/cors"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestHandler(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)).Tracer("test")
+
+	c := cors.New(cors.Options{AllowedOrigins: []string{"http://foo.com"}})
+	h := Handler(c)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, origin := range []string{"http://foo.com", "http://bar.com"} {
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.Header.Set("Origin", origin)
+		ctx, span := tracer.Start(req.Context(), "request")
+		h.ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+		span.End()
+	}
+
+	spans := sr.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	cases := []map[attribute.Key]attribute.Value{
+		{AllowedKey: attribute.BoolValue(true), OriginKey: attribute.StringValue("http://foo.com")},
+		{AllowedKey: attribute.BoolValue(false), OriginKey: attribute.StringValue("http://bar.com"), DenialReasonKey: attribute.StringValue(cors.ReasonOrigin)},
+	}
+	for i, want := range cases {
+		got := map[attribute.Key]attribute.Value{}
+		for _, kv := range spans[i].Attributes() {
+			got[kv.Key] = kv.Value
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("span %d: %s = %v, want %v", i, k, got[k].Emit(), v.Emit())
+			}
+		}
+		if _, ok := got[DenialReasonKey]; ok && i == 0 {
+			t.Errorf("span %d: unexpected %s", i, DenialReasonKey)
+		}
+		if events := spans[i].Events(); len(events) != 1 || events[0].Name != "cors.decision" {
+			t.Errorf("span %d: events = %v", i, events)
+		}
+	}
+}
+
+func TestHandlerSingleEvaluation(t *testing.T) {
+	sr := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)).Tracer("test")
+
+	calls := 0
+	c := cors.New(cors.Options{
+		AllowOriginFunc: func(r *http.Request, origin string) bool {
+			calls++
+			return origin == "http://foo.com"
+		},
+		AllowedMethods: []string{"PUT"},
+		SkipPaths:      []string{"/health"},
+	})
+	h := Handler(c)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, path := range []string{"/", "/health"} {
+		req := httptest.NewRequest("OPTIONS", "http://example.com"+path, nil)
+		req.Header.Set("Origin", "http://foo.com")
+		req.Header.Set("Access-Control-Request-Method", "PUT")
+		ctx, span := tracer.Start(req.Context(), "request")
+		h.ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+		span.End()
+	}
+	if calls != 1 {
+		t.Errorf("AllowOriginFunc called %d times, want 1", calls)
+	}
+	spans := sr.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	got := map[attribute.Key]attribute.Value{}
+	for _, kv := range spans[0].Attributes() {
+		got[kv.Key] = kv.Value
+	}
+	if got[AllowedKey] != attribute.BoolValue(true) || got[PreflightKey] != attribute.BoolValue(true) {
+		t.Errorf("preflight span attributes = %v", spans[0].Attributes())
+	}
+	if attrs := spans[1].Attributes(); len(attrs) != 0 {
+		t.Errorf("skipped request recorded: %v", attrs)
+	}
+}
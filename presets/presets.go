@@ -0,0 +1,88 @@
+// Package presets provides vetted CORS configurations for common deployments.
+//
+// Presets return Options rather than handlers so that they can be adjusted before
+// being passed to cors.New:
+//
+//	options := presets.SPA("https://app.example.com")
+//	options.MaxAge = 3600
+//	r.Use(cors.New(options).Handler)
+package presets
+
+import (
+	"net/http"
+
+	"github.com/go-chi/cors"
+)
+
+// AllowAll allows any origin to use all standard methods with any header, without
+// credentials. It suits public APIs which don't rely on cookies or HTTP
+// authentication.
+func AllowAll() cors.Options {
+	return cors.Options{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{
+			http.MethodHead,
+			http.MethodGet,
+			http.MethodPost,
+			http.MethodPut,
+			http.MethodPatch,
+			http.MethodDelete,
+		},
+		AllowedHeaders: []string{"*"},
+		MaxAge:         600,
+	}
+}
+
+// PublicAPI allows any origin to read the API: only GET and HEAD, the default
+// headers and no credentials.
+func PublicAPI() cors.Options {
+	return cors.Options{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{http.MethodHead, http.MethodGet},
+		MaxAge:         600,
+	}
+}
+
+// SPA allows single page applications served from origins to call the API with
+// cookies or an Authorization header, using the methods and headers of usual JSON
+// APIs. Origins must be https, or on localhost for development.
+func SPA(origins ...string) cors.Options {
+	return cors.Options{
+		AllowedOrigins: origins,
+		AllowedMethods: []string{
+			http.MethodHead,
+			http.MethodGet,
+			http.MethodPost,
+			http.MethodPut,
+			http.MethodPatch,
+			http.MethodDelete,
+		},
+		AllowedHeaders:       []string{"Accept", "Content-Type", "X-Requested-With", "X-CSRF-Token"},
+		ExposedHeaders:       []string{"Link", "Location"},
+		AllowCredentials:     true,
+		AutoAllowAuthHeaders: true,
+		MaxAge:               600,
+	}
+}
+
+// GRPCWeb allows gRPC-Web clients served from origins to call the API, with the
+// request headers set by grpc-web and the trailers it reads exposed.
+func GRPCWeb(origins ...string) cors.Options {
+	return cors.Options{
+		AllowedOrigins: origins,
+		AllowedMethods: []string{http.MethodPost},
+		AllowedHeaders: []string{
+			"Content-Type",
+			"Authorization",
+			"X-Grpc-Web",
+			"X-User-Agent",
+			"Grpc-Timeout",
+		},
+		ExposedHeaders: []string{
+			"Grpc-Status",
+			"Grpc-Message",
+			"Grpc-Status-Details-Bin",
+		},
+		MaxAge: 600,
+	}
+}
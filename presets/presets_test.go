@@ -0,0 +1,54 @@
+package presets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/cors"
+)
+
+func TestPresetsValidate(t *testing.T) {
+	presets := map[string]cors.Options{
+		"AllowAll":  AllowAll(),
+		"PublicAPI": PublicAPI(),
+		"SPA":       SPA("https://app.example.com"),
+		"GRPCWeb":   GRPCWeb("https://app.example.com"),
+	}
+	for name, options := range presets {
+		if err := options.Validate(); err != nil {
+			t.Errorf("%s: Validate() = %v", name, err)
+		}
+		if w := options.Warnings(); len(w) > 0 {
+			t.Errorf("%s: Warnings() = %q", name, w)
+		}
+	}
+}
+
+func TestSPA(t *testing.T) {
+	h := cors.New(SPA("https://app.example.com")).Handler(http.NotFoundHandler())
+	req := httptest.NewRequest("OPTIONS", "http://api.example.com/", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "DELETE")
+	req.Header.Set("Access-Control-Request-Headers", "authorization,content-type")
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	if got := res.Header().Get("Access-Control-Allow-Headers"); got != "Authorization, Content-Type" {
+		t.Errorf("Access-Control-Allow-Headers = %q", got)
+	}
+	if got := res.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Access-Control-Allow-Credentials = %q", got)
+	}
+}
+
+func TestPublicAPI(t *testing.T) {
+	h := cors.New(PublicAPI()).Handler(http.NotFoundHandler())
+	req := httptest.NewRequest("OPTIONS", "http://api.example.com/", nil)
+	req.Header.Set("Origin", "https://anyone.com")
+	req.Header.Set("Access-Control-Request-Method", "DELETE")
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	if got := res.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("DELETE should not be allowed, got Access-Control-Allow-Origin = %q", got)
+	}
+}
@@ -0,0 +1,68 @@
+package cors
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// ProblemTypePrefix prefixes the kind of denial in the type URI of the documents
+// written by ProblemJSONErrorHandler, e.g.
+// "tag:github.com,2024:go-chi/cors:origin-not-allowed"
+const ProblemTypePrefix = "tag:github.com,2024:go-chi/cors:"
+
+// problemKinds maps denial reasons to the kind and title of their problem documents
+var problemKinds = map[string][2]string{
+	ReasonOrigin:              {"origin-not-allowed", "Origin not allowed"},
+	ReasonMalformedOrigin:     {"malformed-origin", "Malformed origin"},
+	ReasonMethod:              {"method-not-allowed", "Method not allowed"},
+	ReasonHeaders:             {"headers-not-allowed", "Headers not allowed"},
+	ReasonMalformedHeaders:    {"malformed-headers", "Malformed requested headers"},
+	ReasonRequestHeadersLimit: {"request-headers-limit", "Too many requested headers"},
+	ReasonHeaderBudget:        {"header-budget", "CORS headers too large"},
+	ReasonPolicy:              {"policy-unavailable", "CORS policy unavailable"},
+}
+
+// problem is an RFC 9457 problem details document
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Origin   string `json:"origin,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// ProblemJSONErrorHandler is an Options.ErrorHandler rendering denials as RFC 9457
+// application/problem+json documents. Their type is ProblemTypePrefix followed by
+// the kind of denial (origin-not-allowed, method-not-allowed, headers-not-allowed,
+// malformed-origin...), the detail is Decision.Message, or the denial error when
+// empty, and the origin and reason extension members hold the request origin and
+// Decision.Reason.
+func ProblemJSONErrorHandler(w http.ResponseWriter, r *http.Request, status int, d Decision) {
+	kind, ok := problemKinds[d.Reason]
+	if !ok {
+		kind = [2]string{"request-denied", "Cross-origin request denied"}
+	}
+	doc := problem{
+		Type:     ProblemTypePrefix + kind[0],
+		Title:    kind[1],
+		Status:   status,
+		Instance: r.URL.Path,
+		Origin:   d.Origin,
+		Reason:   d.Reason,
+	}
+	if doc.Detail = d.Message; doc.Detail == "" && d.Err != nil {
+		doc.Detail = d.Err.Error()
+	}
+	body, err := json.Marshal(doc)
+	if err != nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	w.Write(body)
+}
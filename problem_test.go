@@ -0,0 +1,60 @@
+package cors
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestProblemJSONErrorHandler(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"https://app.com"},
+		AllowedMethods: []string{"GET"},
+		DenyWithStatus: http.StatusForbidden,
+		ErrorHandler:   ProblemJSONErrorHandler,
+	})
+	handler := s.Handler(testHandler)
+	cases := []struct {
+		origin, method string
+		want           map[string]interface{}
+	}{
+		{"https://evil.com", "GET", map[string]interface{}{
+			"type":     ProblemTypePrefix + "origin-not-allowed",
+			"title":    "Origin not allowed",
+			"status":   float64(403),
+			"detail":   "origin 'https://evil.com' not allowed",
+			"instance": "/foo",
+			"origin":   "https://evil.com",
+			"reason":   ReasonOrigin,
+		}},
+		{"https://app.com", "DELETE", map[string]interface{}{
+			"type":     ProblemTypePrefix + "method-not-allowed",
+			"title":    "Method not allowed",
+			"status":   float64(403),
+			"detail":   "method 'DELETE' not allowed",
+			"instance": "/foo",
+			"origin":   "https://app.com",
+			"reason":   ReasonMethod,
+		}},
+	}
+	for _, tc := range cases {
+		req, _ := http.NewRequest("OPTIONS", "http://api.com/foo", nil)
+		req.Header.Add("Origin", tc.origin)
+		req.Header.Add("Access-Control-Request-Method", tc.method)
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		assertResponse(t, res, http.StatusForbidden)
+		if ct := res.Header().Get("Content-Type"); ct != "application/problem+json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		var got map[string]interface{}
+		if err := json.Unmarshal(res.Body.Bytes(), &got); err != nil {
+			t.Fatalf("invalid problem document %q: %v", res.Body.String(), err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("problem = %v, want %v", got, tc.want)
+		}
+	}
+}
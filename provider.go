@@ -0,0 +1,177 @@
+package cors
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-chi/cors/engine"
+)
+
+// OriginProvider supplies allowed origins from a dynamic source. Origins use the
+// same syntax as Options.AllowedOrigins. ctx carries the values of the request
+// triggering the load, but not its cancelation, and times out after 10 seconds.
+// Panics are reported as errors.
+type OriginProvider interface {
+	AllowedOrigins(ctx context.Context) ([]string, error)
+}
+
+// OriginProviderFunc is an adapter to use a function as an OriginProvider
+type OriginProviderFunc func(ctx context.Context) ([]string, error)
+
+// AllowedOrigins calls f(ctx)
+func (f OriginProviderFunc) AllowedOrigins(ctx context.Context) ([]string, error) {
+	return f(ctx)
+}
+
+const (
+	// Default time origins returned by a provider are used before reloading them
+	defaultOriginProviderTTL = time.Minute
+	// Delay before retrying a failed reload, the previous origins being kept meanwhile
+	originProviderRetryDelay = time.Second
+	// Maximum duration of a reload
+	originProviderTimeout = 10 * time.Second
+)
+
+// dynamicOrigins caches the origins returned by an OriginProvider and makes sure
+// a single caller reloads them at a time.
+type dynamicOrigins struct {
+	provider OriginProvider
+	ttl      time.Duration
+	mode     OriginMatchMode
+
+	mu      sync.Mutex
+	matcher *engine.OriginMatcher
+	expires time.Time
+	// Closed when the in-flight reload completes, nil when none is running
+	loading chan struct{}
+	// Set by Freeze, the origins are never reloaded afterwards
+	pinned bool
+
+	onError func(err error)
+	now     func() time.Time
+}
+
+func newDynamicOrigins(provider OriginProvider, ttl time.Duration, mode OriginMatchMode) *dynamicOrigins {
+	if ttl <= 0 {
+		ttl = defaultOriginProviderTTL
+	}
+	return &dynamicOrigins{
+		provider: provider,
+		ttl:      ttl,
+		mode:     mode,
+		onError:  func(error) {},
+		now:      time.Now,
+	}
+}
+
+// match returns the provided pattern matching origin, or nil if none matches
+func (d *dynamicOrigins) match(ctx context.Context, origin string) *engine.Pattern {
+	if m := d.current(ctx); m != nil {
+		return m.Match(origin)
+	}
+	return nil
+}
+
+// current returns the current origins, reloading them if they expired. Stale
+// origins are returned while another caller reloads them; callers only wait when
+// no origins were ever loaded.
+func (d *dynamicOrigins) current(ctx context.Context) *engine.OriginMatcher {
+	d.mu.Lock()
+	m := d.matcher
+	if d.pinned || m != nil && d.now().Before(d.expires) {
+		d.mu.Unlock()
+		return m
+	}
+	loading := d.loading
+	if loading == nil {
+		loading = make(chan struct{})
+		d.loading = loading
+		d.mu.Unlock()
+		return d.reload(ctx, loading)
+	}
+	d.mu.Unlock()
+	if m != nil {
+		return m
+	}
+	select {
+	case <-loading:
+	case <-ctx.Done():
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.matcher
+}
+
+// pin loads the origins if they never were and stops reloading them, see Freeze
+func (d *dynamicOrigins) pin() {
+	d.current(context.Background())
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pinned = true
+}
+
+// loaded returns the origins in use, without loading them
+func (d *dynamicOrigins) loaded() *engine.OriginMatcher {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.matcher
+}
+
+// reload fetches origins from the provider, keeping the previous ones on error.
+// As the reload is shared by all the callers, it is not canceled with the request
+// triggering it but times out after originProviderTimeout.
+func (d *dynamicOrigins) reload(ctx context.Context, done chan struct{}) (m *engine.OriginMatcher) {
+	defer func() {
+		d.mu.Lock()
+		d.loading = nil
+		m = d.matcher
+		d.mu.Unlock()
+		close(done)
+	}()
+	ctx, cancel := context.WithTimeout(detachedContext{ctx}, originProviderTimeout)
+	defer cancel()
+	origins, err := d.fetch(ctx)
+	var next *engine.OriginMatcher
+	if err == nil {
+		next, err = engine.NewOriginMatcher(origins, nil, engine.MatchMode(d.mode))
+	}
+	d.mu.Lock()
+	switch {
+	case d.pinned:
+		// Frozen while loading
+	case err != nil:
+		d.expires = d.now().Add(originProviderRetryDelay)
+	default:
+		d.matcher = next
+		d.expires = d.now().Add(d.ttl)
+	}
+	d.mu.Unlock()
+	if err != nil {
+		d.onError(err)
+	}
+	return
+}
+
+// fetch calls the provider, turning its panics into errors
+func (d *dynamicOrigins) fetch(ctx context.Context) (origins []string, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("cors: origin provider panicked: %v", v)
+		}
+	}()
+	return d.provider.AllowedOrigins(ctx)
+}
+
+// detachedContext keeps the values of a context without its cancelation
+type detachedContext struct {
+	context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+
+func (detachedContext) Done() <-chan struct{} { return nil }
+
+func (detachedContext) Err() error { return nil }
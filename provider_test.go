@@ -0,0 +1,132 @@
+package cors
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOriginProvider(t *testing.T) {
+	var calls int32
+	origins := []string{"http://foo.com"}
+	var fail bool
+	s := New(Options{
+		AllowedOrigins: []string{"http://static.com"},
+		OriginProvider: OriginProviderFunc(func(ctx context.Context) ([]string, error) {
+			atomic.AddInt32(&calls, 1)
+			if fail {
+				return nil, errors.New("database unavailable")
+			}
+			return origins, nil
+		}),
+		OriginProviderTTL: time.Minute,
+	})
+	now := time.Now()
+	s.current().originProvider.now = func() time.Time { return now }
+
+	if !s.current().isOriginAllowed(nil, "http://static.com") {
+		t.Error("static origins should still be allowed")
+	}
+	if !s.current().isOriginAllowed(nil, "http://foo.com") {
+		t.Error("provided origin should be allowed")
+	}
+	origins = []string{"http://*.bar.com"}
+	if s.current().isOriginAllowed(nil, "http://a.bar.com") {
+		t.Error("origins should be cached until the TTL expires")
+	}
+	now = now.Add(2 * time.Minute)
+	if !s.current().isOriginAllowed(nil, "http://a.bar.com") {
+		t.Error("origins should be reloaded once the TTL expired")
+	}
+	if s.current().isOriginAllowed(nil, "http://foo.com") {
+		t.Error("removed origin should not be allowed anymore")
+	}
+	fail = true
+	now = now.Add(2 * time.Minute)
+	if !s.current().isOriginAllowed(nil, "http://a.bar.com") {
+		t.Error("previous origins should be kept when reload fails")
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Errorf("provider called %d times, want 3", n)
+	}
+}
+
+func TestOriginProviderSingleflight(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	d := newDynamicOrigins(OriginProviderFunc(func(ctx context.Context) ([]string, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return []string{"http://foo.com"}, nil
+	}), 0, MatchMostSpecific)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if d.match(context.Background(), "http://foo.com") == nil {
+				t.Error("http://foo.com should be allowed")
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("provider called %d times, want 1", n)
+	}
+}
+
+func TestOriginProviderPanic(t *testing.T) {
+	var panics int32
+	d := newDynamicOrigins(OriginProviderFunc(func(ctx context.Context) ([]string, error) {
+		if atomic.AddInt32(&panics, 1) == 1 {
+			panic("boom")
+		}
+		return []string{"http://foo.com"}, nil
+	}), 0, MatchMostSpecific)
+	var reported error
+	d.onError = func(err error) { reported = err }
+	now := time.Now()
+	d.now = func() time.Time { return now }
+
+	if d.match(context.Background(), "http://foo.com") != nil {
+		t.Error("no origin should be allowed after a panic")
+	}
+	if reported == nil || !strings.Contains(reported.Error(), "boom") {
+		t.Errorf("reported error = %v, want the panic", reported)
+	}
+	// The next reload is not blocked by the panicked one
+	now = now.Add(2 * originProviderRetryDelay)
+	if d.match(context.Background(), "http://foo.com") == nil {
+		t.Error("http://foo.com should be allowed once reloaded")
+	}
+}
+
+func TestOriginProviderDetachedContext(t *testing.T) {
+	type key struct{}
+	var deadline bool
+	var value interface{}
+	var err error
+	d := newDynamicOrigins(OriginProviderFunc(func(ctx context.Context) ([]string, error) {
+		_, deadline = ctx.Deadline()
+		value, err = ctx.Value(key{}), ctx.Err()
+		return []string{"http://foo.com"}, nil
+	}), 0, MatchMostSpecific)
+
+	// The request triggering the initial load is already gone
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "v"))
+	cancel()
+	d.reload(ctx, make(chan struct{}))
+	if err != nil || !deadline || value != "v" {
+		t.Errorf("provider context: err = %v, deadline = %v, value = %v", err, deadline, value)
+	}
+	if d.match(context.Background(), "http://foo.com") == nil {
+		t.Error("http://foo.com should be allowed")
+	}
+}
@@ -0,0 +1,125 @@
+package cors
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// ReportingGroup is the name under which Options.ReportingEndpoint is advertised
+// in Reporting-Endpoints, to be named by the report-to parameter of policies
+const ReportingGroup = "cors"
+
+// ReportTypeDenial is the type of the reports of denied requests sent by the
+// middleware to its ReportCollector
+const ReportTypeDenial = "cors-denial"
+
+// Report is a report in the format of the Reporting API. Denials reported by the
+// middleware have the ReportTypeDenial type and a body holding the origin,
+// method, preflight, reason and message of the decision, and the referrer.
+type Report struct {
+	Type      string                 `json:"type"`
+	URL       string                 `json:"url"`
+	Age       int                    `json:"age"`
+	UserAgent string                 `json:"user_agent"`
+	Body      map[string]interface{} `json:"body"`
+}
+
+// ReportCollector funnels the reports browsers deliver through the Reporting API
+// and the denials of the middleware to OnReport, to learn which users hit
+// denials and from which origins. It serves the endpoint browsers post reports
+// to, to be mounted at the Options.ReportingEndpoint path, and is set as
+// Options.ReportCollector to receive denials:
+//
+//	collector := &cors.ReportCollector{OnReport: func(r cors.Report) { log.Println(r) }}
+//	c := cors.New(cors.Options{
+//	    ReportCollector:   collector,
+//	    ReportingEndpoint: "https://api.example.com/reports",
+//	})
+//	mux.Handle("/reports", collector)
+//
+// When reports come from other origins, the endpoint must itself allow them to
+// post application/reports+json through CORS.
+type ReportCollector struct {
+	// OnReport is called with every report, from the goroutine of the request
+	// which delivered or caused it. It must not block.
+	OnReport func(Report)
+
+	// MaxBodyBytes limits the size of the report batches delivered by browsers.
+	// Default value is 64KiB.
+	MaxBodyBytes int64
+}
+
+// ServeHTTP accepts the report batches POSTed by browsers
+func (rc *ReportCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	mediaType := strings.TrimSpace(strings.SplitN(r.Header.Get("Content-Type"), ";", 2)[0])
+	if !strings.EqualFold(mediaType, "application/reports+json") && !strings.EqualFold(mediaType, "application/json") {
+		http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+	max := rc.MaxBodyBytes
+	if max <= 0 {
+		max = 64 << 10
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, max+1))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if int64(len(body)) > max {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+	var reports []Report
+	if err := json.Unmarshal(body, &reports); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	if rc.OnReport != nil {
+		for _, report := range reports {
+			rc.OnReport(report)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// collectDenial reports the denial d of the request r
+func (rc *ReportCollector) collectDenial(r *http.Request, d Decision) {
+	if rc.OnReport == nil {
+		return
+	}
+	body := map[string]interface{}{
+		"origin":    d.Origin,
+		"method":    d.Method,
+		"preflight": d.Preflight,
+		"reason":    d.Reason,
+	}
+	if d.Err != nil {
+		body["message"] = d.Err.Error()
+	}
+	if referrer := r.Header.Get("Referer"); referrer != "" {
+		body["referrer"] = referrer
+	}
+	rc.OnReport(Report{
+		Type:      ReportTypeDenial,
+		URL:       requestURL(r),
+		UserAgent: r.Header.Get("User-Agent"),
+		Body:      body,
+	})
+}
+
+// requestURL returns the absolute URL of the request r
+func requestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.RequestURI()
+}
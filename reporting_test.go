@@ -0,0 +1,67 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReportCollector(t *testing.T) {
+	var reports []Report
+	collector := &ReportCollector{OnReport: func(r Report) { reports = append(reports, r) }}
+	s := New(Options{
+		AllowedOrigins:    []string{"http://foo.com"},
+		ReportCollector:   collector,
+		ReportingEndpoint: "https://example.com/reports",
+	})
+
+	req, _ := http.NewRequest("GET", "http://example.com/foo?q=1", nil)
+	req.Header.Set("Origin", "http://bar.com")
+	req.Header.Set("User-Agent", "test")
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	if got, want := res.Header().Get("Reporting-Endpoints"), `cors="https://example.com/reports"`; got != want {
+		t.Errorf("Reporting-Endpoints = %q, want %q", got, want)
+	}
+	req.Header.Set("Origin", "http://foo.com")
+	s.Handler(testHandler).ServeHTTP(httptest.NewRecorder(), req)
+	if len(reports) != 1 {
+		t.Fatalf("%d reports, want 1", len(reports))
+	}
+	r := reports[0]
+	if r.Type != ReportTypeDenial || r.URL != "http://example.com/foo?q=1" || r.UserAgent != "test" ||
+		r.Body["origin"] != "http://bar.com" || r.Body["reason"] != ReasonOrigin {
+		t.Errorf("report = %+v", r)
+	}
+
+	batch := `[{"type":"coep","url":"https://example.com/","age":10,"user_agent":"test","body":{"blockedURL":"https://cdn.com/x.js"}}]`
+	req, _ = http.NewRequest("POST", "http://example.com/reports", strings.NewReader(batch))
+	req.Header.Set("Content-Type", "application/reports+json")
+	res = httptest.NewRecorder()
+	collector.ServeHTTP(res, req)
+	if res.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", res.Code, http.StatusNoContent)
+	}
+	if len(reports) != 2 || reports[1].Type != "coep" || reports[1].Body["blockedURL"] != "https://cdn.com/x.js" {
+		t.Errorf("reports = %+v", reports)
+	}
+
+	for _, tc := range []struct {
+		method, contentType, body string
+		status                    int
+	}{
+		{"GET", "", "", http.StatusMethodNotAllowed},
+		{"POST", "text/plain", batch, http.StatusUnsupportedMediaType},
+		{"POST", "application/reports+json", "{", http.StatusBadRequest},
+		{"POST", "application/reports+json", "[" + strings.Repeat(" ", 64<<10) + "]", http.StatusRequestEntityTooLarge},
+	} {
+		req, _ = http.NewRequest(tc.method, "http://example.com/reports", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", tc.contentType)
+		res = httptest.NewRecorder()
+		collector.ServeHTTP(res, req)
+		if res.Code != tc.status {
+			t.Errorf("%s %s: status = %d, want %d", tc.method, tc.contentType, res.Code, tc.status)
+		}
+	}
+}
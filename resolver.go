@@ -0,0 +1,100 @@
+package cors
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// PolicyResolver supplies the configuration applied to a request, for policies
+// depending on the tenant, the API key or the path rather than on the origin only.
+type PolicyResolver interface {
+	// Resolve returns the options for r, or nil to apply the handler's own
+	// options. Returned options are compiled once and cached by value, so that
+	// they can be built per request, unless they hold functions or interfaces:
+	// these are cached per pointer, the same *Options must then be returned for
+	// the same policy and never modified afterwards.
+	Resolve(r *http.Request) (*Options, error)
+}
+
+// PolicyResolverFunc is an adapter to use a function as PolicyResolver
+type PolicyResolverFunc func(r *http.Request) (*Options, error)
+
+// Resolve calls f(r)
+func (f PolicyResolverFunc) Resolve(r *http.Request) (*Options, error) {
+	return f(r)
+}
+
+// Maximum number of resolved policies kept compiled
+const resolvedPoliciesMax = 1024
+
+// resolvedPolicies caches the policies compiled from resolved options, by their
+// canonical encoding or by pointer. Entries keep their options alive, so that
+// their address can't be reused.
+type resolvedPolicies struct {
+	mu       sync.Mutex
+	policies map[interface{}]*policy
+}
+
+// resolve returns the policy to apply to r
+func (p *policy) resolve(r *http.Request) (*policy, error) {
+	if p.resolver == nil {
+		return p, nil
+	}
+	o, err := p.resolver.Resolve(r)
+	if err != nil {
+		return nil, err
+	}
+	if o == nil {
+		return p, nil
+	}
+	var key interface{} = o
+	if v := reflect.ValueOf(*o); isPlainData(v) {
+		var b strings.Builder
+		writeCanonical(&b, v)
+		key = b.String()
+	}
+	p.resolved.mu.Lock()
+	defer p.resolved.mu.Unlock()
+	if rp, ok := p.resolved.policies[key]; ok {
+		return rp, nil
+	}
+	rp, err := newPolicy(p.c, *o)
+	if err != nil {
+		return nil, err
+	}
+	// Resolved options never resolve further
+	rp.resolver = nil
+	if len(p.resolved.policies) >= resolvedPoliciesMax {
+		p.resolved.policies = nil
+	}
+	if p.resolved.policies == nil {
+		p.resolved.policies = map[interface{}]*policy{}
+	}
+	p.resolved.policies[key] = rp
+	return rp, nil
+}
+
+// resolveError reports a request whose policy could not be resolved, no CORS
+// header being added to its response
+func (p *policy) resolveError(w http.ResponseWriter, r *http.Request, err error) Decision {
+	p.logf(r, "Policy resolver failed: %v", err)
+	preflight := isPreflight(r)
+	if w != nil {
+		if preflight {
+			p.addVary(w.Header(), "Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers")
+		} else {
+			p.addVary(w.Header(), actualVary...)
+		}
+	}
+	d := Decision{Preflight: preflight, Origin: headerValue(r.Header, "Origin"), Method: r.Method}
+	if preflight {
+		d.Method = strings.ToUpper(headerValue(r.Header, "Access-Control-Request-Method"))
+	}
+	d = d.deny(ReasonPolicy, err)
+	if w != nil {
+		p.setDebugHeader(w.Header(), d)
+	}
+	return d
+}
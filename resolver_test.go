@@ -0,0 +1,105 @@
+package cors
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPolicyResolver(t *testing.T) {
+	tenants := map[string]*Options{
+		"acme": {
+			AllowedOrigins:   []string{"https://acme.com"},
+			AllowedMethods:   []string{"GET", "PUT"},
+			AllowCredentials: true,
+		},
+		"globex": {
+			AllowedOrigins: []string{"https://globex.com"},
+		},
+	}
+	resolves := 0
+	var denied []Decision
+	s := New(Options{
+		AllowedOrigins: []string{"https://default.com"},
+		PolicyResolver: PolicyResolverFunc(func(r *http.Request) (*Options, error) {
+			resolves++
+			tenant := strings.TrimSuffix(r.Host, ".api.com")
+			if tenant == "broken" {
+				return nil, errors.New("tenant store unavailable")
+			}
+			return tenants[tenant], nil
+		}),
+		OnDecision: func(d Decision) {
+			if !d.Allowed {
+				denied = append(denied, d)
+			}
+		},
+	})
+	h := s.Handler(testHandler)
+
+	cases := []struct {
+		host, origin, method string
+		allowed              bool
+		credentials          string
+	}{
+		{"acme.api.com", "https://acme.com", "PUT", true, "true"},
+		{"acme.api.com", "https://acme.com", "PUT", true, "true"},
+		{"globex.api.com", "https://globex.com", "PUT", false, ""},
+		{"globex.api.com", "https://acme.com", "GET", false, ""},
+		{"other.api.com", "https://default.com", "GET", true, ""},
+		{"broken.api.com", "https://default.com", "GET", false, ""},
+	}
+	for _, tc := range cases {
+		req, _ := http.NewRequest("OPTIONS", "http://"+tc.host+"/foo", nil)
+		req.Header.Add("Origin", tc.origin)
+		req.Header.Add("Access-Control-Request-Method", tc.method)
+		res := httptest.NewRecorder()
+		h.ServeHTTP(res, req)
+		if allowed := res.Header().Get("Access-Control-Allow-Origin") != ""; allowed != tc.allowed {
+			t.Errorf("%s from %s: allowed = %v, want %v", tc.host, tc.origin, allowed, tc.allowed)
+		}
+		if got := res.Header().Get("Access-Control-Allow-Credentials"); got != tc.credentials {
+			t.Errorf("%s from %s: Access-Control-Allow-Credentials = %q, want %q", tc.host, tc.origin, got, tc.credentials)
+		}
+		if d := s.Check(req); d.Allowed != tc.allowed {
+			t.Errorf("%s from %s: Check().Allowed = %v, want %v", tc.host, tc.origin, d.Allowed, tc.allowed)
+		}
+	}
+	if n := len(s.current().resolved.policies); n != 2 {
+		t.Errorf("%d resolved policies compiled, want 2", n)
+	}
+	if last := denied[len(denied)-1]; last.Reason != ReasonPolicy || last.Err == nil {
+		t.Errorf("resolver failure reported as %+v", last)
+	}
+}
+
+func TestPolicyResolverFreshOptions(t *testing.T) {
+	var logs strings.Builder
+	allowAll := func(r *http.Request, origin string) bool { return true }
+	s := New(Options{
+		PolicyResolver: PolicyResolverFunc(func(r *http.Request) (*Options, error) {
+			if r.URL.Path == "/func" {
+				return &Options{AllowOriginFunc: allowAll}, nil
+			}
+			// Broad wildcard, warned about when compiled
+			return &Options{AllowedOrigins: []string{"https://*.com"}}, nil
+		}),
+	})
+	s.Log = log.New(&logs, "", 0)
+	h := s.Handler(testHandler)
+	for _, path := range []string{"/", "/", "/", "/func", "/func"} {
+		req, _ := http.NewRequest("GET", "http://example.com"+path, nil)
+		req.Header.Add("Origin", "https://foo.com")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	// Options built per request are compiled once, unless they hold functions
+	if n := len(s.current().resolved.policies); n != 3 {
+		t.Errorf("%d resolved policies compiled, want 3", n)
+	}
+	if n := strings.Count(logs.String(), "Warning"); n != 1 {
+		t.Errorf("%d warnings logged, want 1:\n%s", n, logs.String())
+	}
+}
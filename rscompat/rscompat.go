@@ -0,0 +1,200 @@
+// Package rscompat eases migrations from github.com/rs/cors: its Options mirror
+// the ones of rs/cors and its Cors handler has the same methods, so that imports
+// can be switched first and configurations moved to cors.Options later, one
+// service at a time:
+//
+//	c := rscompat.New(rscompat.Options{
+//		AllowedOrigins:       []string{"https://*.example.com"},
+//		AllowCredentials:     true,
+//		OptionsSuccessStatus: http.StatusNoContent,
+//	})
+//	http.ListenAndServe(":8080", c.Handler(mux))
+//
+// Unlike rs/cors, requested headers are echoed in canonical form and denied
+// origins are reported with the reasons of cors.Decision.
+package rscompat
+
+import (
+	"net/http"
+
+	"github.com/go-chi/cors"
+)
+
+// Logger is the logger of rs/cors, satisfied by *log.Logger
+type Logger = cors.Logger
+
+// Options mirrors the options of rs/cors
+type Options struct {
+	// AllowedOrigins is a list of origins a cross-domain request can be executed
+	// from, "*" allowing all of them. Default value is ["*"].
+	AllowedOrigins []string
+
+	// AllowOriginFunc validates the origin, AllowedOrigins being ignored when set.
+	// Deprecated in rs/cors: use AllowOriginVaryRequestFunc.
+	AllowOriginFunc func(origin string) bool
+
+	// AllowOriginRequestFunc validates the origin with the request, AllowedOrigins
+	// and AllowOriginFunc being ignored when set. Deprecated in rs/cors: use
+	// AllowOriginVaryRequestFunc.
+	AllowOriginRequestFunc func(r *http.Request, origin string) bool
+
+	// AllowOriginVaryRequestFunc validates the origin with the request and returns
+	// the request headers the decision depends on, which are added to Vary.
+	// It takes precedence over all the other origin options.
+	AllowOriginVaryRequestFunc func(r *http.Request, origin string) (bool, []string)
+
+	// AllowedMethods is a list of methods the client is allowed to use. Default
+	// value is simple methods (HEAD, GET and POST).
+	AllowedMethods []string
+
+	// AllowedHeaders is a list of non simple headers the client is allowed to
+	// use, "*" allowing all of them. Default value is Accept, Content-Type and
+	// X-Requested-With, plus Origin.
+	AllowedHeaders []string
+
+	// ExposedHeaders indicates which headers are safe to expose
+	ExposedHeaders []string
+
+	// MaxAge indicates how long (in seconds) the results of a preflight request
+	// can be cached
+	MaxAge int
+
+	// AllowCredentials indicates whether the request can include user credentials
+	AllowCredentials bool
+
+	// AllowPrivateNetwork indicates whether to accept cross-origin requests over a
+	// private network
+	AllowPrivateNetwork bool
+
+	// OptionsPassthrough instructs preflight to let other potential next handlers
+	// process the OPTIONS method
+	OptionsPassthrough bool
+
+	// OptionsSuccessStatus is the status of preflight responses, 204 by default
+	// as in rs/cors
+	OptionsSuccessStatus int
+
+	// Debug logs decisions to Logger, or to the standard output if nil
+	Debug bool
+
+	// Logger receives the debugging output when Debug is set
+	Logger Logger
+}
+
+// Convert returns the cors.Options equivalent to o. OptionsSuccessStatus, which
+// is only honored by the rscompat handler, is not carried over.
+func Convert(o Options) cors.Options {
+	options := cors.Options{
+		AllowedOrigins:      o.AllowedOrigins,
+		AllowedMethods:      o.AllowedMethods,
+		AllowedHeaders:      o.AllowedHeaders,
+		ExposedHeaders:      o.ExposedHeaders,
+		MaxAge:              o.MaxAge,
+		AllowCredentials:    o.AllowCredentials,
+		AllowPrivateNetwork: o.AllowPrivateNetwork,
+		OptionsPassthrough:  o.OptionsPassthrough,
+		Debug:               o.Debug,
+	}
+	if len(options.AllowedHeaders) == 0 {
+		options.AllowedHeaders = []string{"Accept", "Content-Type", "X-Requested-With"}
+	}
+	switch {
+	case o.AllowOriginVaryRequestFunc != nil:
+		options.AllowOriginVaryFunc = o.AllowOriginVaryRequestFunc
+	case o.AllowOriginRequestFunc != nil:
+		options.AllowOriginFunc = o.AllowOriginRequestFunc
+	case o.AllowOriginFunc != nil:
+		f := o.AllowOriginFunc
+		options.AllowOriginFunc = func(r *http.Request, origin string) bool {
+			return f(origin)
+		}
+	}
+	return options
+}
+
+// Cors is a handler mirroring the methods of the rs/cors handler
+type Cors struct {
+	// Log is the logger of the underlying handler
+	Log Logger
+
+	cors          *cors.Cors
+	successStatus int
+	passthrough   bool
+}
+
+// New creates a handler with the rs/cors options o. It panics if they are
+// invalid, see cors.Options.Validate.
+func New(o Options) *Cors {
+	c := &Cors{
+		cors:          cors.New(Convert(o)),
+		successStatus: o.OptionsSuccessStatus,
+		passthrough:   o.OptionsPassthrough,
+	}
+	if c.successStatus == 0 {
+		c.successStatus = http.StatusNoContent
+	}
+	if o.Logger != nil {
+		c.cors.Log = o.Logger
+	}
+	c.Log = c.cors.Log
+	return c
+}
+
+// Default creates a handler with the rs/cors defaults: all origins with simple
+// methods
+func Default() *Cors {
+	return New(Options{})
+}
+
+// AllowAll creates a handler allowing all origins with all standard methods and
+// any header, without credentials
+func AllowAll() *Cors {
+	return New(Options{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{
+			http.MethodHead,
+			http.MethodGet,
+			http.MethodPost,
+			http.MethodPut,
+			http.MethodPatch,
+			http.MethodDelete,
+		},
+		AllowedHeaders: []string{"*"},
+	})
+}
+
+// Handler applies the CORS specification on the request, and adds relevant CORS
+// headers as necessary
+func (c *Cors) Handler(h http.Handler) http.Handler {
+	inner := c.cors.Handler(h)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !c.passthrough && r.Method == http.MethodOptions && r.Header.Get("Origin") != "" &&
+			r.Header.Get("Access-Control-Request-Method") != "" {
+			w = &statusWriter{ResponseWriter: w, status: c.successStatus}
+		}
+		inner.ServeHTTP(w, r)
+	})
+}
+
+// HandlerFunc provides Martini compatible handler
+func (c *Cors) HandlerFunc(w http.ResponseWriter, r *http.Request) {
+	c.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(w, r)
+}
+
+// ServeHTTP provides Negroni compatible handler
+func (c *Cors) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	c.Handler(next).ServeHTTP(w, r)
+}
+
+// statusWriter replaces the 200 status of preflight responses
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if status == http.StatusOK {
+		status = w.status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
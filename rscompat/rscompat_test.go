@@ -0,0 +1,117 @@
+package rscompat
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("bar"))
+})
+
+func serve(h http.Handler, method, origin string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "http://example.com/foo", nil)
+	req.Header.Set("Origin", origin)
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	return res
+}
+
+func TestPreflightSuccessStatus(t *testing.T) {
+	preflight := map[string]string{"Access-Control-Request-Method": "PUT"}
+	for status, h := range map[int]http.Handler{
+		http.StatusNoContent: New(Options{AllowedMethods: []string{"PUT"}}).Handler(testHandler),
+		http.StatusOK:        New(Options{AllowedMethods: []string{"PUT"}, OptionsSuccessStatus: 200}).Handler(testHandler),
+	} {
+		res := serve(h, "OPTIONS", "http://foo.com", preflight)
+		if res.Code != status {
+			t.Errorf("status = %d, want %d", res.Code, status)
+		}
+		if got := res.Header().Get("Access-Control-Allow-Methods"); got != "PUT" {
+			t.Errorf("Access-Control-Allow-Methods = %q", got)
+		}
+	}
+
+	res := serve(Default().Handler(testHandler), "GET", "http://foo.com", nil)
+	if res.Code != http.StatusOK || res.Body.String() != "bar" {
+		t.Errorf("actual request: %d %q", res.Code, res.Body.String())
+	}
+}
+
+func TestOriginFuncs(t *testing.T) {
+	allowFoo := func(origin string) bool { return origin == "http://foo.com" }
+	cases := map[string]Options{
+		"AllowOriginFunc": {AllowOriginFunc: allowFoo},
+		"AllowOriginRequestFunc": {
+			AllowOriginFunc:        func(string) bool { return false },
+			AllowOriginRequestFunc: func(r *http.Request, origin string) bool { return allowFoo(origin) },
+		},
+	}
+	for name, o := range cases {
+		h := New(o).Handler(testHandler)
+		if got := serve(h, "GET", "http://foo.com", nil).Header().Get("Access-Control-Allow-Origin"); got != "http://foo.com" {
+			t.Errorf("%s: allowed origin got %q", name, got)
+		}
+		if got := serve(h, "GET", "http://bar.com", nil).Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("%s: denied origin got %q", name, got)
+		}
+	}
+}
+
+func TestAllowOriginVaryRequestFunc(t *testing.T) {
+	calls := 0
+	h := New(Options{
+		AllowOriginVaryRequestFunc: func(r *http.Request, origin string) (bool, []string) {
+			calls++
+			return r.Header.Get("X-Tenant") == "acme", []string{"X-Tenant"}
+		},
+	}).Handler(testHandler)
+
+	res := serve(h, "GET", "http://foo.com", map[string]string{"X-Tenant": "acme"})
+	if got := res.Header().Get("Access-Control-Allow-Origin"); got != "http://foo.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q", got)
+	}
+	if vary := strings.Join(res.Header().Values("Vary"), ", "); !strings.Contains(vary, "X-Tenant") || !strings.Contains(vary, "Origin") {
+		t.Errorf("Vary = %q", vary)
+	}
+	if calls != 1 {
+		t.Errorf("AllowOriginVaryRequestFunc called %d times, want 1", calls)
+	}
+	if got := serve(h, "GET", "http://foo.com", nil).Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("denied: Access-Control-Allow-Origin = %q", got)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	o := Convert(Options{
+		AllowedOrigins:      []string{"https://*.example.com"},
+		AllowedMethods:      []string{"GET", "PUT"},
+		AllowCredentials:    true,
+		AllowPrivateNetwork: true,
+		MaxAge:              600,
+	})
+	if !reflect.DeepEqual(o.AllowedHeaders, []string{"Accept", "Content-Type", "X-Requested-With"}) {
+		t.Errorf("AllowedHeaders = %q, want the rs/cors defaults", o.AllowedHeaders)
+	}
+	if !o.AllowCredentials || !o.AllowPrivateNetwork || o.MaxAge != 600 || len(o.AllowedOrigins) != 1 {
+		t.Errorf("unexpected options %s", o)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	c := AllowAll()
+	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("Origin", "http://foo.com")
+	res := httptest.NewRecorder()
+	called := false
+	c.ServeHTTP(res, req, func(w http.ResponseWriter, r *http.Request) { called = true })
+	if !called || res.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("next called = %v, headers = %v", called, res.Header())
+	}
+}
@@ -0,0 +1,27 @@
+package cors
+
+import (
+	"net/http"
+	"reflect"
+)
+
+// compareShadow evaluates r against the shadow policy and reports a divergence
+// from d, the decision of the active policy
+func (p *policy) compareShadow(r *http.Request, d Decision) {
+	if p.shadow == nil || d.Origin == "" {
+		return
+	}
+	var sd Decision
+	if d.Preflight {
+		sd = p.shadow.checkPreflight(r)
+	} else {
+		sd = p.shadow.checkActual(r)
+	}
+	if sd.Allowed == d.Allowed && reflect.DeepEqual(sd.header, d.header) {
+		return
+	}
+	p.logf(r, "Shadow policy diverges for origin '%s': allowed %v, shadow allowed %v", d.Origin, d.Allowed, sd.Allowed)
+	if p.onShadowDivergence != nil {
+		p.onShadowDivergence(d, sd)
+	}
+}
@@ -0,0 +1,61 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestShadowPolicy(t *testing.T) {
+	type divergence struct{ active, shadow Decision }
+	var got []divergence
+	s := New(Options{
+		AllowedOrigins: []string{"http://foo.com", "http://bar.com"},
+		MaxAge:         10,
+		ShadowPolicy: &Options{
+			AllowedOrigins: []string{"http://foo.com"},
+			MaxAge:         20,
+		},
+		OnShadowDivergence: func(active, shadow Decision) {
+			got = append(got, divergence{active, shadow})
+		},
+	})
+	h := s.Handler(testHandler)
+
+	// Same outcome and headers
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "http://foo.com")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Denied by the shadow policy
+	req, _ = http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "http://bar.com")
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	if res.Header().Get("Access-Control-Allow-Origin") != "http://bar.com" {
+		t.Error("shadow policy affected the response")
+	}
+
+	// Allowed by both with different headers
+	req, _ = http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "http://foo.com")
+	req.Header.Add("Access-Control-Request-Method", "GET")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(got) != 2 {
+		t.Fatalf("got %d divergences, want 2", len(got))
+	}
+	if !got[0].active.Allowed || got[0].shadow.Allowed || got[0].shadow.Reason != ReasonOrigin {
+		t.Errorf("divergence = %+v, want shadow denial", got[0])
+	}
+	if !got[1].active.Allowed || !got[1].shadow.Allowed || !got[1].active.Preflight {
+		t.Errorf("divergence = %+v, want preflight allowed by both", got[1])
+	}
+}
+
+func TestShadowPolicyInvalid(t *testing.T) {
+	s := New(Options{})
+	if err := s.UpdateOptions(Options{ShadowPolicy: &Options{AllowedOriginsRegex: []string{"("}}}); err == nil {
+		t.Error("UpdateOptions() accepted an invalid shadow policy")
+	}
+}
@@ -0,0 +1,154 @@
+package cors
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// OriginWatcher watches an external source of allowed origins, such as a Kubernetes
+// ConfigMap or a Consul KV prefix, calling update with the full list of origins
+// every time it changes until ctx is done. Implementations live outside of this
+// package so that it stays free of dependencies.
+type OriginWatcher interface {
+	Watch(ctx context.Context, update func(origins []string)) error
+}
+
+// SourceHealth describes the state of a dynamic origins source
+type SourceHealth struct {
+	// Ready is set once origins were received at least once
+	Ready bool
+	// LastUpdate is the time origins were last received
+	LastUpdate time.Time
+	// Stale is set when origins were not received for longer than the allowed staleness
+	Stale bool
+	// Err is the last error returned by the source, if any
+	Err error
+}
+
+// HealthReporter is implemented by origin providers able to report their health,
+// which is then surfaced by (*Cors).Health.
+type HealthReporter interface {
+	Health() SourceHealth
+}
+
+// Health describes the state of the dynamic parts of a Cors handler
+type Health struct {
+	// Origins is the health of the OriginProvider, nil if none is configured or
+	// if it doesn't implement HealthReporter
+	Origins *SourceHealth
+
+	// Policy reports whether the configuration is frozen and intact
+	Policy PolicyHealth
+}
+
+// Health reports the state of the dynamic parts of the handler
+func (c *Cors) Health() Health {
+	h := Health{Policy: c.policyHealth()}
+	p := c.current()
+	if p.originProvider != nil {
+		if r, ok := p.originProvider.provider.(HealthReporter); ok {
+			sh := r.Health()
+			h.Origins = &sh
+		}
+	}
+	return h
+}
+
+// Delay before restarting a watcher which returned an error
+const originWatcherRetryDelay = 5 * time.Second
+
+// ErrOriginStoreNotReady is returned by OriginStore.AllowedOrigins when no origins
+// were received yet
+var ErrOriginStoreNotReady = errors.New("cors: origin store not ready")
+
+// OriginStore is an OriginProvider fed by an OriginWatcher. It always serves the
+// last received origins from memory, so a short Options.OriginProviderTTL can be
+// used to pick up changes quickly.
+type OriginStore struct {
+	maxStaleness time.Duration
+
+	mu      sync.RWMutex
+	origins []string
+	updated time.Time
+	err     error
+	ready   chan struct{}
+
+	now func() time.Time
+}
+
+// NewOriginStore starts watching origins with w until ctx is done, restarting
+// the watcher when it fails. Origins are reported stale by Health when they were
+// not received for longer than maxStaleness, zero meaning they never go stale.
+func NewOriginStore(ctx context.Context, w OriginWatcher, maxStaleness time.Duration) *OriginStore {
+	s := &OriginStore{
+		maxStaleness: maxStaleness,
+		ready:        make(chan struct{}),
+		now:          time.Now,
+	}
+	go s.watch(ctx, w)
+	return s
+}
+
+func (s *OriginStore) watch(ctx context.Context, w OriginWatcher) {
+	for {
+		err := w.Watch(ctx, s.update)
+		if ctx.Err() != nil {
+			return
+		}
+		s.mu.Lock()
+		s.err = err
+		s.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(originWatcherRetryDelay):
+		}
+	}
+}
+
+func (s *OriginStore) update(origins []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.origins = append([]string(nil), origins...)
+	s.updated = s.now()
+	s.err = nil
+	if s.ready != nil {
+		close(s.ready)
+		s.ready = nil
+	}
+}
+
+// AllowedOrigins returns the last received origins, waiting for the first ones
+// until ctx is done.
+func (s *OriginStore) AllowedOrigins(ctx context.Context) ([]string, error) {
+	s.mu.RLock()
+	ready := s.ready
+	origins := s.origins
+	s.mu.RUnlock()
+	if ready == nil {
+		return origins, nil
+	}
+	select {
+	case <-ready:
+	case <-ctx.Done():
+		return nil, ErrOriginStoreNotReady
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.origins, nil
+}
+
+// Health reports whether origins were received and how fresh they are
+func (s *OriginStore) Health() SourceHealth {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	h := SourceHealth{
+		Ready:      s.ready == nil,
+		LastUpdate: s.updated,
+		Err:        s.err,
+	}
+	h.Stale = !h.Ready || (s.maxStaleness > 0 && s.now().Sub(s.updated) > s.maxStaleness)
+	return h
+}
@@ -0,0 +1,63 @@
+package cors
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// chanWatcher sends origins received on a channel
+type chanWatcher chan []string
+
+func (w chanWatcher) Watch(ctx context.Context, update func([]string)) error {
+	for {
+		select {
+		case origins := <-w:
+			update(origins)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func TestOriginStore(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := make(chanWatcher)
+	store := NewOriginStore(ctx, w, time.Minute)
+
+	if h := store.Health(); h.Ready || !h.Stale {
+		t.Errorf("Health() = %+v, want not ready and stale", h)
+	}
+	shortCtx, shortCancel := context.WithTimeout(ctx, time.Millisecond)
+	defer shortCancel()
+	if _, err := store.AllowedOrigins(shortCtx); err != ErrOriginStoreNotReady {
+		t.Errorf("AllowedOrigins() error = %v, want ErrOriginStoreNotReady", err)
+	}
+
+	s := New(Options{OriginProvider: store, OriginProviderTTL: time.Nanosecond})
+	w <- []string{"http://foo.com"}
+	if !s.current().isOriginAllowed(nil, "http://foo.com") {
+		t.Error("http://foo.com should be allowed once received")
+	}
+	w <- []string{"http://bar.com"}
+	w <- []string{"http://bar.com"}
+	if s.current().isOriginAllowed(nil, "http://foo.com") || !s.current().isOriginAllowed(nil, "http://bar.com") {
+		t.Error("origins should follow the watched source")
+	}
+
+	h := s.Health()
+	if h.Origins == nil || !h.Origins.Ready || h.Origins.Stale {
+		t.Errorf("Health() = %+v, want ready and fresh origins", h.Origins)
+	}
+	now := time.Now().Add(2 * time.Minute)
+	store.mu.Lock()
+	store.now = func() time.Time { return now }
+	store.mu.Unlock()
+	if h := s.Health(); !h.Origins.Stale {
+		t.Error("origins should be stale after maxStaleness")
+	}
+	if h := New(Options{}).Health(); h.Origins != nil {
+		t.Errorf("Health() = %+v, want no origins health without provider", h)
+	}
+}
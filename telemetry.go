@@ -0,0 +1,96 @@
+package cors
+
+import (
+	"sort"
+	"sync"
+)
+
+// Denial reasons reported by the middleware
+const (
+	// ReasonOrigin is reported when the request origin is not allowed
+	ReasonOrigin = "origin"
+	// ReasonMalformedOrigin is reported when the Origin header is not a single,
+	// well-formed serialized origin
+	ReasonMalformedOrigin = "malformed-origin"
+	// ReasonPolicy is reported when Options.PolicyResolver fails
+	ReasonPolicy = "policy"
+	// ReasonMethod is reported when the request method is not allowed
+	ReasonMethod = "method"
+	// ReasonHeaders is reported when one of the preflight requested headers is not allowed
+	ReasonHeaders = "headers"
+	// ReasonHeaderBudget is reported when the CORS headers would exceed MaxAddedHeaderBytes
+	ReasonHeaderBudget = "header-budget"
+	// ReasonRequestHeadersLimit is reported when Access-Control-Request-Headers exceeds
+	// MaxPreflightHeaderBytes or MaxPreflightHeaderTokens
+	ReasonRequestHeadersLimit = "request-headers-limit"
+	// ReasonMalformedHeaders is reported when Access-Control-Request-Headers is not
+	// the list a browser sends, see StrictRequestHeaders
+	ReasonMalformedHeaders = "malformed-headers"
+	// ReasonFetchMetadata is reported when a cross-site request is rejected by
+	// Options.EnforceFetchMetadata
+	ReasonFetchMetadata = "fetch-metadata"
+	// ReasonUnsafeOrigin is reported when a state-changing request is rejected by
+	// Options.EnforceOriginForUnsafeMethods
+	ReasonUnsafeOrigin = "unsafe-origin"
+)
+
+// TelemetryKey labels a decision counter
+type TelemetryKey struct {
+	// Policy is the Name of the Cors instance which took the decision
+	Policy string
+	// Preflight is set for preflight requests, unset for actual requests
+	Preflight bool
+	// Allowed is set when the request was allowed
+	Allowed bool
+	// Reason is the denial reason, empty for allowed requests
+	Reason string
+}
+
+// TelemetryCount is a decision counter value
+type TelemetryCount struct {
+	TelemetryKey
+	Count uint64
+}
+
+// Telemetry aggregates the decision counters of one or more Cors instances, so that
+// a single metrics collector can export them for every policy of a service. It is
+// safe for concurrent use.
+type Telemetry struct {
+	mu     sync.Mutex
+	counts map[TelemetryKey]uint64
+}
+
+// NewTelemetry creates an empty Telemetry to share between Cors instances.
+func NewTelemetry() *Telemetry {
+	return &Telemetry{counts: map[TelemetryKey]uint64{}}
+}
+
+func (t *Telemetry) record(key TelemetryKey) {
+	t.mu.Lock()
+	t.counts[key]++
+	t.mu.Unlock()
+}
+
+// Snapshot returns the current value of every counter, sorted by policy name.
+func (t *Telemetry) Snapshot() []TelemetryCount {
+	t.mu.Lock()
+	counts := make([]TelemetryCount, 0, len(t.counts))
+	for k, v := range t.counts {
+		counts = append(counts, TelemetryCount{k, v})
+	}
+	t.mu.Unlock()
+	sort.Slice(counts, func(i, j int) bool {
+		a, b := counts[i], counts[j]
+		if a.Policy != b.Policy {
+			return a.Policy < b.Policy
+		}
+		if a.Preflight != b.Preflight {
+			return a.Preflight
+		}
+		if a.Allowed != b.Allowed {
+			return a.Allowed
+		}
+		return a.Reason < b.Reason
+	})
+	return counts
+}
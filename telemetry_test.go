@@ -0,0 +1,38 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestTelemetry(t *testing.T) {
+	tel := NewTelemetry()
+	api := New(Options{Name: "api", Telemetry: tel, AllowedOrigins: []string{"http://foo.com"}})
+	admin := New(Options{Name: "admin", Telemetry: tel, AllowedOrigins: []string{"http://admin.foo.com"}, AllowedMethods: []string{"GET"}})
+
+	serve := func(c *Cors, method string, headers map[string]string) {
+		req, _ := http.NewRequest(method, "http://example.com/foo", nil)
+		for name, value := range headers {
+			req.Header.Add(name, value)
+		}
+		c.Handler(testHandler).ServeHTTP(httptest.NewRecorder(), req)
+	}
+	serve(api, "GET", map[string]string{"Origin": "http://foo.com"})
+	serve(api, "GET", map[string]string{"Origin": "http://foo.com"})
+	serve(api, "GET", map[string]string{"Origin": "http://bar.com"})
+	serve(api, "GET", nil)
+	serve(admin, "OPTIONS", map[string]string{"Origin": "http://admin.foo.com", "Access-Control-Request-Method": "DELETE"})
+	serve(admin, "OPTIONS", map[string]string{"Origin": "http://admin.foo.com", "Access-Control-Request-Method": "GET"})
+
+	want := []TelemetryCount{
+		{TelemetryKey{Policy: "admin", Preflight: true, Allowed: true}, 1},
+		{TelemetryKey{Policy: "admin", Preflight: true, Reason: ReasonMethod}, 1},
+		{TelemetryKey{Policy: "api", Allowed: true}, 2},
+		{TelemetryKey{Policy: "api", Reason: ReasonOrigin}, 1},
+	}
+	if got := tel.Snapshot(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Snapshot() = %+v, want %+v", got, want)
+	}
+}
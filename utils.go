@@ -1,18 +1,65 @@
 package cors
 
-import "strings"
-
-const toLower = 'a' - 'A'
+import (
+	"hash/fnv"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+)
 
 type converter func(string) string
 
-type wildcard struct {
-	prefix string
-	suffix string
+// isToken checks s is a valid HTTP token as used for method and header names
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		if (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') {
+			continue
+		}
+		if strings.IndexByte("!#$%&'*+-.^_`|~", b) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// headerValues returns the values of a request header regardless of the casing of
+// its name. Requests parsed by net/http have canonical names, but handcrafted ones
+// may not: values of the canonical name come first, then those of other casings in
+// byte order of the names, so that the result is deterministic.
+func headerValues(h http.Header, name string) []string {
+	values := h[name]
+	var others []string
+	for k := range h {
+		if k != name && strings.EqualFold(k, name) {
+			others = append(others, k)
+		}
+	}
+	if len(others) == 0 {
+		return values
+	}
+	sort.Strings(others)
+	values = append([]string(nil), values...)
+	for _, k := range others {
+		values = append(values, h[k]...)
+	}
+	return values
 }
 
-func (w wildcard) match(s string) bool {
-	return len(s) >= len(w.prefix+w.suffix) && strings.HasPrefix(s, w.prefix) && strings.HasSuffix(s, w.suffix)
+// headerValue returns the first value of a request header regardless of the casing
+// of its name, see headerValues.
+func headerValue(h http.Header, name string) string {
+	if v := h[name]; len(v) > 0 {
+		return v[0]
+	}
+	if v := headerValues(h, name); len(v) > 0 {
+		return v[0]
+	}
+	return ""
 }
 
 // convert converts a list of string using the passed converter function
@@ -24,47 +71,113 @@ func convert(s []string, c converter) []string {
 	return out
 }
 
-// parseHeaderList tokenize + normalize a string containing a list of headers
-func parseHeaderList(headerList string) []string {
-	l := len(headerList)
-	h := make([]byte, 0, l)
-	upper := true
-	// Estimate the number headers in order to allocate the right splice size
-	t := 0
-	for i := 0; i < l; i++ {
-		if headerList[i] == ',' {
-			t++
-		}
-	}
-	headers := make([]string, 0, t)
-	for i := 0; i < l; i++ {
-		b := headerList[i]
-		if b >= 'a' && b <= 'z' {
-			if upper {
-				h = append(h, b-toLower)
-			} else {
-				h = append(h, b)
+// isFetchHeaderList reports whether s is an Access-Control-Request-Headers value
+// as the Fetch standard makes browsers send it: byte-lowercased header names,
+// sorted without duplicates and separated by commas without spaces
+func isFetchHeaderList(s string) bool {
+	prev := ""
+	for len(s) > 0 {
+		name := s
+		if i := strings.IndexByte(s, ','); i >= 0 {
+			name, s = s[:i], s[i+1:]
+			if s == "" {
+				return false
 			}
-		} else if b >= 'A' && b <= 'Z' {
-			if !upper {
-				h = append(h, b+toLower)
-			} else {
-				h = append(h, b)
+		} else {
+			s = ""
+		}
+		if !isToken(name) || name <= prev {
+			return false
+		}
+		for i := 0; i < len(name); i++ {
+			if name[i] >= 'A' && name[i] <= 'Z' {
+				return false
 			}
-		} else if b == '-' || b == '_' || b == '.' || (b >= '0' && b <= '9') {
-			h = append(h, b)
 		}
+		prev = name
+	}
+	return true
+}
 
-		if b == ' ' || b == ',' || i == l-1 {
-			if len(h) > 0 {
-				// Flush the found header
-				headers = append(headers, string(h))
-				h = h[:0]
-				upper = true
-			}
-		} else {
-			upper = b == '-'
+// sampleRate normalizes a sampling option: zero means everything is sampled and
+// negative values mean nothing is.
+func sampleRate(rate float64) float64 {
+	if rate == 0 {
+		return 1
+	}
+	if rate < 0 {
+		return 0
+	}
+	return rate
+}
+
+// hashFraction maps s to a stable value in [0, 1)
+func hashFraction(s string) float64 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return float64(h.Sum32()) / (1 << 32)
+}
+
+// headerSize returns the size of headers once serialized on the wire
+func headerSize(headers map[string][]string) int {
+	size := 0
+	for k, v := range headers {
+		for _, value := range v {
+			size += len(k) + len(value) + len(": \r\n")
+		}
+	}
+	return size
+}
+
+// preflightKey builds the preflight cache key of a request, methods being
+// evaluated case-insensitively
+func preflightKey(origin, method, headers string) string {
+	return origin + "\x00" + strings.ToUpper(method) + "\x00" + headers
+}
+
+// sortedCopy returns a sorted copy of s
+func sortedCopy(s []string) []string {
+	out := append([]string(nil), s...)
+	sort.Strings(out)
+	return out
+}
+
+// sortedSet returns the values of s sorted and without duplicates, so that the
+// headers built from them are deterministic. s is left untouched.
+func sortedSet(s []string) []string {
+	out := sortedCopy(s)
+	for i := 1; i < len(out); i++ {
+		if out[i] == out[i-1] {
+			out = append(out[:i], out[i+1:]...)
+			i--
+		}
+	}
+	return out
+}
+
+// isSafelistedContentType reports whether a Content-Type value is one a browser
+// sends without a preflight: form or plain text of at most 128 bytes
+func isSafelistedContentType(v string) bool {
+	if len(v) > 128 {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(v)
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "application/x-www-form-urlencoded", "multipart/form-data", "text/plain":
+		return true
+	}
+	return false
+}
+
+// containsString reports whether s contains v
+func containsString(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
 		}
 	}
-	return headers
+	return false
 }
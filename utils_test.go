@@ -1,25 +1,49 @@
 package cors
 
 import (
+	"net/http"
+	"reflect"
 	"strings"
 	"testing"
 )
 
-func TestWildcard(t *testing.T) {
-	w := wildcard{"foo", "bar"}
-	if !w.match("foobar") {
-		t.Error("foo*bar should match foobar")
+func TestIsToken(t *testing.T) {
+	for _, s := range []string{"GET", "X-Header_1", "M-SEARCH", "x.y"} {
+		if !isToken(s) {
+			t.Errorf("%q should be a token", s)
+		}
 	}
-	if !w.match("foobazbar") {
-		t.Error("foo*bar should match foobazbar")
+	for _, s := range []string{"", "GET POST", "X-Header,", "caf\u00e9", "(x)"} {
+		if isToken(s) {
+			t.Errorf("%q should not be a token", s)
+		}
 	}
-	if w.match("foobaz") {
-		t.Error("foo*bar should not match foobaz")
+}
+
+func TestHeaderSize(t *testing.T) {
+	h := map[string][]string{"A": {"bc"}, "Def": {"g", "h"}}
+	if n := headerSize(h); n != 7+8+8 {
+		t.Errorf("headerSize() = %d, want 23", n)
 	}
+}
 
-	w = wildcard{"foo", "oof"}
-	if w.match("foof") {
-		t.Error("foo*oof should not match foof")
+func TestHeaderValues(t *testing.T) {
+	h := http.Header{
+		"origin":  {"c"},
+		"Origin":  {"a", "b"},
+		"ORIGIN":  {"d"},
+		"X-Other": {"e"},
+	}
+	for i := 0; i < 20; i++ {
+		if got := headerValues(h, "Origin"); strings.Join(got, ",") != "a,b,d,c" {
+			t.Fatalf("headerValues() = %v, want [a b d c]", got)
+		}
+	}
+	if got := headerValue(http.Header{"oRiGiN": {"x"}}, "Origin"); got != "x" {
+		t.Errorf("headerValue() = %q, want x", got)
+	}
+	if got := headerValue(h, "Missing"); got != "" {
+		t.Errorf("headerValue() = %q, want empty", got)
 	}
 }
 
@@ -31,56 +55,41 @@ func TestConvert(t *testing.T) {
 	}
 }
 
-func TestParseHeaderList(t *testing.T) {
-	h := parseHeaderList("header, second-header, THIRD-HEADER, Numb3r3d-H34d3r, Header_with_underscore Header.with.full.stop")
-	e := []string{"Header", "Second-Header", "Third-Header", "Numb3r3d-H34d3r", "Header_with_underscore", "Header.with.full.stop"}
-	if h[0] != e[0] || h[1] != e[1] || h[2] != e[2] || h[3] != e[3] || h[4] != e[4] || h[5] != e[5] {
-		t.Errorf("%v != %v", h, e)
+func TestSampleRate(t *testing.T) {
+	for _, c := range []struct{ in, want float64 }{{0, 1}, {-1, 0}, {0.25, 0.25}, {2, 2}} {
+		if got := sampleRate(c.in); got != c.want {
+			t.Errorf("sampleRate(%v) = %v, want %v", c.in, got, c.want)
+		}
 	}
 }
 
-func TestParseHeaderListEmpty(t *testing.T) {
-	if len(parseHeaderList("")) != 0 {
-		t.Error("should be empty slice")
-	}
-	if len(parseHeaderList(" , ")) != 0 {
-		t.Error("should be empty slice")
+func TestHashFraction(t *testing.T) {
+	for _, s := range []string{"", "http://foo.com", "https://bar.com:8443"} {
+		if f := hashFraction(s); f < 0 || f >= 1 {
+			t.Errorf("hashFraction(%q) = %v, want value in [0, 1)", s, f)
+		}
 	}
 }
 
-func BenchmarkParseHeaderList(b *testing.B) {
-	b.ReportAllocs()
-	for i := 0; i < b.N; i++ {
-		parseHeaderList("header, second-header, THIRD-HEADER")
+func TestIsSafelistedContentType(t *testing.T) {
+	for _, v := range []string{"text/plain", "Text/Plain; charset=utf-8", "application/x-www-form-urlencoded", "multipart/form-data; boundary=x"} {
+		if !isSafelistedContentType(v) {
+			t.Errorf("isSafelistedContentType(%q) = false, want true", v)
+		}
 	}
-}
-
-func BenchmarkParseHeaderListSingle(b *testing.B) {
-	b.ReportAllocs()
-	for i := 0; i < b.N; i++ {
-		parseHeaderList("header")
+	for _, v := range []string{"application/json", "text/html", "text/plain; charset=" + strings.Repeat("x", 128), "text/plain;;"} {
+		if isSafelistedContentType(v) {
+			t.Errorf("isSafelistedContentType(%q) = true, want false", v)
+		}
 	}
 }
 
-func BenchmarkParseHeaderListNormalized(b *testing.B) {
-	b.ReportAllocs()
-	for i := 0; i < b.N; i++ {
-		parseHeaderList("Header1, Header2, Third-Header")
+func TestSortedSet(t *testing.T) {
+	in := []string{"PUT", "GET", "PUT", "DELETE", "GET"}
+	if got, want := sortedSet(in), []string{"DELETE", "GET", "PUT"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sortedSet() = %v, want %v", got, want)
+	}
+	if in[0] != "PUT" || in[1] != "GET" {
+		t.Errorf("sortedSet modified its input: %v", in)
 	}
-}
-
-func BenchmarkWildcard(b *testing.B) {
-	w := wildcard{"foo", "bar"}
-	b.Run("match", func(b *testing.B) {
-		b.ReportAllocs()
-		for i := 0; i < b.N; i++ {
-			w.match("foobazbar")
-		}
-	})
-	b.Run("too short", func(b *testing.B) {
-		b.ReportAllocs()
-		for i := 0; i < b.N; i++ {
-			w.match("fobar")
-		}
-	})
 }
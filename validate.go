@@ -0,0 +1,177 @@
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/cors/engine"
+)
+
+// Validate checks the options for configurations which can never work as
+// intended, such as origins with a path or "*" combined with credentials.
+func (o Options) Validate() error {
+	for _, origin := range o.AllowedOrigins {
+		if origin == "*" {
+			if o.AllowCredentials || o.AllowCredentialsFunc != nil {
+				return errors.New(`cors: allowed origin "*" cannot be combined with AllowCredentials or AllowCredentialsFunc`)
+			}
+			continue
+		}
+		if strings.EqualFold(origin, "null") {
+			return errors.New(`cors: the "null" origin cannot be listed in AllowedOrigins, use AllowNullOrigin`)
+		}
+		if err := validateOriginPattern(origin); err != nil {
+			return fmt.Errorf("cors: invalid allowed origin %q: %v", origin, err)
+		}
+		if !o.AllowBroadWildcards && isBroadWildcard(origin) {
+			return fmt.Errorf("cors: allowed origin %q matches a whole public suffix, set AllowBroadWildcards if intended", origin)
+		}
+	}
+	for _, origin := range o.DeniedOrigins {
+		if err := validateOriginPattern(origin); err != nil {
+			return fmt.Errorf("cors: invalid denied origin %q: %v", origin, err)
+		}
+	}
+	for _, re := range o.AllowedOriginsRegex {
+		if _, err := engine.NewOriginMatcher(nil, []string{re}, engine.MatchFirst); err != nil {
+			return fmt.Errorf("cors: invalid allowed origin regex %q: %v", re, err)
+		}
+	}
+	for _, method := range o.AllowedMethods {
+		if !isToken(method) {
+			return fmt.Errorf("cors: invalid allowed method %q", method)
+		}
+	}
+	for _, header := range o.AllowedHeaders {
+		if header != "*" && !isToken(header) {
+			return fmt.Errorf("cors: invalid allowed header %q", header)
+		}
+	}
+	for method, headers := range o.MethodHeaderPolicy {
+		if !isToken(method) {
+			return fmt.Errorf("cors: invalid method %q in MethodHeaderPolicy", method)
+		}
+		for _, header := range headers {
+			if header == "*" || !isToken(header) {
+				return fmt.Errorf("cors: invalid allowed header %q for %s", header, method)
+			}
+		}
+	}
+	for _, path := range o.SkipPaths {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("cors: skip path %q does not start with a slash", path)
+		}
+	}
+	for _, header := range o.ExposedHeaders {
+		if !isToken(header) {
+			return fmt.Errorf("cors: invalid exposed header %q", header)
+		}
+	}
+	if o.DenyWithStatus != 0 && (o.DenyWithStatus < 400 || o.DenyWithStatus > 599) {
+		return fmt.Errorf("cors: DenyWithStatus %d is not an error status", o.DenyWithStatus)
+	}
+	return nil
+}
+
+// Warnings lists configurations which are valid but likely to cause surprising
+// failures. They are logged when the options are applied.
+func (o Options) Warnings() []string {
+	var warnings []string
+	if o.AllowCredentials && !o.AutoAllowAuthHeaders && !allowsHeader(o.AllowedHeaders, "Authorization") {
+		warnings = append(warnings, "AllowCredentials is set but Authorization is not an allowed header, "+
+			"authenticated requests will fail their preflight (see AutoAllowAuthHeaders)")
+	}
+	if !o.AllowBroadWildcards {
+		for _, origin := range o.AllowedOrigins {
+			if origin != "*" && validateOriginPattern(origin) == nil && isBroadWildcard(origin) {
+				warnings = append(warnings, fmt.Sprintf("allowed origin %q matches a whole public suffix, "+
+					"anyone can register a domain it allows (see AllowBroadWildcards)", origin))
+			}
+		}
+	}
+	return warnings
+}
+
+// allowsHeader reports whether the AllowedHeaders option lists header or "*"
+func allowsHeader(allowed []string, header string) bool {
+	for _, h := range allowed {
+		if h == "*" || http.CanonicalHeaderKey(h) == header {
+			return true
+		}
+	}
+	return false
+}
+
+// NewWithError creates a new Cors handler with the provided options, or returns an
+// error if they are invalid (see Options.Validate).
+func NewWithError(options Options) (*Cors, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	return New(options), nil
+}
+
+// validateOriginPattern checks an AllowedOrigins entry has the shape of a
+// serialized origin: scheme://host[:port], where host and port may use wildcards.
+func validateOriginPattern(origin string) error {
+	if origin == "" {
+		return errors.New("empty origin")
+	}
+	if strings.Count(origin, "*") > 1 && !(strings.Count(origin, "*") == 2 && strings.HasSuffix(origin, ":*")) {
+		return errors.New("only one wildcard is allowed, plus an optional :* port")
+	}
+	i := strings.Index(origin, "://")
+	if i <= 0 {
+		return errors.New("missing scheme")
+	}
+	host := origin[i+3:]
+	switch {
+	case host == "":
+		return errors.New("missing host")
+	case strings.ContainsAny(host, "/?#"):
+		return errors.New("origins cannot contain a path, query or fragment")
+	case strings.ContainsRune(host, '@'):
+		return errors.New("origins cannot contain user info")
+	}
+	return nil
+}
+
+// publicSuffixes are common multi-label public suffixes, under which anyone can
+// register a domain. The list is not exhaustive: it only catches the usual
+// mistakes, single-label suffixes being detected by their shape.
+var publicSuffixes = map[string]bool{
+	"ac.uk": true, "co.uk": true, "gov.uk": true, "org.uk": true,
+	"com.au": true, "net.au": true, "org.au": true,
+	"co.jp": true, "co.nz": true, "co.in": true, "co.za": true,
+	"com.br": true, "com.cn": true, "com.mx": true, "com.tr": true,
+	"appspot.com": true, "azurewebsites.net": true, "blogspot.com": true,
+	"cloudfront.net": true, "firebaseapp.com": true, "github.io": true,
+	"gitlab.io": true, "herokuapp.com": true, "netlify.app": true,
+	"pages.dev": true, "vercel.app": true, "web.app": true, "workers.dev": true,
+}
+
+// isBroadWildcard reports whether a wildcard origin pattern matches any domain
+// under a public suffix, such as https://*.com, https://*.github.io or https://*.
+// Only web schemes are concerned: the host of custom schemes is an application or
+// extension ID (chrome-extension://*), not a domain.
+func isBroadWildcard(origin string) bool {
+	i := strings.Index(origin, "://")
+	switch strings.ToLower(origin[:i]) {
+	case "http", "https", "ws", "wss":
+	default:
+		return false
+	}
+	host := strings.ToLower(origin[i+3:])
+	host = strings.TrimSuffix(host, ":*")
+	star := strings.IndexByte(host, '*')
+	if star < 0 {
+		return false
+	}
+	suffix := strings.TrimPrefix(engine.TrimPort(host[star+1:]), ".")
+	if suffix == "localhost" {
+		return false
+	}
+	return !strings.Contains(suffix, ".") || publicSuffixes[suffix]
+}
@@ -0,0 +1,94 @@
+package cors
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		options Options
+		valid   bool
+	}{
+		{"Default", Options{}, true},
+		{"Origins", Options{AllowedOrigins: []string{"https://foo.com", "http://*.bar.com", "http://localhost:*", "http://*.baz.com:*", "https://foo.com:8443"}}, true},
+		{"AllOrigins", Options{AllowedOrigins: []string{"*"}}, true},
+		{"AllOriginsWithCredentials", Options{AllowedOrigins: []string{"*"}, AllowCredentials: true}, false},
+		{"AllOriginsWithCredentialsFunc", Options{AllowedOrigins: []string{"*"}, AllowCredentialsFunc: func(*http.Request, string) bool { return true }}, false},
+		{"TrailingSlash", Options{AllowedOrigins: []string{"http://example.com/"}}, false},
+		{"Path", Options{AllowedOrigins: []string{"http://example.com/api"}}, false},
+		{"NoScheme", Options{AllowedOrigins: []string{"example.com"}}, false},
+		{"UserInfo", Options{AllowedOrigins: []string{"http://user@example.com"}}, false},
+		{"NullOrigin", Options{AllowedOrigins: []string{"null"}}, false},
+		{"EmptyOrigin", Options{AllowedOrigins: []string{""}}, false},
+		{"EmptyHost", Options{AllowedOrigins: []string{"http://"}}, false},
+		{"TwoWildcards", Options{AllowedOrigins: []string{"http://*.*.com"}}, false},
+		{"Regex", Options{AllowedOriginsRegex: []string{`https://pr-\d+\.example\.com`}}, true},
+		{"InvalidRegex", Options{AllowedOriginsRegex: []string{"("}}, false},
+		{"Methods", Options{AllowedMethods: []string{"GET", "m-search"}}, true},
+		{"InvalidMethod", Options{AllowedMethods: []string{"GET POST"}}, false},
+		{"WildcardHeader", Options{AllowedHeaders: []string{"*"}}, true},
+		{"InvalidHeader", Options{AllowedHeaders: []string{"X-Foo:"}}, false},
+		{"MethodHeaders", Options{MethodHeaderPolicy: map[string][]string{"delete": {"X-Admin-Token"}}}, true},
+		{"InvalidMethodHeadersMethod", Options{MethodHeaderPolicy: map[string][]string{"GET POST": {"X-Foo"}}}, false},
+		{"WildcardMethodHeader", Options{MethodHeaderPolicy: map[string][]string{"DELETE": {"*"}}}, false},
+		{"SkipPaths", Options{SkipPaths: []string{"/healthz", "/webhooks/"}}, true},
+		{"RelativeSkipPath", Options{SkipPaths: []string{"healthz"}}, false},
+		{"InvalidExposedHeader", Options{ExposedHeaders: []string{""}}, false},
+		{"DenyWithStatus", Options{DenyWithStatus: http.StatusForbidden}, true},
+		{"DenyWithSuccessStatus", Options{DenyWithStatus: http.StatusOK}, false},
+		{"BroadWildcard", Options{AllowedOrigins: []string{"https://*.com"}}, false},
+		{"BroadWildcardAnyHost", Options{AllowedOrigins: []string{"https://*"}}, false},
+		{"BroadWildcardPort", Options{AllowedOrigins: []string{"https://*.io:*"}}, false},
+		{"BroadWildcardPublicSuffix", Options{AllowedOrigins: []string{"https://*.github.io"}}, false},
+		{"BroadWildcardAllowed", Options{AllowedOrigins: []string{"https://*.com"}, AllowBroadWildcards: true}, true},
+		{"AppSchemes", Options{AllowedOrigins: []string{"chrome-extension://*", "moz-extension://*", "capacitor://localhost", "tauri://localhost"}}, true},
+		{"PrefixWildcard", Options{AllowedOrigins: []string{"https://pr-*.example.co.uk", "http://*.localhost:*"}}, true},
+		{"DeniedOrigin", Options{DeniedOrigins: []string{"https://*.example.com"}}, true},
+		{"InvalidDeniedOrigin", Options{DeniedOrigins: []string{"example.com"}}, false},
+	}
+	for _, tc := range cases {
+		err := tc.options.Validate()
+		if valid := err == nil; valid != tc.valid {
+			t.Errorf("%s: Validate() = %v, want valid=%v", tc.name, err, tc.valid)
+		}
+	}
+}
+
+func TestNewWithError(t *testing.T) {
+	if c, err := NewWithError(Options{AllowedOriginsRegex: []string{"("}}); err == nil || c != nil {
+		t.Errorf("NewWithError() = %v, %v, want an error", c, err)
+	}
+	if c, err := NewWithError(Options{AllowedOrigins: []string{"https://foo.com"}}); err != nil || c == nil {
+		t.Errorf("NewWithError() = %v, %v, want a handler", c, err)
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	cases := []struct {
+		name     string
+		options  Options
+		warnings int
+	}{
+		{"NoCredentials", Options{}, 0},
+		{"CredentialsWithoutAuthorization", Options{AllowCredentials: true, AllowedHeaders: []string{"X-Foo"}}, 1},
+		{"CredentialsWithAuthorization", Options{AllowCredentials: true, AllowedHeaders: []string{"authorization"}}, 0},
+		{"CredentialsWithWildcardHeader", Options{AllowCredentials: true, AllowedHeaders: []string{"*"}}, 0},
+		{"AutoAllowAuthHeaders", Options{AllowCredentials: true, AutoAllowAuthHeaders: true}, 0},
+		{"BroadWildcard", Options{AllowedOrigins: []string{"https://*.com", "https://*.github.io", "https://*.foo.com"}}, 2},
+		{"AllowBroadWildcards", Options{AllowedOrigins: []string{"https://*.com"}, AllowBroadWildcards: true}, 0},
+		{"InvalidOrigin", Options{AllowedOrigins: []string{"*.com", "*"}}, 0},
+	}
+	for _, tc := range cases {
+		if w := tc.options.Warnings(); len(w) != tc.warnings {
+			t.Errorf("%s: Warnings() = %q, want %d warnings", tc.name, w, tc.warnings)
+		}
+	}
+	logger := &recordingLevelLogger{}
+	New(Options{AllowedOrigins: []string{"https://*.github.io"}, Logger: logger})
+	if len(logger.entries) != 1 || !strings.Contains(logger.entries[0], `WARN cors: allowed origin "https://*.github.io"`) {
+		t.Errorf("New logged %q, want the broad wildcard warning", logger.entries)
+	}
+}
@@ -0,0 +1,184 @@
+package cors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Default interval at which Watch checks the configuration file for changes
+const defaultWatchInterval = 2 * time.Second
+
+// WatchOption configures Watch
+type WatchOption func(*watchConfig)
+
+type watchConfig struct {
+	ctx      context.Context
+	interval time.Duration
+	decode   func(data []byte, v interface{}) error
+	base     Options
+	onError  func(error)
+	onReload func(Options)
+}
+
+// WithWatchContext stops watching the file when ctx is done, the last loaded
+// policy staying in effect. By default the file is watched for the lifetime of
+// the process.
+func WithWatchContext(ctx context.Context) WatchOption {
+	return func(w *watchConfig) {
+		w.ctx = ctx
+	}
+}
+
+// WithWatchInterval sets how often the file is checked for changes, every 2
+// seconds by default.
+func WithWatchInterval(d time.Duration) WatchOption {
+	return func(w *watchConfig) {
+		w.interval = d
+	}
+}
+
+// WithDecoder sets the function decoding the file, json.Unmarshal by default.
+// yaml.Unmarshal of gopkg.in/yaml.v3 or sigs.k8s.io/yaml can be used for YAML
+// files, which is required for files with a .yaml or .yml extension.
+func WithDecoder(decode func(data []byte, v interface{}) error) WatchOption {
+	return func(w *watchConfig) {
+		w.decode = decode
+	}
+}
+
+// WithBaseOptions sets the options the file is decoded onto, e.g. to set the
+// functions, providers or logger which can't be expressed in a file.
+func WithBaseOptions(options Options) WatchOption {
+	return func(w *watchConfig) {
+		w.base = options
+	}
+}
+
+// WithReloadError sets a function called when the file can't be read, decoded or
+// validated on reload, in which case the previous policy stays in effect.
+func WithReloadError(f func(error)) WatchOption {
+	return func(w *watchConfig) {
+		w.onError = f
+	}
+}
+
+// WithReload sets a function called with the new options after each successful
+// reload.
+func WithReload(f func(Options)) WatchOption {
+	return func(w *watchConfig) {
+		w.onReload = f
+	}
+}
+
+// Watch creates a Cors handler with the options of the JSON (or YAML, see
+// WithDecoder) file at path, and atomically replaces its policy when the file
+// changes or the process receives SIGHUP, so that origins can be added without
+// restarting. The file is polled for changes to stay free of dependencies, which
+// also follows atomic replacements such as Kubernetes ConfigMap updates. An error
+// is returned if the file can't be loaded initially; reload errors are reported
+// to WithReloadError and keep the previous policy. The handler is not reloaded
+// once frozen.
+func Watch(path string, opts ...WatchOption) (*Cors, error) {
+	w := &watchConfig{
+		ctx:      context.Background(),
+		interval: defaultWatchInterval,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.decode == nil {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml":
+			return nil, fmt.Errorf("cors: no decoder for YAML file %s, see WithDecoder", path)
+		}
+		w.decode = json.Unmarshal
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	options, err := w.load(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewWithError(options)
+	if err != nil {
+		return nil, err
+	}
+	go w.watch(c, path, info)
+	return c, nil
+}
+
+// load reads and validates the options of the file at path
+func (w *watchConfig) load(path string) (Options, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Options{}, err
+	}
+	options := w.base
+	if err := w.decode(data, &options); err != nil {
+		return Options{}, fmt.Errorf("cors: decoding %s: %w", path, err)
+	}
+	if err := options.Validate(); err != nil {
+		return Options{}, fmt.Errorf("cors: %s: %w", path, err)
+	}
+	return options, nil
+}
+
+// watch reloads c when the file at path changes or on SIGHUP until the context
+// is done
+func (w *watchConfig) watch(c *Cors, path string, last os.FileInfo) {
+	hup := make(chan os.Signal, 1)
+	if len(reloadSignals) > 0 {
+		signal.Notify(hup, reloadSignals...)
+		defer signal.Stop(hup)
+	}
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-hup:
+		case <-ticker.C:
+			info, err := os.Stat(path)
+			if err != nil {
+				w.reloadError(err)
+				last = nil
+				continue
+			}
+			if last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+				continue
+			}
+			last = info
+		}
+		w.reload(c, path)
+	}
+}
+
+func (w *watchConfig) reload(c *Cors, path string) {
+	options, err := w.load(path)
+	if err == nil {
+		err = c.UpdateOptions(options)
+	}
+	if err != nil {
+		w.reloadError(err)
+		return
+	}
+	if w.onReload != nil {
+		w.onReload(options)
+	}
+}
+
+func (w *watchConfig) reloadError(err error) {
+	if w.onError != nil {
+		w.onError(err)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !js
+// +build !js
+
+package cors
+
+import (
+	"os"
+	"syscall"
+)
+
+// reloadSignals are the signals making Watch reload the configuration file
+var reloadSignals = []os.Signal{syscall.SIGHUP}
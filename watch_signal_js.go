@@ -0,0 +1,6 @@
+package cors
+
+import "os"
+
+// reloadSignals is empty as there are no signals in the browser
+var reloadSignals []os.Signal
@@ -0,0 +1,100 @@
+package cors
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, path, data string, mtime time.Time) {
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func allowsOrigin(c *Cors, origin string) bool {
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("Origin", origin)
+	return c.Check(req).Allowed
+}
+
+func TestWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cors.json")
+	now := time.Now()
+	writeConfig(t, path, `{"allowedOrigins": ["https://foo.com"]}`, now)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 10)
+	reloads := make(chan Options, 10)
+	c, err := Watch(path,
+		WithWatchContext(ctx),
+		WithWatchInterval(5*time.Millisecond),
+		WithReloadError(func(err error) { errs <- err }),
+		WithReload(func(o Options) { reloads <- o }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !allowsOrigin(c, "https://foo.com") || allowsOrigin(c, "https://bar.com") {
+		t.Fatal("initial policy not loaded")
+	}
+
+	writeConfig(t, path, `{"allowedOrigins": ["https://foo.com", "https://bar.com"]}`, now.Add(time.Second))
+	select {
+	case <-reloads:
+	case err := <-errs:
+		t.Fatalf("reload error: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("file change not picked up")
+	}
+	if !allowsOrigin(c, "https://bar.com") {
+		t.Error("reloaded policy not applied")
+	}
+
+	writeConfig(t, path, `{"allowedOrigins": [`, now.Add(2*time.Second))
+	select {
+	case <-errs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("invalid file not reported")
+	}
+	if !allowsOrigin(c, "https://bar.com") {
+		t.Error("previous policy not kept on error")
+	}
+}
+
+func TestWatchError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if _, err := Watch(filepath.Join(dir, "missing.json"), WithWatchContext(ctx)); err == nil {
+		t.Error("missing file: expected an error")
+	}
+	yaml := filepath.Join(dir, "cors.yaml")
+	writeConfig(t, yaml, "allowedOrigins: [https://foo.com]", time.Now())
+	if _, err := Watch(yaml, WithWatchContext(ctx)); err == nil {
+		t.Error("YAML without decoder: expected an error")
+	}
+	invalid := filepath.Join(dir, "cors.json")
+	writeConfig(t, invalid, `{"allowedOrigins": ["https://*.com"]}`, time.Now())
+	if _, err := Watch(invalid, WithWatchContext(ctx)); err == nil {
+		t.Error("invalid options: expected an error")
+	}
+}
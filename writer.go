@@ -0,0 +1,363 @@
+package cors
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/cors/engine"
+)
+
+// Headers which only belong to preflight responses
+var preflightOnlyHeaders = []string{
+	"Access-Control-Allow-Methods",
+	"Access-Control-Allow-Headers",
+	"Access-Control-Allow-Private-Network",
+	"Access-Control-Max-Age",
+}
+
+// Headers which only belong to actual responses
+var actualOnlyHeaders = []string{
+	"Access-Control-Expose-Headers",
+}
+
+// Vary values of actual responses
+var actualVary = []string{"Origin"}
+
+// preflightVary returns the Vary values of preflight responses
+func (p *policy) preflightVary() []string {
+	if p.allowPrivateNetwork {
+		return []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers",
+			"Access-Control-Request-Private-Network"}
+	}
+	return []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}
+}
+
+// addVary adds names to the Vary header, merged with its current values unless
+// Options.DisableVaryMerge is set
+func (p *policy) addVary(h http.Header, names ...string) {
+	if p.mergeVary {
+		mergeVary(h, names...)
+		return
+	}
+	for _, name := range names {
+		h.Add("Vary", name)
+	}
+}
+
+// mergeVary rewrites the Vary header as a single line holding its current values
+// followed by names, without duplicates. "*" absorbs every other value.
+func mergeVary(h http.Header, names ...string) {
+	current := h.Values("Vary")
+	merged := make([]string, 0, len(current)+len(names))
+	add := func(v string) bool {
+		v = strings.TrimSpace(v)
+		if v == "*" {
+			return false
+		}
+		if v == "" {
+			return true
+		}
+		for _, m := range merged {
+			if strings.EqualFold(m, v) {
+				return true
+			}
+		}
+		merged = append(merged, v)
+		return true
+	}
+	for _, line := range current {
+		for _, v := range strings.Split(line, ",") {
+			if !add(v) {
+				h.Set("Vary", "*")
+				return
+			}
+		}
+	}
+	for _, name := range names {
+		if !add(name) {
+			h.Set("Vary", "*")
+			return
+		}
+	}
+	h.Set("Vary", strings.Join(merged, ", "))
+}
+
+// beforeWrite returns the function fixing the headers set by the next handler
+// right before they are written, or nil if there is nothing to fix, so that
+// responses are only wrapped when needed: under OverrideUpstreamHeaders the
+// headers of the middleware, found in h, override the ones of the handler and
+// its Vary values are merged back in case the handler replaced them, and under
+// StrictHeaderPlacement misplaced headers are removed.
+func (p *policy) beforeWrite(h http.Header, misplaced, vary []string) func(http.Header) {
+	if p.overrideUpstream {
+		keepExposed := !containsString(misplaced, "Access-Control-Expose-Headers")
+		override := overrideHeaders(accessControlHeaders(h), keepExposed)
+		return func(h http.Header) {
+			override(h)
+			mergeVary(h, vary...)
+		}
+	}
+	if p.strictPlacement {
+		return removeHeaders(misplaced)
+	}
+	return nil
+}
+
+// accessControlHeaders returns the Access-Control-* headers of h
+func accessControlHeaders(h http.Header) http.Header {
+	own := http.Header{}
+	for name, values := range h {
+		if strings.HasPrefix(name, "Access-Control-") {
+			own[name] = values
+		}
+	}
+	return own
+}
+
+// removeAccessControlHeaders deletes the Access-Control-* headers of h
+func removeAccessControlHeaders(h http.Header) {
+	for name := range h {
+		if strings.HasPrefix(name, "Access-Control-") {
+			delete(h, name)
+		}
+	}
+}
+
+// overrideHeaders returns a function replacing the Access-Control-* headers of a
+// response with own. With keepExposed, exposed headers are merged instead when own
+// allows the request, as handlers may add some with ExposeHeaders.
+func overrideHeaders(own http.Header, keepExposed bool) func(http.Header) {
+	return func(h http.Header) {
+		var exposed []string
+		if keepExposed && own.Get("Access-Control-Allow-Origin") != "" && own.Get("Access-Control-Expose-Headers") != "*" {
+			exposed = h.Values("Access-Control-Expose-Headers")
+		}
+		removeAccessControlHeaders(h)
+		for name, values := range own {
+			h[name] = values
+		}
+		if len(exposed) > 0 {
+			all := engine.ParseHeaderList(strings.Join(append(own.Values("Access-Control-Expose-Headers"), exposed...), ","))
+			h.Set("Access-Control-Expose-Headers", strings.Join(sortedSet(all), ", "))
+		}
+	}
+}
+
+// removeHeaders returns a function deleting names from response headers
+func removeHeaders(names []string) func(http.Header) {
+	return func(h http.Header) {
+		for _, name := range names {
+			h.Del(name)
+		}
+	}
+}
+
+// ExposeHeaders adds headers to the Access-Control-Expose-Headers of a response, so
+// that a handler can expose headers specific to some responses (X-Request-Id,
+// Content-Range...) without widening ExposedHeaders for all of them. It must be
+// called before the response is written, and does nothing unless the middleware
+// allowed the request, in which case Access-Control-Allow-Origin is set.
+func ExposeHeaders(w http.ResponseWriter, headers ...string) {
+	h := w.Header()
+	if h.Get("Access-Control-Allow-Origin") == "" || h.Get("Access-Control-Expose-Headers") == "*" {
+		return
+	}
+	exposed := engine.ParseHeaderList(strings.Join(h.Values("Access-Control-Expose-Headers"), ","))
+	added := false
+	for _, header := range headers {
+		header = http.CanonicalHeaderKey(header)
+		if !containsString(exposed, header) {
+			exposed = append(exposed, header)
+			added = true
+		}
+	}
+	if added {
+		// Set replaces the value slice, which may be shared with other responses
+		h.Set("Access-Control-Expose-Headers", strings.Join(exposed, ", "))
+	}
+}
+
+// beforeWriteWriter is a http.ResponseWriter calling a function on the response
+// headers right before they are written.
+type beforeWriteWriter struct {
+	http.ResponseWriter
+	before      func(http.Header)
+	wroteHeader bool
+}
+
+func newBeforeWriteWriter(w http.ResponseWriter, before func(http.Header)) *beforeWriteWriter {
+	return &beforeWriteWriter{ResponseWriter: w, before: before}
+}
+
+// serveBeforeWrite serves r with next, calling before on the response headers
+// right before they are written, including when next returns without writing
+// anything and the server writes them on its behalf. before may be nil.
+func serveBeforeWrite(next http.Handler, w http.ResponseWriter, r *http.Request, before func(http.Header)) {
+	if before == nil {
+		next.ServeHTTP(w, r)
+		return
+	}
+	bw := newBeforeWriteWriter(w, before)
+	next.ServeHTTP(bw.wrap(), r)
+	bw.writeHeaders()
+}
+
+// writeHeaders calls before unless the headers were already written
+func (w *beforeWriteWriter) writeHeaders() {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.before(w.Header())
+	}
+}
+
+func (w *beforeWriteWriter) WriteHeader(code int) {
+	w.writeHeaders()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *beforeWriteWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the wrapped writer, as expected by http.ResponseController
+func (w *beforeWriteWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// wrap returns w implementing the same optional interfaces among http.Flusher,
+// http.Hijacker, io.ReaderFrom and http.Pusher as the wrapped writer, so that
+// type assertions of handlers behave as without the middleware.
+func (w *beforeWriteWriter) wrap() http.ResponseWriter {
+	f, isFlusher := w.ResponseWriter.(http.Flusher)
+	h, isHijacker := w.ResponseWriter.(http.Hijacker)
+	rf, isReaderFrom := w.ResponseWriter.(io.ReaderFrom)
+	p, isPusher := w.ResponseWriter.(http.Pusher)
+	flusher := flushWriter{w, f}
+	readerFrom := readFromWriter{w, rf}
+	type unwrapper interface {
+		http.ResponseWriter
+		Unwrap() http.ResponseWriter
+	}
+	switch {
+	case isFlusher && isHijacker && isReaderFrom && isPusher:
+		return struct {
+			unwrapper
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+			http.Pusher
+		}{w, flusher, h, readerFrom, p}
+	case isFlusher && isHijacker && isReaderFrom:
+		return struct {
+			unwrapper
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{w, flusher, h, readerFrom}
+	case isFlusher && isHijacker && isPusher:
+		return struct {
+			unwrapper
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{w, flusher, h, p}
+	case isFlusher && isReaderFrom && isPusher:
+		return struct {
+			unwrapper
+			http.Flusher
+			io.ReaderFrom
+			http.Pusher
+		}{w, flusher, readerFrom, p}
+	case isHijacker && isReaderFrom && isPusher:
+		return struct {
+			unwrapper
+			http.Hijacker
+			io.ReaderFrom
+			http.Pusher
+		}{w, h, readerFrom, p}
+	case isFlusher && isHijacker:
+		return struct {
+			unwrapper
+			http.Flusher
+			http.Hijacker
+		}{w, flusher, h}
+	case isFlusher && isReaderFrom:
+		return struct {
+			unwrapper
+			http.Flusher
+			io.ReaderFrom
+		}{w, flusher, readerFrom}
+	case isFlusher && isPusher:
+		return struct {
+			unwrapper
+			http.Flusher
+			http.Pusher
+		}{w, flusher, p}
+	case isHijacker && isReaderFrom:
+		return struct {
+			unwrapper
+			http.Hijacker
+			io.ReaderFrom
+		}{w, h, readerFrom}
+	case isHijacker && isPusher:
+		return struct {
+			unwrapper
+			http.Hijacker
+			http.Pusher
+		}{w, h, p}
+	case isReaderFrom && isPusher:
+		return struct {
+			unwrapper
+			io.ReaderFrom
+			http.Pusher
+		}{w, readerFrom, p}
+	case isFlusher:
+		return struct {
+			unwrapper
+			http.Flusher
+		}{w, flusher}
+	case isHijacker:
+		return struct {
+			unwrapper
+			http.Hijacker
+		}{w, h}
+	case isReaderFrom:
+		return struct {
+			unwrapper
+			io.ReaderFrom
+		}{w, readerFrom}
+	case isPusher:
+		return struct {
+			unwrapper
+			http.Pusher
+		}{w, p}
+	}
+	return w
+}
+
+// flushWriter flushes a beforeWriteWriter, writing its headers first
+type flushWriter struct {
+	w *beforeWriteWriter
+	f http.Flusher
+}
+
+func (fw flushWriter) Flush() {
+	fw.w.writeHeaders()
+	fw.f.Flush()
+}
+
+// readFromWriter copies to a beforeWriteWriter with the ReadFrom of the wrapped
+// writer (e.g. sendfile), writing its headers first
+type readFromWriter struct {
+	w  *beforeWriteWriter
+	rf io.ReaderFrom
+}
+
+func (rw readFromWriter) ReadFrom(r io.Reader) (int64, error) {
+	rw.w.writeHeaders()
+	return rw.rf.ReadFrom(r)
+}
@@ -0,0 +1,241 @@
+package cors
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBeforeWriteWriter(t *testing.T) {
+	calls := 0
+	res := httptest.NewRecorder()
+	bw := newBeforeWriteWriter(res, func(h http.Header) {
+		calls++
+		h.Del("X-Removed")
+	})
+	w := bw.wrap()
+	w.Header().Set("X-Removed", "1")
+	w.Header().Set("X-Kept", "1")
+	w.Write([]byte("foo"))
+	w.WriteHeader(http.StatusTeapot)
+	w.(http.Flusher).Flush()
+	if calls != 1 {
+		t.Errorf("before called %d times, want 1", calls)
+	}
+	if res.Result().Header.Get("X-Removed") != "" || res.Result().Header.Get("X-Kept") == "" {
+		t.Errorf("unexpected written headers %v", res.Result().Header)
+	}
+	if w.(interface{ Unwrap() http.ResponseWriter }).Unwrap() != res {
+		t.Error("Unwrap should return the wrapped writer")
+	}
+}
+
+// readerFromRecorder is a ResponseRecorder implementing io.ReaderFrom like the
+// writers of net/http servers
+type readerFromRecorder struct {
+	*httptest.ResponseRecorder
+	readFrom bool
+}
+
+func (r *readerFromRecorder) ReadFrom(src io.Reader) (int64, error) {
+	r.readFrom = true
+	return io.Copy(r.ResponseRecorder, src)
+}
+
+// plainWriter is a ResponseWriter without any optional interface
+type plainWriter struct {
+	http.ResponseWriter
+}
+
+func TestBeforeWriteWriterInterfaces(t *testing.T) {
+	res := &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+	calls := 0
+	w := newBeforeWriteWriter(res, func(h http.Header) {
+		calls++
+		h.Set("X-Fixed", "1")
+	}).wrap()
+	if _, ok := w.(http.Flusher); !ok {
+		t.Error("http.Flusher not passed through")
+	}
+	rf, ok := w.(io.ReaderFrom)
+	if !ok {
+		t.Fatal("io.ReaderFrom not passed through")
+	}
+	if _, ok := w.(http.Hijacker); ok {
+		t.Error("http.Hijacker advertised without the wrapped writer supporting it")
+	}
+	if _, ok := w.(http.Pusher); ok {
+		t.Error("http.Pusher advertised without the wrapped writer supporting it")
+	}
+	rf.ReadFrom(strings.NewReader("foo"))
+	if !res.readFrom || calls != 1 || res.Header().Get("X-Fixed") == "" || res.Body.String() != "foo" {
+		t.Errorf("ReadFrom: readFrom = %v, calls = %d, headers = %v, body = %q", res.readFrom, calls, res.Header(), res.Body)
+	}
+
+	w = newBeforeWriteWriter(plainWriter{httptest.NewRecorder()}, func(http.Header) {}).wrap()
+	if _, ok := w.(http.Flusher); ok {
+		t.Error("http.Flusher advertised without the wrapped writer supporting it")
+	}
+	if _, ok := w.(io.ReaderFrom); ok {
+		t.Error("io.ReaderFrom advertised without the wrapped writer supporting it")
+	}
+
+	// Responses are only wrapped when headers need fixing
+	var got http.ResponseWriter
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = w })
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "http://foobar.com")
+	New(Options{AllowedOrigins: []string{"http://foobar.com"}}).Handler(handler).ServeHTTP(res, req)
+	if got != http.ResponseWriter(res) {
+		t.Errorf("response writer wrapped by default: %T", got)
+	}
+	New(Options{AllowedOrigins: []string{"http://foobar.com"}, OverrideUpstreamHeaders: true}).Handler(handler).ServeHTTP(res, req)
+	if _, ok := got.(io.ReaderFrom); !ok {
+		t.Errorf("io.ReaderFrom not passed through by the middleware: %T", got)
+	}
+}
+
+func TestExposeHeaders(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"http://foo.com"},
+		ExposedHeaders: []string{"X-Global"},
+	})
+	h := s.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ExposeHeaders(w, "x-request-id", "X-Global", "Content-Range")
+	}))
+
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "http://foo.com")
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	if got := res.Header().Get("Access-Control-Expose-Headers"); got != "X-Global, X-Request-Id, Content-Range" {
+		t.Errorf("Access-Control-Expose-Headers = %q", got)
+	}
+	if got := s.current().exposeHeadersValue[0]; got != "X-Global" {
+		t.Errorf("shared exposed headers value modified: %q", got)
+	}
+
+	// Not allowed
+	req.Header.Set("Origin", "http://bar.com")
+	res = httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	if got := res.Header().Get("Access-Control-Expose-Headers"); got != "" {
+		t.Errorf("Access-Control-Expose-Headers = %q on a denied request", got)
+	}
+}
+
+func TestMergeVary(t *testing.T) {
+	cases := []struct {
+		current []string
+		names   []string
+		want    []string
+	}{
+		{nil, []string{"Origin"}, []string{"Origin"}},
+		{[]string{"Accept-Encoding"}, []string{"Origin"}, []string{"Accept-Encoding, Origin"}},
+		{[]string{"accept-encoding, origin", "Origin"}, []string{"Origin", "Access-Control-Request-Method"},
+			[]string{"accept-encoding, origin, Access-Control-Request-Method"}},
+		{[]string{"Accept, *"}, []string{"Origin"}, []string{"*"}},
+	}
+	for _, tc := range cases {
+		h := http.Header{"Vary": tc.current}
+		mergeVary(h, tc.names...)
+		if got := h["Vary"]; !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("mergeVary(%q, %q) = %q, want %q", tc.current, tc.names, got, tc.want)
+		}
+	}
+}
+
+func TestVaryMerge(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Vary", "Accept-Encoding")
+		w.Header().Add("Vary", "Origin")
+	})
+	cases := []struct {
+		options Options
+		handler http.Handler
+		want    []string
+	}{
+		{Options{}, testHandler, []string{"Accept-Language, Origin"}},
+		{Options{DisableVaryMerge: true}, testHandler, []string{"Accept-Language", "Origin"}},
+		// Vary values of next handlers are only merged when overriding them
+		{Options{}, handler, []string{"Accept-Encoding", "Origin"}},
+		{Options{OverrideUpstreamHeaders: true}, handler, []string{"Accept-Encoding, Origin"}},
+	}
+	for _, tc := range cases {
+		tc.options.AllowedOrigins = []string{"http://foobar.com"}
+		req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+		req.Header.Add("Origin", "http://foobar.com")
+		res := httptest.NewRecorder()
+		res.Header().Set("Vary", "Accept-Language")
+		New(tc.options).Handler(tc.handler).ServeHTTP(res, req)
+		if got := res.Header()["Vary"]; !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%+v: Vary = %q, want %q", tc.options, got, tc.want)
+		}
+	}
+
+	s := New(Options{AllowedOrigins: []string{"http://foobar.com"}})
+	req, _ := http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+	req.Header.Add("Origin", "http://foobar.com")
+	req.Header.Add("Access-Control-Request-Method", "GET")
+	res := httptest.NewRecorder()
+	res.Header().Set("Vary", "Accept-Encoding")
+	s.Handler(testHandler).ServeHTTP(res, req)
+	want := []string{"Accept-Encoding, Origin, Access-Control-Request-Method, Access-Control-Request-Headers"}
+	if got := res.Header()["Vary"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("preflight Vary = %q, want %q", got, want)
+	}
+}
+
+func TestVaryOriginWithoutOrigin(t *testing.T) {
+	// A handler replacing Vary must not strip Vary: Origin from same-origin
+	// responses when the middleware overrides upstream headers
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Vary", "Accept-Encoding")
+		w.Write([]byte("bar"))
+	})
+	s := New(Options{AllowedOrigins: []string{"http://foobar.com"}, OverrideUpstreamHeaders: true})
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	res := httptest.NewRecorder()
+	s.Handler(handler).ServeHTTP(res, req)
+	if got, want := res.Header()["Vary"], []string{"Accept-Encoding, Origin"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Vary = %q, want %q", got, want)
+	}
+}
+
+func TestOverrideUpstreamHeaders(t *testing.T) {
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Upstream")
+		w.Header().Add("Vary", "Origin")
+		w.Header().Add("Vary", "Accept-Encoding")
+		w.Write([]byte("bar"))
+	})
+	s := New(Options{
+		AllowedOrigins:          []string{"http://foobar.com"},
+		ExposedHeaders:          []string{"X-Request-Id"},
+		OverrideUpstreamHeaders: true,
+		DisableVaryMerge:        true,
+	})
+	handler := s.Handler(upstream)
+	serve := func(origin string) http.Header {
+		req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+		req.Header.Add("Origin", origin)
+		res := httptest.NewRecorder()
+		res.Header().Set("Access-Control-Max-Age", "600")
+		handler.ServeHTTP(res, req)
+		return res.Header()
+	}
+
+	assertHeaders(t, serve("http://foobar.com"), map[string]string{
+		"Vary":                          "Origin, Accept-Encoding",
+		"Access-Control-Allow-Origin":   "http://foobar.com",
+		"Access-Control-Expose-Headers": "X-Request-Id, X-Upstream",
+	})
+	assertHeaders(t, serve("http://evil.com"), map[string]string{
+		"Vary": "Origin, Accept-Encoding",
+	})
+}